@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
 	"goat/app/middleware"
 	"net/http"
@@ -8,28 +9,82 @@ import (
 	"github.com/go-chi/chi/v5"
 	chiMiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/uptrace/bun"
 
 	"goat/app/models"
 	"goat/services/config"
+	"goat/services/migrate"
+	model "goat/services/models"
+	"goat/services/notify"
+	"goat/services/search"
 )
 
 func SetupServer() {
 	fmt.Printf("Server starting on :8420\n")
 	d := config.ConnectDB()
+	if config.AutoMigrate() {
+		applied, err := migrate.Up(context.Background(), d, 0)
+		if err != nil {
+			panic(err)
+		}
+		for _, m := range applied {
+			fmt.Printf("Applied migration %04d_%s\n", m.Version, m.Name)
+		}
+	}
 	r := chi.NewRouter()
 	r.Use(chiMiddleware.Logger)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match"},
+		ExposedHeaders:   []string{"Link", "ETag", "X-Total-Count"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
-	userHandler := models.NewUserHandler(d)
-	ticketHandler := models.NewTicketHandler(d)
-	commentHandler := models.NewCommentHandler(d)
+	r.Use(middleware.TenantMiddleware(d))
+
+	repos, err := repositories(d)
+	if err != nil {
+		panic(err)
+	}
+	userHandler := models.NewUserHandler(d, repos)
+	ticketHandler := models.NewTicketHandler(d, repos)
+	commentHandler := models.NewCommentHandler(d, repos, notify.LogNotifier{}, config.CommentEditWindow())
+	searchHandler := models.NewSearchHandler(d, repos, search.NewSearcher(d))
+	teamHandler := models.NewTeamHandler(d)
+	companyHandler := models.NewCompanyHandler(d, repos)
+	viewHandler := models.NewViewHandler(d)
+	tagHandler := models.NewTagHandler(d)
+	categoryHandler := models.NewCategoryHandler(d)
+	fieldHandler := models.NewFieldHandler(d)
+	linkHandler := models.NewLinkHandler(d)
+	mergeHandler := models.NewMergeHandler(d, repos, notify.LogNotifier{})
+	participantHandler := models.NewParticipantHandler(d, repos)
+	macroHandler := models.NewMacroHandler(d, repos, commentHandler)
+	triggerHandler := models.NewTriggerHandler(d)
+
+	publicKey, err := config.AuditPublicKey()
+	if err != nil {
+		panic(err)
+	}
+	signingKey, err := config.AuditSigningKey()
+	if err != nil {
+		panic(err)
+	}
+	if signingKey != nil {
+		go model.RunAuditCheckpoints(context.Background(), d, signingKey, config.AuditCheckpointInterval())
+	} else {
+		fmt.Printf("AUDIT_SIGNING_KEY is not set, audit log checkpoints are disabled\n")
+	}
+	auditHandler := models.NewAuditHandler(d, publicKey)
+	trashHandler := models.NewTrashHandler(d, repos)
+	organizationHandler := models.NewOrganizationHandler(d, repos)
+	go model.RunPurgeDeleted(context.Background(), d, repos.Tickets, config.DeletedRetention())
+
+	// Routes that reach past the repositories into the SQL database are only available when the
+	// tickets, users and comments are stored there.
+	sqlRows := middleware.SQLRowsMiddleware(repos.SQL)
 
 	r.Route("/admin", func(r chi.Router) {
 		r.Use(middleware.AuthMiddleware)
@@ -44,9 +99,81 @@ func SetupServer() {
 		r.Post("/tickets", ticketHandler.CreateTicket)
 		r.Get("/tickets/{id}", ticketHandler.GetTicket)
 		r.Put("/tickets/{id}", ticketHandler.UpdateTicket)
+		r.Delete("/tickets/{id}", ticketHandler.DeleteTicket)
 		r.Get("/comments", commentHandler.ListComments)
 		r.Post("/comments", commentHandler.CreateComment)
 		r.Get("/comments/ticket/{id}", commentHandler.ListCommentsByTicketID)
+		r.Put("/comments/{id}", commentHandler.EditComment)
+		r.Delete("/comments/{id}", commentHandler.DeleteComment)
+		r.Get("/companies", companyHandler.ListCompanies)
+		r.Post("/companies", companyHandler.CreateCompany)
+		r.Get("/companies/{id}", companyHandler.GetCompany)
+		r.Put("/companies/{id}", companyHandler.UpdateCompany)
+		r.Post("/companies/{id}/members", companyHandler.AddCompanyMember)
+		r.Delete("/companies/{id}/members/{userID}", companyHandler.RemoveCompanyMember)
+		r.Post("/tags", tagHandler.CreateTag)
+		r.Delete("/tags/{id}", tagHandler.DeleteTag)
+		r.Get("/categories", categoryHandler.ListCategories)
+		r.Post("/categories", categoryHandler.CreateCategory)
+		r.Delete("/categories/{id}", categoryHandler.DeleteCategory)
+		r.Get("/fields", fieldHandler.ListFields)
+		r.Post("/fields", fieldHandler.CreateField)
+		r.Put("/fields/{id}", fieldHandler.UpdateField)
+		r.Delete("/fields/{id}", fieldHandler.DeleteField)
+		r.Get("/audit", auditHandler.ListAuditEntries)
+		r.Get("/audit/verify", auditHandler.VerifyAuditLog)
+
+		r.Group(func(r chi.Router) {
+			r.Use(sqlRows)
+			r.Get("/tickets/search", searchHandler.SearchTickets)
+			r.Post("/tickets/{id}/links", linkHandler.CreateTicketLink)
+			r.Delete("/tickets/{id}/links/{linkID}", linkHandler.DeleteTicketLink)
+			r.Post("/tickets/{id}/watchers", participantHandler.AddTicketWatcher)
+			r.Delete("/tickets/{id}/watchers/{userID}", participantHandler.RemoveTicketWatcher)
+			r.Post("/tickets/{id}/ccs", participantHandler.AddTicketCC)
+			r.Delete("/tickets/{id}/ccs/{ccID}", participantHandler.RemoveTicketCC)
+			r.Post("/tickets/{id}/merge", mergeHandler.MergeTickets)
+			r.Post("/tickets/{id}/split", mergeHandler.SplitTicket)
+			r.Get("/tickets/{id}/history", auditHandler.GetTicketHistory)
+			r.Get("/comments/{id}/revisions", commentHandler.ListCommentRevisions)
+			r.Get("/teams", teamHandler.ListTeams)
+			r.Post("/teams", teamHandler.CreateTeam)
+			r.Get("/teams/{id}", teamHandler.GetTeam)
+			r.Delete("/teams/{id}", teamHandler.DeleteTeam)
+			r.Post("/teams/{id}/members", teamHandler.AddTeamMember)
+			r.Delete("/teams/{id}/members/{userID}", teamHandler.RemoveTeamMember)
+			r.Get("/companies/report", companyHandler.CompanyReport)
+			r.Delete("/companies/{id}", companyHandler.DeleteCompany)
+			r.Get("/companies/{id}/members", companyHandler.ListCompanyMembers)
+			r.Get("/companies/{id}/pending", companyHandler.ListPendingCompanyMembers)
+			r.Get("/companies/{id}/tickets", companyHandler.ListCompanyTickets)
+			r.Get("/tags", tagHandler.ListTagStats)
+			r.Get("/triggers", triggerHandler.ListTriggers)
+			r.Post("/triggers", triggerHandler.CreateTrigger)
+			r.Post("/triggers/dry-run", triggerHandler.DryRunTriggers)
+			r.Get("/triggers/runs", triggerHandler.ListTriggerRuns)
+			r.Get("/triggers/{id}", triggerHandler.GetTrigger)
+			r.Put("/triggers/{id}", triggerHandler.UpdateTrigger)
+			r.Delete("/triggers/{id}", triggerHandler.DeleteTrigger)
+			r.Get("/trash/users", trashHandler.ListDeletedUsers)
+			r.Post("/trash/users/{id}/restore", trashHandler.RestoreUser)
+			r.Get("/trash/tickets", trashHandler.ListDeletedTickets)
+			r.Post("/trash/tickets/{id}/restore", trashHandler.RestoreTicket)
+			r.Get("/trash/comments", trashHandler.ListDeletedComments)
+			r.Post("/trash/comments/{id}/restore", trashHandler.RestoreComment)
+		})
+	})
+
+	r.Route("/superadmin", func(r chi.Router) {
+		r.Use(middleware.AuthMiddleware)
+		r.Use(middleware.RoleMiddleware("SuperAdmin"))
+		r.Use(middleware.AllTenantsMiddleware)
+		r.Get("/organizations", organizationHandler.ListOrganizations)
+		r.Post("/organizations", organizationHandler.CreateOrganization)
+		r.Get("/organizations/{id}", organizationHandler.GetOrganization)
+		r.Put("/organizations/{id}", organizationHandler.UpdateOrganization)
+		r.Get("/organizations/{id}/users", organizationHandler.ListOrganizationUsers)
+		r.Post("/organizations/{id}/users", organizationHandler.CreateOrganizationUser)
 	})
 
 	r.Post("/login", userHandler.Login)
@@ -62,6 +189,43 @@ func SetupServer() {
 		r.Get("/tickets/{id}", ticketHandler.GetAgentTicket)
 		r.Put("/tickets/{id}", ticketHandler.UpdateAgentTicket)
 		r.Post("/tickets/{id}/comments", commentHandler.CreateAgentComment)
+		r.Put("/comments/{id}", commentHandler.EditComment)
+		r.Delete("/comments/{id}", commentHandler.DeleteComment)
+		r.Get("/tags", tagHandler.SuggestTags)
+		r.Get("/categories", categoryHandler.ListCategories)
+		r.Get("/fields", fieldHandler.ListFields)
+		r.Get("/companies", companyHandler.ListCompanies)
+		r.Get("/companies/{id}", companyHandler.GetCompany)
+
+		r.Group(func(r chi.Router) {
+			r.Use(sqlRows)
+			r.Get("/tickets/search", searchHandler.SearchTickets)
+			r.Post("/tickets/tags", tagHandler.BulkUpdateTicketTags)
+			r.Post("/tickets/{id}/tags", tagHandler.UpdateTicketTags)
+			r.Post("/tickets/{id}/links", linkHandler.CreateTicketLink)
+			r.Delete("/tickets/{id}/links/{linkID}", linkHandler.DeleteTicketLink)
+			r.Post("/tickets/{id}/watchers", participantHandler.AddTicketWatcher)
+			r.Delete("/tickets/{id}/watchers/{userID}", participantHandler.RemoveTicketWatcher)
+			r.Post("/tickets/{id}/ccs", participantHandler.AddTicketCC)
+			r.Delete("/tickets/{id}/ccs/{ccID}", participantHandler.RemoveTicketCC)
+			r.Post("/tickets/{id}/merge", mergeHandler.MergeTickets)
+			r.Post("/tickets/{id}/split", mergeHandler.SplitTicket)
+			r.Get("/tickets/{id}/history", auditHandler.GetTicketHistory)
+			r.Post("/tickets/{id}/macros/{macroID}", macroHandler.ApplyMacro)
+			r.Get("/mentions", commentHandler.ListMentions)
+			r.Get("/views", viewHandler.ListViews)
+			r.Post("/views", viewHandler.CreateView)
+			r.Get("/views/{id}/tickets", viewHandler.ListViewTickets)
+			r.Delete("/views/{id}", viewHandler.DeleteView)
+			r.Get("/macros", macroHandler.ListMacros)
+			r.Post("/macros", macroHandler.CreateMacro)
+			r.Put("/macros/{id}", macroHandler.UpdateMacro)
+			r.Delete("/macros/{id}", macroHandler.DeleteMacro)
+			r.Get("/companies/report", companyHandler.CompanyReport)
+			r.Get("/companies/{id}/members", companyHandler.ListCompanyMembers)
+			r.Get("/companies/{id}/pending", companyHandler.ListPendingCompanyMembers)
+			r.Get("/companies/{id}/tickets", companyHandler.ListCompanyTickets)
+		})
 	})
 
 	r.Route("/customer", func(r chi.Router) {
@@ -72,6 +236,17 @@ func SetupServer() {
 		r.Get("/tickets/{id}", ticketHandler.GetCustomerTicket)
 		r.Post("/tickets/{id}/comments", commentHandler.CreateCustomerComment)
 		r.Put("/tickets/{id}", ticketHandler.CloseCustomerTicket)
+		r.Put("/comments/{id}", commentHandler.EditComment)
+		r.Delete("/comments/{id}", commentHandler.DeleteComment)
+
+		r.Group(func(r chi.Router) {
+			r.Use(sqlRows)
+			r.Get("/tickets/search", searchHandler.SearchCustomerTickets)
+			r.Post("/tickets/{id}/ccs", participantHandler.AddCustomerTicketCC)
+			r.Delete("/tickets/{id}/cc", participantHandler.LeaveCustomerTicket)
+			r.Post("/ccs/claim", participantHandler.ClaimTicketCC)
+			r.Get("/mentions", commentHandler.ListMentions)
+		})
 	})
 
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
@@ -80,3 +255,31 @@ func SetupServer() {
 
 	http.ListenAndServe(":8420", r)
 }
+
+// repositories returns the ticket, user and comment stores: the SQL database, optionally paired
+// with or replaced by the document database when MONGO_URI is set.
+func repositories(d *bun.DB) (model.Repositories, error) {
+	repos := model.NewBunRepositories(d)
+	docDB, err := config.ConnectDocumentDB()
+	if docDB == nil || err != nil {
+		return repos, err
+	}
+	store, err := model.NewDocumentStore(context.Background(), docDB)
+	if err != nil {
+		return repos, err
+	}
+	mode, err := config.DocumentMode()
+	if err != nil {
+		return repos, err
+	}
+	if mode == "document" {
+		fmt.Printf("Storing tickets, users and comments in the document database\n")
+		return store.Repositories(), nil
+	}
+	readPercent, err := config.DocumentReadPercent()
+	if err != nil {
+		return repos, err
+	}
+	fmt.Printf("Writing tickets, users and comments to both databases, reading %d%% from the document database\n", readPercent)
+	return model.NewDualWriteRepositories(repos, store, readPercent), nil
+}
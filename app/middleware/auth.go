@@ -10,6 +10,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 
 	"goat/app/renderer"
+	"goat/services/models"
 )
 
 type contextKey string
@@ -17,6 +18,15 @@ type contextKey string
 const UserIDKey contextKey = "userID"
 const UserRoleKey contextKey = "userRole"
 
+// HostTenantKey holds the ID of the organization the request's hostname belongs to, if any.
+const HostTenantKey contextKey = "hostTenant"
+
+// Claims are the claims of the tokens issued by Login. The user's role is the audience.
+type Claims struct {
+	jwt.RegisteredClaims
+	TenantID int64 `json:"tid,omitempty"` // Organization of the user; tokens without one are for the default organization
+}
+
 func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
@@ -38,7 +48,7 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			jwtKey = []byte("default-secret-key")
 		}
 
-		claims := &jwt.RegisteredClaims{}
+		claims := &Claims{}
 		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 			return jwtKey, nil
 		})
@@ -49,8 +59,19 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		tenantID := claims.TenantID
+		if tenantID == 0 {
+			tenantID = models.DefaultTenantID
+		}
+		if hostTenant, ok := r.Context().Value(HostTenantKey).(int64); ok && hostTenant != tenantID {
+			render.Status(r, http.StatusForbidden)
+			renderer.PrettyJSON(w, r, "Token is not valid for this organization")
+			return
+		}
+
 		ctx := context.WithValue(r.Context(), UserIDKey, claims.Subject)
 		ctx = context.WithValue(ctx, UserRoleKey, claims.Audience[0])
+		ctx = models.WithTenant(ctx, tenantID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+
+	"goat/app/renderer"
+)
+
+// SQLRowsMiddleware guards routes that work on tickets, users or comments in the SQL database
+// directly rather than through the repositories, such as merges, tags and the trash. db is the
+// database the repositories store them in, nil while they are kept in the document database, and
+// the routes then answer 501 Not Implemented.
+func SQLRowsMiddleware(db *bun.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if db != nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			render.Status(r, http.StatusNotImplemented)
+			renderer.PrettyJSON(w, r, "Not available while tickets are stored in the document database")
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"goat/app/middleware"
+	"goat/services/config"
+)
+
+func TestSQLRowsMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_PATH", ":memory:")
+	db := config.ConnectDB()
+	t.Cleanup(func() { db.Close() })
+
+	w := httptest.NewRecorder()
+	middleware.SQLRowsMiddleware(db)(next).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tickets/search", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("with the SQL database got %d, want %d", w.Code, http.StatusNoContent)
+	}
+
+	w = httptest.NewRecorder()
+	middleware.SQLRowsMiddleware(nil)(next).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tickets/search", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("without the SQL database got %d, want %d", w.Code, http.StatusNotImplemented)
+	}
+}
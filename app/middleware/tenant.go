@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+
+	"goat/app/renderer"
+	"goat/services/models"
+)
+
+// TenantMiddleware resolves the organization a request is for from its hostname. Requests on a
+// hostname of no organization are for the default organization until AuthMiddleware applies the
+// one named in the token.
+func TenantMiddleware(db *bun.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+
+			ctx := models.WithTenant(r.Context(), models.DefaultTenantID)
+			org, err := models.GetOrganizationByHost(db, r.Context(), strings.ToLower(host))
+			switch {
+			case err == nil:
+				ctx = models.WithTenant(context.WithValue(ctx, HostTenantKey, org.ID), org.ID)
+			case err != sql.ErrNoRows:
+				render.Status(r, http.StatusInternalServerError)
+				renderer.PrettyJSON(w, r, err.Error())
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// AllTenantsMiddleware lets the super-admin scope see every organization. It must run after
+// AuthMiddleware and RoleMiddleware("SuperAdmin").
+func AllTenantsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(models.WithAllTenants(r.Context())))
+	})
+}
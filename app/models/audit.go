@@ -0,0 +1,150 @@
+package models
+
+import (
+	"crypto/ed25519"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+
+	"goat/app/middleware"
+	"goat/app/renderer"
+	"goat/services/models"
+)
+
+type AuditHandler struct {
+	db        *bun.DB
+	publicKey ed25519.PublicKey
+}
+
+// NewAuditHandler creates the audit handler. publicKey verifies checkpoint signatures and may be nil.
+func NewAuditHandler(db *bun.DB, publicKey ed25519.PublicKey) *AuditHandler {
+	return &AuditHandler{db: db, publicKey: publicKey}
+}
+
+// ListAuditEntries handles the request to query the audit log.
+// Filters: actor_id, action, entity_type, entity_id, since and until (dates or RFC 3339 times).
+func (h *AuditHandler) ListAuditEntries(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter := models.AuditFilter{
+		Action:     params.Get("action"),
+		EntityType: params.Get("entity_type"),
+	}
+
+	for name, dst := range map[string]*int64{"actor_id": &filter.ActorID, "entity_id": &filter.EntityID} {
+		if v := params.Get(name); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				render.Status(r, http.StatusBadRequest)
+				renderer.PrettyJSON(w, r, "Invalid "+name)
+				return
+			}
+			*dst = id
+		}
+	}
+
+	var err error
+	if filter.Since, err = parseDate(params.Get("since")); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Invalid since: "+err.Error())
+		return
+	}
+	if filter.Until, err = parseDate(params.Get("until")); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Invalid until: "+err.Error())
+		return
+	}
+
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := models.ListAuditEntries(h.db, r.Context(), filter, page)
+	if err != nil {
+		renderListError(w, r, err)
+		return
+	}
+
+	renderPage(w, r, entries)
+}
+
+// VerifyAuditLog handles the request to check the audit log's hash chain and checkpoints.
+// A broken chain is reported in the response body with the first entry that fails.
+func (h *AuditHandler) VerifyAuditLog(w http.ResponseWriter, r *http.Request) {
+	result, err := models.VerifyAuditChain(h.db, r.Context(), h.publicKey)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, result)
+}
+
+// GetTicketHistory handles the request to show a ticket's field changes interleaved with its comments.
+func (h *AuditHandler) GetTicketHistory(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "id", "ticket")
+	if !ok {
+		return
+	}
+
+	if _, err := models.GetTicketByID(h.db, r.Context(), id); err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "Ticket not found")
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	events, err := models.GetTicketHistory(h.db, r.Context(), id, true)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, events)
+}
+
+// recordAudit appends a change made by the current request to the audit log. before and after are
+// snapshots of the entity, either of which may be nil for creations and deletions. Failures are
+// logged rather than failing the request, which has already been applied. Handlers built without a
+// database, as over the in-memory repositories in tests, record nothing.
+func recordAudit(db bun.IDB, r *http.Request, action, entityType string, entityID int64, before, after any) {
+	if bunDB, ok := db.(*bun.DB); db == nil || ok && bunDB == nil {
+		return
+	}
+	if err := models.RecordChange(db, r.Context(), auditOrigin(r), action, entityType, entityID, before, after); err != nil {
+		fmt.Printf("Error recording audit entry for %s %d: %v\n", entityType, entityID, err)
+	}
+}
+
+// auditOrigin identifies the client and user behind the current request in audit entries.
+func auditOrigin(r *http.Request) models.AuditOrigin {
+	origin := models.AuditOrigin{IP: clientIP(r), UserAgent: r.UserAgent()}
+	if userID, ok := r.Context().Value(middleware.UserIDKey).(string); ok {
+		if id, err := strconv.ParseInt(userID, 10, 64); err == nil {
+			origin.ActorID = sql.NullInt64{Int64: id, Valid: true}
+		}
+	}
+	return origin
+}
+
+// clientIP returns the address of the client that sent the request.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
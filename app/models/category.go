@@ -0,0 +1,109 @@
+package models
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+
+	"goat/app/renderer"
+	"goat/services/models"
+)
+
+type CategoryHandler struct {
+	db *bun.DB
+}
+
+func NewCategoryHandler(db *bun.DB) *CategoryHandler {
+	return &CategoryHandler{db: db}
+}
+
+// ListCategories handles the request to list the category tree.
+func (h *CategoryHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := models.ListCategoryTree(h.db, r.Context())
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, categories)
+}
+
+// CreateCategory handles the request to add a category, optionally below a parent category.
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name     string `json:"name"`
+		ParentID *int64 `json:"parent_id"`
+	}
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	if req.Name == "" {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Category name is required")
+		return
+	}
+
+	category := models.Category{Name: req.Name}
+	if req.ParentID != nil {
+		if _, err := models.GetCategoryByID(h.db, r.Context(), *req.ParentID); err != nil {
+			if err == sql.ErrNoRows {
+				render.Status(r, http.StatusNotFound)
+				renderer.PrettyJSON(w, r, "Parent category not found")
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			renderer.PrettyJSON(w, r, err.Error())
+			return
+		}
+		category.ParentID = sql.NullInt64{Int64: *req.ParentID, Valid: true}
+	}
+
+	if err := models.CreateCategory(h.db, r.Context(), &category); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditCreate, "category", category.ID, nil, category)
+
+	render.Status(r, http.StatusCreated)
+	renderer.PrettyJSON(w, r, category)
+}
+
+// DeleteCategory handles the request to delete a category without subcategories.
+func (h *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "id", "category")
+	if !ok {
+		return
+	}
+
+	existing, err := models.GetCategoryByID(h.db, r.Context(), id)
+	if err != nil && err != sql.ErrNoRows {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	if err := models.DeleteCategory(h.db, r.Context(), id); err != nil {
+		if err == models.ErrCategoryHasChildren {
+			render.Status(r, http.StatusConflict)
+			renderer.PrettyJSON(w, r, "Delete or move the subcategories first")
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if existing != nil {
+		recordAudit(h.db, r, models.AuditDelete, "category", id, existing, nil)
+	}
+
+	render.Status(r, http.StatusAccepted)
+	renderer.PrettyJSON(w, r, map[string]string{"message": "Category deleted successfully"})
+}
@@ -1,11 +1,13 @@
 package models
 
 import (
-	"context"
 	"database/sql"
+	"fmt"
 	"goat/app/middleware"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
@@ -13,14 +15,24 @@ import (
 
 	"goat/app/renderer"
 	"goat/services/models"
+	"goat/services/notify"
 )
 
 type CommentHandler struct {
-	db *bun.DB
+	db       *bun.DB // Holds the companies and audit log
+	tickets  models.TicketRepository
+	users    models.UserRepository
+	comments models.CommentRepository
+	notifier notify.Notifier
+	// editWindow is how long after posting authors may edit or delete their comments
+	editWindow time.Duration
+	// related holds the revisions and mentions of comments and the trigger runs of their tickets,
+	// nil unless comments are stored in the SQL database
+	related *bun.DB
 }
 
-func NewCommentHandler(db *bun.DB) *CommentHandler {
-	return &CommentHandler{db: db}
+func NewCommentHandler(db *bun.DB, repos models.Repositories, notifier notify.Notifier, editWindow time.Duration) *CommentHandler {
+	return &CommentHandler{db: db, tickets: repos.Tickets, users: repos.Users, comments: repos.Comments, notifier: notifier, editWindow: editWindow, related: repos.SQL}
 }
 
 // ListComments handles the request to list all comments.
@@ -35,34 +47,26 @@ func (h *CommentHandler) ListComments(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	comments, err := models.ListComments(h.db, ctx)
-	if err != nil {
-		render.Status(r, http.StatusInternalServerError)
-		renderer.PrettyJSON(w, r, err.Error())
+	page, ok := parsePageRequest(w, r)
+	if !ok {
 		return
 	}
 
 	// Filter comments based on user role
-	if userRole == "Customer" {
-		filteredComments := []models.Comment{}
-		for _, comment := range comments {
-			if !comment.IsInternal {
-				filteredComments = append(filteredComments, comment)
-			}
-		}
-		render.Status(r, http.StatusOK)
-		renderer.PrettyJSON(w, r, filteredComments)
+	filter := models.CommentFilter{PublicOnly: userRole == "Customer"}
+
+	comments, err := h.comments.List(ctx, page, filter)
+	if err != nil {
+		renderListError(w, r, err)
 		return
 	}
 
-	render.Status(r, http.StatusOK)
-	renderer.PrettyJSON(w, r, comments)
-
+	renderPage(w, r, comments)
 }
 
 // CreateComment handles the request to create a new comment.
 func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 
 	var req struct {
 		TicketID   int64  `json:"TicketID"`
@@ -84,7 +88,7 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if the author exists
-	author, err := models.GetUserByID(h.db, ctx, req.AuthorID)
+	author, err := h.users.GetByID(ctx, req.AuthorID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			render.Status(r, http.StatusNotFound)
@@ -98,7 +102,7 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 	comment.AuthorID = author.ID
 
 	// Check if the ticket exists
-	_, err = models.GetTicketByID(h.db, ctx, req.TicketID)
+	ticket, err := h.tickets.GetByID(ctx, req.TicketID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			render.Status(r, http.StatusNotFound)
@@ -110,16 +114,123 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := models.CreateComment(h.db, ctx, &comment); err != nil {
+	if err := h.comments.Create(ctx, &comment); err != nil {
 		render.Status(r, http.StatusInternalServerError)
 		renderer.PrettyJSON(w, r, err.Error())
 		return
 	}
+	h.commentCreated(r, ticket, &comment)
 
 	render.Status(r, http.StatusCreated)
 	renderer.PrettyJSON(w, r, comment)
 }
 
+// EditComment handles the request to change a comment's body, under the same rules as deleting it.
+// The replaced text is kept as a revision and the comment is marked as edited.
+func (h *CommentHandler) EditComment(w http.ResponseWriter, r *http.Request) {
+	existingComment, actorID, ok := h.loadOwnComment(w, r, "edit")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Body string `json:"body"`
+	}
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "body is required")
+		return
+	}
+	if req.Body == existingComment.Body {
+		render.Status(r, http.StatusOK)
+		renderer.PrettyJSON(w, r, existingComment)
+		return
+	}
+
+	if h.related != nil {
+		revision := models.CommentRevision{CommentID: existingComment.ID, Body: existingComment.Body, EditorID: actorID}
+		if err := models.CreateCommentRevision(h.related, r.Context(), &revision); err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			renderer.PrettyJSON(w, r, err.Error())
+			return
+		}
+	}
+
+	comment := *existingComment
+	editedAt := time.Now().UTC().Truncate(time.Second)
+	comment.Body, comment.EditedAt = req.Body, &editedAt
+	if err := h.comments.Update(r.Context(), &comment); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditUpdate, "comment", comment.ID, existingComment, comment)
+
+	if ticket, err := h.tickets.GetByID(r.Context(), comment.TicketID); err == nil {
+		h.recordMentions(r, ticket, &comment)
+	}
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, comment)
+}
+
+// ListCommentRevisions handles the request to show a comment with the earlier versions of its
+// body, oldest first.
+func (h *CommentHandler) ListCommentRevisions(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "id", "comment")
+	if !ok {
+		return
+	}
+
+	comment, err := h.comments.GetByID(r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "Comment not found")
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	revisions, err := models.ListCommentRevisions(h.related, r.Context(), id)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, map[string]any{"Comment": comment, "Revisions": revisions})
+}
+
+// DeleteComment handles the request to move a comment to the trash. Admins may delete any comment,
+// other users their own while the edit window is open.
+func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	existingComment, _, ok := h.loadOwnComment(w, r, "delete")
+	if !ok {
+		return
+	}
+	id := existingComment.ID
+
+	if err := h.comments.Delete(r.Context(), id); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditDelete, "comment", id, existingComment, nil)
+
+	render.Status(r, http.StatusAccepted)
+	renderer.PrettyJSON(w, r, map[string]string{"message": "Comment deleted successfully"})
+}
+
 // ListCommentsByTicketID handles the request to list comments for a specific ticket.
 func (h *CommentHandler) ListCommentsByTicketID(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -138,28 +249,24 @@ func (h *CommentHandler) ListCommentsByTicketID(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	comments, err := models.ListCommentsByTicketID(h.db, ctx, id)
-	if err != nil {
-		render.Status(r, http.StatusInternalServerError)
-		renderer.PrettyJSON(w, r, err.Error())
+	page, ok := parsePageRequest(w, r)
+	if !ok {
 		return
 	}
+	if len(page.Sort) == 0 {
+		page.Sort = []models.SortKey{{Column: "created_at", Desc: true}}
+	}
 
 	// Filter comments based on user role
-	if userRole == "Customer" {
-		filteredComments := []models.Comment{}
-		for _, comment := range comments {
-			if !comment.IsInternal {
-				filteredComments = append(filteredComments, comment)
-			}
-		}
-		render.Status(r, http.StatusOK)
-		renderer.PrettyJSON(w, r, filteredComments)
+	filter := models.CommentFilter{TicketID: id, PublicOnly: userRole == "Customer"}
+
+	comments, err := h.comments.List(ctx, page, filter)
+	if err != nil {
+		renderListError(w, r, err)
 		return
 	}
 
-	render.Status(r, http.StatusOK)
-	renderer.PrettyJSON(w, r, comments)
+	renderPage(w, r, comments)
 }
 
 func (h *CommentHandler) CreateAgentComment(w http.ResponseWriter, r *http.Request) {
@@ -186,7 +293,7 @@ func (h *CommentHandler) CreateAgentComment(w http.ResponseWriter, r *http.Reque
 	}
 
 	// Check if the ticket exists
-	_, err = models.GetTicketByID(h.db, r.Context(), ticketID)
+	ticket, err := h.tickets.GetByID(r.Context(), ticketID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			render.Status(r, http.StatusNotFound)
@@ -216,11 +323,12 @@ func (h *CommentHandler) CreateAgentComment(w http.ResponseWriter, r *http.Reque
 		IsInternal: req.IsInternal,
 	}
 
-	if err := models.CreateComment(h.db, r.Context(), &comment); err != nil {
+	if err := h.comments.Create(r.Context(), &comment); err != nil {
 		render.Status(r, http.StatusInternalServerError)
 		renderer.PrettyJSON(w, r, err.Error())
 		return
 	}
+	h.commentCreated(r, ticket, &comment)
 
 	render.Status(r, http.StatusCreated)
 	renderer.PrettyJSON(w, r, comment)
@@ -249,7 +357,7 @@ func (h *CommentHandler) CreateCustomerComment(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	ticket, err := models.GetTicketByID(h.db, r.Context(), ticketID)
+	ticket, err := h.tickets.GetByID(r.Context(), ticketID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			render.Status(r, http.StatusNotFound)
@@ -261,7 +369,13 @@ func (h *CommentHandler) CreateCustomerComment(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	if ticket.RequesterID != authorID {
+	allowed, err := canAccessCustomerTicket(h.db, h.related, h.users, r.Context(), ticket, authorID)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if !allowed {
 		render.Status(r, http.StatusForbidden)
 		renderer.PrettyJSON(w, r, "You are not authorized to comment on this ticket")
 		return
@@ -284,12 +398,71 @@ func (h *CommentHandler) CreateCustomerComment(w http.ResponseWriter, r *http.Re
 		IsInternal: false,
 	}
 
-	if err := models.CreateComment(h.db, r.Context(), &comment); err != nil {
+	if err := h.comments.Create(r.Context(), &comment); err != nil {
 		render.Status(r, http.StatusInternalServerError)
 		renderer.PrettyJSON(w, r, err.Error())
 		return
 	}
+	h.commentCreated(r, ticket, &comment)
 
 	render.Status(r, http.StatusCreated)
 	renderer.PrettyJSON(w, r, comment)
 }
+
+// loadOwnComment loads the comment in the URL for the current user to edit or delete. Admins may
+// change any comment, other users only their own while the edit window is open. Otherwise a 404 or
+// 403 response has already been written and false is returned.
+func (h *CommentHandler) loadOwnComment(w http.ResponseWriter, r *http.Request, verb string) (*models.Comment, int64, bool) {
+	actorID, ok := currentUserID(w, r)
+	if !ok {
+		return nil, 0, false
+	}
+	id, ok := urlParamID(w, r, "id", "comment")
+	if !ok {
+		return nil, 0, false
+	}
+
+	comment, err := h.comments.GetByID(r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "Comment not found")
+			return nil, 0, false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return nil, 0, false
+	}
+
+	if role, _ := r.Context().Value(middleware.UserRoleKey).(string); role == "Admin" {
+		return comment, actorID, true
+	}
+	if comment.AuthorID != actorID {
+		render.Status(r, http.StatusForbidden)
+		renderer.PrettyJSON(w, r, fmt.Sprintf("You can only %s your own comments", verb))
+		return nil, 0, false
+	}
+	if time.Since(comment.CreatedAt) > h.editWindow {
+		render.Status(r, http.StatusForbidden)
+		renderer.PrettyJSON(w, r, fmt.Sprintf("The time to %s this comment has passed", verb))
+		return nil, 0, false
+	}
+	return comment, actorID, true
+}
+
+// commentCreated runs what follows every new comment once it is saved: its mentions are recorded,
+// it is audited, the ticket's followers are notified and the comment.created triggers fire. It
+// returns the ticket as the triggers left it, or nil when none fired.
+func (h *CommentHandler) commentCreated(r *http.Request, ticket *models.Ticket, comment *models.Comment) *models.Ticket {
+	h.recordMentions(r, ticket, comment)
+	recordAudit(h.db, r, models.AuditCreate, "comment", comment.ID, nil, comment)
+	h.notifyComment(r, ticket, comment)
+	return fireTriggers(h.related, h.tickets, r, models.TriggerEvent{Type: models.EventCommentCreated, Comment: comment}, ticket.ID)
+}
+
+// notifyComment tells the ticket's followers about a new comment. Internal notes only reach the
+// assignee and watchers.
+func (h *CommentHandler) notifyComment(r *http.Request, ticket *models.Ticket, comment *models.Comment) {
+	notifyTickets(h.related, h.notifier, r, []*models.Ticket{ticket}, comment.AuthorID, ticket.ID, comment.IsInternal,
+		fmt.Sprintf("New comment on ticket #%d", ticket.ID), comment.Body)
+}
@@ -0,0 +1,51 @@
+package models_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	model "goat/services/models"
+)
+
+func TestCommentHandler(t *testing.T) {
+	s := newTestServer(t)
+	customer := s.newUser(t, "Customer", 0)
+	stranger := s.newUser(t, "Customer", 0)
+	agent := s.newUser(t, "Agent", 0)
+
+	var ticket model.Ticket
+	w := s.do(t, customer, http.MethodPost, "/customer/tickets", map[string]any{"title": "Refund", "priority": "Low"})
+	decode(t, w, http.StatusCreated, &ticket)
+
+	customerPath := fmt.Sprintf("/customer/tickets/%d/comments", ticket.ID)
+	decode(t, s.do(t, stranger, http.MethodPost, customerPath, map[string]any{"body": "Me too"}), http.StatusForbidden, nil)
+
+	var comment model.Comment
+	decode(t, s.do(t, customer, http.MethodPost, customerPath, map[string]any{"body": "Any news?"}), http.StatusCreated, &comment)
+	agentPath := fmt.Sprintf("/agent/tickets/%d/comments", ticket.ID)
+	decode(t, s.do(t, agent, http.MethodPost, agentPath, map[string]any{"body": "Ask finance", "is_internal": true}), http.StatusCreated, nil)
+
+	listPath := fmt.Sprintf("/admin/comments/ticket/%d", ticket.ID)
+	var comments []model.Comment
+	decode(t, s.do(t, customer, http.MethodGet, listPath, nil), http.StatusOK, &comments)
+	if len(comments) != 1 || comments[0].ID != comment.ID {
+		t.Errorf("customer got %d comments, want only their own", len(comments))
+	}
+	decode(t, s.do(t, agent, http.MethodGet, listPath, nil), http.StatusOK, &comments)
+	if len(comments) != 2 {
+		t.Errorf("agent got %d comments, want 2 including the internal note", len(comments))
+	}
+
+	editPath := fmt.Sprintf("/customer/comments/%d", comment.ID)
+	decode(t, s.do(t, customer, http.MethodPut, editPath, map[string]any{"body": "Any news on the refund?"}), http.StatusOK, &comment)
+	if comment.Body != "Any news on the refund?" || comment.EditedAt == nil {
+		t.Errorf("got body %q edited at %v, want the new body with an edit time", comment.Body, comment.EditedAt)
+	}
+	decode(t, s.do(t, stranger, http.MethodDelete, editPath, nil), http.StatusForbidden, nil)
+	decode(t, s.do(t, customer, http.MethodDelete, editPath, nil), http.StatusAccepted, nil)
+	decode(t, s.do(t, agent, http.MethodGet, listPath, nil), http.StatusOK, &comments)
+	if len(comments) != 1 {
+		t.Errorf("got %d comments after deleting one, want 1", len(comments))
+	}
+}
@@ -0,0 +1,429 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+
+	"goat/app/renderer"
+	"goat/services/models"
+)
+
+type CompanyHandler struct {
+	db      *bun.DB
+	users   models.UserRepository
+	tickets models.TicketRepository
+}
+
+func NewCompanyHandler(db *bun.DB, repos models.Repositories) *CompanyHandler {
+	return &CompanyHandler{db: db, users: repos.Users, tickets: repos.Tickets}
+}
+
+// companyRequest is the body of the requests creating or changing a company.
+type companyRequest struct {
+	Name          string `json:"name"`
+	Domain        string `json:"domain"`         // Email domain whose customers are suggested as members, empty for none
+	SharedTickets bool   `json:"shared_tickets"` // Members may view and comment on each other's tickets
+}
+
+// ListCompanies handles the request to list all companies.
+func (h *CompanyHandler) ListCompanies(w http.ResponseWriter, r *http.Request) {
+	companies, err := models.ListCompanies(h.db, r.Context())
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, companies)
+}
+
+// CreateCompany handles the request to create a company.
+func (h *CompanyHandler) CreateCompany(w http.ResponseWriter, r *http.Request) {
+	var req companyRequest
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if req.Name == "" {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Company name is required")
+		return
+	}
+
+	company := models.Company{Name: req.Name, Domain: normalizeDomain(req.Domain), SharedTickets: req.SharedTickets}
+	if err := models.CreateCompany(h.db, r.Context(), &company); err != nil {
+		renderCompanyError(w, r, err)
+		return
+	}
+	recordAudit(h.db, r, models.AuditCreate, "company", company.ID, nil, company)
+
+	render.Status(r, http.StatusCreated)
+	renderer.PrettyJSON(w, r, company)
+}
+
+// GetCompany handles the request to get a company.
+func (h *CompanyHandler) GetCompany(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.company(w, r)
+	if !ok {
+		return
+	}
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, company)
+}
+
+// UpdateCompany handles the request to change a company's name, domain or ticket sharing.
+// Members on the old domain stay.
+func (h *CompanyHandler) UpdateCompany(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.company(w, r)
+	if !ok {
+		return
+	}
+
+	var req companyRequest
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if req.Name == "" {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Company name is required")
+		return
+	}
+
+	before := *company
+	company.Name = req.Name
+	company.Domain = normalizeDomain(req.Domain)
+	company.SharedTickets = req.SharedTickets
+	if err := models.UpdateCompany(h.db, r.Context(), company); err != nil {
+		renderCompanyError(w, r, err)
+		return
+	}
+	recordAudit(h.db, r, models.AuditUpdate, "company", company.ID, before, company)
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, company)
+}
+
+// DeleteCompany handles the request to delete a company. Its members stay, without a company.
+func (h *CompanyHandler) DeleteCompany(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.company(w, r)
+	if !ok {
+		return
+	}
+
+	memberIDs, err := models.ListCompanyMemberIDs(h.db, r.Context(), company.ID)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	for _, id := range memberIDs {
+		if !h.setCompany(w, r, id, sql.NullInt64{}) {
+			return
+		}
+	}
+
+	if err := models.DeleteCompany(h.db, r.Context(), company.ID); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditDelete, "company", company.ID, company, nil)
+
+	render.Status(r, http.StatusAccepted)
+	renderer.PrettyJSON(w, r, map[string]string{"message": "Company deleted successfully"})
+}
+
+// ListCompanyMembers handles the request to list a company's members.
+func (h *CompanyHandler) ListCompanyMembers(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.company(w, r)
+	if !ok {
+		return
+	}
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+	members, err := models.ListCompanyMembers(h.db, r.Context(), company.ID, page)
+	if err != nil {
+		renderListError(w, r, err)
+		return
+	}
+	renderPage(w, r, members)
+}
+
+// ListPendingCompanyMembers handles the request to list the customers without a company whose
+// email is on the company's domain. They only join once added with AddCompanyMember: nothing
+// proves they own the address, and members may see each other's tickets.
+func (h *CompanyHandler) ListPendingCompanyMembers(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.company(w, r)
+	if !ok {
+		return
+	}
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+	if company.Domain == "" {
+		renderPage(w, r, &models.Page[*models.User]{Items: []*models.User{}})
+		return
+	}
+	pending, err := models.ListDomainCustomers(h.db, r.Context(), company.Domain, page)
+	if err != nil {
+		renderListError(w, r, err)
+		return
+	}
+	renderPage(w, r, pending)
+}
+
+// AddCompanyMember handles the request to move a customer into a company.
+func (h *CompanyHandler) AddCompanyMember(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.company(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		UserID int64 `json:"user_id"`
+	}
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	if !h.setCompany(w, r, req.UserID, sql.NullInt64{Int64: company.ID, Valid: true}) {
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, map[string]string{"message": "Member added successfully"})
+}
+
+// RemoveCompanyMember handles the request to take a customer out of a company.
+func (h *CompanyHandler) RemoveCompanyMember(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.company(w, r)
+	if !ok {
+		return
+	}
+	userID, ok := urlParamID(w, r, "userID", "user")
+	if !ok {
+		return
+	}
+
+	user, err := h.users.GetByID(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "User not found")
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if !user.CompanyID.Valid || user.CompanyID.Int64 != company.ID {
+		render.Status(r, http.StatusNotFound)
+		renderer.PrettyJSON(w, r, "User is not a member of this company")
+		return
+	}
+
+	if !h.setCompany(w, r, userID, sql.NullInt64{}) {
+		return
+	}
+
+	render.Status(r, http.StatusAccepted)
+	renderer.PrettyJSON(w, r, map[string]string{"message": "Member removed successfully"})
+}
+
+// ListCompanyTickets handles the request to list the tickets requested by a company's members.
+// It accepts the same "q" and "tag" parameters as the other ticket lists.
+func (h *CompanyHandler) ListCompanyTickets(w http.ResponseWriter, r *http.Request) {
+	company, ok := h.company(w, r)
+	if !ok {
+		return
+	}
+	filter, ok := ticketQueryFilter(w, r)
+	if !ok {
+		return
+	}
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	tickets, err := h.tickets.List(r.Context(), page, models.CompanyTickets(company.ID), filter)
+	if err != nil {
+		renderListError(w, r, err)
+		return
+	}
+	renderPage(w, r, tickets)
+}
+
+// CompanyReport handles the request for each company's member count and ticket counts per status,
+// optionally limited to tickets created between created_after and created_before.
+func (h *CompanyHandler) CompanyReport(w http.ResponseWriter, r *http.Request) {
+	var createdAfter, createdBefore time.Time
+	dates := map[string]*time.Time{"created_after": &createdAfter, "created_before": &createdBefore}
+	for name, dst := range dates {
+		if v := r.URL.Query().Get(name); v != "" {
+			t, err := parseDate(v)
+			if err != nil {
+				render.Status(r, http.StatusBadRequest)
+				renderer.PrettyJSON(w, r, "Invalid "+name+", expected YYYY-MM-DD or RFC 3339")
+				return
+			}
+			*dst = t
+		}
+	}
+
+	stats, err := models.GetCompanyStats(h.db, r.Context(), createdAfter, createdBefore)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, stats)
+}
+
+// company loads the company named by the id URL parameter, writing an error response when it is
+// invalid or missing.
+func (h *CompanyHandler) company(w http.ResponseWriter, r *http.Request) (*models.Company, bool) {
+	id, ok := urlParamID(w, r, "id", "company")
+	if !ok {
+		return nil, false
+	}
+	company, err := models.GetCompanyByID(h.db, r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "Company not found")
+			return nil, false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return nil, false
+	}
+	return company, true
+}
+
+// setCompany moves a customer into a company, or out of theirs for an invalid companyID, through
+// the user repository so that every store sees the change. It writes an error response on failure.
+func (h *CompanyHandler) setCompany(w http.ResponseWriter, r *http.Request, userID int64, companyID sql.NullInt64) bool {
+	user, err := h.users.GetByID(r.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "User not found")
+			return false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return false
+	}
+	if companyID.Valid && user.Role != "Customer" {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Only customers can join a company")
+		return false
+	}
+	if user.CompanyID == companyID {
+		return true
+	}
+
+	before := *user
+	user.CompanyID = companyID
+	if err := h.users.Update(r.Context(), user); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return false
+	}
+	recordAudit(h.db, r, models.AuditUpdate, "user", user.ID, before, user)
+	return true
+}
+
+// normalizeDomain lowercases an email domain and drops a leading "@".
+func normalizeDomain(domain string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+}
+
+func renderCompanyError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, models.ErrDuplicate) {
+		render.Status(r, http.StatusConflict)
+		renderer.PrettyJSON(w, r, "A company with this name or domain already exists")
+		return
+	}
+	render.Status(r, http.StatusInternalServerError)
+	renderer.PrettyJSON(w, r, err.Error())
+}
+
+// sharingCompany returns the company of a user if it shares tickets between its members, or nil.
+func sharingCompany(db bun.IDB, users models.UserRepository, ctx context.Context, userID int64) (*models.Company, error) {
+	user, err := users.GetByID(ctx, userID)
+	if err == sql.ErrNoRows || err == nil && !user.CompanyID.Valid {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	company, err := models.GetCompanyByID(db, ctx, user.CompanyID.Int64)
+	if err == sql.ErrNoRows || err == nil && !company.SharedTickets {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return company, nil
+}
+
+// customerTickets returns the filter matching the tickets a customer may see, as
+// canAccessCustomerTicket decides for a single ticket.
+func customerTickets(db bun.IDB, users models.UserRepository, ctx context.Context, userID int64) (models.QueryFilter, error) {
+	company, err := sharingCompany(db, users, ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var companyID int64
+	if company != nil {
+		companyID = company.ID
+	}
+	return models.CustomerTickets(userID, companyID), nil
+}
+
+// canAccessCustomerTicket reports whether a customer may view and comment on a ticket: their own,
+// one they are copied on, or one requested by another member of their company when it shares
+// tickets. db holds the companies and related the CCs, nil when tickets are not stored in the SQL
+// database. customerTickets must stay in step with it.
+func canAccessCustomerTicket(db bun.IDB, related *bun.DB, users models.UserRepository, ctx context.Context, ticket *models.Ticket, userID int64) (bool, error) {
+	if ticket.RequesterID == userID {
+		return true, nil
+	}
+	if related != nil {
+		if _, err := models.GetTicketCCForUser(related, ctx, ticket.ID, userID); err == nil {
+			return true, nil
+		} else if err != sql.ErrNoRows {
+			return false, err
+		}
+	}
+	company, err := sharingCompany(db, users, ctx, userID)
+	if err != nil || company == nil {
+		return false, err
+	}
+	requester, err := users.GetByID(ctx, ticket.RequesterID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	return requester.CompanyID.Valid && requester.CompanyID.Int64 == company.ID, nil
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+
+	"goat/app/middleware"
+	"goat/app/renderer"
+	"goat/services/models"
+)
+
+// currentUserID returns the authenticated user's ID, writing an error response when it is missing.
+func currentUserID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
+	if !ok {
+		render.Status(r, http.StatusUnauthorized)
+		renderer.PrettyJSON(w, r, "Unauthorized")
+		return 0, false
+	}
+
+	id, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, "Invalid user ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// currentUserRole returns the authenticated user's role, or an empty string.
+func currentUserRole(r *http.Request) string {
+	role, _ := r.Context().Value(middleware.UserRoleKey).(string)
+	return role
+}
+
+// urlParamID parses a numeric chi URL parameter, writing a 400 response naming the entity when invalid.
+func urlParamID(w http.ResponseWriter, r *http.Request, param, entity string) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, param), 10, 64)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Invalid "+entity+" ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// publicTenant returns the request narrowed to the organization an unauthenticated request is for:
+// the one its hostname belongs to or, on other hostnames, orgID, which defaults to the default
+// organization. It writes a 400 response when orgID is unknown or names another organization than
+// the hostname.
+func publicTenant(db bun.IDB, w http.ResponseWriter, r *http.Request, orgID int64) (*http.Request, bool) {
+	if hostTenant, ok := r.Context().Value(middleware.HostTenantKey).(int64); ok {
+		if orgID != 0 && orgID != hostTenant {
+			render.Status(r, http.StatusBadRequest)
+			renderer.PrettyJSON(w, r, "organization_id does not match the hostname")
+			return nil, false
+		}
+		return r, true
+	}
+	if orgID == 0 || orgID == models.DefaultTenantID {
+		return r.WithContext(models.WithTenant(r.Context(), models.DefaultTenantID)), true
+	}
+
+	if _, err := models.GetOrganizationByID(db, r.Context(), orgID); err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusBadRequest)
+			renderer.PrettyJSON(w, r, "Unknown organization")
+			return nil, false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return nil, false
+	}
+	return r.WithContext(models.WithTenant(r.Context(), orgID)), true
+}
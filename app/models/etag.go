@@ -0,0 +1,64 @@
+package models
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+
+	"goat/app/renderer"
+	"goat/services/models"
+)
+
+// ticketETag returns the entity tag for the stored version of a ticket.
+func ticketETag(ticket *models.Ticket) string {
+	return `"` + strconv.FormatInt(ticket.Version, 10) + `"`
+}
+
+// checkTicketIfMatch enforces the If-Match precondition on ticket writes.
+// On success ticket.Version is left as the version the client is allowed to overwrite.
+// Otherwise a 428 or 412 response carrying the current ticket has already been written and false is returned.
+func checkTicketIfMatch(w http.ResponseWriter, r *http.Request, ticket *models.Ticket) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		render.Status(r, http.StatusPreconditionRequired)
+		renderer.PrettyJSON(w, r, "If-Match header is required")
+		return false
+	}
+
+	if strings.TrimSpace(ifMatch) == "*" {
+		return true
+	}
+
+	current := ticketETag(ticket)
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if strings.TrimSpace(tag) == current {
+			return true
+		}
+	}
+
+	renderVersionConflict(w, r, ticket)
+	return false
+}
+
+// renderVersionConflict responds with 412 Precondition Failed and the current ticket representation.
+func renderVersionConflict(w http.ResponseWriter, r *http.Request, ticket *models.Ticket) {
+	w.Header().Set("ETag", ticketETag(ticket))
+	render.Status(r, http.StatusPreconditionFailed)
+	renderer.PrettyJSON(w, r, ticket)
+}
+
+// filterForRequester removes internal comments and links to other tickets so the ticket can be shown to its requester.
+func filterForRequester(ticket *models.Ticket) {
+	filteredComments := []models.Comment{}
+	for _, comment := range ticket.Comments {
+		if !comment.IsInternal {
+			filteredComments = append(filteredComments, comment)
+		}
+	}
+	ticket.Comments = filteredComments
+	ticket.Links = nil
+	ticket.Children = nil
+	ticket.Watchers = nil
+}
@@ -0,0 +1,217 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+
+	"goat/app/renderer"
+	"goat/services/models"
+)
+
+type FieldHandler struct {
+	db *bun.DB
+}
+
+func NewFieldHandler(db *bun.DB) *FieldHandler {
+	return &FieldHandler{db: db}
+}
+
+// ListFields handles the request to list the custom ticket field definitions.
+func (h *FieldHandler) ListFields(w http.ResponseWriter, r *http.Request) {
+	fields, err := models.ListCustomFields(h.db, r.Context())
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, fields)
+}
+
+// CreateField handles the request to define a new custom ticket field.
+func (h *FieldHandler) CreateField(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key         string   `json:"key"`
+		Name        string   `json:"name"`
+		Type        string   `json:"type"`
+		Options     []string `json:"options"`
+		RequiredFor []string `json:"required_for"`
+	}
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	field := models.CustomField{
+		Key:         req.Key,
+		Name:        req.Name,
+		Type:        req.Type,
+		Options:     req.Options,
+		RequiredFor: req.RequiredFor,
+	}
+	if err := field.Validate(); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	if err := models.CreateCustomField(h.db, r.Context(), &field); err != nil {
+		if errors.Is(err, models.ErrDuplicateFieldKey) {
+			render.Status(r, http.StatusConflict)
+			renderer.PrettyJSON(w, r, err.Error())
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditCreate, "field", field.ID, nil, field)
+
+	render.Status(r, http.StatusCreated)
+	renderer.PrettyJSON(w, r, field)
+}
+
+// UpdateField handles the request to rename a custom field or change its options and required ticket types.
+func (h *FieldHandler) UpdateField(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "id", "field")
+	if !ok {
+		return
+	}
+
+	field, err := models.GetCustomFieldByID(h.db, r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "Field not found")
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	before := *field
+
+	var req struct {
+		Name        *string   `json:"name"`
+		Options     *[]string `json:"options"`
+		RequiredFor *[]string `json:"required_for"`
+	}
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	if req.Name != nil {
+		field.Name = *req.Name
+	}
+	if req.Options != nil {
+		field.Options = *req.Options
+	}
+	if req.RequiredFor != nil {
+		field.RequiredFor = *req.RequiredFor
+	}
+	if err := field.Validate(); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	if err := models.UpdateCustomField(h.db, r.Context(), field); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditUpdate, "field", field.ID, before, field)
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, field)
+}
+
+// DeleteField handles the request to delete a custom field and its values on every ticket.
+func (h *FieldHandler) DeleteField(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "id", "field")
+	if !ok {
+		return
+	}
+
+	existing, err := models.GetCustomFieldByID(h.db, r.Context(), id)
+	if err != nil && err != sql.ErrNoRows {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	if err := models.DeleteCustomField(h.db, r.Context(), id); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if existing != nil {
+		recordAudit(h.db, r, models.AuditDelete, "field", id, existing, nil)
+	}
+
+	render.Status(r, http.StatusAccepted)
+	renderer.PrettyJSON(w, r, map[string]string{"message": "Field deleted successfully"})
+}
+
+// checkTicketType verifies that a ticket type is known, writing an error response when it is not.
+func checkTicketType(w http.ResponseWriter, r *http.Request, ticketType string) bool {
+	if !slices.Contains(models.TicketTypes, ticketType) {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Invalid ticket type, expected one of "+strings.Join(models.TicketTypes, ", "))
+		return false
+	}
+	return true
+}
+
+// ticketFieldChanges validates custom field values for a ticket, writing an error response when
+// they are rejected. Rejected values respond with 422 and the reason for each field.
+func ticketFieldChanges(db *bun.DB, w http.ResponseWriter, r *http.Request, ticket *models.Ticket, values map[string]json.RawMessage) (models.FieldChanges, bool) {
+	if db == nil {
+		return nil, true // Without a database there are no custom fields
+	}
+	changes, err := models.ValidateTicketFields(db, r.Context(), ticket, values)
+	if err != nil {
+		var fieldErrs models.FieldErrors
+		if errors.As(err, &fieldErrs) {
+			render.Status(r, http.StatusUnprocessableEntity)
+			renderer.PrettyJSON(w, r, map[string]any{"message": "Invalid custom fields", "fields": fieldErrs})
+			return nil, false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return nil, false
+	}
+	return changes, true
+}
+
+// saveTicketFields stores validated custom field values and reloads them into the ticket.
+func saveTicketFields(db *bun.DB, w http.ResponseWriter, r *http.Request, ticket *models.Ticket, changes models.FieldChanges) bool {
+	if db == nil {
+		return true
+	}
+	if err := models.SaveTicketFields(db, r.Context(), ticket.ID, changes); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return false
+	}
+
+	ticket.Fields = nil
+	if err := models.LoadTicketFields(db, r.Context(), ticket); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+
+	"goat/app/renderer"
+	"goat/services/models"
+)
+
+type LinkHandler struct {
+	db *bun.DB
+}
+
+func NewLinkHandler(db *bun.DB) *LinkHandler {
+	return &LinkHandler{db: db}
+}
+
+// CreateTicketLink handles the request to link a ticket to another ticket.
+// The type is read from the ticket in the URL, e.g. {"type": "blocked_by", "ticket_id": 17}.
+func (h *LinkHandler) CreateTicketLink(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "id", "ticket")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Type     string `json:"type"`
+		TicketID int64  `json:"ticket_id"`
+	}
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	link, err := models.NewTicketLink(id, req.Type, req.TicketID)
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	count, err := models.CountTickets(h.db, r.Context(), func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("ticket.id IN (?)", bun.In([]int64{id, req.TicketID}))
+	})
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if count != 2 {
+		render.Status(r, http.StatusNotFound)
+		renderer.PrettyJSON(w, r, "Ticket not found")
+		return
+	}
+
+	if err := models.CreateTicketLink(h.db, r.Context(), link); err != nil {
+		if errors.Is(err, models.ErrDuplicateLink) || errors.Is(err, models.ErrLinkCycle) || errors.Is(err, models.ErrParentExists) {
+			render.Status(r, http.StatusConflict)
+			renderer.PrettyJSON(w, r, err.Error())
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	h.auditLink(r, models.AuditLink, link)
+
+	h.renderLinks(w, r, id, http.StatusCreated)
+}
+
+// DeleteTicketLink handles the request to remove a link from a ticket.
+func (h *LinkHandler) DeleteTicketLink(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "id", "ticket")
+	if !ok {
+		return
+	}
+	linkID, ok := urlParamID(w, r, "linkID", "link")
+	if !ok {
+		return
+	}
+
+	link, err := models.DeleteTicketLink(h.db, r.Context(), id, linkID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "Link not found")
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	h.auditLink(r, models.AuditUnlink, link)
+
+	h.renderLinks(w, r, id, http.StatusOK)
+}
+
+// auditLink records a created or removed link in the history of both linked tickets.
+func (h *LinkHandler) auditLink(r *http.Request, action string, link *models.TicketLink) {
+	before, after := map[string]any{"Link": nil}, map[string]any{"Link": link.String()}
+	if action == models.AuditUnlink {
+		before, after = after, before
+	}
+	recordAudit(h.db, r, action, "ticket", link.SourceID, before, after)
+	recordAudit(h.db, r, action, "ticket", link.TargetID, before, after)
+}
+
+// renderLinks responds with the current links of a ticket.
+func (h *LinkHandler) renderLinks(w http.ResponseWriter, r *http.Request, ticketID int64, status int) {
+	links, err := models.ListTicketLinks(h.db, r.Context(), ticketID)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if links == nil {
+		links = []models.LinkedTicket{}
+	}
+	render.Status(r, status)
+	renderer.PrettyJSON(w, r, links)
+}
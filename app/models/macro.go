@@ -0,0 +1,401 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+
+	"goat/app/renderer"
+	"goat/services/models"
+)
+
+type MacroHandler struct {
+	db       *bun.DB
+	tickets  models.TicketRepository
+	comments *CommentHandler // Handles the replies macros post like any other comment
+}
+
+func NewMacroHandler(db *bun.DB, repos models.Repositories, comments *CommentHandler) *MacroHandler {
+	return &MacroHandler{db: db, tickets: repos.Tickets, comments: comments}
+}
+
+// macroRequest is the body of the requests creating and updating a macro.
+type macroRequest struct {
+	Name       string              `json:"name"`
+	Body       string              `json:"body"`
+	IsInternal bool                `json:"is_internal"`
+	Actions    models.MacroActions `json:"actions"`
+	TeamID     *int64              `json:"team_id"`
+	Shared     bool                `json:"shared"` // Only admins may share a macro with everyone
+}
+
+// ListMacros handles the request to list the caller's macros and the macros shared with them,
+// each with the number of times it has been applied.
+func (h *MacroHandler) ListMacros(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	teamIDs, err := models.ListTeamIDsByUserID(h.db, r.Context(), userID)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	macros, err := models.ListMacrosForUser(h.db, r.Context(), userID, teamIDs)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, macros)
+}
+
+// CreateMacro handles the request to save a macro, optionally shared with a team or everyone.
+func (h *MacroHandler) CreateMacro(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	macro := models.Macro{OwnerID: userID}
+	if !h.decodeMacro(w, r, &macro) {
+		return
+	}
+
+	if err := models.CreateMacro(h.db, r.Context(), &macro); err != nil {
+		renderMacroError(w, r, err)
+		return
+	}
+	recordAudit(h.db, r, models.AuditCreate, "macro", macro.ID, nil, macro)
+
+	render.Status(r, http.StatusCreated)
+	renderer.PrettyJSON(w, r, macro)
+}
+
+// UpdateMacro handles the request to change a macro. Only its owner or an admin may change it.
+func (h *MacroHandler) UpdateMacro(w http.ResponseWriter, r *http.Request) {
+	macro, ok := h.ownMacro(w, r, "change")
+	if !ok {
+		return
+	}
+
+	before := *macro
+	if !h.decodeMacro(w, r, macro) {
+		return
+	}
+
+	if err := models.UpdateMacro(h.db, r.Context(), macro); err != nil {
+		renderMacroError(w, r, err)
+		return
+	}
+	recordAudit(h.db, r, models.AuditUpdate, "macro", macro.ID, before, macro)
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, macro)
+}
+
+// DeleteMacro handles the request to delete a macro. Only its owner or an admin may delete it.
+func (h *MacroHandler) DeleteMacro(w http.ResponseWriter, r *http.Request) {
+	macro, ok := h.ownMacro(w, r, "delete")
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteMacro(h.db, r.Context(), macro.ID); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditDelete, "macro", macro.ID, macro, nil)
+
+	render.Status(r, http.StatusAccepted)
+	renderer.PrettyJSON(w, r, map[string]string{"message": "Macro deleted successfully"})
+}
+
+// ApplyMacro handles the request to apply a macro to a ticket: its actions change the ticket and
+// its body, with the placeholders filled in, is added as a comment, all in one transaction.
+// Like other ticket updates, If-Match must hold the ticket's ETag and agents may only apply macros
+// to tickets that are unassigned or assigned to them, unless the macro assigns the ticket to them.
+func (h *MacroHandler) ApplyMacro(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+	ticketID, ok := urlParamID(w, r, "id", "ticket")
+	if !ok {
+		return
+	}
+	macro, ok := h.visibleMacro(w, r, actorID, "macroID")
+	if !ok {
+		return
+	}
+
+	ticket, err := models.GetTicketByID(h.db, r.Context(), ticketID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "Ticket not found")
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if !checkTicketIfMatch(w, r, ticket) {
+		return
+	}
+	before := *ticket
+
+	assigneeID, ok := h.macroAssignee(w, r, macro, ticket, actorID)
+	if !ok {
+		return
+	}
+
+	if currentUserRole(r) != "Admin" && ticket.AssigneeID.Valid && ticket.AssigneeID.Int64 != actorID &&
+		assigneeID.Int64 != actorID {
+		render.Status(r, http.StatusForbidden)
+		renderer.PrettyJSON(w, r, "You are not authorized to update this ticket")
+		return
+	}
+
+	if macro.Actions.Status != "" {
+		ticket.Status = macro.Actions.Status
+	}
+	if macro.Actions.Priority != "" {
+		ticket.Priority = macro.Actions.Priority
+	}
+	ticket.AssigneeID = assigneeID
+
+	var comment *models.Comment
+	if macro.Body != "" {
+		c := models.MacroContext{
+			Ticket:    ticket,
+			Requester: h.lookupUser(r.Context(), sql.NullInt64{Int64: ticket.RequesterID, Valid: true}),
+			Agent:     h.lookupUser(r.Context(), sql.NullInt64{Int64: actorID, Valid: true}),
+			Assignee:  h.lookupUser(r.Context(), ticket.AssigneeID),
+		}
+		comment = &models.Comment{
+			TicketID:   ticket.ID,
+			AuthorID:   actorID,
+			Body:       models.RenderMacro(macro.Body, c),
+			IsInternal: macro.IsInternal,
+		}
+	}
+
+	if err := models.ApplyMacro(h.db, r.Context(), macro, ticket, comment); err != nil {
+		if errors.Is(err, models.ErrVersionConflict) {
+			if current, err := models.GetTicketByID(h.db, r.Context(), ticketID); err == nil {
+				renderVersionConflict(w, r, current)
+				return
+			}
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	models.SyncChanges(h.tickets, r.Context(), models.RowChanges{TicketIDs: []int64{ticketID}})
+
+	updated, err := models.GetTicketByID(h.db, r.Context(), ticketID)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditUpdate, "ticket", ticketID, before, updated)
+
+	if triggered := fireTriggers(h.db, h.tickets, r, models.TriggerEvent{Type: models.EventTicketUpdated, Before: &before}, ticketID); triggered != nil {
+		updated = triggered
+	}
+	if comment != nil {
+		if triggered := h.comments.commentCreated(r, updated, comment); triggered != nil {
+			updated = triggered
+		}
+	}
+
+	w.Header().Set("ETag", ticketETag(updated))
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, updated)
+}
+
+// decodeMacro reads a macro request into macro, writing a 400 or 403 response and returning false
+// when it is invalid or shares the macro more widely than the caller may.
+func (h *MacroHandler) decodeMacro(w http.ResponseWriter, r *http.Request, macro *models.Macro) bool {
+	var req macroRequest
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return false
+	}
+
+	if req.Name == "" {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Macro name is required")
+		return false
+	}
+	if err := models.CheckMacroBody(req.Body); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Invalid macro body: "+err.Error())
+		return false
+	}
+	if err := models.CheckMacroActions(req.Actions); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Invalid macro actions: "+err.Error())
+		return false
+	}
+
+	role := currentUserRole(r)
+	if req.Shared && role != "Admin" {
+		render.Status(r, http.StatusForbidden)
+		renderer.PrettyJSON(w, r, "Only admins can share macros with everyone")
+		return false
+	}
+	macro.TeamID = sql.NullInt64{}
+	if req.TeamID != nil {
+		teamIDs, err := models.ListTeamIDsByUserID(h.db, r.Context(), macro.OwnerID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			renderer.PrettyJSON(w, r, err.Error())
+			return false
+		}
+		if !slices.Contains(teamIDs, *req.TeamID) {
+			if role != "Admin" {
+				render.Status(r, http.StatusForbidden)
+				renderer.PrettyJSON(w, r, "You can only share macros with your own teams")
+				return false
+			}
+			if _, err := models.GetTeamByID(h.db, r.Context(), *req.TeamID); err != nil {
+				renderTeamLookupError(w, r, err)
+				return false
+			}
+		}
+		macro.TeamID = sql.NullInt64{Int64: *req.TeamID, Valid: true}
+	}
+
+	macro.Name = req.Name
+	macro.Body = req.Body
+	macro.IsInternal = req.IsInternal
+	macro.Actions = req.Actions
+	macro.Shared = req.Shared
+	return true
+}
+
+// macroAssignee returns who the ticket is assigned to once the macro is applied. It writes a 422
+// response and returns false when the macro names a user who is gone or is not staff.
+func (h *MacroHandler) macroAssignee(w http.ResponseWriter, r *http.Request, macro *models.Macro, ticket *models.Ticket, actorID int64) (sql.NullInt64, bool) {
+	switch macro.Actions.Assignee {
+	case "":
+		return ticket.AssigneeID, true
+	case "me":
+		return sql.NullInt64{Int64: actorID, Valid: true}, true
+	case "none":
+		return sql.NullInt64{}, true
+	}
+
+	id, _ := strconv.ParseInt(macro.Actions.Assignee, 10, 64)
+	user, err := models.GetUserByID(h.db, r.Context(), id)
+	if err != nil && err != sql.ErrNoRows {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return sql.NullInt64{}, false
+	}
+	if err == sql.ErrNoRows || user.Role == "Customer" {
+		render.Status(r, http.StatusUnprocessableEntity)
+		renderer.PrettyJSON(w, r, fmt.Sprintf("The macro assigns tickets to user %d, who is not an agent", id))
+		return sql.NullInt64{}, false
+	}
+	return sql.NullInt64{Int64: user.ID, Valid: true}, true
+}
+
+// lookupUser loads a user for a macro's placeholders. A user who cannot be loaded leaves their
+// placeholders empty rather than failing the request.
+func (h *MacroHandler) lookupUser(ctx context.Context, id sql.NullInt64) *models.User {
+	if !id.Valid {
+		return nil
+	}
+	user, err := models.GetUserByID(h.db, ctx, id.Int64)
+	if err != nil {
+		fmt.Printf("Error fetching user %d for macro: %v\n", id.Int64, err)
+		return nil
+	}
+	return user
+}
+
+// ownMacro loads the macro named in the URL if the caller owns it or is an admin.
+func (h *MacroHandler) ownMacro(w http.ResponseWriter, r *http.Request, verb string) (*models.Macro, bool) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return nil, false
+	}
+	macro, ok := h.visibleMacro(w, r, userID, "id")
+	if !ok {
+		return nil, false
+	}
+	if macro.OwnerID != userID && currentUserRole(r) != "Admin" {
+		render.Status(r, http.StatusForbidden)
+		renderer.PrettyJSON(w, r, "You are not authorized to "+verb+" this macro")
+		return nil, false
+	}
+	return macro, true
+}
+
+// visibleMacro loads the macro named by the URL parameter if the user owns it, it is shared with
+// everyone or with one of their teams, or the user is an admin.
+func (h *MacroHandler) visibleMacro(w http.ResponseWriter, r *http.Request, userID int64, param string) (*models.Macro, bool) {
+	id, ok := urlParamID(w, r, param, "macro")
+	if !ok {
+		return nil, false
+	}
+
+	macro, err := models.GetMacroByID(h.db, r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "Macro not found")
+			return nil, false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return nil, false
+	}
+
+	visible := macro.OwnerID == userID || macro.Shared || currentUserRole(r) == "Admin"
+	if !visible && macro.TeamID.Valid {
+		teamIDs, err := models.ListTeamIDsByUserID(h.db, r.Context(), userID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			renderer.PrettyJSON(w, r, err.Error())
+			return nil, false
+		}
+		visible = slices.Contains(teamIDs, macro.TeamID.Int64)
+	}
+	if !visible {
+		render.Status(r, http.StatusForbidden)
+		renderer.PrettyJSON(w, r, "You are not authorized to use this macro")
+		return nil, false
+	}
+	return macro, true
+}
+
+// renderMacroError writes the response for a failed macro write. A team that does not exist is
+// the client's mistake.
+func renderMacroError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, models.ErrReference) {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Team not found")
+		return
+	}
+	render.Status(r, http.StatusInternalServerError)
+	renderer.PrettyJSON(w, r, err.Error())
+}
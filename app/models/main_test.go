@@ -0,0 +1,133 @@
+package models_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/uptrace/bun"
+
+	"goat/app/middleware"
+	"goat/app/models"
+	"goat/services/config"
+	"goat/services/migrate"
+	model "goat/services/models"
+	"goat/services/notify"
+)
+
+// testServer serves the ticket and comment handlers over in-memory repositories. Only the
+// categories, companies and audit log are kept in a SQLite database, as in document mode.
+type testServer struct {
+	router http.Handler
+	repos  model.Repositories
+	db     *bun.DB
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "goat.db"))
+	db := config.ConnectDB()
+	t.Cleanup(func() { db.Close() })
+	if _, err := migrate.Up(context.Background(), db, 0); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	repos := model.NewMemoryRepositories()
+	tickets := models.NewTicketHandler(db, repos)
+	comments := models.NewCommentHandler(db, repos, notify.LogNotifier{}, time.Hour)
+
+	r := chi.NewRouter()
+	r.Use(testAuth)
+	r.Post("/admin/tickets", tickets.CreateTicket)
+	r.Get("/admin/tickets/{id}", tickets.GetTicket)
+	r.Put("/admin/tickets/{id}", tickets.UpdateTicket)
+	r.Delete("/admin/tickets/{id}", tickets.DeleteTicket)
+	r.Get("/admin/comments/ticket/{id}", comments.ListCommentsByTicketID)
+	r.Get("/agent/tickets/{id}", tickets.GetAgentTicket)
+	r.Post("/agent/tickets/{id}/comments", comments.CreateAgentComment)
+	r.Post("/customer/tickets", tickets.CreateCustomerTicket)
+	r.Get("/customer/tickets/{id}", tickets.GetCustomerTicket)
+	r.Post("/customer/tickets/{id}/comments", comments.CreateCustomerComment)
+	r.Put("/customer/comments/{id}", comments.EditComment)
+	r.Delete("/customer/comments/{id}", comments.DeleteComment)
+
+	return &testServer{router: r, repos: repos, db: db}
+}
+
+// testAuth stands in for AuthMiddleware, taking the user from the X-Test-User and X-Test-Role
+// headers. Every request is for the default organization.
+func testAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := model.WithTenant(r.Context(), model.DefaultTenantID)
+		if id := r.Header.Get("X-Test-User"); id != "" {
+			ctx = context.WithValue(ctx, middleware.UserIDKey, id)
+			ctx = context.WithValue(ctx, middleware.UserRoleKey, r.Header.Get("X-Test-Role"))
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// do sends a request on behalf of user with body encoded as JSON. header holds extra header
+// name and value pairs.
+func (s *testServer) do(t *testing.T, user *model.User, method, path string, body any, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("encode request: %v", err)
+		}
+	}
+	r := httptest.NewRequest(method, path, &buf)
+	r.Header.Set("Content-Type", "application/json")
+	if user != nil {
+		r.Header.Set("X-Test-User", strconv.FormatInt(user.ID, 10))
+		r.Header.Set("X-Test-Role", user.Role)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, r)
+	return w
+}
+
+var testUsers atomic.Int64
+
+// newUser stores a user of the given role in the repositories, in the company with the ID unless
+// it is 0.
+func (s *testServer) newUser(t *testing.T, role string, companyID int64) *model.User {
+	t.Helper()
+	user := &model.User{Name: role, Role: role, PasswordHash: "x"}
+	user.Email = fmt.Sprintf("%s-%d@example.com", role, testUsers.Add(1))
+	if companyID != 0 {
+		user.CompanyID = sql.NullInt64{Int64: companyID, Valid: true}
+	}
+	if err := s.repos.Users.Create(model.WithTenant(context.Background(), model.DefaultTenantID), user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// decode reads a JSON response into v, failing the test unless it has the wanted status.
+func decode(t *testing.T, w *httptest.ResponseRecorder, status int, v any) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("got status %d, want %d: %s", w.Code, status, w.Body.String())
+	}
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+}
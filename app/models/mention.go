@@ -0,0 +1,113 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"goat/app/middleware"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/render"
+
+	"goat/app/renderer"
+	"goat/services/models"
+	"goat/services/notify"
+)
+
+// ListMentions handles the request to list the comments mentioning the current user, newest first.
+// Customers only see public comments.
+func (h *CommentHandler) ListMentions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+	userRole, ok := r.Context().Value(middleware.UserRoleKey).(string)
+	if !ok {
+		render.Status(r, http.StatusUnauthorized)
+		renderer.PrettyJSON(w, r, "Unauthorized")
+		return
+	}
+
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+	if len(page.Sort) == 0 {
+		page.Sort = []models.SortKey{{Column: "created_at", Desc: true}}
+	}
+
+	comments, err := models.ListMentions(h.related, r.Context(), userID, userRole == "Customer", page)
+	if err != nil {
+		renderListError(w, r, err)
+		return
+	}
+
+	renderPage(w, r, comments)
+}
+
+// recordMentions stores the users newly mentioned in a created or edited comment who can see it,
+// fills in the comment's Mentions and notifies them. Handles that name nobody, or someone who
+// cannot see the comment, are left as plain text. Failures are logged and do not fail the request.
+// Mentions are only recorded for comments stored in the SQL database.
+func (h *CommentHandler) recordMentions(r *http.Request, ticket *models.Ticket, comment *models.Comment) {
+	if h.related == nil {
+		return
+	}
+	ctx := r.Context()
+	users, err := models.ResolveMentions(h.related, ctx, models.ParseMentions(comment.Body))
+	if err != nil {
+		fmt.Printf("Error resolving mentions in comment %d: %v\n", comment.ID, err)
+		return
+	}
+	mentioned, err := models.ListMentionedUserIDs(h.related, ctx, comment.ID)
+	if err != nil {
+		fmt.Printf("Error loading mentions in comment %d: %v\n", comment.ID, err)
+		return
+	}
+
+	var userIDs []int64
+	for _, user := range users {
+		if slices.Contains(mentioned, user.ID) {
+			continue
+		}
+		allowed, err := h.canSeeComment(ctx, ticket, comment, &user)
+		if err != nil {
+			fmt.Printf("Error checking access of user %d to comment %d: %v\n", user.ID, comment.ID, err)
+			continue
+		}
+		if allowed && user.ID != comment.AuthorID {
+			userIDs = append(userIDs, user.ID)
+		}
+	}
+	if len(userIDs) == 0 {
+		return
+	}
+
+	if err := models.CreateMentions(h.related, ctx, comment.ID, userIDs); err != nil {
+		fmt.Printf("Error saving mentions in comment %d: %v\n", comment.ID, err)
+		return
+	}
+	comment.Mentions = userIDs
+
+	n := notify.Notification{
+		UserIDs:  userIDs,
+		TicketID: ticket.ID,
+		Subject:  fmt.Sprintf("You were mentioned on ticket #%d", ticket.ID),
+		Body:     comment.Body,
+	}
+	if err := h.notifier.Notify(ctx, n); err != nil {
+		fmt.Printf("Error sending notification for ticket %d: %v\n", ticket.ID, err)
+	}
+}
+
+// canSeeComment reports whether a user may read a comment: staff see every comment, customers
+// only the public comments of tickets they can access.
+func (h *CommentHandler) canSeeComment(ctx context.Context, ticket *models.Ticket, comment *models.Comment, user *models.User) (bool, error) {
+	if user.Role != "Customer" {
+		return true, nil
+	}
+	if comment.IsInternal {
+		return false, nil
+	}
+	return canAccessCustomerTicket(h.db, h.related, h.users, ctx, ticket, user.ID)
+}
@@ -0,0 +1,214 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+
+	"goat/app/renderer"
+	"goat/services/models"
+	"goat/services/notify"
+)
+
+type MergeHandler struct {
+	db       *bun.DB
+	tickets  models.TicketRepository
+	notifier notify.Notifier
+}
+
+func NewMergeHandler(db *bun.DB, repos models.Repositories, notifier notify.Notifier) *MergeHandler {
+	return &MergeHandler{db: db, tickets: repos.Tickets, notifier: notifier}
+}
+
+// MergeTickets handles the request to merge other tickets into the ticket in the URL.
+// The sources are closed and their comments move to the target. If-Match must hold the target's
+// ETag.
+func (h *MergeHandler) MergeTickets(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+	targetID, ok := urlParamID(w, r, "id", "ticket")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		SourceIDs []int64 `json:"source_ids"`
+	}
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	sourceIDs := slices.Compact(slices.Sorted(slices.Values(req.SourceIDs)))
+	if len(sourceIDs) == 0 {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "source_ids is required")
+		return
+	}
+	if slices.Contains(sourceIDs, targetID) {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, models.ErrMergeIntoSelf.Error())
+		return
+	}
+
+	tickets, ok := h.loadTickets(w, r, append([]int64{targetID}, sourceIDs...))
+	if !ok || !checkTicketIfMatch(w, r, tickets[0]) {
+		return
+	}
+
+	err := models.MergeTickets(h.db, r.Context(), targetID, tickets[0].Version, sourceIDs, auditOrigin(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrVersionConflict):
+			h.renderTicketConflict(w, r, targetID)
+		case errors.Is(err, sql.ErrNoRows):
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "Ticket not found")
+		case errors.Is(err, models.ErrMergeClosed), errors.Is(err, models.ErrAlreadyMerged):
+			render.Status(r, http.StatusConflict)
+			renderer.PrettyJSON(w, r, err.Error())
+		default:
+			render.Status(r, http.StatusInternalServerError)
+			renderer.PrettyJSON(w, r, err.Error())
+		}
+		return
+	}
+	models.SyncChanges(h.tickets, r.Context(), models.RowChanges{TicketIDs: append([]int64{targetID}, sourceIDs...)})
+
+	notifyTickets(h.db, h.notifier, r, tickets, actorID, targetID, false,
+		fmt.Sprintf("Tickets merged into #%d", targetID),
+		fmt.Sprintf("Tickets %v were merged into ticket #%d. Please continue the conversation there.", sourceIDs, targetID))
+
+	target, err := models.GetTicketByID(h.db, r.Context(), targetID)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	w.Header().Set("ETag", ticketETag(target))
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, target)
+}
+
+// SplitTicket handles the request to create a new ticket from selected comments of the ticket in the URL.
+// If-Match must hold the ticket's ETag.
+func (h *MergeHandler) SplitTicket(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+	id, ok := urlParamID(w, r, "id", "ticket")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		CommentIDs  []int64 `json:"comment_ids"`
+		Title       string  `json:"title"`
+		Description string  `json:"description"`
+	}
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	commentIDs := slices.Compact(slices.Sorted(slices.Values(req.CommentIDs)))
+	if len(commentIDs) == 0 || req.Title == "" {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "comment_ids and title are required")
+		return
+	}
+
+	tickets, ok := h.loadTickets(w, r, []int64{id})
+	if !ok || !checkTicketIfMatch(w, r, tickets[0]) {
+		return
+	}
+	source := tickets[0]
+
+	// The new ticket keeps the requester and classification of the ticket it was split from.
+	ticket := models.Ticket{
+		Title:       req.Title,
+		Description: req.Description,
+		Type:        source.Type,
+		Status:      "Open",
+		Priority:    source.Priority,
+		RequesterID: source.RequesterID,
+		AssigneeID:  source.AssigneeID,
+		CategoryID:  source.CategoryID,
+	}
+
+	if err := models.SplitTicket(h.db, r.Context(), id, source.Version, commentIDs, &ticket, auditOrigin(r)); err != nil {
+		if errors.Is(err, models.ErrVersionConflict) {
+			h.renderTicketConflict(w, r, id)
+			return
+		}
+		if errors.Is(err, models.ErrCommentNotOnTicket) {
+			render.Status(r, http.StatusBadRequest)
+			renderer.PrettyJSON(w, r, err.Error())
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	models.SyncChanges(h.tickets, r.Context(), models.RowChanges{TicketIDs: []int64{id, ticket.ID}})
+
+	notifyTickets(h.db, h.notifier, r, tickets, actorID, ticket.ID, false,
+		fmt.Sprintf("Ticket #%d was split", id),
+		fmt.Sprintf("Part of ticket #%d continues in the new ticket #%d.", id, ticket.ID))
+
+	created, err := models.GetTicketByID(h.db, r.Context(), ticket.ID)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	w.Header().Set("ETag", ticketETag(created))
+	render.Status(r, http.StatusCreated)
+	renderer.PrettyJSON(w, r, created)
+}
+
+// renderTicketConflict responds with 412 Precondition Failed and the current version of a ticket
+// that changed after it was read.
+func (h *MergeHandler) renderTicketConflict(w http.ResponseWriter, r *http.Request, id int64) {
+	ticket, err := models.GetTicketByID(h.db, r.Context(), id)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	renderVersionConflict(w, r, ticket)
+}
+
+// loadTickets loads every listed ticket, writing a 404 response if any of them does not exist.
+func (h *MergeHandler) loadTickets(w http.ResponseWriter, r *http.Request, ids []int64) ([]*models.Ticket, bool) {
+	tickets := make([]*models.Ticket, len(ids))
+	for i, id := range ids {
+		ticket, err := models.GetTicketByID(h.db, r.Context(), id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				render.Status(r, http.StatusNotFound)
+				renderer.PrettyJSON(w, r, fmt.Sprintf("Ticket %d not found", id))
+				return nil, false
+			}
+			render.Status(r, http.StatusInternalServerError)
+			renderer.PrettyJSON(w, r, err.Error())
+			return nil, false
+		}
+		tickets[i] = ticket
+	}
+	return tickets, true
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/uptrace/bun"
+
+	"goat/services/models"
+	"goat/services/notify"
+)
+
+// notifyTickets notifies everyone following the given tickets about ticketID: their assignees and
+// watchers and, unless the notification is internal, their requesters and CCs. The actor is left
+// out. related holds the watchers and CCs, nil when tickets are not stored in the SQL database.
+// Delivery failures are logged and do not fail the request.
+func notifyTickets(related *bun.DB, notifier notify.Notifier, r *http.Request, tickets []*models.Ticket, actorID, ticketID int64, internal bool, subject, body string) {
+	var userIDs, ticketIDs []int64
+	for _, t := range tickets {
+		ticketIDs = append(ticketIDs, t.ID)
+		if !internal {
+			userIDs = append(userIDs, t.RequesterID)
+		}
+		if t.AssigneeID.Valid {
+			userIDs = append(userIDs, t.AssigneeID.Int64)
+		}
+	}
+
+	var emails []string
+	tokens := map[string]string{}
+	if related != nil {
+		subscriberIDs, contacts, err := models.ListTicketSubscribers(related, r.Context(), ticketIDs, internal)
+		if err != nil {
+			fmt.Printf("Error loading subscribers of ticket %d: %v\n", ticketID, err)
+		}
+		userIDs = append(userIDs, subscriberIDs...)
+		for _, cc := range contacts {
+			// A contact copied on several tickets claims the one the notification is about.
+			if _, ok := tokens[cc.Email]; !ok || cc.TicketID == ticketID {
+				tokens[cc.Email] = cc.ClaimToken
+			}
+			emails = append(emails, cc.Email)
+		}
+	}
+	userIDs = slices.DeleteFunc(slices.Compact(slices.Sorted(slices.Values(userIDs))), func(id int64) bool { return id == actorID })
+	emails = slices.Compact(slices.Sorted(slices.Values(emails)))
+	if len(userIDs) == 0 && len(emails) == 0 {
+		return
+	}
+
+	n := notify.Notification{UserIDs: userIDs, Emails: emails, ClaimTokens: tokens, TicketID: ticketID, Subject: subject, Body: body}
+	if err := notifier.Notify(r.Context(), n); err != nil {
+		fmt.Printf("Error sending notification for ticket %d: %v\n", ticketID, err)
+	}
+}
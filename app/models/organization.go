@@ -0,0 +1,214 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+	"golang.org/x/crypto/bcrypt"
+
+	"goat/app/renderer"
+	"goat/services/models"
+)
+
+// OrganizationHandler serves the super-admin scope, which manages organizations and their users
+// across every organization.
+type OrganizationHandler struct {
+	db    *bun.DB
+	users models.UserRepository
+}
+
+func NewOrganizationHandler(db *bun.DB, repos models.Repositories) *OrganizationHandler {
+	return &OrganizationHandler{db: db, users: repos.Users}
+}
+
+// organizationRequest is the body of the requests creating or changing an organization.
+type organizationRequest struct {
+	Name string `json:"name"`
+	Host string `json:"host"` // Hostname the organization is served on, empty for none
+}
+
+// ListOrganizations handles the request to list every organization.
+func (h *OrganizationHandler) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	orgs, err := models.ListOrganizations(h.db, r.Context())
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, orgs)
+}
+
+// CreateOrganization handles the request to add an organization.
+func (h *OrganizationHandler) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var req organizationRequest
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if req.Name == "" {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Organization name is required")
+		return
+	}
+
+	org := models.Organization{Name: req.Name, Host: strings.ToLower(req.Host)}
+	if err := models.CreateOrganization(h.db, r.Context(), &org); err != nil {
+		renderOrganizationError(w, r, err)
+		return
+	}
+	recordAudit(h.db, r, models.AuditCreate, "organization", org.ID, nil, org)
+
+	render.Status(r, http.StatusCreated)
+	renderer.PrettyJSON(w, r, org)
+}
+
+// GetOrganization handles the request to get an organization.
+func (h *OrganizationHandler) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.organization(w, r)
+	if !ok {
+		return
+	}
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, org)
+}
+
+// UpdateOrganization handles the request to rename an organization or change its hostname.
+func (h *OrganizationHandler) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.organization(w, r)
+	if !ok {
+		return
+	}
+
+	var req organizationRequest
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if req.Name == "" {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Organization name is required")
+		return
+	}
+
+	before := *org
+	org.Name = req.Name
+	org.Host = strings.ToLower(req.Host)
+	if err := models.UpdateOrganization(h.db, r.Context(), org); err != nil {
+		renderOrganizationError(w, r, err)
+		return
+	}
+	recordAudit(h.db, r, models.AuditUpdate, "organization", org.ID, before, org)
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, org)
+}
+
+// ListOrganizationUsers handles the request to list the users of an organization.
+func (h *OrganizationHandler) ListOrganizationUsers(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.organization(w, r)
+	if !ok {
+		return
+	}
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+	users, err := h.users.List(models.WithTenant(r.Context(), org.ID), page)
+	if err != nil {
+		renderListError(w, r, err)
+		return
+	}
+	renderPage(w, r, users)
+}
+
+// CreateOrganizationUser handles the request to add a user, by default an admin, to an organization.
+// This is how a new organization gets its first admin.
+func (h *OrganizationHandler) CreateOrganizationUser(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.organization(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+		Password string `json:"password"`
+		Role     string `json:"role"`
+	}
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if req.Email == "" || req.Password == "" {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Email and password are required")
+		return
+	}
+	if req.Role == "" {
+		req.Role = "Admin"
+	}
+	if req.Role == superAdminRole {
+		render.Status(r, http.StatusForbidden)
+		renderer.PrettyJSON(w, r, "Super admins can only be appointed with the superadmin command")
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, "Failed to hash password")
+		return
+	}
+
+	// The user and its audit entry belong to the organization, not to the super admin's scope.
+	r = r.WithContext(models.WithTenant(r.Context(), org.ID))
+	user := &models.User{Name: req.Name, Email: req.Email, PasswordHash: string(hashedPassword), Role: req.Role}
+	if err := h.users.Create(r.Context(), user); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditCreate, "user", user.ID, nil, user)
+
+	render.Status(r, http.StatusCreated)
+	renderer.PrettyJSON(w, r, user)
+}
+
+// organization loads the organization named by the id URL parameter, writing an error response
+// when it is invalid or missing.
+func (h *OrganizationHandler) organization(w http.ResponseWriter, r *http.Request) (*models.Organization, bool) {
+	id, ok := urlParamID(w, r, "id", "organization")
+	if !ok {
+		return nil, false
+	}
+	org, err := models.GetOrganizationByID(h.db, r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "Organization not found")
+			return nil, false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return nil, false
+	}
+	return org, true
+}
+
+func renderOrganizationError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, models.ErrDuplicate) {
+		render.Status(r, http.StatusConflict)
+		renderer.PrettyJSON(w, r, "Another organization is already served on this host")
+		return
+	}
+	render.Status(r, http.StatusInternalServerError)
+	renderer.PrettyJSON(w, r, err.Error())
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/render"
+
+	"goat/app/renderer"
+	"goat/services/models"
+)
+
+// parsePageRequest reads the limit, cursor, sort and count query parameters shared by list endpoints.
+func parsePageRequest(w http.ResponseWriter, r *http.Request) (models.PageRequest, bool) {
+	params := r.URL.Query()
+	page := models.PageRequest{
+		Cursor:    params.Get("cursor"),
+		Sort:      models.ParseSort(params.Get("sort")),
+		WithTotal: params.Get("count") == "true",
+	}
+
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > models.MaxPageLimit {
+			render.Status(r, http.StatusBadRequest)
+			renderer.PrettyJSON(w, r, "Invalid limit, expected 1-"+strconv.Itoa(models.MaxPageLimit))
+			return page, false
+		}
+		page.Limit = limit
+	}
+	return page, true
+}
+
+// renderPage writes a page of items, linking to the next page and reporting the total count when requested.
+func renderPage[T any](w http.ResponseWriter, r *http.Request, page *models.Page[T]) {
+	if page.NextCursor != "" {
+		next := url.URL{Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+		params := next.Query()
+		params.Set("cursor", page.NextCursor)
+		next.RawQuery = params.Encode()
+		w.Header().Set("Link", "<"+next.String()+`>; rel="next"`)
+	}
+	if page.Total != nil {
+		w.Header().Set("X-Total-Count", strconv.Itoa(*page.Total))
+	}
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, page.Items)
+}
+
+// renderListError maps errors from paginated list queries to responses.
+func renderListError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, models.ErrInvalidCursor) || errors.Is(err, models.ErrInvalidSort) {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if errors.Is(err, models.ErrFilterUnsupported) {
+		render.Status(r, http.StatusNotImplemented)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	render.Status(r, http.StatusInternalServerError)
+	renderer.PrettyJSON(w, r, err.Error())
+}
@@ -0,0 +1,359 @@
+package models
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+
+	"goat/app/renderer"
+	"goat/services/models"
+)
+
+type ParticipantHandler struct {
+	db      *bun.DB
+	tickets models.TicketRepository
+	users   models.UserRepository
+}
+
+func NewParticipantHandler(db *bun.DB, repos models.Repositories) *ParticipantHandler {
+	return &ParticipantHandler{db: db, tickets: repos.Tickets, users: repos.Users}
+}
+
+// participants is the response listing who follows a ticket.
+type participants struct {
+	Watchers []int64           `json:"Watchers"`
+	CCs      []models.TicketCC `json:"CCs"`
+}
+
+// AddTicketWatcher handles the request to subscribe an agent or admin to a ticket, by default the
+// current user, e.g. {"user_id": 7}.
+func (h *ParticipantHandler) AddTicketWatcher(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+	id, ok := urlParamID(w, r, "id", "ticket")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		UserID int64 `json:"user_id"`
+	}
+
+	if r.ContentLength != 0 {
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			render.Status(r, http.StatusBadRequest)
+			renderer.PrettyJSON(w, r, err.Error())
+			return
+		}
+	}
+	if req.UserID == 0 {
+		req.UserID = actorID
+	}
+
+	if _, ok := h.loadTicket(w, r, id); !ok {
+		return
+	}
+	user, ok := h.loadUser(w, r, req.UserID)
+	if !ok {
+		return
+	}
+	if user.Role != "Admin" && user.Role != "Agent" {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Only agents and admins can watch a ticket, customers are CC'd")
+		return
+	}
+
+	if err := models.AddTicketWatcher(h.db, r.Context(), id, user.ID); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditUpdate, "ticket", id, map[string]any{"Watcher": nil}, map[string]any{"Watcher": user.ID})
+
+	h.renderParticipants(w, r, id, http.StatusCreated)
+}
+
+// RemoveTicketWatcher handles the request to unsubscribe a user from a ticket.
+func (h *ParticipantHandler) RemoveTicketWatcher(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "id", "ticket")
+	if !ok {
+		return
+	}
+	userID, ok := urlParamID(w, r, "userID", "user")
+	if !ok {
+		return
+	}
+
+	watching, err := models.IsWatchingTicket(h.db, r.Context(), id, userID)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if !watching {
+		render.Status(r, http.StatusNotFound)
+		renderer.PrettyJSON(w, r, "Watcher not found")
+		return
+	}
+
+	if err := models.RemoveTicketWatcher(h.db, r.Context(), id, userID); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditUpdate, "ticket", id, map[string]any{"Watcher": userID}, map[string]any{"Watcher": nil})
+
+	h.renderParticipants(w, r, id, http.StatusOK)
+}
+
+// AddTicketCC handles the request to copy a customer, or a contact known only by email, on a
+// ticket, e.g. {"user_id": 12} or {"email": "ops@example.com"}.
+func (h *ParticipantHandler) AddTicketCC(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "id", "ticket")
+	if !ok {
+		return
+	}
+	if _, ok := h.loadTicket(w, r, id); !ok {
+		return
+	}
+	h.addCC(w, r, id)
+}
+
+// RemoveTicketCC handles the request to remove a CC from a ticket.
+func (h *ParticipantHandler) RemoveTicketCC(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "id", "ticket")
+	if !ok {
+		return
+	}
+	ccID, ok := urlParamID(w, r, "ccID", "CC")
+	if !ok {
+		return
+	}
+
+	ccs, err := models.ListTicketCCs(h.db, r.Context(), id)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	for _, cc := range ccs {
+		if cc.ID == ccID {
+			h.removeCC(w, r, &cc)
+			return
+		}
+	}
+	render.Status(r, http.StatusNotFound)
+	renderer.PrettyJSON(w, r, "CC not found")
+}
+
+// AddCustomerTicketCC handles the request of a ticket's requester to copy someone on it.
+func (h *ParticipantHandler) AddCustomerTicketCC(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+	id, ok := urlParamID(w, r, "id", "ticket")
+	if !ok {
+		return
+	}
+
+	ticket, ok := h.loadTicket(w, r, id)
+	if !ok {
+		return
+	}
+	if ticket.RequesterID != userID {
+		render.Status(r, http.StatusForbidden)
+		renderer.PrettyJSON(w, r, "Only the requester can copy others on this ticket")
+		return
+	}
+	h.addCC(w, r, id)
+}
+
+// LeaveCustomerTicket handles the request of a CC'd customer to stop following a ticket.
+func (h *ParticipantHandler) LeaveCustomerTicket(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+	id, ok := urlParamID(w, r, "id", "ticket")
+	if !ok {
+		return
+	}
+
+	cc, err := models.GetTicketCCForUser(h.db, r.Context(), id, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "You are not copied on this ticket")
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	h.removeCC(w, r, cc)
+}
+
+// ClaimTicketCC handles the request of a customer to claim the CC of their email address with the
+// token sent to it, e.g. {"token": "..."}, so that they can open the ticket under their account.
+func (h *ParticipantHandler) ClaimTicketCC(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if req.Token == "" {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "token is required")
+		return
+	}
+	user, ok := h.loadUser(w, r, userID)
+	if !ok {
+		return
+	}
+	if user.Role != "Customer" {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Only customers can be CC'd, agents and admins watch a ticket")
+		return
+	}
+
+	before, err := models.ClaimTicketCC(h.db, r.Context(), req.Token, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "Invalid or already used token")
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	after, err := models.GetTicketCCForUser(h.db, r.Context(), before.TicketID, userID)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditUpdate, "ticket", before.TicketID, map[string]any{"CC": before}, map[string]any{"CC": after})
+
+	h.renderParticipants(w, r, before.TicketID, http.StatusOK)
+}
+
+// addCC copies the user or email in the request body on a ticket. An email is copied as a contact
+// even when a customer registered it, since that does not prove owning it; the contact claims the
+// CC for their account with the token their notifications carry.
+func (h *ParticipantHandler) addCC(w http.ResponseWriter, r *http.Request, ticketID int64) {
+	var req struct {
+		UserID int64  `json:"user_id"`
+		Email  string `json:"email"`
+	}
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	cc := models.TicketCC{TicketID: ticketID}
+	switch {
+	case req.UserID != 0:
+		user, ok := h.loadUser(w, r, req.UserID)
+		if !ok {
+			return
+		}
+		if user.Role != "Customer" {
+			render.Status(r, http.StatusBadRequest)
+			renderer.PrettyJSON(w, r, "Only customers can be CC'd, agents and admins watch a ticket")
+			return
+		}
+		cc.UserID = sql.NullInt64{Int64: user.ID, Valid: true}
+	case strings.Contains(req.Email, "@"):
+		cc.Email = req.Email
+	default:
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "user_id or a valid email is required")
+		return
+	}
+
+	if err := models.AddTicketCC(h.db, r.Context(), &cc); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditUpdate, "ticket", ticketID, map[string]any{"CC": nil}, map[string]any{"CC": cc})
+
+	h.renderParticipants(w, r, ticketID, http.StatusCreated)
+}
+
+// removeCC removes a CC from its ticket.
+func (h *ParticipantHandler) removeCC(w http.ResponseWriter, r *http.Request, cc *models.TicketCC) {
+	if err := models.RemoveTicketCC(h.db, r.Context(), cc.TicketID, cc.ID); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditUpdate, "ticket", cc.TicketID, map[string]any{"CC": cc}, map[string]any{"CC": nil})
+
+	h.renderParticipants(w, r, cc.TicketID, http.StatusOK)
+}
+
+// loadTicket loads a ticket, writing a 404 response if it does not exist.
+func (h *ParticipantHandler) loadTicket(w http.ResponseWriter, r *http.Request, id int64) (*models.Ticket, bool) {
+	ticket, err := h.tickets.GetByID(r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "Ticket not found")
+			return nil, false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return nil, false
+	}
+	return ticket, true
+}
+
+// loadUser loads a user, writing a 404 response if they do not exist.
+func (h *ParticipantHandler) loadUser(w http.ResponseWriter, r *http.Request, id int64) (*models.User, bool) {
+	user, err := h.users.GetByID(r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "User not found")
+			return nil, false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return nil, false
+	}
+	return user, true
+}
+
+// renderParticipants responds with the current watchers and CCs of a ticket.
+func (h *ParticipantHandler) renderParticipants(w http.ResponseWriter, r *http.Request, ticketID int64, status int) {
+	var p participants
+	var err error
+	if p.Watchers, err = models.ListTicketWatcherIDs(h.db, r.Context(), ticketID); err == nil {
+		p.CCs, err = models.ListTicketCCs(h.db, r.Context(), ticketID)
+	}
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	render.Status(r, status)
+	renderer.PrettyJSON(w, r, p)
+}
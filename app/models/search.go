@@ -0,0 +1,133 @@
+package models
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+
+	"goat/app/renderer"
+	"goat/services/models"
+	"goat/services/search"
+)
+
+type SearchHandler struct {
+	db       *bun.DB
+	users    models.UserRepository
+	searcher search.Searcher
+}
+
+func NewSearchHandler(db *bun.DB, repos models.Repositories, searcher search.Searcher) *SearchHandler {
+	return &SearchHandler{db: db, users: repos.Users, searcher: searcher}
+}
+
+// SearchTickets handles full-text ticket search for admins and agents, including internal comments.
+func (h *SearchHandler) SearchTickets(w http.ResponseWriter, r *http.Request) {
+	q, ok := parseSearchQuery(w, r)
+	if !ok {
+		return
+	}
+	q.IncludeInternal = true
+
+	h.search(w, r, q)
+}
+
+// SearchCustomerTickets handles full-text search restricted to public comments and the tickets the
+// caller may open: their own, those they are copied on and those shared by their company.
+func (h *SearchHandler) SearchCustomerTickets(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	q, ok := parseSearchQuery(w, r)
+	if !ok {
+		return
+	}
+	visible, err := customerTickets(h.db, h.users, r.Context(), userID)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	q.Visible = visible
+	q.IncludeInternal = false
+
+	h.search(w, r, q)
+}
+
+func (h *SearchHandler) search(w http.ResponseWriter, r *http.Request, q search.Query) {
+	results, err := h.searcher.SearchTickets(r.Context(), q)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, results)
+}
+
+// parseSearchQuery reads the search text and structured filters from the query string.
+func parseSearchQuery(w http.ResponseWriter, r *http.Request) (search.Query, bool) {
+	params := r.URL.Query()
+	q := search.Query{
+		Text:     params.Get("q"),
+		Status:   params.Get("status"),
+		Priority: params.Get("priority"),
+	}
+
+	if q.Text == "" {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Missing search text (q)")
+		return q, false
+	}
+
+	ids := map[string]*int64{"assignee_id": &q.AssigneeID, "requester_id": &q.RequesterID}
+	for name, dst := range ids {
+		if v := params.Get(name); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				render.Status(r, http.StatusBadRequest)
+				renderer.PrettyJSON(w, r, "Invalid "+name)
+				return q, false
+			}
+			*dst = id
+		}
+	}
+
+	dates := map[string]*time.Time{"created_after": &q.CreatedAfter, "created_before": &q.CreatedBefore}
+	for name, dst := range dates {
+		if v := params.Get(name); v != "" {
+			t, err := parseDate(v)
+			if err != nil {
+				render.Status(r, http.StatusBadRequest)
+				renderer.PrettyJSON(w, r, "Invalid "+name+", expected YYYY-MM-DD or RFC 3339")
+				return q, false
+			}
+			*dst = t
+		}
+	}
+
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 100 {
+			render.Status(r, http.StatusBadRequest)
+			renderer.PrettyJSON(w, r, "Invalid limit, expected 1-100")
+			return q, false
+		}
+		q.Limit = limit
+	}
+
+	return q, true
+}
+
+// parseDate accepts a plain date or an RFC 3339 timestamp.
+func parseDate(v string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
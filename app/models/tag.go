@@ -0,0 +1,230 @@
+package models
+
+import (
+	"database/sql"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+
+	"goat/app/renderer"
+	"goat/services/models"
+)
+
+type TagHandler struct {
+	db *bun.DB
+}
+
+func NewTagHandler(db *bun.DB) *TagHandler {
+	return &TagHandler{db: db}
+}
+
+// ListTagStats handles the request to list every tag with its usage count.
+func (h *TagHandler) ListTagStats(w http.ResponseWriter, r *http.Request) {
+	tags, err := models.ListTagStats(h.db, r.Context())
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, tags)
+}
+
+// CreateTag handles the request to add a curated tag suggestion.
+func (h *TagHandler) CreateTag(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	tag, err := models.CreateSuggestedTag(h.db, r.Context(), req.Name)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if tag == nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Tag name is required")
+		return
+	}
+
+	recordAudit(h.db, r, models.AuditCreate, "tag", tag.ID, nil, tag)
+
+	render.Status(r, http.StatusCreated)
+	renderer.PrettyJSON(w, r, tag)
+}
+
+// DeleteTag handles the request to delete a tag from every ticket.
+func (h *TagHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "id", "tag")
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteTag(h.db, r.Context(), id); err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "Tag not found")
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditDelete, "tag", id, map[string]any{"ID": id}, nil)
+
+	render.Status(r, http.StatusAccepted)
+	renderer.PrettyJSON(w, r, map[string]string{"message": "Tag deleted successfully"})
+}
+
+// SuggestTags handles the request to autocomplete tag names, curated suggestions first.
+func (h *TagHandler) SuggestTags(w http.ResponseWriter, r *http.Request) {
+	limit := 10
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			render.Status(r, http.StatusBadRequest)
+			renderer.PrettyJSON(w, r, "Invalid limit, expected 1-100")
+			return
+		}
+		limit = n
+	}
+
+	tags, err := models.SuggestTags(h.db, r.Context(), r.URL.Query().Get("prefix"), limit)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, tags)
+}
+
+// UpdateTicketTags handles the request to add and remove tags on a single ticket.
+func (h *TagHandler) UpdateTicketTags(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "id", "ticket")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Add    []string `json:"add"`
+		Remove []string `json:"remove"`
+	}
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	if !h.applyTagChanges(w, r, []int64{id}, req.Add, req.Remove) {
+		return
+	}
+
+	tags, err := models.ListTagNamesByTicketID(h.db, r.Context(), id)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, tags)
+}
+
+// BulkUpdateTicketTags handles the request to add and remove tags on several tickets at once.
+func (h *TagHandler) BulkUpdateTicketTags(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TicketIDs []int64  `json:"ticket_ids"`
+		Add       []string `json:"add"`
+		Remove    []string `json:"remove"`
+	}
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	if len(req.TicketIDs) == 0 {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "ticket_ids is required")
+		return
+	}
+
+	if !h.applyTagChanges(w, r, req.TicketIDs, req.Add, req.Remove) {
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, map[string]string{"message": "Tags updated successfully"})
+}
+
+// applyTagChanges checks that every ticket exists, then removes and adds the given tags.
+// The resulting tag list of every ticket is recorded in the audit log.
+func (h *TagHandler) applyTagChanges(w http.ResponseWriter, r *http.Request, ticketIDs []int64, add, remove []string) bool {
+	ticketIDs = slices.Compact(slices.Sorted(slices.Values(ticketIDs)))
+	count, err := models.CountTickets(h.db, r.Context(), func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("ticket.id IN (?)", bun.In(ticketIDs))
+	})
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return false
+	}
+	if count != len(ticketIDs) {
+		render.Status(r, http.StatusNotFound)
+		renderer.PrettyJSON(w, r, "Ticket not found")
+		return false
+	}
+
+	before, ok := h.ticketTags(w, r, ticketIDs)
+	if !ok {
+		return false
+	}
+
+	if err := models.RemoveTicketTags(h.db, r.Context(), ticketIDs, remove); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return false
+	}
+	if err := models.AddTicketTags(h.db, r.Context(), ticketIDs, add); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return false
+	}
+
+	after, ok := h.ticketTags(w, r, ticketIDs)
+	if !ok {
+		return false
+	}
+	for i, id := range ticketIDs {
+		recordAudit(h.db, r, models.AuditUpdate, "ticket", id, map[string]any{"Tags": before[i]}, map[string]any{"Tags": after[i]})
+	}
+	return true
+}
+
+// ticketTags loads the tag names of every listed ticket.
+func (h *TagHandler) ticketTags(w http.ResponseWriter, r *http.Request, ticketIDs []int64) ([][]string, bool) {
+	tags := make([][]string, len(ticketIDs))
+	for i, id := range ticketIDs {
+		names, err := models.ListTagNamesByTicketID(h.db, r.Context(), id)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			renderer.PrettyJSON(w, r, err.Error())
+			return nil, false
+		}
+		tags[i] = names
+	}
+	return tags, true
+}
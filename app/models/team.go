@@ -0,0 +1,196 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+
+	"goat/app/renderer"
+	"goat/services/models"
+)
+
+type TeamHandler struct {
+	db *bun.DB
+}
+
+func NewTeamHandler(db *bun.DB) *TeamHandler {
+	return &TeamHandler{db: db}
+}
+
+// ListTeams handles the request to list all teams.
+func (h *TeamHandler) ListTeams(w http.ResponseWriter, r *http.Request) {
+	teams, err := models.ListTeams(h.db, r.Context())
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, teams)
+}
+
+// CreateTeam handles the request to create a new team.
+func (h *TeamHandler) CreateTeam(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	if req.Name == "" {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Team name is required")
+		return
+	}
+
+	team := models.Team{Name: req.Name}
+	if err := models.CreateTeam(h.db, r.Context(), &team); err != nil {
+		if errors.Is(err, models.ErrDuplicate) {
+			render.Status(r, http.StatusConflict)
+			renderer.PrettyJSON(w, r, "A team with this name already exists")
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditCreate, "team", team.ID, nil, team)
+
+	render.Status(r, http.StatusCreated)
+	renderer.PrettyJSON(w, r, team)
+}
+
+// GetTeam handles the request to get a team and its members.
+func (h *TeamHandler) GetTeam(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "id", "team")
+	if !ok {
+		return
+	}
+
+	team, err := models.GetTeamByID(h.db, r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "Team not found")
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, team)
+}
+
+// DeleteTeam handles the request to delete a team.
+func (h *TeamHandler) DeleteTeam(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "id", "team")
+	if !ok {
+		return
+	}
+
+	existing, err := models.GetTeamByID(h.db, r.Context(), id)
+	if err != nil && err != sql.ErrNoRows {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	if err := models.DeleteTeam(h.db, r.Context(), id); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if existing != nil {
+		recordAudit(h.db, r, models.AuditDelete, "team", id, existing, nil)
+	}
+
+	render.Status(r, http.StatusAccepted)
+	renderer.PrettyJSON(w, r, map[string]string{"message": "Team deleted successfully"})
+}
+
+// AddTeamMember handles the request to add a user to a team.
+func (h *TeamHandler) AddTeamMember(w http.ResponseWriter, r *http.Request) {
+	teamID, ok := urlParamID(w, r, "id", "team")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		UserID int64 `json:"user_id"`
+	}
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	if _, err := models.GetTeamByID(h.db, r.Context(), teamID); err != nil {
+		renderTeamLookupError(w, r, err)
+		return
+	}
+
+	if _, err := models.GetUserByID(h.db, r.Context(), req.UserID); err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "User not found")
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	if err := models.AddTeamMember(h.db, r.Context(), teamID, req.UserID); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditUpdate, "team", teamID, map[string]any{"AddedMember": nil}, map[string]any{"AddedMember": req.UserID})
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, map[string]string{"message": "Member added successfully"})
+}
+
+// RemoveTeamMember handles the request to remove a user from a team.
+func (h *TeamHandler) RemoveTeamMember(w http.ResponseWriter, r *http.Request) {
+	teamID, ok := urlParamID(w, r, "id", "team")
+	if !ok {
+		return
+	}
+	userID, ok := urlParamID(w, r, "userID", "user")
+	if !ok {
+		return
+	}
+
+	if err := models.RemoveTeamMember(h.db, r.Context(), teamID, userID); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditUpdate, "team", teamID, map[string]any{"RemovedMember": nil}, map[string]any{"RemovedMember": userID})
+
+	render.Status(r, http.StatusAccepted)
+	renderer.PrettyJSON(w, r, map[string]string{"message": "Member removed successfully"})
+}
+
+// renderTeamLookupError writes the response for a team that could not be loaded: 404 when it does
+// not exist in the organization, 500 otherwise.
+func renderTeamLookupError(w http.ResponseWriter, r *http.Request, err error) {
+	if err == sql.ErrNoRows {
+		render.Status(r, http.StatusNotFound)
+		renderer.PrettyJSON(w, r, "Team not found")
+		return
+	}
+	render.Status(r, http.StatusInternalServerError)
+	renderer.PrettyJSON(w, r, err.Error())
+}
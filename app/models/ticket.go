@@ -3,9 +3,13 @@ package models
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"goat/app/middleware"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
@@ -14,42 +18,60 @@ import (
 
 	"goat/app/renderer"
 	"goat/services/models"
+	"goat/services/query"
 )
 
 type TicketHandler struct {
-	db *bun.DB
+	db      *bun.DB // Holds the categories, companies and audit log
+	tickets models.TicketRepository
+	users   models.UserRepository
+	// related holds the custom field values, participants, mentions and trigger runs of tickets,
+	// nil unless tickets are stored in the SQL database
+	related *bun.DB
 }
 
-func NewTicketHandler(db *bun.DB) *TicketHandler {
-	return &TicketHandler{db: db}
+func NewTicketHandler(db *bun.DB, repos models.Repositories) *TicketHandler {
+	return &TicketHandler{db: db, tickets: repos.Tickets, users: repos.Users, related: repos.SQL}
 }
 
 // ListTickets handles the request to list all tickets.
 func (h *TicketHandler) ListTickets(w http.ResponseWriter, r *http.Request) {
 
-	ctx := context.Background()
+	ctx := r.Context()
 
-	tickets, err := models.ListTickets(h.db, ctx)
+	filter, ok := ticketQueryFilter(w, r)
+	if !ok {
+		return
+	}
+
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	tickets, err := h.tickets.List(ctx, page, filter)
 	if err != nil {
-		render.Status(r, http.StatusInternalServerError)
-		renderer.PrettyJSON(w, r, err.Error())
+		renderListError(w, r, err)
 		return
 	}
-	render.Status(r, http.StatusOK)
-	renderer.PrettyJSON(w, r, tickets)
+
+	renderPage(w, r, tickets)
 }
 
 // CreateTicket handles the request to create a new ticket.
 func (h *TicketHandler) CreateTicket(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 
 	var req struct {
-		Title       string `json:"Title"`
-		Description string `json:"Description"`
-		Status      string `json:"Status"`
-		Priority    string `json:"Priority"`
-		RequesterID int64  `json:"RequesterID"`
-		AssigneeID  *int64 `json:"AssigneeID"` // Use pointer to int64 to handle null
+		Title       string                     `json:"Title"`
+		Description string                     `json:"Description"`
+		Status      string                     `json:"Status"`
+		Priority    string                     `json:"Priority"`
+		RequesterID int64                      `json:"RequesterID"`
+		AssigneeID  *int64                     `json:"AssigneeID"` // Use pointer to int64 to handle null
+		CategoryID  *int64                     `json:"CategoryID"`
+		Type        string                     `json:"Type"`
+		Fields      map[string]json.RawMessage `json:"Fields"`
 	}
 
 	if err := render.DecodeJSON(r.Body, &req); err != nil {
@@ -61,12 +83,20 @@ func (h *TicketHandler) CreateTicket(w http.ResponseWriter, r *http.Request) {
 	ticket := models.Ticket{
 		Title:       req.Title,
 		Description: req.Description,
+		Type:        req.Type,
 		Status:      req.Status,
 		Priority:    req.Priority,
 	}
 
+	if ticket.Type == "" {
+		ticket.Type = models.TicketTypes[0]
+	}
+	if !checkTicketType(w, r, ticket.Type) {
+		return
+	}
+
 	// Check if the requester exists
-	requester, err := models.GetUserByID(h.db, ctx, req.RequesterID)
+	requester, err := h.users.GetByID(ctx, req.RequesterID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			render.Status(r, http.StatusNotFound)
@@ -81,7 +111,7 @@ func (h *TicketHandler) CreateTicket(w http.ResponseWriter, r *http.Request) {
 
 	if req.AssigneeID != nil {
 		// Check if the assignee exists
-		assignee, err := models.GetUserByID(h.db, ctx, *req.AssigneeID)
+		assignee, err := h.users.GetByID(ctx, *req.AssigneeID)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				render.Status(r, http.StatusNotFound)
@@ -97,19 +127,40 @@ func (h *TicketHandler) CreateTicket(w http.ResponseWriter, r *http.Request) {
 		ticket.AssigneeID = sql.NullInt64{Valid: false}
 	}
 
-	if err := models.CreateTicket(h.db, ctx, &ticket); err != nil {
+	if req.CategoryID != nil {
+		if !h.checkCategory(w, r, *req.CategoryID) {
+			return
+		}
+		ticket.CategoryID = sql.NullInt64{Int64: *req.CategoryID, Valid: true}
+	}
+
+	changes, ok := ticketFieldChanges(h.related, w, r, &ticket, req.Fields)
+	if !ok {
+		return
+	}
+
+	if err := h.tickets.Create(ctx, &ticket); err != nil {
 		render.Status(r, http.StatusInternalServerError)
 		renderer.PrettyJSON(w, r, err.Error())
 		return
 	}
 
+	if !saveTicketFields(h.related, w, r, &ticket, changes) {
+		return
+	}
+	recordAudit(h.db, r, models.AuditCreate, "ticket", ticket.ID, nil, ticket)
+	if triggered := fireTriggers(h.related, h.tickets, r, models.TriggerEvent{Type: models.EventTicketCreated}, ticket.ID); triggered != nil {
+		ticket = *triggered
+	}
+
+	w.Header().Set("ETag", ticketETag(&ticket))
 	render.Status(r, http.StatusCreated)
 	renderer.PrettyJSON(w, r, ticket)
 }
 
 // GetTicket handles the request to get a ticket by ID.
 func (h *TicketHandler) GetTicket(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 	idParam := chi.URLParam(r, "id")
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
@@ -118,7 +169,7 @@ func (h *TicketHandler) GetTicket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ticket, err := models.GetTicketByID(h.db, ctx, id)
+	ticket, err := h.tickets.GetByID(ctx, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			render.Status(r, http.StatusNotFound)
@@ -130,13 +181,14 @@ func (h *TicketHandler) GetTicket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("ETag", ticketETag(ticket))
 	render.Status(r, http.StatusOK)
 	renderer.PrettyJSON(w, r, ticket)
 }
 
 // UpdateTicket handles the request to update an existing ticket.
 func (h *TicketHandler) UpdateTicket(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 	idParam := chi.URLParam(r, "id")
 	id, err := strconv.ParseInt(idParam, 10, 64)
 	if err != nil {
@@ -145,13 +197,32 @@ func (h *TicketHandler) UpdateTicket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	existingTicket, err := h.tickets.GetByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "Ticket not found")
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	if !checkTicketIfMatch(w, r, existingTicket) {
+		return
+	}
+
 	var req struct {
-		Title       string `json:"Title"`
-		Description string `json:"Description"`
-		Status      string `json:"Status"`
-		Priority    string `json:"Priority"`
-		RequesterID int64  `json:"RequesterID"`
-		AssigneeID  *int64 `json:"AssigneeID"`
+		Title       string                     `json:"Title"`
+		Description string                     `json:"Description"`
+		Status      string                     `json:"Status"`
+		Priority    string                     `json:"Priority"`
+		RequesterID int64                      `json:"RequesterID"`
+		AssigneeID  *int64                     `json:"AssigneeID"`
+		CategoryID  *int64                     `json:"CategoryID"` // Keeps the current category when omitted
+		Type        string                     `json:"Type"`       // Keeps the current type when empty
+		Fields      map[string]json.RawMessage `json:"Fields"`     // Only the listed fields change, null clears one
 	}
 
 	if err := render.DecodeJSON(r.Body, &req); err != nil {
@@ -164,12 +235,22 @@ func (h *TicketHandler) UpdateTicket(w http.ResponseWriter, r *http.Request) {
 		ID:          id,
 		Title:       req.Title,
 		Description: req.Description,
+		Type:        existingTicket.Type,
 		Status:      req.Status,
 		Priority:    req.Priority,
+		CategoryID:  existingTicket.CategoryID,
+		Version:     existingTicket.Version,
+	}
+
+	if req.Type != "" {
+		if !checkTicketType(w, r, req.Type) {
+			return
+		}
+		ticket.Type = req.Type
 	}
 
 	// Check if the requester exists
-	requester, err := models.GetUserByID(h.db, ctx, req.RequesterID)
+	requester, err := h.users.GetByID(ctx, req.RequesterID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			render.Status(r, http.StatusNotFound)
@@ -184,7 +265,7 @@ func (h *TicketHandler) UpdateTicket(w http.ResponseWriter, r *http.Request) {
 
 	if req.AssigneeID != nil {
 		// Check if the assignee exists
-		assignee, err := models.GetUserByID(h.db, ctx, *req.AssigneeID)
+		assignee, err := h.users.GetByID(ctx, *req.AssigneeID)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				render.Status(r, http.StatusNotFound)
@@ -200,16 +281,136 @@ func (h *TicketHandler) UpdateTicket(w http.ResponseWriter, r *http.Request) {
 		ticket.AssigneeID = sql.NullInt64{Valid: false}
 	}
 
-	if err := models.UpdateTicket(h.db, ctx, &ticket); err != nil {
+	if req.CategoryID != nil {
+		if !h.checkCategory(w, r, *req.CategoryID) {
+			return
+		}
+		ticket.CategoryID = sql.NullInt64{Int64: *req.CategoryID, Valid: true}
+	}
+
+	changes, ok := ticketFieldChanges(h.related, w, r, &ticket, req.Fields)
+	if !ok {
+		return
+	}
+
+	if err := h.tickets.Update(ctx, &ticket); err != nil {
+		if errors.Is(err, models.ErrVersionConflict) {
+			h.renderTicketConflict(w, r, id, false)
+			return
+		}
 		render.Status(r, http.StatusInternalServerError)
 		renderer.PrettyJSON(w, r, err.Error())
 		return
 	}
 
+	if !saveTicketFields(h.related, w, r, &ticket, changes) {
+		return
+	}
+	recordAudit(h.db, r, models.AuditUpdate, "ticket", id, existingTicket, ticket)
+	if triggered := fireTriggers(h.related, h.tickets, r, models.TriggerEvent{Type: models.EventTicketUpdated, Before: existingTicket}, id); triggered != nil {
+		ticket = *triggered
+	}
+
+	w.Header().Set("ETag", ticketETag(&ticket))
 	render.Status(r, http.StatusOK)
 	renderer.PrettyJSON(w, r, ticket)
 }
 
+// DeleteTicket handles the request to move a ticket to the trash.
+func (h *TicketHandler) DeleteTicket(w http.ResponseWriter, r *http.Request) {
+	id, ok := urlParamID(w, r, "id", "ticket")
+	if !ok {
+		return
+	}
+
+	existingTicket, err := h.tickets.GetByID(r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "Ticket not found")
+			return
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	if err := h.tickets.Delete(r.Context(), id); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditDelete, "ticket", id, existingTicket, nil)
+
+	render.Status(r, http.StatusAccepted)
+	renderer.PrettyJSON(w, r, map[string]string{"message": "Ticket deleted successfully"})
+}
+
+// followsTicket reports whether an agent watches a ticket or was mentioned on it. Nobody does
+// when tickets are not stored in the SQL database.
+func followsTicket(related *bun.DB, ctx context.Context, ticketID, userID int64) (bool, error) {
+	if related == nil {
+		return false, nil
+	}
+	watching, err := models.IsWatchingTicket(related, ctx, ticketID, userID)
+	if err != nil || watching {
+		return watching, err
+	}
+	return models.IsMentionedOnTicket(related, ctx, ticketID, userID)
+}
+
+// checkCategory verifies that a category exists, writing an error response when it does not.
+func (h *TicketHandler) checkCategory(w http.ResponseWriter, r *http.Request, categoryID int64) bool {
+	if _, err := models.GetCategoryByID(h.db, r.Context(), categoryID); err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "Category not found")
+			return false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return false
+	}
+	return true
+}
+
+// ticketQueryFilter compiles the optional "q" parameter, written in the ticket query language,
+// and any "tag" parameters for the current user.
+func ticketQueryFilter(w http.ResponseWriter, r *http.Request) (func(*bun.SelectQuery) *bun.SelectQuery, bool) {
+	input := r.URL.Query().Get("q")
+	for _, tag := range r.URL.Query()["tag"] {
+		input += ` tag:"` + strings.ReplaceAll(tag, `"`, "") + `"`
+	}
+	if input == "" {
+		return nil, true
+	}
+
+	userID, _ := r.Context().Value(middleware.UserIDKey).(string)
+	id, _ := strconv.ParseInt(userID, 10, 64)
+
+	filter, err := query.Filter(input, query.Env{UserID: id, Now: time.Now()})
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Invalid query: "+err.Error())
+		return nil, false
+	}
+	return filter, true
+}
+
+// renderTicketConflict reloads a ticket that lost an update race and responds with 412.
+func (h *TicketHandler) renderTicketConflict(w http.ResponseWriter, r *http.Request, id int64, customerView bool) {
+	current, err := h.tickets.GetByID(r.Context(), id)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if customerView {
+		filterForRequester(current)
+	}
+	renderVersionConflict(w, r, current)
+}
+
 func (h *TicketHandler) ListAgentTickets(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
 	if !ok {
@@ -225,28 +426,45 @@ func (h *TicketHandler) ListAgentTickets(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	tickets, err := models.ListTicketsByAssigneeID(h.db, r.Context(), assigneeID)
+	filter, ok := ticketQueryFilter(w, r)
+	if !ok {
+		return
+	}
+
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	tickets, err := h.tickets.ListByAssigneeID(r.Context(), assigneeID, page, filter)
 	if err != nil {
-		render.Status(r, http.StatusInternalServerError)
-		renderer.PrettyJSON(w, r, err.Error())
+		renderListError(w, r, err)
 		return
 	}
 
-	render.Status(r, http.StatusOK)
-	renderer.PrettyJSON(w, r, tickets)
+	renderPage(w, r, tickets)
 }
 
 func (h *TicketHandler) ListOpenTickets(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 
-	tickets, err := models.ListOpenTickets(h.db, ctx)
+	filter, ok := ticketQueryFilter(w, r)
+	if !ok {
+		return
+	}
+
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	tickets, err := h.tickets.ListOpen(ctx, page, filter)
 	if err != nil {
-		render.Status(r, http.StatusInternalServerError)
-		renderer.PrettyJSON(w, r, err.Error())
+		renderListError(w, r, err)
 		return
 	}
-	render.Status(r, http.StatusOK)
-	renderer.PrettyJSON(w, r, tickets)
+
+	renderPage(w, r, tickets)
 }
 
 func (h *TicketHandler) GetAgentTicket(w http.ResponseWriter, r *http.Request) {
@@ -272,7 +490,7 @@ func (h *TicketHandler) GetAgentTicket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ticket, err := models.GetTicketByID(h.db, r.Context(), id)
+	ticket, err := h.tickets.GetByID(r.Context(), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			render.Status(r, http.StatusNotFound)
@@ -285,12 +503,22 @@ func (h *TicketHandler) GetAgentTicket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !ticket.AssigneeID.Valid || ticket.AssigneeID.Int64 != assigneeID {
-		render.Status(r, http.StatusForbidden)
-		renderer.PrettyJSON(w, r, "You are not authorized to view this ticket")
-		return
+		// Watchers and agents mentioned on the ticket may follow it while it is assigned to someone else
+		watching, err := followsTicket(h.related, r.Context(), id, assigneeID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			renderer.PrettyJSON(w, r, err.Error())
+			return
+		}
+		if !watching {
+			render.Status(r, http.StatusForbidden)
+			renderer.PrettyJSON(w, r, "You are not authorized to view this ticket")
+			return
+		}
 	}
 
 	// Agent can see all comments
+	w.Header().Set("ETag", ticketETag(ticket))
 	render.Status(r, http.StatusOK)
 	renderer.PrettyJSON(w, r, ticket)
 }
@@ -318,7 +546,7 @@ func (h *TicketHandler) UpdateAgentTicket(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	existingTicket, err := models.GetTicketByID(h.db, r.Context(), id)
+	existingTicket, err := h.tickets.GetByID(r.Context(), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			render.Status(r, http.StatusNotFound)
@@ -331,9 +559,12 @@ func (h *TicketHandler) UpdateAgentTicket(w http.ResponseWriter, r *http.Request
 	}
 
 	var req struct {
-		Status     *string `json:"status"`
-		Priority   *string `json:"priority"`
-		AssigneeID *int64  `json:"AssigneeID"`
+		Status     *string                    `json:"status"`
+		Priority   *string                    `json:"priority"`
+		AssigneeID *int64                     `json:"AssigneeID"`
+		CategoryID *int64                     `json:"category_id"`
+		Type       *string                    `json:"type"`
+		Fields     map[string]json.RawMessage `json:"fields"` // Only the listed fields change, null clears one
 	}
 
 	if err := render.DecodeJSON(r.Body, &req); err != nil {
@@ -359,12 +590,34 @@ func (h *TicketHandler) UpdateAgentTicket(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if !checkTicketIfMatch(w, r, existingTicket) {
+		return
+	}
+	before := *existingTicket
+
 	if req.Status != nil && *req.Status != "" {
 		existingTicket.Status = *req.Status
 	}
 	if req.Priority != nil && *req.Priority != "" {
 		existingTicket.Priority = *req.Priority
 	}
+	if req.CategoryID != nil {
+		if !h.checkCategory(w, r, *req.CategoryID) {
+			return
+		}
+		existingTicket.CategoryID = sql.NullInt64{Int64: *req.CategoryID, Valid: true}
+	}
+	if req.Type != nil && *req.Type != "" {
+		if !checkTicketType(w, r, *req.Type) {
+			return
+		}
+		existingTicket.Type = *req.Type
+	}
+
+	changes, ok := ticketFieldChanges(h.related, w, r, existingTicket, req.Fields)
+	if !ok {
+		return
+	}
 
 	// Explicitly set AssigneeID from the JWT-derived assigneeID if req.AssigneeID is provided
 	if req.AssigneeID != nil {
@@ -375,12 +628,25 @@ func (h *TicketHandler) UpdateAgentTicket(w http.ResponseWriter, r *http.Request
 	}
 	// If req.AssigneeID is nil and existingTicket.AssigneeID is valid, keep existing AssigneeID
 
-	if err := models.UpdateTicket(h.db, r.Context(), existingTicket); err != nil {
+	if err := h.tickets.Update(r.Context(), existingTicket); err != nil {
+		if errors.Is(err, models.ErrVersionConflict) {
+			h.renderTicketConflict(w, r, id, false)
+			return
+		}
 		render.Status(r, http.StatusInternalServerError)
 		renderer.PrettyJSON(w, r, err.Error())
 		return
 	}
 
+	if !saveTicketFields(h.related, w, r, existingTicket, changes) {
+		return
+	}
+	recordAudit(h.db, r, models.AuditUpdate, "ticket", id, before, existingTicket)
+	if triggered := fireTriggers(h.related, h.tickets, r, models.TriggerEvent{Type: models.EventTicketUpdated, Before: &before}, id); triggered != nil {
+		existingTicket = triggered
+	}
+
+	w.Header().Set("ETag", ticketETag(existingTicket))
 	render.Status(r, http.StatusOK)
 	renderer.PrettyJSON(w, r, existingTicket)
 }
@@ -421,16 +687,24 @@ func (h *TicketHandler) CreateCustomerTicket(w http.ResponseWriter, r *http.Requ
 		Priority:    req.Priority,
 	}
 
-	if err := models.CreateTicket(h.db, r.Context(), &ticket); err != nil {
+	if err := h.tickets.Create(r.Context(), &ticket); err != nil {
 		render.Status(r, http.StatusInternalServerError)
 		renderer.PrettyJSON(w, r, err.Error())
 		return
 	}
+	recordAudit(h.db, r, models.AuditCreate, "ticket", ticket.ID, nil, ticket)
+	if triggered := fireTriggers(h.related, h.tickets, r, models.TriggerEvent{Type: models.EventTicketCreated}, ticket.ID); triggered != nil {
+		ticket = *triggered
+		filterForRequester(&ticket)
+	}
 
+	w.Header().Set("ETag", ticketETag(&ticket))
 	render.Status(r, http.StatusCreated)
 	renderer.PrettyJSON(w, r, ticket)
 }
 
+// ListCustomerTickets lists the caller's own tickets or, with scope=company, every ticket of their
+// company when it shares tickets between its members.
 func (h *TicketHandler) ListCustomerTickets(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(middleware.UserIDKey).(string)
 	if !ok {
@@ -446,15 +720,46 @@ func (h *TicketHandler) ListCustomerTickets(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	tickets, err := models.ListTicketsByRequesterID(h.db, r.Context(), requesterID)
+	filter, ok := ticketQueryFilter(w, r)
+	if !ok {
+		return
+	}
+
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var tickets *models.Page[models.Ticket]
+	switch r.URL.Query().Get("scope") {
+	case "", "mine":
+		tickets, err = h.tickets.ListByRequesterID(r.Context(), requesterID, page, filter)
+	case "company":
+		company, cerr := sharingCompany(h.db, h.users, r.Context(), requesterID)
+		if cerr != nil {
+			render.Status(r, http.StatusInternalServerError)
+			renderer.PrettyJSON(w, r, cerr.Error())
+			return
+		}
+		if company == nil {
+			render.Status(r, http.StatusForbidden)
+			renderer.PrettyJSON(w, r, "Your company does not share tickets")
+			return
+		}
+		tickets, err = h.tickets.List(r.Context(), page, models.CompanyTickets(company.ID), filter)
+	case "cc":
+		tickets, err = h.tickets.List(r.Context(), page, models.CCTickets(requesterID), filter)
+	default:
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Invalid scope, expected mine, company or cc")
+		return
+	}
 	if err != nil {
-		render.Status(r, http.StatusInternalServerError)
-		renderer.PrettyJSON(w, r, err.Error())
+		renderListError(w, r, err)
 		return
 	}
 
-	render.Status(r, http.StatusOK)
-	renderer.PrettyJSON(w, r, tickets)
+	renderPage(w, r, tickets)
 }
 
 func (h *TicketHandler) GetCustomerTicket(w http.ResponseWriter, r *http.Request) {
@@ -480,7 +785,7 @@ func (h *TicketHandler) GetCustomerTicket(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	ticket, err := models.GetTicketByID(h.db, r.Context(), id)
+	ticket, err := h.tickets.GetByID(r.Context(), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			render.Status(r, http.StatusNotFound)
@@ -492,21 +797,22 @@ func (h *TicketHandler) GetCustomerTicket(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if ticket.RequesterID != requesterID {
+	allowed, err := canAccessCustomerTicket(h.db, h.related, h.users, r.Context(), ticket, requesterID)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if !allowed {
 		render.Status(r, http.StatusForbidden)
 		renderer.PrettyJSON(w, r, "You are not authorized to view this ticket")
 		return
 	}
 
 	// Filter internal comments for customers
-	filteredComments := []models.Comment{}
-	for _, comment := range ticket.Comments {
-		if !comment.IsInternal {
-			filteredComments = append(filteredComments, comment)
-		}
-	}
-	ticket.Comments = filteredComments
+	filterForRequester(ticket)
 
+	w.Header().Set("ETag", ticketETag(ticket))
 	render.Status(r, http.StatusOK)
 	renderer.PrettyJSON(w, r, ticket)
 }
@@ -534,7 +840,7 @@ func (h *TicketHandler) CloseCustomerTicket(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	existingTicket, err := models.GetTicketByID(h.db, r.Context(), id)
+	existingTicket, err := h.tickets.GetByID(r.Context(), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			render.Status(r, http.StatusNotFound)
@@ -552,14 +858,30 @@ func (h *TicketHandler) CloseCustomerTicket(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	filterForRequester(existingTicket)
+	if !checkTicketIfMatch(w, r, existingTicket) {
+		return
+	}
+
+	before := *existingTicket
 	existingTicket.Status = "Closed"
 
-	if err := models.UpdateTicket(h.db, r.Context(), existingTicket); err != nil {
+	if err := h.tickets.Update(r.Context(), existingTicket); err != nil {
+		if errors.Is(err, models.ErrVersionConflict) {
+			h.renderTicketConflict(w, r, id, true)
+			return
+		}
 		render.Status(r, http.StatusInternalServerError)
 		renderer.PrettyJSON(w, r, err.Error())
 		return
 	}
+	recordAudit(h.db, r, models.AuditUpdate, "ticket", id, before, existingTicket)
+	if triggered := fireTriggers(h.related, h.tickets, r, models.TriggerEvent{Type: models.EventTicketUpdated, Before: &before}, id); triggered != nil {
+		existingTicket = triggered
+		filterForRequester(existingTicket)
+	}
 
+	w.Header().Set("ETag", ticketETag(existingTicket))
 	render.Status(r, http.StatusOK)
 	renderer.PrettyJSON(w, r, existingTicket)
 }
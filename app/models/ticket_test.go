@@ -0,0 +1,103 @@
+package models_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	model "goat/services/models"
+)
+
+func TestTicketHandlerLifecycle(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.newUser(t, "Admin", 0)
+	customer := s.newUser(t, "Customer", 0)
+	ctx := model.WithTenant(context.Background(), model.DefaultTenantID)
+
+	category := &model.Category{Name: "Billing"}
+	if err := model.CreateCategory(s.db, ctx, category); err != nil {
+		t.Fatalf("create category: %v", err)
+	}
+
+	w := s.do(t, admin, http.MethodPost, "/admin/tickets", map[string]any{
+		"Title": "Printer", "Status": "Open", "Priority": "Low", "RequesterID": customer.ID, "CategoryID": 999,
+	})
+	decode(t, w, http.StatusNotFound, nil)
+
+	var ticket model.Ticket
+	w = s.do(t, admin, http.MethodPost, "/admin/tickets", map[string]any{
+		"Title": "Printer", "Status": "Open", "Priority": "Low", "RequesterID": customer.ID, "CategoryID": category.ID,
+	})
+	decode(t, w, http.StatusCreated, &ticket)
+	if etag := w.Header().Get("ETag"); etag != `"1"` {
+		t.Errorf("got ETag %s after create, want \"1\"", etag)
+	}
+	path := fmt.Sprintf("/admin/tickets/%d", ticket.ID)
+
+	stored, err := s.repos.Tickets.GetByID(ctx, ticket.ID)
+	if err != nil {
+		t.Fatalf("ticket not in the repository: %v", err)
+	}
+	if stored.CategoryID.Int64 != category.ID {
+		t.Errorf("got category %d, want %d", stored.CategoryID.Int64, category.ID)
+	}
+
+	update := map[string]any{"Title": "Printer", "Status": "Pending", "Priority": "High", "RequesterID": customer.ID}
+	decode(t, s.do(t, admin, http.MethodPut, path, update), http.StatusPreconditionRequired, nil)
+	decode(t, s.do(t, admin, http.MethodPut, path, update, "If-Match", `"7"`), http.StatusPreconditionFailed, nil)
+
+	w = s.do(t, admin, http.MethodPut, path, update, "If-Match", `"1"`)
+	decode(t, w, http.StatusOK, &ticket)
+	if ticket.Status != "Pending" || ticket.Priority != "High" || w.Header().Get("ETag") != `"2"` {
+		t.Errorf("got %s/%s with ETag %s, want Pending/High with \"2\"", ticket.Status, ticket.Priority, w.Header().Get("ETag"))
+	}
+
+	decode(t, s.do(t, admin, http.MethodDelete, path, nil), http.StatusAccepted, nil)
+	decode(t, s.do(t, admin, http.MethodGet, path, nil), http.StatusNotFound, nil)
+
+	entries, err := model.ListAuditEntries(s.db, ctx, model.AuditFilter{EntityType: "ticket"}, model.PageRequest{Limit: 10})
+	if err != nil {
+		t.Fatalf("list audit entries: %v", err)
+	}
+	if len(entries.Items) != 3 {
+		t.Errorf("got %d ticket audit entries, want 3 for create, update and delete", len(entries.Items))
+	}
+}
+
+func TestCustomerTicketAccess(t *testing.T) {
+	s := newTestServer(t)
+	ctx := model.WithTenant(context.Background(), model.DefaultTenantID)
+
+	company := &model.Company{Name: "Acme", SharedTickets: true}
+	if err := model.CreateCompany(s.db, ctx, company); err != nil {
+		t.Fatalf("create company: %v", err)
+	}
+	requester := s.newUser(t, "Customer", company.ID)
+	colleague := s.newUser(t, "Customer", company.ID)
+	stranger := s.newUser(t, "Customer", 0)
+	agent := s.newUser(t, "Agent", 0)
+
+	var ticket model.Ticket
+	w := s.do(t, requester, http.MethodPost, "/customer/tickets", map[string]any{"title": "Login fails", "priority": "Medium"})
+	decode(t, w, http.StatusCreated, &ticket)
+	path := fmt.Sprintf("/customer/tickets/%d", ticket.ID)
+
+	for _, tc := range []struct {
+		name   string
+		user   *model.User
+		status int
+	}{
+		{"requester", requester, http.StatusOK},
+		{"company member", colleague, http.StatusOK},
+		{"other customer", stranger, http.StatusForbidden},
+	} {
+		if w := s.do(t, tc.user, http.MethodGet, path, nil); w.Code != tc.status {
+			t.Errorf("%s: got status %d, want %d", tc.name, w.Code, tc.status)
+		}
+	}
+
+	// Agents see the tickets assigned to them; without SQL rows nobody watches or is mentioned.
+	agentPath := fmt.Sprintf("/agent/tickets/%d", ticket.ID)
+	decode(t, s.do(t, agent, http.MethodGet, agentPath, nil), http.StatusForbidden, nil)
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+
+	"goat/app/renderer"
+	"goat/services/models"
+)
+
+type TrashHandler struct {
+	db      *bun.DB
+	tickets models.TicketRepository
+}
+
+func NewTrashHandler(db *bun.DB, repos models.Repositories) *TrashHandler {
+	return &TrashHandler{db: db, tickets: repos.Tickets}
+}
+
+// ListDeletedUsers handles the request to list the users in the trash.
+func (h *TrashHandler) ListDeletedUsers(w http.ResponseWriter, r *http.Request) {
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	users, err := models.ListDeletedUsers(h.db, r.Context(), page)
+	if err != nil {
+		renderListError(w, r, err)
+		return
+	}
+
+	renderPage(w, r, users)
+}
+
+// ListDeletedTickets handles the request to list the tickets in the trash.
+func (h *TrashHandler) ListDeletedTickets(w http.ResponseWriter, r *http.Request) {
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	tickets, err := models.ListDeletedTickets(h.db, r.Context(), page)
+	if err != nil {
+		renderListError(w, r, err)
+		return
+	}
+
+	renderPage(w, r, tickets)
+}
+
+// ListDeletedComments handles the request to list the comments in the trash.
+func (h *TrashHandler) ListDeletedComments(w http.ResponseWriter, r *http.Request) {
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	comments, err := models.ListDeletedComments(h.db, r.Context(), page)
+	if err != nil {
+		renderListError(w, r, err)
+		return
+	}
+
+	renderPage(w, r, comments)
+}
+
+// RestoreUser handles the request to take a user out of the trash.
+func (h *TrashHandler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	h.restore(w, r, "user", models.RestoreUser, func(id int64) models.RowChanges {
+		return models.RowChanges{UserIDs: []int64{id}}
+	})
+}
+
+// RestoreTicket handles the request to take a ticket out of the trash.
+func (h *TrashHandler) RestoreTicket(w http.ResponseWriter, r *http.Request) {
+	h.restore(w, r, "ticket", models.RestoreTicket, func(id int64) models.RowChanges {
+		return models.RowChanges{TicketIDs: []int64{id}}
+	})
+}
+
+// RestoreComment handles the request to take a comment out of the trash.
+func (h *TrashHandler) RestoreComment(w http.ResponseWriter, r *http.Request) {
+	h.restore(w, r, "comment", models.RestoreComment, func(id int64) models.RowChanges {
+		return models.RowChanges{CommentIDs: []int64{id}}
+	})
+}
+
+// restore takes the row in the URL out of the trash and tells any copy kept by the ticket
+// repository about the change.
+func (h *TrashHandler) restore(w http.ResponseWriter, r *http.Request, entityType string,
+	restore func(*bun.DB, context.Context, int64) (bool, error), changes func(int64) models.RowChanges) {
+	id, ok := urlParamID(w, r, "id", entityType)
+	if !ok {
+		return
+	}
+
+	restored, err := restore(h.db, r.Context(), id)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if !restored {
+		render.Status(r, http.StatusNotFound)
+		renderer.PrettyJSON(w, r, "No deleted "+entityType+" with this ID")
+		return
+	}
+	models.SyncChanges(h.tickets, r.Context(), changes(id))
+	recordAudit(h.db, r, models.AuditRestore, entityType, id, map[string]any{"Deleted": true}, map[string]any{"Deleted": false})
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, map[string]string{"message": "Restored successfully"})
+}
@@ -0,0 +1,359 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+
+	"goat/app/middleware"
+	"goat/app/renderer"
+	"goat/services/models"
+)
+
+type TriggerHandler struct {
+	db *bun.DB
+}
+
+func NewTriggerHandler(db *bun.DB) *TriggerHandler {
+	return &TriggerHandler{db: db}
+}
+
+// triggerRequest is the body of the requests creating and updating a trigger.
+type triggerRequest struct {
+	Name       string                   `json:"name"`
+	Event      string                   `json:"event"`
+	Conditions models.TriggerConditions `json:"conditions"`
+	Actions    []models.TriggerAction   `json:"actions"`
+	Position   int                      `json:"position"`
+	Active     *bool                    `json:"active"` // Defaults to true
+}
+
+// ListTriggers handles the request to list every trigger in the order they are evaluated.
+func (h *TriggerHandler) ListTriggers(w http.ResponseWriter, r *http.Request) {
+	triggers, err := models.ListTriggers(h.db, r.Context(), "")
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, triggers)
+}
+
+// CreateTrigger handles the request to define a trigger.
+func (h *TriggerHandler) CreateTrigger(w http.ResponseWriter, r *http.Request) {
+	var trigger models.Trigger
+	if !h.decodeTrigger(w, r, &trigger) {
+		return
+	}
+
+	if err := models.CreateTrigger(h.db, r.Context(), &trigger); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditCreate, "trigger", trigger.ID, nil, trigger)
+
+	render.Status(r, http.StatusCreated)
+	renderer.PrettyJSON(w, r, trigger)
+}
+
+// GetTrigger handles the request to get a trigger by ID.
+func (h *TriggerHandler) GetTrigger(w http.ResponseWriter, r *http.Request) {
+	trigger, ok := h.trigger(w, r)
+	if !ok {
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, trigger)
+}
+
+// UpdateTrigger handles the request to replace a trigger's definition.
+func (h *TriggerHandler) UpdateTrigger(w http.ResponseWriter, r *http.Request) {
+	trigger, ok := h.trigger(w, r)
+	if !ok {
+		return
+	}
+
+	before := *trigger
+	if !h.decodeTrigger(w, r, trigger) {
+		return
+	}
+
+	if err := models.UpdateTrigger(h.db, r.Context(), trigger); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditUpdate, "trigger", trigger.ID, before, trigger)
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, trigger)
+}
+
+// DeleteTrigger handles the request to delete a trigger along with its log.
+func (h *TriggerHandler) DeleteTrigger(w http.ResponseWriter, r *http.Request) {
+	trigger, ok := h.trigger(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteTrigger(h.db, r.Context(), trigger.ID); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditDelete, "trigger", trigger.ID, trigger, nil)
+
+	render.Status(r, http.StatusAccepted)
+	renderer.PrettyJSON(w, r, map[string]string{"message": "Trigger deleted successfully"})
+}
+
+// DryRunTriggers handles the request to show what the triggers would do to a ticket for an event,
+// without changing anything. With trigger_id only that trigger is evaluated, even if it is
+// inactive; otherwise the event's active triggers are.
+func (h *TriggerHandler) DryRunTriggers(w http.ResponseWriter, r *http.Request) {
+	actorID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Event     string `json:"event"`
+		TicketID  int64  `json:"ticket_id"`
+		TriggerID int64  `json:"trigger_id"`
+		ActorID   int64  `json:"actor_id"` // The user the event is made by, the caller by default
+		Comment   *struct {
+			Body       string `json:"body"`
+			IsInternal bool   `json:"is_internal"`
+		} `json:"comment"` // The comment of a comment.created event
+	}
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	var triggers []models.Trigger
+	if req.TriggerID != 0 {
+		trigger, err := models.GetTriggerByID(h.db, r.Context(), req.TriggerID)
+		if err != nil {
+			renderTriggerLookupError(w, r, err, "Trigger not found")
+			return
+		}
+		if req.Event == "" {
+			req.Event = trigger.Event
+		}
+		triggers = []models.Trigger{*trigger}
+	} else {
+		var err error
+		triggers, err = models.ListTriggers(h.db, r.Context(), req.Event)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			renderer.PrettyJSON(w, r, err.Error())
+			return
+		}
+	}
+
+	event := models.TriggerEvent{Type: req.Event, ActorID: actorID, ActorRole: currentUserRole(r)}
+	if !slices.Contains(models.TriggerEvents, event.Type) {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, fmt.Sprintf("Unknown event %q", event.Type))
+		return
+	}
+	if req.ActorID != 0 {
+		actor, err := models.GetUserByID(h.db, r.Context(), req.ActorID)
+		if err != nil {
+			renderTriggerLookupError(w, r, err, "Actor not found")
+			return
+		}
+		event.ActorID, event.ActorRole = actor.ID, actor.Role
+	}
+
+	ticket, err := models.GetTicketByID(h.db, r.Context(), req.TicketID)
+	if err != nil {
+		renderTriggerLookupError(w, r, err, "Ticket not found")
+		return
+	}
+	if req.Comment != nil {
+		event.Comment = &models.Comment{TicketID: ticket.ID, AuthorID: event.ActorID, Body: req.Comment.Body, IsInternal: req.Comment.IsInternal}
+	}
+
+	result, err := models.EvaluateTriggers(h.db, r.Context(), triggers, event, ticket)
+	if err != nil {
+		render.Status(r, http.StatusUnprocessableEntity)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, result)
+}
+
+// ListTriggerRuns handles the request to list the trigger log, newest first. It can be narrowed
+// to one trigger with trigger_id and to one ticket with ticket_id.
+func (h *TriggerHandler) ListTriggerRuns(w http.ResponseWriter, r *http.Request) {
+	var filter models.TriggerRunFilter
+	for param, dst := range map[string]*int64{"trigger_id": &filter.TriggerID, "ticket_id": &filter.TicketID} {
+		if value := r.URL.Query().Get(param); value != "" {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				render.Status(r, http.StatusBadRequest)
+				renderer.PrettyJSON(w, r, "Invalid "+param)
+				return
+			}
+			*dst = id
+		}
+	}
+	if value := r.URL.Query().Get("failed"); value != "" {
+		failed, err := strconv.ParseBool(value)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			renderer.PrettyJSON(w, r, "Invalid failed")
+			return
+		}
+		filter.Failed = failed
+	}
+
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	runs, err := models.ListTriggerRuns(h.db, r.Context(), filter, page)
+	if err != nil {
+		renderListError(w, r, err)
+		return
+	}
+
+	renderPage(w, r, runs)
+}
+
+// decodeTrigger reads a trigger request into trigger, writing a 400 response and returning false
+// when the definition is invalid or an action names a user, team or category that does not exist.
+func (h *TriggerHandler) decodeTrigger(w http.ResponseWriter, r *http.Request, trigger *models.Trigger) bool {
+	var req triggerRequest
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return false
+	}
+
+	trigger.Name = req.Name
+	trigger.Event = req.Event
+	trigger.Conditions = req.Conditions
+	trigger.Actions = req.Actions
+	trigger.Position = req.Position
+	trigger.Active = req.Active == nil || *req.Active
+
+	if trigger.Name == "" {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Trigger name is required")
+		return false
+	}
+	if err := models.CheckTrigger(trigger); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Invalid trigger: "+err.Error())
+		return false
+	}
+
+	for _, a := range trigger.Actions {
+		id, _ := strconv.ParseInt(a.Value, 10, 64)
+		var err error
+		switch a.Type {
+		case models.ActionAssignUser:
+			var user *models.User
+			if user, err = models.GetUserByID(h.db, r.Context(), id); err == nil && user.Role == "Customer" {
+				err = sql.ErrNoRows
+			}
+		case models.ActionAssignTeam:
+			_, err = models.GetTeamByID(h.db, r.Context(), id)
+		case models.ActionSetCategory:
+			if a.Value != "none" {
+				_, err = models.GetCategoryByID(h.db, r.Context(), id)
+			}
+		}
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusBadRequest)
+			renderer.PrettyJSON(w, r, fmt.Sprintf("Invalid trigger: action %q names %s, which does not exist", a.Type, a.Value))
+			return false
+		}
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			renderer.PrettyJSON(w, r, err.Error())
+			return false
+		}
+	}
+	return true
+}
+
+// trigger loads the trigger named in the URL.
+func (h *TriggerHandler) trigger(w http.ResponseWriter, r *http.Request) (*models.Trigger, bool) {
+	id, ok := urlParamID(w, r, "id", "trigger")
+	if !ok {
+		return nil, false
+	}
+
+	trigger, err := models.GetTriggerByID(h.db, r.Context(), id)
+	if err != nil {
+		renderTriggerLookupError(w, r, err, "Trigger not found")
+		return nil, false
+	}
+	return trigger, true
+}
+
+// renderTriggerLookupError writes the response for a failed lookup of a trigger or of a row a
+// trigger request names: 404 with message when it does not exist, 500 otherwise.
+func renderTriggerLookupError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	if err == sql.ErrNoRows {
+		render.Status(r, http.StatusNotFound)
+		renderer.PrettyJSON(w, r, message)
+		return
+	}
+	render.Status(r, http.StatusInternalServerError)
+	renderer.PrettyJSON(w, r, err.Error())
+}
+
+// fireTriggers runs the triggers of an event on a ticket once the change that raised it has been
+// saved. It returns the ticket as the triggers left it, or nil when none fired. Their changes are
+// audited as the request's own and copied to any copy kept by tickets. Failures do not fail the
+// request, which has already been applied; triggers that fail are kept in the execution log with
+// their error, and every failure is logged. db is nil when tickets are not stored in SQL, and
+// triggers do not run then.
+func fireTriggers(db *bun.DB, tickets models.TicketRepository, r *http.Request, event models.TriggerEvent, ticketID int64) *models.Ticket {
+	if db == nil {
+		return nil
+	}
+	ctx := r.Context()
+	if userID, ok := ctx.Value(middleware.UserIDKey).(string); ok {
+		event.ActorID, _ = strconv.ParseInt(userID, 10, 64)
+	}
+	event.ActorRole = currentUserRole(r)
+
+	result, err := models.RunTriggers(db, ctx, event, ticketID)
+	if err != nil {
+		log.Printf("Error running %s triggers on ticket %d: %v", event.Type, ticketID, err)
+		return nil
+	}
+	if result == nil {
+		return nil
+	}
+	models.SyncChanges(tickets, ctx, models.RowChanges{TicketIDs: []int64{ticketID}})
+
+	updated, err := models.GetTicketByID(db, ctx, ticketID)
+	if err != nil {
+		log.Printf("Error loading ticket %d after %s triggers: %v", ticketID, event.Type, err)
+		return nil
+	}
+	recordAudit(db, r, models.AuditUpdate, "ticket", ticketID, result.Before, updated)
+	return updated
+}
@@ -1,7 +1,6 @@
 package models
 
 import (
-	"context"
 	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
@@ -17,39 +16,49 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/uptrace/bun"
 
+	"goat/app/middleware"
 	"goat/app/renderer"
 	"goat/services/models"
 )
 
+// superAdminRole is the role of the users who manage organizations. It is granted with the
+// superadmin command only, never through the per-organization admin routes.
+const superAdminRole = "SuperAdmin"
+
 type UserHandler struct {
-	db *bun.DB
+	db    *bun.DB
+	users models.UserRepository
 }
 
-func NewUserHandler(db *bun.DB) *UserHandler {
-	return &UserHandler{db: db}
+func NewUserHandler(db *bun.DB, repos models.Repositories) *UserHandler {
+	return &UserHandler{db: db, users: repos.Users}
 }
 
 func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := models.GetUsers(h.db, context.Background())
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+	users, err := h.users.List(r.Context(), page)
 	if err != nil {
-		render.Status(r, http.StatusInternalServerError)
-		renderer.PrettyJSON(w, r, err.Error())
+		renderListError(w, r, err)
 		return
 	}
-	render.Status(r, http.StatusOK)
-	renderer.PrettyJSON(w, r, users)
+	renderPage(w, r, users)
 }
 
 func (h *UserHandler) ListUsersByRole(w http.ResponseWriter, r *http.Request) {
 	role := chi.URLParam(r, "role")
-	users, err := models.GetUsersByRole(h.db, context.Background(), role)
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+	users, err := h.users.ListByRole(r.Context(), role, page)
 	if err != nil {
-		render.Status(r, http.StatusInternalServerError)
-		renderer.PrettyJSON(w, r, err.Error())
+		renderListError(w, r, err)
 		return
 	}
-	render.Status(r, http.StatusOK)
-	renderer.PrettyJSON(w, r, users)
+	renderPage(w, r, users)
 }
 
 func (h *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
@@ -60,7 +69,7 @@ func (h *UserHandler) GetUsers(w http.ResponseWriter, r *http.Request) {
 		renderer.PrettyJSON(w, r, "Invalid user ID")
 		return
 	}
-	user, err := models.GetUserByID(h.db, context.Background(), idNum)
+	user, err := h.users.GetByID(r.Context(), idNum)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			render.Status(r, http.StatusNotFound)
@@ -97,12 +106,32 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	}
 	data.PasswordHash = string(hashedPassword)
 
-	err = models.CreateUser(h.db, context.Background(), data)
+	if data.Role == superAdminRole {
+		render.Status(r, http.StatusForbidden)
+		renderer.PrettyJSON(w, r, "Super admins can only be appointed with the superadmin command")
+		return
+	}
+
+	if data.CompanyID.Valid {
+		if _, err := models.GetCompanyByID(h.db, r.Context(), data.CompanyID.Int64); err != nil {
+			if err == sql.ErrNoRows {
+				render.Status(r, http.StatusNotFound)
+				renderer.PrettyJSON(w, r, "Company not found")
+				return
+			}
+			render.Status(r, http.StatusInternalServerError)
+			renderer.PrettyJSON(w, r, err.Error())
+			return
+		}
+	}
+
+	err = h.users.Create(r.Context(), data)
 	if err != nil {
 		render.Status(r, http.StatusInternalServerError)
 		renderer.PrettyJSON(w, r, err.Error())
 		return
 	}
+	recordAudit(h.db, r, models.AuditCreate, "user", data.ID, nil, data)
 	render.Status(r, http.StatusCreated)
 	renderer.PrettyJSON(w, r, data)
 }
@@ -116,7 +145,7 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	existingUser, err := models.GetUserByID(h.db, context.Background(), idNum)
+	existingUser, err := h.users.GetByID(r.Context(), idNum)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			render.Status(r, http.StatusNotFound)
@@ -139,17 +168,24 @@ func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		renderer.PrettyJSON(w, r, err.Error())
 		return
 	}
+	if existingUser.Role == superAdminRole || updateData.Role == superAdminRole {
+		render.Status(r, http.StatusForbidden)
+		renderer.PrettyJSON(w, r, "Super admins can only be appointed with the superadmin command")
+		return
+	}
 
+	before := *existingUser
 	existingUser.Name = updateData.Name
 	existingUser.Email = updateData.Email
 	existingUser.Role = updateData.Role
 
-	err = models.UpdateUser(h.db, context.Background(), existingUser)
+	err = h.users.Update(r.Context(), existingUser)
 	if err != nil {
 		render.Status(r, http.StatusInternalServerError)
 		renderer.PrettyJSON(w, r, err.Error())
 		return
 	}
+	recordAudit(h.db, r, models.AuditUpdate, "user", idNum, before, existingUser)
 	render.Status(r, http.StatusOK)
 	renderer.PrettyJSON(w, r, existingUser)
 }
@@ -163,20 +199,36 @@ func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = models.DeleteUser(h.db, context.Background(), idNum)
+	existingUser, err := h.users.GetByID(r.Context(), idNum)
+	if err != nil && err != sql.ErrNoRows {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	if existingUser != nil && existingUser.Role == superAdminRole {
+		render.Status(r, http.StatusForbidden)
+		renderer.PrettyJSON(w, r, "Super admins cannot be deleted from an organization")
+		return
+	}
+
+	err = h.users.Delete(r.Context(), idNum)
 	if err != nil {
 		render.Status(r, http.StatusInternalServerError)
 		renderer.PrettyJSON(w, r, err.Error())
 		return
 	}
+	if existingUser != nil {
+		recordAudit(h.db, r, models.AuditDelete, "user", idNum, existingUser, nil)
+	}
 	render.Status(r, http.StatusAccepted)
 	renderer.PrettyJSON(w, r, map[string]string{"message": "User deleted successfully"})
 }
 
 func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var creds struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email          string `json:"email"`
+		Password       string `json:"password"`
+		OrganizationID int64  `json:"organization_id"` // Only needed on hostnames of no organization
 	}
 	err := json.NewDecoder(r.Body).Decode(&creds)
 	if err != nil {
@@ -184,8 +236,12 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		renderer.PrettyJSON(w, r, "Invalid request body")
 		return
 	}
+	r, ok := publicTenant(h.db, w, r, creds.OrganizationID)
+	if !ok {
+		return
+	}
 
-	user, err := models.GetUserByEmail(h.db, context.Background(), creds.Email)
+	user, err := h.users.GetByEmail(r.Context(), creds.Email)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			render.Status(r, http.StatusUnauthorized)
@@ -205,12 +261,15 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &jwt.RegisteredClaims{
-		ExpiresAt: jwt.NewNumericDate(expirationTime),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		Subject:   strconv.FormatInt(user.ID, 10),
-		Issuer:    "goat",
-		Audience:  []string{user.Role},
+	claims := &middleware.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Subject:   strconv.FormatInt(user.ID, 10),
+			Issuer:    "goat",
+			Audience:  []string{user.Role},
+		},
+		TenantID: user.TenantID,
 	}
 
 	jwtKey := []byte(os.Getenv("JWT_SECRET"))
@@ -232,7 +291,8 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Email string `json:"email"`
+		Email          string `json:"email"`
+		OrganizationID int64  `json:"organization_id"`
 	}
 
 	if err := render.DecodeJSON(r.Body, &req); err != nil {
@@ -240,8 +300,12 @@ func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 		renderer.PrettyJSON(w, r, "Invalid request body")
 		return
 	}
+	r, ok := publicTenant(h.db, w, r, req.OrganizationID)
+	if !ok {
+		return
+	}
 
-	user, err := models.GetUserByEmail(h.db, context.Background(), req.Email)
+	user, err := h.users.GetByEmail(r.Context(), req.Email)
 	if err != nil {
 		render.Status(r, http.StatusNotFound)
 		renderer.PrettyJSON(w, r, "User with that email not found")
@@ -257,16 +321,18 @@ func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 	token := hex.EncodeToString(tokenBytes)
 	expires := time.Now().Add(time.Hour * 1).UTC()
 
+	before := *user
 	user.PasswordResetToken.String = token
 	user.PasswordResetToken.Valid = true
 	user.PasswordResetExpires.Time = expires
 	user.PasswordResetExpires.Valid = true
 
-	if err := models.UpdateUser(h.db, context.Background(), user); err != nil {
+	if err := h.users.Update(r.Context(), user); err != nil {
 		render.Status(r, http.StatusInternalServerError)
 		renderer.PrettyJSON(w, r, "Failed to save reset token")
 		return
 	}
+	recordAudit(h.db, r, models.AuditUpdate, "user", user.ID, before, user)
 
 	render.Status(r, http.StatusOK)
 	renderer.PrettyJSON(w, r, map[string]string{"message": "Password reset link sent to your email (check console for token)", "token": token})
@@ -274,8 +340,9 @@ func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 
 func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Token       string `json:"token"`
-		NewPassword string `json:"new_password"`
+		Token          string `json:"token"`
+		NewPassword    string `json:"new_password"`
+		OrganizationID int64  `json:"organization_id"`
 	}
 
 	if err := render.DecodeJSON(r.Body, &req); err != nil {
@@ -283,9 +350,12 @@ func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		renderer.PrettyJSON(w, r, "Invalid request body")
 		return
 	}
+	r, ok := publicTenant(h.db, w, r, req.OrganizationID)
+	if !ok {
+		return
+	}
 
-	user := new(models.User)
-	err := h.db.NewSelect().Model(user).Where("password_reset_token = ?", req.Token).Scan(context.Background())
+	user, err := h.users.GetByResetToken(r.Context(), req.Token)
 	if err != nil {
 		render.Status(r, http.StatusBadRequest)
 		renderer.PrettyJSON(w, r, "Invalid or expired token")
@@ -304,15 +374,17 @@ func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		renderer.PrettyJSON(w, r, "Failed to hash new password")
 		return
 	}
+	before := *user
 	user.PasswordHash = string(hashedPassword)
 	user.PasswordResetToken.Valid = false
 	user.PasswordResetExpires.Valid = false
 
-	if err := models.UpdateUser(h.db, context.Background(), user); err != nil {
+	if err := h.users.Update(r.Context(), user); err != nil {
 		render.Status(r, http.StatusInternalServerError)
 		renderer.PrettyJSON(w, r, err.Error())
 		return
 	}
+	recordAudit(h.db, r, models.AuditUpdate, "user", user.ID, before, user)
 
 	render.Status(r, http.StatusOK)
 	renderer.PrettyJSON(w, r, map[string]string{"message": "Password has been reset successfully"})
@@ -320,9 +392,10 @@ func (h *UserHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 
 func (h *UserHandler) RegisterCustomer(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Name     string `json:"name"`
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Name           string `json:"name"`
+		Email          string `json:"email"`
+		Password       string `json:"password"`
+		OrganizationID int64  `json:"organization_id"`
 	}
 
 	if err := render.DecodeJSON(r.Body, &req); err != nil {
@@ -330,6 +403,10 @@ func (h *UserHandler) RegisterCustomer(w http.ResponseWriter, r *http.Request) {
 		renderer.PrettyJSON(w, r, "Invalid request body")
 		return
 	}
+	r, ok := publicTenant(h.db, w, r, req.OrganizationID)
+	if !ok {
+		return
+	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -345,11 +422,12 @@ func (h *UserHandler) RegisterCustomer(w http.ResponseWriter, r *http.Request) {
 		Role:         "Customer",
 	}
 
-	if err := models.CreateUser(h.db, context.Background(), user); err != nil {
+	if err := h.users.Create(r.Context(), user); err != nil {
 		render.Status(r, http.StatusInternalServerError)
 		renderer.PrettyJSON(w, r, err.Error())
 		return
 	}
+	recordAudit(h.db, r, models.AuditCreate, "user", user.ID, nil, user)
 
 	render.Status(r, http.StatusCreated)
 	renderer.PrettyJSON(w, r, user)
@@ -0,0 +1,257 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/uptrace/bun"
+
+	"goat/app/renderer"
+	"goat/services/models"
+	"goat/services/query"
+)
+
+type ViewHandler struct {
+	db *bun.DB
+}
+
+func NewViewHandler(db *bun.DB) *ViewHandler {
+	return &ViewHandler{db: db}
+}
+
+// ListViews handles the request to list the caller's views and the views shared with their teams.
+// Each view carries the number of tickets currently in its queue.
+func (h *ViewHandler) ListViews(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	teamIDs, err := models.ListTeamIDsByUserID(h.db, r.Context(), userID)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	views, err := models.ListViewsForUser(h.db, r.Context(), userID, teamIDs)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	env := query.Env{UserID: userID, Now: time.Now()}
+	for i := range views {
+		filter, err := query.Filter(views[i].Query, env)
+		if err != nil {
+			// A view saved before a field was removed can no longer be counted; still list it.
+			continue
+		}
+		count, err := models.CountTickets(h.db, r.Context(), filter)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			renderer.PrettyJSON(w, r, err.Error())
+			return
+		}
+		views[i].TicketCount = &count
+	}
+
+	render.Status(r, http.StatusOK)
+	renderer.PrettyJSON(w, r, views)
+}
+
+// CreateView handles the request to save a named ticket query, optionally shared with a team.
+func (h *ViewHandler) CreateView(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name   string `json:"name"`
+		Query  string `json:"query"`
+		TeamID *int64 `json:"team_id"`
+	}
+
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+
+	if req.Name == "" {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "View name is required")
+		return
+	}
+
+	parsed, err := query.Parse(req.Query)
+	if err == nil {
+		_, err = parsed.Compile(query.Env{UserID: userID, Now: time.Now()})
+	}
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		renderer.PrettyJSON(w, r, "Invalid query: "+err.Error())
+		return
+	}
+
+	view := models.View{
+		Name:    req.Name,
+		Query:   parsed.String(),
+		OwnerID: userID,
+	}
+
+	if req.TeamID != nil {
+		teamIDs, err := models.ListTeamIDsByUserID(h.db, r.Context(), userID)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			renderer.PrettyJSON(w, r, err.Error())
+			return
+		}
+		if !slices.Contains(teamIDs, *req.TeamID) {
+			if currentUserRole(r) != "Admin" {
+				render.Status(r, http.StatusForbidden)
+				renderer.PrettyJSON(w, r, "You can only share views with your own teams")
+				return
+			}
+			if _, err := models.GetTeamByID(h.db, r.Context(), *req.TeamID); err != nil {
+				renderTeamLookupError(w, r, err)
+				return
+			}
+		}
+		view.TeamID = sql.NullInt64{Int64: *req.TeamID, Valid: true}
+	}
+
+	if err := models.CreateView(h.db, r.Context(), &view); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditCreate, "view", view.ID, nil, view)
+
+	render.Status(r, http.StatusCreated)
+	renderer.PrettyJSON(w, r, view)
+}
+
+// ListViewTickets handles the request to list the tickets in a view's queue.
+func (h *ViewHandler) ListViewTickets(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	view, ok := h.visibleView(w, r, userID)
+	if !ok {
+		return
+	}
+
+	filter, err := query.Filter(view.Query, query.Env{UserID: userID, Now: time.Now()})
+	if err != nil {
+		render.Status(r, http.StatusUnprocessableEntity)
+		renderer.PrettyJSON(w, r, "Saved query is no longer valid: "+err.Error())
+		return
+	}
+
+	page, ok := parsePageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	tickets, err := models.ListTickets(h.db, r.Context(), page, filter)
+	if err != nil {
+		renderListError(w, r, err)
+		return
+	}
+
+	renderPage(w, r, tickets)
+}
+
+// DeleteView handles the request to delete a saved view. Only its owner or an admin may delete it.
+func (h *ViewHandler) DeleteView(w http.ResponseWriter, r *http.Request) {
+	userID, ok := currentUserID(w, r)
+	if !ok {
+		return
+	}
+
+	view, ok := h.visibleView(w, r, userID)
+	if !ok {
+		return
+	}
+
+	if view.OwnerID != userID && currentUserRole(r) != "Admin" {
+		render.Status(r, http.StatusForbidden)
+		renderer.PrettyJSON(w, r, "You are not authorized to delete this view")
+		return
+	}
+
+	if err := models.DeleteView(h.db, r.Context(), view.ID); err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return
+	}
+	recordAudit(h.db, r, models.AuditDelete, "view", view.ID, view, nil)
+
+	render.Status(r, http.StatusAccepted)
+	renderer.PrettyJSON(w, r, map[string]string{"message": "View deleted successfully"})
+}
+
+// visibleView loads the view named in the URL if the user owns it, belongs to its team, or is an admin.
+func (h *ViewHandler) visibleView(w http.ResponseWriter, r *http.Request, userID int64) (*models.View, bool) {
+	id, ok := urlParamID(w, r, "id", "view")
+	if !ok {
+		return nil, false
+	}
+
+	view, err := models.GetViewByID(h.db, r.Context(), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			render.Status(r, http.StatusNotFound)
+			renderer.PrettyJSON(w, r, "View not found")
+			return nil, false
+		}
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return nil, false
+	}
+
+	visible, err := h.canSeeView(r.Context(), view, userID, currentUserRole(r))
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		renderer.PrettyJSON(w, r, err.Error())
+		return nil, false
+	}
+	if !visible {
+		render.Status(r, http.StatusForbidden)
+		renderer.PrettyJSON(w, r, "You are not authorized to view this view")
+		return nil, false
+	}
+	return view, true
+}
+
+func (h *ViewHandler) canSeeView(ctx context.Context, view *models.View, userID int64, role string) (bool, error) {
+	// Views of other organizations are not loaded in the first place; this guards against a view
+	// read without the request's scope.
+	tenantID, err := models.TenantID(ctx)
+	if err != nil {
+		return false, err
+	}
+	if tenantID != 0 && view.TenantID != tenantID {
+		return false, nil
+	}
+	if view.OwnerID == userID || role == "Admin" {
+		return true, nil
+	}
+	if !view.TeamID.Valid {
+		return false, nil
+	}
+	teamIDs, err := models.ListTeamIDsByUserID(h.db, ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(teamIDs, view.TeamID.Int64), nil
+}
@@ -2,13 +2,21 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/uptrace/bun"
 
 	router "goat/app/controllers"
+	"goat/services/config"
+	"goat/services/migrate"
 	model "goat/services/models"
 )
 
@@ -180,7 +188,291 @@ func demoCommentOps(db *bun.DB, ctx context.Context) {
 	}
 }
 
+// auditCommand runs "audit verify", which checks the audit log's hash chain and exits non-zero if
+// it is broken, or "audit keygen", which prints a new checkpoint signing key.
+func auditCommand(args []string) int {
+	switch {
+	case len(args) == 1 && args[0] == "verify":
+		publicKey, err := config.AuditPublicKey()
+		if err != nil {
+			log.Println(err)
+			return 2
+		}
+		result, err := model.VerifyAuditChain(config.ConnectDB(), context.Background(), publicKey)
+		if err != nil {
+			log.Printf("Error verifying audit log: %v\n", err)
+			return 2
+		}
+
+		fmt.Printf("Checked %d entries and %d checkpoints (%d legacy entries skipped)\n",
+			result.EntriesChecked, result.CheckpointsChecked, result.LegacyEntries)
+		if !result.SignaturesChecked {
+			fmt.Println("Warning: AUDIT_PUBLIC_KEY is not set, checkpoint signatures were not checked")
+		}
+		if !result.Valid {
+			fmt.Printf("BROKEN at entry %d: %s\n", result.BrokenEntryID, result.Reason)
+			if result.BrokenCheckpointID != 0 {
+				fmt.Printf("Checkpoint: %d\n", result.BrokenCheckpointID)
+			}
+			return 1
+		}
+		fmt.Println("Audit log is intact")
+		return 0
+
+	case len(args) == 1 && args[0] == "keygen":
+		publicKey, privateKey, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			log.Println(err)
+			return 2
+		}
+		fmt.Printf("AUDIT_SIGNING_KEY=%s\n", base64.StdEncoding.EncodeToString(privateKey.Seed()))
+		fmt.Printf("AUDIT_PUBLIC_KEY=%s\n", base64.StdEncoding.EncodeToString(publicKey))
+		return 0
+	}
+
+	fmt.Println("Usage: goat audit verify | goat audit keygen")
+	return 2
+}
+
+// migrateCommand runs "migrate up [N]", which applies pending schema migrations (all unless N is
+// given), "migrate down [N]", which reverts the last N (default 1), "migrate status" or "migrate
+// baseline", which records the initial migration of a database created before migrations.
+func migrateCommand(args []string) int {
+	usage := func() int {
+		fmt.Println("Usage: goat migrate up [N] | goat migrate down [N] | goat migrate status | goat migrate baseline")
+		return 2
+	}
+	if len(args) == 0 || len(args) > 2 {
+		return usage()
+	}
+	steps := 0
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 || args[0] == "status" || args[0] == "baseline" {
+			return usage()
+		}
+		steps = n
+	}
+
+	ctx := context.Background()
+	db := config.ConnectDB()
+	switch args[0] {
+	case "up":
+		applied, err := migrate.Up(ctx, db, steps)
+		for _, m := range applied {
+			fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Printf("Error migrating: %v\n", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+		return 0
+
+	case "down":
+		if steps == 0 {
+			steps = 1
+		}
+		reverted, err := migrate.Down(ctx, db, steps)
+		for _, m := range reverted {
+			fmt.Printf("Reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Printf("Error migrating: %v\n", err)
+			return 1
+		}
+		return 0
+
+	case "baseline":
+		m, err := migrate.Baseline(ctx, db)
+		if err != nil {
+			log.Printf("Error recording the baseline: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Recorded %04d_%s as applied\n", m.Version, m.Name)
+		return 0
+
+	case "status":
+		statuses, err := migrate.GetStatus(ctx, db)
+		if err != nil {
+			log.Printf("Error reading migrations: %v\n", err)
+			return 2
+		}
+		code := 0
+		for _, s := range statuses {
+			state := "pending"
+			switch {
+			case s.Unknown:
+				state = "applied " + s.AppliedAt.Format(time.RFC3339) + ", unknown to this release"
+			case s.Modified:
+				state = "applied " + s.AppliedAt.Format(time.RFC3339) + ", CHANGED since"
+				code = 1
+			case s.Applied:
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s: %s\n", s.Version, s.Name, state)
+		}
+		return code
+	}
+	return usage()
+}
+
+// migrateDataCommand runs "migrate-data [-batch N] [-restart] [-verify-only] sql|document", which
+// copies users, tickets and comments from the database configured by DB_* to the SQL database
+// configured by TARGET_DB_* or to the document database at MONGO_URI, then compares both sides.
+func migrateDataCommand(args []string) int {
+	flags := flag.NewFlagSet("migrate-data", flag.ContinueOnError)
+	batchSize := flags.Int("batch", migrate.DefaultBatchSize, "rows copied at a time")
+	restart := flags.Bool("restart", false, "copy everything again instead of resuming")
+	verifyOnly := flags.Bool("verify-only", false, "only compare the source and target")
+	flags.Usage = func() {
+		fmt.Println("Usage: goat migrate-data [-batch N] [-restart] [-verify-only] sql|document")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	// Every organization is copied, each row keeping its own.
+	ctx := model.WithAllTenants(context.Background())
+	sourceDB := config.ConnectDB()
+	source := migrate.NewSQLData(sourceDB)
+	var target migrate.DataTarget
+	switch flags.Arg(0) {
+	case "sql":
+		targetDB := config.ConnectTargetDB()
+		if _, err := migrate.Up(ctx, targetDB, 0); err != nil {
+			log.Printf("Error creating the target schema: %v\n", err)
+			return 2
+		}
+		sqlTarget := migrate.NewSQLData(targetDB)
+		if !*verifyOnly {
+			if err := sqlTarget.CopyOrganizations(ctx, source); err != nil {
+				log.Printf("Error copying organizations: %v\n", err)
+				return 2
+			}
+			if err := sqlTarget.CopyCompanies(ctx, source); err != nil {
+				log.Printf("Error copying companies: %v\n", err)
+				return 2
+			}
+			if err := sqlTarget.CopyCategories(ctx, source); err != nil {
+				log.Printf("Error copying categories: %v\n", err)
+				return 2
+			}
+		}
+		target = sqlTarget
+	case "document":
+		docDB, err := config.ConnectDocumentDB()
+		if docDB == nil && err == nil {
+			err = fmt.Errorf("MONGO_URI is not set")
+		}
+		if err != nil {
+			log.Println(err)
+			return 2
+		}
+		store, err := model.NewDocumentStore(ctx, docDB)
+		if err != nil {
+			log.Println(err)
+			return 2
+		}
+		target = migrate.NewDocumentData(store)
+	default:
+		flags.Usage()
+		return 2
+	}
+
+	if !*verifyOnly {
+		err := migrate.CopyData(ctx, source, target, migrate.CopyOptions{
+			BatchSize: *batchSize,
+			Restart:   *restart,
+			Progress: func(entity string, n int) {
+				fmt.Printf("Copied %d %s\n", n, entity)
+			},
+		})
+		if err != nil {
+			log.Printf("Error copying data, run again to resume: %v\n", err)
+			return 1
+		}
+	}
+
+	reports, err := migrate.VerifyData(ctx, source, target, *batchSize)
+	if err != nil {
+		log.Printf("Error verifying data: %v\n", err)
+		return 2
+	}
+	code := 0
+	fmt.Printf("%-10s %10s %10s  %s\n", "ENTITY", "SOURCE", "TARGET", "CHECKSUM")
+	for _, r := range reports {
+		state := "match"
+		if !r.Match() {
+			state = fmt.Sprintf("MISMATCH (source %.12s, target %.12s)", r.SourceChecksum, r.TargetChecksum)
+			code = 1
+		}
+		fmt.Printf("%-10s %10d %10d  %s\n", r.Entity, r.SourceCount, r.TargetCount, state)
+	}
+	return code
+}
+
+// superadminCommand runs "superadmin grant <email> [org]", which makes a user of an organization
+// (default 1) a super admin, or "superadmin revoke <email> [org]", which makes them an admin of
+// their organization again. Super admins manage every organization under /superadmin.
+func superadminCommand(args []string) int {
+	if len(args) < 2 || len(args) > 3 || (args[0] != "grant" && args[0] != "revoke") {
+		fmt.Println("Usage: goat superadmin grant|revoke <email> [organization ID]")
+		return 2
+	}
+	orgID := model.DefaultTenantID
+	if len(args) == 3 {
+		n, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil || n <= 0 {
+			fmt.Println("Usage: goat superadmin grant|revoke <email> [organization ID]")
+			return 2
+		}
+		orgID = n
+	}
+
+	ctx := model.WithTenant(context.Background(), orgID)
+	db := config.ConnectDB()
+	user, err := model.GetUserByEmail(db, ctx, args[1])
+	if err != nil {
+		log.Printf("Error finding %s in organization %d: %v\n", args[1], orgID, err)
+		return 1
+	}
+	before := *user
+	user.Role = "SuperAdmin"
+	if args[0] == "revoke" {
+		user.Role = "Admin"
+	}
+	if err := model.UpdateUser(db, ctx, user); err != nil {
+		log.Printf("Error updating %s: %v\n", user.Email, err)
+		return 1
+	}
+	changes, _ := model.Diff(before, user)
+	entry := model.AuditEntry{Action: model.AuditUpdate, EntityType: "user", EntityID: user.ID, Changes: changes}
+	if err := model.CreateAuditEntry(db, ctx, &entry); err != nil {
+		log.Printf("Error recording audit entry: %v\n", err)
+	}
+	fmt.Printf("%s is now %s\n", user.Email, user.Role)
+	return 0
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		os.Exit(auditCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(migrateCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate-data" {
+		os.Exit(migrateDataCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "superadmin" {
+		os.Exit(superadminCommand(os.Args[2:]))
+	}
+
 	// ctx := context.Background()
 	// db := config.ConnectDB()
 
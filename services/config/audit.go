@@ -0,0 +1,52 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"time"
+)
+
+// AuditSigningKey returns the key used to sign audit log checkpoints, read from AUDIT_SIGNING_KEY
+// as a base64-encoded 32-byte ed25519 seed. It returns nil when the variable is unset.
+func AuditSigningKey() (ed25519.PrivateKey, error) {
+	v := os.Getenv("AUDIT_SIGNING_KEY")
+	if v == "" {
+		return nil, nil
+	}
+	seed, err := base64.StdEncoding.DecodeString(v)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("AUDIT_SIGNING_KEY must be a base64-encoded %d-byte seed", ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// AuditPublicKey returns the key used to verify audit log checkpoints: AUDIT_PUBLIC_KEY as base64,
+// or else the public half of AUDIT_SIGNING_KEY. Auditors only need the former. It returns nil when
+// neither variable is set.
+func AuditPublicKey() (ed25519.PublicKey, error) {
+	v := os.Getenv("AUDIT_PUBLIC_KEY")
+	if v == "" {
+		key, err := AuditSigningKey()
+		if key == nil || err != nil {
+			return nil, err
+		}
+		return key.Public().(ed25519.PublicKey), nil
+	}
+	key, err := base64.StdEncoding.DecodeString(v)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("AUDIT_PUBLIC_KEY must be a base64-encoded %d-byte key", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// AuditCheckpointInterval returns how often the audit log is checkpointed, read from
+// AUDIT_CHECKPOINT_INTERVAL as a duration such as "15m" (default: one hour).
+func AuditCheckpointInterval() time.Duration {
+	d, err := time.ParseDuration(os.Getenv("AUDIT_CHECKPOINT_INTERVAL"))
+	if err != nil || d <= 0 {
+		return time.Hour
+	}
+	return d
+}
@@ -0,0 +1,17 @@
+package config
+
+import (
+	"os"
+	"time"
+)
+
+// CommentEditWindow returns how long after posting authors may edit or delete their own comments,
+// read from COMMENT_EDIT_WINDOW as a duration such as "30m" (default: 15 minutes). Admins are not
+// limited.
+func CommentEditWindow() time.Duration {
+	d, err := time.ParseDuration(os.Getenv("COMMENT_EDIT_WINDOW"))
+	if err != nil || d <= 0 {
+		return 15 * time.Minute
+	}
+	return d
+}
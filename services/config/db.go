@@ -3,30 +3,63 @@ package config
 import (
 	"database/sql"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
 
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/mysqldialect"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"github.com/uptrace/bun/driver/sqliteshim"
 )
 
+// ConnectDB opens the database selected by DB_DRIVER: "mysql" (the default) for MySQL or MariaDB,
+// "postgres" for PostgreSQL, or "sqlite" for a single file, which suits single-node and test
+// deployments.
 func ConnectDB() *bun.DB {
-	dbHost := os.Getenv("DB_HOST")
+	return connectDB("DB_")
+}
+
+// ConnectTargetDB opens the database migrate-data copies to, configured like ConnectDB by the same
+// variables prefixed with TARGET_, e.g. TARGET_DB_DRIVER.
+func ConnectTargetDB() *bun.DB {
+	return connectDB("TARGET_DB_")
+}
+
+func connectDB(prefix string) *bun.DB {
+	switch driver := os.Getenv(prefix + "DRIVER"); driver {
+	case "", "mysql":
+		return connectMySQL(prefix)
+	case "postgres":
+		return connectPostgres(prefix)
+	case "sqlite":
+		return connectSQLite(prefix)
+	default:
+		panic(fmt.Sprintf("unknown %sDRIVER %q, expected mysql, postgres or sqlite", prefix, driver))
+	}
+}
+
+func connectMySQL(prefix string) *bun.DB {
+	dbHost := os.Getenv(prefix + "HOST")
 	if dbHost == "" {
 		dbHost = "127.0.0.1" // Default to localhost
 	}
-	dbPort := os.Getenv("DB_PORT")
+	dbPort := os.Getenv(prefix + "PORT")
 	if dbPort == "" {
 		dbPort = "3306" // Default MySQL port
 	}
-	dbUser := os.Getenv("DB_USER")
+	dbUser := os.Getenv(prefix + "USER")
 	if dbUser == "" {
 		dbUser = "casaos" // Default user
 	}
-	dbPassword := os.Getenv("DB_PASSWORD")
+	dbPassword := os.Getenv(prefix + "PASSWORD")
 	if dbPassword == "" {
 		dbPassword = "casaos" // Default password
 	}
-	dbName := os.Getenv("DB_NAME")
+	dbName := os.Getenv(prefix + "NAME")
 	if dbName == "" {
 		dbName = "casaos" // Default database name
 	}
@@ -38,3 +71,77 @@ func ConnectDB() *bun.DB {
 	}
 	return bun.NewDB(sqldb, mysqldialect.New())
 }
+
+// connectPostgres opens a PostgreSQL database with the same variables as MySQL. Set
+// DB_SSLMODE to "require" or stricter for servers that need TLS.
+func connectPostgres(prefix string) *bun.DB {
+	dbHost := os.Getenv(prefix + "HOST")
+	if dbHost == "" {
+		dbHost = "127.0.0.1" // Default to localhost
+	}
+	dbPort := os.Getenv(prefix + "PORT")
+	if dbPort == "" {
+		dbPort = "5432" // Default PostgreSQL port
+	}
+	dbUser := os.Getenv(prefix + "USER")
+	if dbUser == "" {
+		dbUser = "casaos" // Default user
+	}
+	dbPassword := os.Getenv(prefix + "PASSWORD")
+	if dbPassword == "" {
+		dbPassword = "casaos" // Default password
+	}
+	dbName := os.Getenv(prefix + "NAME")
+	if dbName == "" {
+		dbName = "casaos" // Default database name
+	}
+	sslMode := os.Getenv(prefix + "SSLMODE")
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+
+	dsn := (&url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(dbUser, dbPassword),
+		Host:     net.JoinHostPort(dbHost, dbPort),
+		Path:     "/" + dbName,
+		RawQuery: "sslmode=" + url.QueryEscape(sslMode),
+	}).String()
+	sqldb := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dsn)))
+	return bun.NewDB(sqldb, pgdialect.New())
+}
+
+// connectSQLite opens the SQLite file at DB_PATH. Use ":memory:" for a throwaway database.
+func connectSQLite(prefix string) *bun.DB {
+	dbPath := os.Getenv(prefix + "PATH")
+	if dbPath == "" {
+		dbPath = "goat.db" // Default file in the working directory
+	}
+
+	// Foreign keys are off by default in SQLite and the trash and merge code relies on their
+	// cascades. The busy timeout makes concurrent writers wait for each other instead of failing.
+	dsn := "file:" + dbPath + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if sqliteshim.DriverName() == "sqlite3" {
+		dsn = "file:" + dbPath + "?_foreign_keys=1&_busy_timeout=5000"
+	}
+	sqldb, err := sql.Open(sqliteshim.ShimName, dsn)
+	if err != nil {
+		panic(err)
+	}
+	if dbPath == ":memory:" {
+		// Every connection to ":memory:" gets its own empty database.
+		sqldb.SetMaxOpenConns(1)
+	}
+
+	return bun.NewDB(sqldb, sqlitedialect.New())
+}
+
+// AutoMigrate reports whether the server applies pending schema migrations on start, read from
+// DB_AUTO_MIGRATE. It defaults to true on SQLite, whose database is usually private to one node,
+// and to false elsewhere, where "goat migrate up" is run as a deployment step instead.
+func AutoMigrate() bool {
+	if v, err := strconv.ParseBool(os.Getenv("DB_AUTO_MIGRATE")); err == nil {
+		return v
+	}
+	return os.Getenv("DB_DRIVER") == "sqlite"
+}
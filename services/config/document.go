@@ -0,0 +1,60 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ConnectDocumentDB connects to the MongoDB-compatible database at MONGO_URI and returns the
+// database named by MONGO_DATABASE (default "goat"). It returns nil when MONGO_URI is unset.
+func ConnectDocumentDB() (*mongo.Database, error) {
+	uri := os.Getenv("MONGO_URI")
+	if uri == "" {
+		return nil, nil
+	}
+	dbName := os.Getenv("MONGO_DATABASE")
+	if dbName == "" {
+		dbName = "goat" // Default database name
+	}
+
+	client, err := mongo.Connect(options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MONGO_URI: %w", err)
+	}
+	if err := client.Ping(context.Background(), nil); err != nil {
+		return nil, fmt.Errorf("failed to reach MONGO_URI: %w", err)
+	}
+	return client.Database(dbName), nil
+}
+
+// DocumentMode returns how the document database is used, read from DOCUMENT_MODE: "dual" (the
+// default) writes to both databases, "document" uses the document database alone.
+func DocumentMode() (string, error) {
+	switch mode := os.Getenv("DOCUMENT_MODE"); mode {
+	case "", "dual":
+		return "dual", nil
+	case "document":
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown DOCUMENT_MODE %q, expected dual or document", mode)
+	}
+}
+
+// DocumentReadPercent returns the share of reads served by the document database in dual mode,
+// read from MONGO_READ_PERCENT as a number from 0 (the default) to 100.
+func DocumentReadPercent() (int, error) {
+	v := os.Getenv("MONGO_READ_PERCENT")
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || n > 100 {
+		return 0, fmt.Errorf("MONGO_READ_PERCENT must be a number from 0 to 100")
+	}
+	return n, nil
+}
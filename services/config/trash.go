@@ -0,0 +1,17 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// DeletedRetention returns how long deleted users, tickets and comments stay in the trash before
+// they are purged, read from DELETED_RETENTION_DAYS (default: 30 days).
+func DeletedRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("DELETED_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
@@ -0,0 +1,42 @@
+// Package markdown renders the Markdown of comments and ticket descriptions to HTML that is safe to
+// embed in a page.
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// md converts CommonMark with GitHub-style tables. Raw HTML in the source is dropped rather than
+// passed through.
+var md = goldmark.New(goldmark.WithExtensions(
+	extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+))
+
+// policy strips everything but formatting from the rendered HTML: no scripts, styles or event
+// handlers, and links and images only to http, https and mailto URLs.
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+	p.RequireNoReferrerOnLinks(true)
+	return p
+}()
+
+// Render converts Markdown source to sanitized HTML. Source that fails to convert is returned
+// escaped as a single paragraph.
+func Render(source string) string {
+	if source == "" {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(source), &buf); err != nil {
+		return "<p>" + html.EscapeString(source) + "</p>\n"
+	}
+	return policy.Sanitize(buf.String())
+}
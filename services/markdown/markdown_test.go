@@ -0,0 +1,138 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    []string // Fragments the output must contain
+		without []string // Fragments it must not contain
+	}{
+		{
+			name:    "script",
+			source:  "Hi <script>alert(1)</script> there",
+			want:    []string{"Hi", "there"},
+			without: []string{"<script", "alert(1)</script>"},
+		},
+		{
+			name:    "script block",
+			source:  "<script>\nalert(1)\n</script>",
+			without: []string{"<script"},
+		},
+		{
+			name:    "onerror",
+			source:  `<img src="x" onerror="alert(1)">`,
+			without: []string{"onerror", "<img"},
+		},
+		{
+			name:    "onclick",
+			source:  `<a href="https://example.com" onclick="alert(1)">x</a>`,
+			without: []string{"onclick"},
+		},
+		{
+			name:    "javascript link",
+			source:  "[click](javascript:alert(1))",
+			want:    []string{"click"},
+			without: []string{"javascript:"},
+		},
+		{
+			name:    "javascript link with entities",
+			source:  "[click](jav&#x61;script:alert(1))",
+			without: []string{"javascript:", "jav&#x61;script"},
+		},
+		{
+			name:    "data link",
+			source:  "[click](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)",
+			without: []string{"data:"},
+		},
+		{
+			name:    "javascript image",
+			source:  "![pic](javascript:alert(1))",
+			without: []string{"javascript:"},
+		},
+		{
+			name:    "data image",
+			source:  "![pic](data:image/svg+xml;base64,PHN2Zz48L3N2Zz4=)",
+			without: []string{"data:"},
+		},
+		{
+			name:    "iframe",
+			source:  `<iframe src="https://example.com"></iframe>`,
+			without: []string{"<iframe"},
+		},
+		{
+			name:   "link",
+			source: "See [the docs](https://example.com/docs).",
+			want:   []string{`<a href="https://example.com/docs"`, `rel="nofollow noreferrer"`, ">the docs</a>"},
+		},
+		{
+			name:   "mailto link",
+			source: "[mail us](mailto:help@example.com)",
+			want:   []string{`href="mailto:help@example.com"`},
+		},
+		{
+			name:   "image",
+			source: "![logo](https://example.com/logo.png)",
+			want:   []string{`<img src="https://example.com/logo.png" alt="logo"`},
+		},
+		{
+			name:   "table",
+			source: "| Name | Count |\n|:-----|------:|\n| a | 1 |\n",
+			want:   []string{"<table>", `<th align="left">Name</th>`, `<td align="right">1</td>`},
+		},
+		{
+			name:   "code fence",
+			source: "```go\nif a < b {\n}\n```",
+			want:   []string{`<pre><code class="language-go">`, "if a &lt; b {"},
+		},
+		{
+			name:   "inline formatting",
+			source: "**bold** and `code`",
+			want:   []string{"<strong>bold</strong>", "<code>code</code>"},
+		},
+	}
+	for _, tt := range tests {
+		got := Render(tt.source)
+		for _, s := range tt.want {
+			if !strings.Contains(got, s) {
+				t.Errorf("%s: output lacks %q:\n%s", tt.name, s, got)
+			}
+		}
+		for _, s := range tt.without {
+			if strings.Contains(got, s) {
+				t.Errorf("%s: output contains %q:\n%s", tt.name, s, got)
+			}
+		}
+	}
+}
+
+// TestPolicy checks the sanitizer on its own, in case the converter ever passes raw HTML through.
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		html    string
+		without string
+	}{
+		{`<p>a<script>alert(1)</script></p>`, "<script"},
+		{`<img src="https://example.com/a.png" onerror="alert(1)">`, "onerror"},
+		{`<a href="https://example.com" onclick="alert(1)">x</a>`, "onclick"},
+		{`<a href="javascript:alert(1)">x</a>`, "javascript:"},
+		{`<img src="data:image/png;base64,AAAA">`, "data:"},
+		{`<iframe src="https://example.com"></iframe>`, "<iframe"},
+		{`<p style="color: red">x</p>`, "style"},
+	}
+	for _, tt := range tests {
+		if got := policy.Sanitize(tt.html); strings.Contains(got, tt.without) {
+			t.Errorf("Sanitize(%q) = %q, still contains %q", tt.html, got, tt.without)
+		}
+	}
+}
+
+func TestRenderEmpty(t *testing.T) {
+	if got := Render(""); got != "" {
+		t.Errorf("Render(\"\") = %q, want \"\"", got)
+	}
+}
@@ -0,0 +1,214 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"goat/services/models"
+)
+
+// DataSource reads rows for CopyData and VerifyData. Each method returns up to limit rows with IDs
+// above afterID in ID order, including rows in the trash.
+type DataSource interface {
+	Users(ctx context.Context, afterID int64, limit int) ([]models.User, error)
+	Tickets(ctx context.Context, afterID int64, limit int) ([]models.Ticket, error)
+	Comments(ctx context.Context, afterID int64, limit int) ([]models.Comment, error)
+}
+
+// DataTarget stores copied rows under their original IDs, replacing rows copied before, and keeps
+// track of how far each entity got so an interrupted copy can resume.
+type DataTarget interface {
+	DataSource
+	PutUsers(ctx context.Context, users []models.User) error
+	PutTickets(ctx context.Context, tickets []models.Ticket) error
+	PutComments(ctx context.Context, comments []models.Comment) error
+	Progress(ctx context.Context, entity string) (int64, error)
+	SaveProgress(ctx context.Context, entity string, lastID int64) error
+	ResetProgress(ctx context.Context) error
+	// Finish runs once everything is copied.
+	Finish(ctx context.Context) error
+}
+
+// CopyOptions tunes CopyData.
+type CopyOptions struct {
+	BatchSize int
+	Restart   bool                       // Copy everything again instead of resuming
+	Progress  func(entity string, n int) // Called after each batch with the rows copied so far
+}
+
+// DefaultBatchSize is how many rows CopyData moves at a time unless told otherwise.
+const DefaultBatchSize = 500
+
+// CopyData copies users, tickets and comments, in that order so references hold, from source to
+// target in batches. It resumes after the last batch a previous run finished unless opts.Restart
+// is set. Rows changed in the source behind the resume point are not copied again, so run it with
+// Restart, or keep dual writes on, to catch up before switching over.
+func CopyData(ctx context.Context, source DataSource, target DataTarget, opts CopyOptions) error {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.Restart {
+		if err := target.ResetProgress(ctx); err != nil {
+			return err
+		}
+	}
+	if err := copyEntity(ctx, "users", target, opts, source.Users, target.PutUsers, func(u *models.User) int64 { return u.ID }); err != nil {
+		return err
+	}
+	if err := copyEntity(ctx, "tickets", target, opts, source.Tickets, target.PutTickets, func(t *models.Ticket) int64 { return t.ID }); err != nil {
+		return err
+	}
+	if err := copyEntity(ctx, "comments", target, opts, source.Comments, target.PutComments, func(c *models.Comment) int64 { return c.ID }); err != nil {
+		return err
+	}
+	return target.Finish(ctx)
+}
+
+func copyEntity[T any](ctx context.Context, entity string, target DataTarget, opts CopyOptions,
+	read func(context.Context, int64, int) ([]T, error), put func(context.Context, []T) error, id func(*T) int64) error {
+	lastID, err := target.Progress(ctx, entity)
+	if err != nil {
+		return fmt.Errorf("failed to read the progress of %s: %w", entity, err)
+	}
+	copied := 0
+	for {
+		rows, err := read(ctx, lastID, opts.BatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to read %s after ID %d: %w", entity, lastID, err)
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		if err := put(ctx, rows); err != nil {
+			return fmt.Errorf("failed to copy %s after ID %d: %w", entity, lastID, err)
+		}
+		lastID = id(&rows[len(rows)-1])
+		if err := target.SaveProgress(ctx, entity, lastID); err != nil {
+			return fmt.Errorf("failed to save the progress of %s: %w", entity, err)
+		}
+		copied += len(rows)
+		if opts.Progress != nil {
+			opts.Progress(entity, copied)
+		}
+	}
+}
+
+// EntityReport compares one entity in the source and target of a copy.
+type EntityReport struct {
+	Entity         string
+	SourceCount    int
+	TargetCount    int
+	SourceChecksum string
+	TargetChecksum string
+}
+
+// Match reports whether both sides hold the same rows.
+func (r EntityReport) Match() bool {
+	return r.SourceCount == r.TargetCount && r.SourceChecksum == r.TargetChecksum
+}
+
+// VerifyData counts and checksums the users, tickets and comments on both sides. Checksums cover
+// every copied column, the organization included, in ID order, with times cut to whole seconds since not every database keeps
+// fractions.
+func VerifyData(ctx context.Context, source DataSource, target DataSource, batchSize int) ([]EntityReport, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	reports := []EntityReport{{Entity: "users"}, {Entity: "tickets"}, {Entity: "comments"}}
+	for i, side := range []DataSource{source, target} {
+		counts := make([]int, 3)
+		sums := make([]string, 3)
+		var err error
+		if counts[0], sums[0], err = checksum(ctx, batchSize, side.Users, func(u *models.User) int64 { return u.ID }, writeUser); err != nil {
+			return nil, err
+		}
+		if counts[1], sums[1], err = checksum(ctx, batchSize, side.Tickets, func(t *models.Ticket) int64 { return t.ID }, writeTicket); err != nil {
+			return nil, err
+		}
+		if counts[2], sums[2], err = checksum(ctx, batchSize, side.Comments, func(c *models.Comment) int64 { return c.ID }, writeComment); err != nil {
+			return nil, err
+		}
+		for j := range reports {
+			if i == 0 {
+				reports[j].SourceCount, reports[j].SourceChecksum = counts[j], sums[j]
+			} else {
+				reports[j].TargetCount, reports[j].TargetChecksum = counts[j], sums[j]
+			}
+		}
+	}
+	return reports, nil
+}
+
+func checksum[T any](ctx context.Context, batchSize int, read func(context.Context, int64, int) ([]T, error),
+	id func(*T) int64, write func(io.Writer, *T)) (int, string, error) {
+	h := sha256.New()
+	count := 0
+	lastID := int64(0)
+	for {
+		rows, err := read(ctx, lastID, batchSize)
+		if err != nil {
+			return 0, "", err
+		}
+		if len(rows) == 0 {
+			return count, hex.EncodeToString(h.Sum(nil)), nil
+		}
+		for i := range rows {
+			write(h, &rows[i])
+		}
+		count += len(rows)
+		lastID = id(&rows[len(rows)-1])
+	}
+}
+
+func writeUser(w io.Writer, u *models.User) {
+	fmt.Fprintf(w, "%d|%d|%q|%q|%q|%q|%s|%s|%q|%s|%s\n", u.ID, u.TenantID, u.Name, u.Email, u.PasswordHash, u.Role,
+		nullInt(u.CompanyID), checksumTime(u.CreatedAt), nullString(u.PasswordResetToken), nullTime(u.PasswordResetExpires), timePtr(u.DeletedAt))
+}
+
+func writeTicket(w io.Writer, t *models.Ticket) {
+	fmt.Fprintf(w, "%d|%d|%q|%q|%q|%q|%q|%d|%s|%s|%s|%s|%s|%d|%s\n", t.ID, t.TenantID, t.Title, t.Type, t.Description, t.Status,
+		t.Priority, t.RequesterID, nullInt(t.AssigneeID), nullInt(t.CategoryID), checksumTime(t.CreatedAt),
+		checksumTime(t.UpdatedAt), nullTime(t.ClosedAt), t.Version, timePtr(t.DeletedAt))
+}
+
+func writeComment(w io.Writer, c *models.Comment) {
+	fmt.Fprintf(w, "%d|%d|%d|%d|%q|%t|%s|%s|%s\n", c.ID, c.TenantID, c.TicketID, c.AuthorID, c.Body, c.IsInternal,
+		checksumTime(c.CreatedAt), timePtr(c.EditedAt), timePtr(c.DeletedAt))
+}
+
+func checksumTime(t time.Time) string {
+	return t.UTC().Truncate(time.Second).Format(time.RFC3339)
+}
+
+func timePtr(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return checksumTime(*t)
+}
+
+func nullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return "-"
+	}
+	return checksumTime(t.Time)
+}
+
+func nullInt(n sql.NullInt64) string {
+	if !n.Valid {
+		return "-"
+	}
+	return fmt.Sprint(n.Int64)
+}
+
+func nullString(s sql.NullString) string {
+	if !s.Valid {
+		return "-"
+	}
+	return fmt.Sprintf("%q", s.String)
+}
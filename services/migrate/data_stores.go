@@ -0,0 +1,225 @@
+package migrate
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+
+	"goat/services/models"
+)
+
+// SQLData reads and writes rows of a SQL database for CopyData and VerifyData.
+type SQLData struct {
+	db *bun.DB
+}
+
+func NewSQLData(db *bun.DB) *SQLData {
+	return &SQLData{db: db}
+}
+
+// copyProgress is a row of the data_migrations table, which records how far CopyData got.
+type copyProgress struct {
+	bun.BaseModel `bun:"table:data_migrations"`
+
+	Entity    string    `bun:"entity,pk"`
+	LastID    int64     `bun:"last_id,notnull"`
+	UpdatedAt time.Time `bun:"updated_at,notnull"`
+}
+
+func (d *SQLData) Users(ctx context.Context, afterID int64, limit int) ([]models.User, error) {
+	var users []models.User
+	err := d.after(&users, afterID, limit).Scan(ctx)
+	return users, err
+}
+
+func (d *SQLData) Tickets(ctx context.Context, afterID int64, limit int) ([]models.Ticket, error) {
+	var tickets []models.Ticket
+	err := d.after(&tickets, afterID, limit).Scan(ctx)
+	return tickets, err
+}
+
+func (d *SQLData) Comments(ctx context.Context, afterID int64, limit int) ([]models.Comment, error) {
+	var comments []models.Comment
+	err := d.after(&comments, afterID, limit).Scan(ctx)
+	return comments, err
+}
+
+func (d *SQLData) after(model any, afterID int64, limit int) *bun.SelectQuery {
+	return d.db.NewSelect().
+		Model(model).
+		WhereAllWithDeleted().
+		Where("?TableAlias.id > ?", afterID).
+		OrderExpr("?TableAlias.id").
+		Limit(limit)
+}
+
+func (d *SQLData) PutUsers(ctx context.Context, users []models.User) error {
+	return d.upsert(ctx, &users, "id")
+}
+
+func (d *SQLData) PutTickets(ctx context.Context, tickets []models.Ticket) error {
+	return d.upsert(ctx, &tickets, "id")
+}
+
+func (d *SQLData) PutComments(ctx context.Context, comments []models.Comment) error {
+	return d.upsert(ctx, &comments, "id")
+}
+
+// upsert inserts the rows of a slice, overwriting every column of rows that already exist.
+func (d *SQLData) upsert(ctx context.Context, rows any, key string) error {
+	table := d.db.Table(reflect.TypeOf(rows).Elem().Elem())
+	q := d.db.NewInsert().Model(rows)
+	mysql := d.db.Dialect().Name() == dialect.MySQL
+	if mysql {
+		q = q.On("DUPLICATE KEY UPDATE")
+	} else {
+		q = q.On("CONFLICT (?) DO UPDATE", bun.Ident(key))
+	}
+	for _, field := range table.Fields {
+		if field.Name == key {
+			continue
+		}
+		if mysql {
+			q = q.Set("? = VALUES(?)", bun.Ident(field.Name), bun.Ident(field.Name))
+		} else {
+			q = q.Set("? = EXCLUDED.?", bun.Ident(field.Name), bun.Ident(field.Name))
+		}
+	}
+	_, err := q.Exec(ctx)
+	return err
+}
+
+func (d *SQLData) Progress(ctx context.Context, entity string) (int64, error) {
+	if _, err := d.db.NewCreateTable().Model((*copyProgress)(nil)).IfNotExists().Exec(ctx); err != nil {
+		return 0, err
+	}
+	var lastIDs []int64
+	err := d.db.NewSelect().Model((*copyProgress)(nil)).Column("last_id").Where("entity = ?", entity).Scan(ctx, &lastIDs)
+	if err != nil || len(lastIDs) == 0 {
+		return 0, err
+	}
+	return lastIDs[0], nil
+}
+
+func (d *SQLData) SaveProgress(ctx context.Context, entity string, lastID int64) error {
+	progress := []copyProgress{{Entity: entity, LastID: lastID, UpdatedAt: time.Now().UTC()}}
+	return d.upsert(ctx, &progress, "entity")
+}
+
+func (d *SQLData) ResetProgress(ctx context.Context) error {
+	_, err := d.db.NewDropTable().Model((*copyProgress)(nil)).IfExists().Exec(ctx)
+	return err
+}
+
+// CopyOrganizations copies every organization from source, since users, tickets and comments
+// belong to them. Like categories, they are copied in one go and again on every run.
+func (d *SQLData) CopyOrganizations(ctx context.Context, source *SQLData) error {
+	var orgs []models.Organization
+	if err := source.db.NewSelect().Model(&orgs).OrderExpr("id").Scan(ctx); err != nil || len(orgs) == 0 {
+		return err
+	}
+	return d.upsert(ctx, &orgs, "id")
+}
+
+// CopyCompanies copies every customer company from source, since users refer to them.
+func (d *SQLData) CopyCompanies(ctx context.Context, source *SQLData) error {
+	var companies []models.Company
+	if err := source.db.NewSelect().Model(&companies).OrderExpr("id").Scan(ctx); err != nil || len(companies) == 0 {
+		return err
+	}
+	return d.upsert(ctx, &companies, "id")
+}
+
+// CopyCategories copies every category from source, since tickets refer to them. Categories are
+// few, so they are copied in one go and again on every run.
+func (d *SQLData) CopyCategories(ctx context.Context, source *SQLData) error {
+	var categories []models.Category
+	if err := source.db.NewSelect().Model(&categories).OrderExpr("id").Scan(ctx); err != nil || len(categories) == 0 {
+		return err
+	}
+	return d.upsert(ctx, &categories, "id")
+}
+
+// Finish moves PostgreSQL's ID sequences past the copied IDs, which were inserted explicitly.
+// MySQL and SQLite do so on their own.
+func (d *SQLData) Finish(ctx context.Context) error {
+	if d.db.Dialect().Name() != dialect.PG {
+		return nil
+	}
+	for _, table := range []string{"organizations", "companies", "users", "tickets", "comments", "categories"} {
+		_, err := d.db.ExecContext(ctx,
+			"SELECT setval(pg_get_serial_sequence(?, 'id'), COALESCE(MAX(id), 1), MAX(id) IS NOT NULL) FROM ?",
+			table, bun.Ident(table))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DocumentData reads and writes rows of the document store for CopyData and VerifyData.
+type DocumentData struct {
+	store *models.DocumentStore
+}
+
+func NewDocumentData(store *models.DocumentStore) *DocumentData {
+	return &DocumentData{store: store}
+}
+
+func (d *DocumentData) Users(ctx context.Context, afterID int64, limit int) ([]models.User, error) {
+	return d.store.UsersAfter(ctx, afterID, limit)
+}
+
+func (d *DocumentData) Tickets(ctx context.Context, afterID int64, limit int) ([]models.Ticket, error) {
+	return d.store.TicketsAfter(ctx, afterID, limit)
+}
+
+func (d *DocumentData) Comments(ctx context.Context, afterID int64, limit int) ([]models.Comment, error) {
+	return d.store.CommentsAfter(ctx, afterID, limit)
+}
+
+func (d *DocumentData) PutUsers(ctx context.Context, users []models.User) error {
+	for i := range users {
+		if err := d.store.PutUser(ctx, &users[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DocumentData) PutTickets(ctx context.Context, tickets []models.Ticket) error {
+	for i := range tickets {
+		if err := d.store.PutTicket(ctx, &tickets[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DocumentData) PutComments(ctx context.Context, comments []models.Comment) error {
+	for i := range comments {
+		if err := d.store.PutComment(ctx, &comments[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DocumentData) Progress(ctx context.Context, entity string) (int64, error) {
+	return d.store.CopyProgress(ctx, entity)
+}
+
+func (d *DocumentData) SaveProgress(ctx context.Context, entity string, lastID int64) error {
+	return d.store.SaveCopyProgress(ctx, entity, lastID)
+}
+
+func (d *DocumentData) ResetProgress(ctx context.Context) error {
+	return d.store.ResetCopyProgress(ctx)
+}
+
+func (d *DocumentData) Finish(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,56 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+const (
+	// lockName is the MySQL named lock held while migrating.
+	lockName = "goat_schema_migrations"
+	// lockKey is the PostgreSQL advisory lock key held while migrating.
+	lockKey = 7746291
+	// lockTimeout is how many seconds MySQL waits for another node to finish migrating.
+	lockTimeout = 600
+)
+
+// withLock runs fn on a connection holding a database-wide lock, so that nodes starting at the
+// same time migrate one after the other. SQLite has no such lock, but allows one writer at a time:
+// apply records each migration first in its transaction, and skips it when another node already
+// has.
+func withLock(ctx context.Context, db *bun.DB, fn func(conn bun.Conn) error) (err error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch db.Dialect().Name() {
+	case dialect.MySQL:
+		var got *int
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, lockTimeout).Scan(&got); err != nil {
+			return fmt.Errorf("failed to lock the schema: %w", err)
+		}
+		if got == nil || *got != 1 {
+			return fmt.Errorf("timed out waiting for another node to finish migrating")
+		}
+		defer func() {
+			if _, unlockErr := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName); err == nil {
+				err = unlockErr
+			}
+		}()
+	case dialect.PG:
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock(?)", lockKey); err != nil {
+			return fmt.Errorf("failed to lock the schema: %w", err)
+		}
+		defer func() {
+			if _, unlockErr := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(?)", lockKey); err == nil {
+				err = unlockErr
+			}
+		}()
+	}
+	return fn(conn)
+}
@@ -0,0 +1,419 @@
+// Package migrate keeps the database schema up to date with versioned SQL migrations embedded in
+// the binary. Each migration has an up and a down script per database, named
+// migrations/<dialect>/<version>_<name>.<up|down>.sql. Applied migrations are recorded in the
+// schema_migrations table together with a checksum of their up script, so edits to a migration
+// after it ran are caught instead of silently diverging between deployments.
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+//go:embed migrations
+var files embed.FS
+
+// ErrChecksum is returned when an applied migration's up script differs from the embedded one.
+var ErrChecksum = errors.New("applied migration has changed")
+
+// ErrUnmanagedSchema is returned by Up when the database already has tables but no migration was
+// ever recorded, as with databases created from the db_schema.sql of earlier releases. The
+// initial migration would skip their existing tables and leave them without later columns.
+var ErrUnmanagedSchema = errors.New("database has tables that were not created by migrations")
+
+// Migration is one schema change.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // Hex SHA-256 of Up
+}
+
+// appliedMigration is a row of the schema_migrations table.
+type appliedMigration struct {
+	bun.BaseModel `bun:"table:schema_migrations"`
+
+	Version   int64     `bun:"version,pk"`
+	Name      string    `bun:"name,notnull"`
+	Checksum  string    `bun:"checksum,notnull"`
+	AppliedAt time.Time `bun:"applied_at,notnull"`
+}
+
+// Status is the state of a migration in the database.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	Modified  bool // Applied from an up script other than the embedded one
+	Unknown   bool // Applied but not embedded, e.g. by a newer release
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// dialectDir returns the directory holding the migrations of the database's dialect.
+func dialectDir(name dialect.Name) (string, error) {
+	switch name {
+	case dialect.MySQL:
+		return "migrations/mysql", nil
+	case dialect.PG:
+		return "migrations/postgres", nil
+	case dialect.SQLite:
+		return "migrations/sqlite", nil
+	}
+	return "", fmt.Errorf("no migrations for the %s dialect", name)
+}
+
+// Migrations returns the embedded migrations for a dialect, oldest first.
+func Migrations(name dialect.Name) ([]Migration, error) {
+	dir, err := dialectDir(name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+		b, err := fs.ReadFile(files, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, m[2])
+		}
+		if m[3] == "up" {
+			migration.Up = string(b)
+			sum := sha256.Sum256(b)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	return migrations, nil
+}
+
+// GetStatus lists the embedded migrations and whether they were applied, followed by any applied
+// migrations this binary does not know.
+func GetStatus(ctx context.Context, db *bun.DB) ([]Status, error) {
+	migrations, err := Migrations(db.Dialect().Name())
+	if err != nil {
+		return nil, err
+	}
+	if err := createTable(ctx, db); err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	return status(migrations, applied), nil
+}
+
+// Up applies up to steps pending migrations in order, or all of them when steps is 0, and returns
+// the ones it applied. It refuses to run when an applied migration has changed.
+func Up(ctx context.Context, db *bun.DB, steps int) ([]Migration, error) {
+	migrations, err := Migrations(db.Dialect().Name())
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = withLock(ctx, db, func(conn bun.Conn) error {
+		statuses, err := verifiedStatus(ctx, conn, migrations)
+		if err != nil {
+			return err
+		}
+		if !slices.ContainsFunc(statuses, func(s Status) bool { return s.Applied }) && tableExists(ctx, conn, "tickets") {
+			return ErrUnmanagedSchema
+		}
+		for _, s := range statuses {
+			if s.Applied || s.Unknown {
+				continue
+			}
+			if steps > 0 && len(done) == steps {
+				break
+			}
+			applied, err := apply(ctx, conn, s.Migration)
+			if err != nil {
+				return err
+			}
+			if applied {
+				done = append(done, s.Migration)
+			}
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Baseline records the initial migration as applied without running it, for a database whose
+// tables were brought in line with it by hand. It fails if any migration was already recorded.
+func Baseline(ctx context.Context, db *bun.DB) (Migration, error) {
+	migrations, err := Migrations(db.Dialect().Name())
+	if err != nil {
+		return Migration{}, err
+	}
+	initial := migrations[0]
+
+	err = withLock(ctx, db, func(conn bun.Conn) error {
+		statuses, err := verifiedStatus(ctx, conn, migrations)
+		if err != nil {
+			return err
+		}
+		if slices.ContainsFunc(statuses, func(s Status) bool { return s.Applied }) {
+			return errors.New("migrations were already applied to this database")
+		}
+		record := &appliedMigration{Version: initial.Version, Name: initial.Name, Checksum: initial.Checksum, AppliedAt: time.Now().UTC()}
+		_, err = conn.NewInsert().Model(record).Exec(ctx)
+		return err
+	})
+	return initial, err
+}
+
+// Down reverts up to steps applied migrations, newest first, and returns the ones it reverted.
+// Applied migrations this binary does not know cannot be reverted and stop it.
+func Down(ctx context.Context, db *bun.DB, steps int) ([]Migration, error) {
+	migrations, err := Migrations(db.Dialect().Name())
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = withLock(ctx, db, func(conn bun.Conn) error {
+		statuses, err := verifiedStatus(ctx, conn, migrations)
+		if err != nil {
+			return err
+		}
+		for i := len(statuses) - 1; i >= 0 && len(done) < steps; i-- {
+			s := statuses[i]
+			if !s.Applied {
+				continue
+			}
+			if s.Unknown {
+				return fmt.Errorf("migration %04d_%s is not known to this release and cannot be reverted", s.Version, s.Name)
+			}
+			if err := revert(ctx, conn, s.Migration); err != nil {
+				return err
+			}
+			done = append(done, s.Migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// verifiedStatus returns the status of the migrations, failing with ErrChecksum if any applied one
+// has changed since.
+func verifiedStatus(ctx context.Context, conn bun.Conn, migrations []Migration) ([]Status, error) {
+	if err := createTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	statuses := status(migrations, applied)
+	for _, s := range statuses {
+		if s.Modified {
+			return nil, fmt.Errorf("%w: %04d_%s", ErrChecksum, s.Version, s.Name)
+		}
+	}
+	return statuses, nil
+}
+
+func status(migrations []Migration, applied []appliedMigration) []Status {
+	appliedByVersion := make(map[int64]appliedMigration)
+	for _, a := range applied {
+		appliedByVersion[a.Version] = a
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, m := range migrations {
+		s := Status{Migration: m}
+		if a, ok := appliedByVersion[m.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = a.AppliedAt
+			s.Modified = a.Checksum != m.Checksum
+			delete(appliedByVersion, m.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for _, a := range applied {
+		if _, ok := appliedByVersion[a.Version]; ok {
+			statuses = append(statuses, Status{
+				Migration: Migration{Version: a.Version, Name: a.Name, Checksum: a.Checksum},
+				Applied:   true,
+				AppliedAt: a.AppliedAt,
+				Unknown:   true,
+			})
+		}
+	}
+	slices.SortStableFunc(statuses, func(a, b Status) int { return cmp.Compare(a.Version, b.Version) })
+	return statuses
+}
+
+// tableExists reports whether the database has the table.
+func tableExists(ctx context.Context, db bun.IDB, table string) bool {
+	_, err := db.NewSelect().Table(table).ColumnExpr("1").Limit(1).Exec(ctx)
+	return err == nil
+}
+
+func createTable(ctx context.Context, db bun.IDB) error {
+	_, err := db.NewCreateTable().Model((*appliedMigration)(nil)).IfNotExists().Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create the schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+func appliedMigrations(ctx context.Context, db bun.IDB) ([]appliedMigration, error) {
+	var applied []appliedMigration
+	err := db.NewSelect().Model(&applied).Order("version").Scan(ctx)
+	return applied, err
+}
+
+// transactionalDDL reports whether schema changes can be rolled back. MySQL commits each one
+// implicitly, so a migration that fails halfway there has to be cleaned up by hand.
+func transactionalDDL(db bun.IDB) bool {
+	return db.Dialect().Name() != dialect.MySQL
+}
+
+// apply runs a migration and records it. It reports false when another node applied it first.
+func apply(ctx context.Context, conn bun.Conn, m Migration) (bool, error) {
+	record := &appliedMigration{Version: m.Version, Name: m.Name, Checksum: m.Checksum, AppliedAt: time.Now().UTC()}
+	if !transactionalDDL(conn) {
+		if err := execScript(ctx, conn, m.Up); err != nil {
+			return false, fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+		}
+		_, err := conn.NewInsert().Model(record).Exec(ctx)
+		return err == nil, err
+	}
+
+	applied := false
+	err := conn.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// Recording the migration first takes SQLite's write lock, see withLock.
+		res, err := tx.NewInsert().Model(record).On("CONFLICT DO NOTHING").Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			return err
+		}
+		if err := execScript(ctx, tx, m.Up); err != nil {
+			return fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+		}
+		applied = true
+		return nil
+	})
+	return applied, err
+}
+
+func revert(ctx context.Context, conn bun.Conn, m Migration) error {
+	remove := func(ctx context.Context, db bun.IDB) error {
+		_, err := db.NewDelete().Model((*appliedMigration)(nil)).Where("version = ?", m.Version).Exec(ctx)
+		return err
+	}
+	if !transactionalDDL(conn) {
+		if err := execScript(ctx, conn, m.Down); err != nil {
+			return fmt.Errorf("reverting migration %04d_%s failed: %w", m.Version, m.Name, err)
+		}
+		return remove(ctx, conn)
+	}
+	return conn.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := remove(ctx, tx); err != nil {
+			return err
+		}
+		if err := execScript(ctx, tx, m.Down); err != nil {
+			return fmt.Errorf("reverting migration %04d_%s failed: %w", m.Version, m.Name, err)
+		}
+		return nil
+	})
+}
+
+// execScript runs the statements of a script one by one, since the MySQL driver only accepts one
+// statement per call. A statement ends with a semicolon at the end of a line, not counting a
+// trailing comment.
+func execScript(ctx context.Context, db bun.IDB, script string) error {
+	for _, stmt := range splitStatements(script) {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitStatements(script string) []string {
+	var stmts []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(stripComment(line))
+		if current.Len() == 0 && trimmed == "" {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, current.String())
+			current.Reset()
+		}
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		stmts = append(stmts, current.String())
+	}
+	return stmts
+}
+
+// stripComment returns a line without its -- comment, if it has one outside a quoted string.
+func stripComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case strings.HasPrefix(line[i:], "--"):
+			return line[:i]
+		}
+	}
+	return line
+}
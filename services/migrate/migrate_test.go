@@ -0,0 +1,79 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/uptrace/bun/dialect"
+)
+
+// TestSplitStatements checks that every embedded script splits into single statements, since
+// the MySQL driver rejects a call holding more than one.
+func TestSplitStatements(t *testing.T) {
+	for _, name := range []dialect.Name{dialect.MySQL, dialect.PG, dialect.SQLite} {
+		migrations, err := Migrations(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, m := range migrations {
+			for direction, script := range map[string]string{"up": m.Up, "down": m.Down} {
+				stmts := splitStatements(script)
+				if len(stmts) == 0 {
+					t.Errorf("%s %04d_%s.%s: no statements", name, m.Version, m.Name, direction)
+				}
+				for _, stmt := range stmts {
+					if n := countSemicolons(stmt); n != 1 {
+						t.Errorf("%s %04d_%s.%s: statement has %d semicolons:\n%s", name, m.Version, m.Name, direction, n, stmt)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSplitStatementsTrailingComment(t *testing.T) {
+	script := "-- Header\n\nALTER TABLE t\n    ADD COLUMN c INT; -- Why c\n\nCREATE TABLE u (\n    s VARCHAR(5) DEFAULT '--;' -- Not a comment in quotes\n);\n"
+	stmts := splitStatements(script)
+	if len(stmts) != 2 {
+		t.Fatalf("got %d statements, want 2: %q", len(stmts), stmts)
+	}
+	if !strings.HasPrefix(stmts[0], "ALTER TABLE t") || !strings.HasPrefix(stmts[1], "CREATE TABLE u") {
+		t.Errorf("unexpected statements %q", stmts)
+	}
+}
+
+func TestStripComment(t *testing.T) {
+	tests := []struct{ line, want string }{
+		{"-- Comment", ""},
+		{"a INT, -- Comment", "a INT, "},
+		{"DEFAULT 'a--b', -- Comment", "DEFAULT 'a--b', "},
+		{"`x--y` INT", "`x--y` INT"},
+		{"no comment;", "no comment;"},
+	}
+	for _, tt := range tests {
+		if got := stripComment(tt.line); got != tt.want {
+			t.Errorf("stripComment(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+// countSemicolons counts the semicolons of a statement outside comments and quoted strings.
+func countSemicolons(stmt string) int {
+	n := 0
+	for _, line := range strings.Split(stmt, "\n") {
+		var quote rune
+		for _, c := range stripComment(line) {
+			switch {
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+			case c == '\'' || c == '"' || c == '`':
+				quote = c
+			case c == ';':
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,15 @@
+-- Drops every table of the initial schema, and with them all data.
+DROP TABLE IF EXISTS `audit_checkpoints`;
+DROP TABLE IF EXISTS `audit_log`;
+DROP TABLE IF EXISTS `ticket_links`;
+DROP TABLE IF EXISTS `ticket_field_values`;
+DROP TABLE IF EXISTS `custom_fields`;
+DROP TABLE IF EXISTS `ticket_tags`;
+DROP TABLE IF EXISTS `tags`;
+DROP TABLE IF EXISTS `views`;
+DROP TABLE IF EXISTS `team_members`;
+DROP TABLE IF EXISTS `teams`;
+DROP TABLE IF EXISTS `comments`;
+DROP TABLE IF EXISTS `tickets`;
+DROP TABLE IF EXISTS `categories`;
+DROP TABLE IF EXISTS `users`;
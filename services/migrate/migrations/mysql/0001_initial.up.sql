@@ -0,0 +1,204 @@
+-- Initial schema. Tables that already exist, e.g. from the hand-applied db_schema.sql of earlier
+-- releases, are left alone so such databases can adopt migrations.
+
+--
+-- Table structure for table `users`
+--
+CREATE TABLE IF NOT EXISTS `users` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `name` VARCHAR(255) NOT NULL,
+    `email` VARCHAR(255) NOT NULL,
+    `password_hash` VARCHAR(255) NOT NULL,
+    `role` VARCHAR(50) NOT NULL DEFAULT 'Agent',
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    `password_reset_token` VARCHAR(255),
+    `password_reset_expires` DATETIME,
+    `deleted_at` DATETIME, -- Set while the user is in the trash
+    KEY `idx_users_deleted_at` (`deleted_at`)
+);
+
+
+--
+-- Table structure for table `categories`
+--
+CREATE TABLE IF NOT EXISTS `categories` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `name` VARCHAR(255) NOT NULL,
+    `parent_id` INT, -- Null for top-level categories
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (`parent_id`) REFERENCES `categories`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table `tickets`
+--
+CREATE TABLE IF NOT EXISTS `tickets` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `title` VARCHAR(255) NOT NULL,
+    `type` VARCHAR(50) NOT NULL DEFAULT 'Question',
+    `description` TEXT,
+    `status` VARCHAR(50) NOT NULL DEFAULT 'Open',
+    `priority` VARCHAR(50) NOT NULL DEFAULT 'Medium',
+    `requester_id` INT NOT NULL,
+    `assignee_id` INT,
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    `closed_at` DATETIME,
+    `version` INT NOT NULL DEFAULT 1,
+    `category_id` INT,
+    `deleted_at` DATETIME, -- Set while the ticket is in the trash
+    KEY `idx_tickets_deleted_at` (`deleted_at`),
+    FULLTEXT KEY `ft_tickets_title_description` (`title`, `description`),
+    FOREIGN KEY (`requester_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (`assignee_id`) REFERENCES `users`(`id`) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (`category_id`) REFERENCES `categories`(`id`) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table `comments`
+--
+CREATE TABLE IF NOT EXISTS `comments` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `ticket_id` INT NOT NULL,
+    `author_id` INT NOT NULL, -- Assuming comments are primarily from agents (users)
+    `body` TEXT NOT NULL,
+    `is_internal` BOOLEAN DEFAULT FALSE,
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    `deleted_at` DATETIME, -- Set while the comment is in the trash
+    KEY `idx_comments_deleted_at` (`deleted_at`),
+    FULLTEXT KEY `ft_comments_body` (`body`),
+    FOREIGN KEY (`ticket_id`) REFERENCES `tickets`(`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (`author_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table `teams`
+--
+CREATE TABLE IF NOT EXISTS `teams` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `name` VARCHAR(255) NOT NULL UNIQUE,
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+--
+-- Table structure for table `team_members`
+--
+CREATE TABLE IF NOT EXISTS `team_members` (
+    `team_id` INT NOT NULL,
+    `user_id` INT NOT NULL,
+    PRIMARY KEY (`team_id`, `user_id`),
+    FOREIGN KEY (`team_id`) REFERENCES `teams`(`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table `views`
+--
+CREATE TABLE IF NOT EXISTS `views` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `name` VARCHAR(255) NOT NULL,
+    `query` TEXT NOT NULL,
+    `owner_id` INT NOT NULL,
+    `team_id` INT, -- Shared with this team when set
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (`owner_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (`team_id`) REFERENCES `teams`(`id`) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table `tags`
+--
+CREATE TABLE IF NOT EXISTS `tags` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `name` VARCHAR(100) NOT NULL UNIQUE,
+    `suggested` BOOLEAN DEFAULT FALSE, -- Curated by admins, offered first in autocomplete
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+--
+-- Table structure for table `ticket_tags`
+--
+CREATE TABLE IF NOT EXISTS `ticket_tags` (
+    `ticket_id` INT NOT NULL,
+    `tag_id` INT NOT NULL,
+    PRIMARY KEY (`ticket_id`, `tag_id`),
+    FOREIGN KEY (`ticket_id`) REFERENCES `tickets`(`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (`tag_id`) REFERENCES `tags`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table `custom_fields`
+--
+CREATE TABLE IF NOT EXISTS `custom_fields` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `field_key` VARCHAR(64) NOT NULL UNIQUE,
+    `name` VARCHAR(255) NOT NULL,
+    `type` VARCHAR(20) NOT NULL, -- text, number, date, select, multiselect or user
+    `options` JSON, -- Allowed values of select and multiselect fields
+    `required_for` JSON, -- Ticket types that must have a value
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+--
+-- Table structure for table `ticket_field_values`
+--
+CREATE TABLE IF NOT EXISTS `ticket_field_values` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `ticket_id` INT NOT NULL,
+    `field_id` INT NOT NULL,
+    `text_value` TEXT, -- Text and select values, one row per option of multiselect fields
+    `number_value` DOUBLE,
+    `date_value` DATETIME,
+    `user_id` INT,
+    `sort_key` VARCHAR(191) NOT NULL, -- Orders values of any type as plain strings
+    KEY `idx_ticket_field_values_ticket` (`ticket_id`, `field_id`),
+    KEY `idx_ticket_field_values_sort` (`field_id`, `sort_key`),
+    FOREIGN KEY (`ticket_id`) REFERENCES `tickets`(`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (`field_id`) REFERENCES `custom_fields`(`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table `ticket_links`
+--
+CREATE TABLE IF NOT EXISTS `ticket_links` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `source_id` INT NOT NULL,
+    `target_id` INT NOT NULL,
+    `type` VARCHAR(20) NOT NULL, -- duplicates, related, blocks or parent_of, read from source to target
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY `uq_ticket_links` (`source_id`, `target_id`, `type`),
+    KEY `idx_ticket_links_target` (`target_id`, `type`),
+    FOREIGN KEY (`source_id`) REFERENCES `tickets`(`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (`target_id`) REFERENCES `tickets`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table `audit_log`
+--
+CREATE TABLE IF NOT EXISTS `audit_log` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `actor_id` INT NULL, -- No foreign key, entries outlive the users who made them
+    `action` VARCHAR(50) NOT NULL,
+    `entity_type` VARCHAR(50) NOT NULL,
+    `entity_id` INT NOT NULL,
+    `changes` JSON,
+    `ip` VARCHAR(45),
+    `user_agent` VARCHAR(512),
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    `prev_hash` CHAR(64) NOT NULL DEFAULT '', -- Hash of the preceding entry
+    `hash` CHAR(64) NOT NULL DEFAULT '', -- SHA-256 over this entry and prev_hash
+    KEY `idx_audit_log_entity` (`entity_type`, `entity_id`, `created_at`),
+    KEY `idx_audit_log_actor` (`actor_id`, `created_at`)
+);
+
+--
+-- Table structure for table `audit_checkpoints`
+--
+CREATE TABLE IF NOT EXISTS `audit_checkpoints` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `last_entry_id` INT NOT NULL, -- No foreign key, a checkpoint must survive to expose deleted entries
+    `hash` CHAR(64) NOT NULL,
+    `signature` VARCHAR(128) NOT NULL, -- Base64 ed25519 signature
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,21 @@
+-- Drops organizations. Every row is kept, but which organization it belonged to is lost.
+ALTER TABLE `audit_log`
+    DROP KEY `idx_audit_log_tenant`,
+    DROP COLUMN `tenant_id`;
+
+ALTER TABLE `comments`
+    DROP FOREIGN KEY `fk_comments_tenant`,
+    DROP KEY `idx_comments_tenant`,
+    DROP COLUMN `tenant_id`;
+
+ALTER TABLE `tickets`
+    DROP FOREIGN KEY `fk_tickets_tenant`,
+    DROP KEY `idx_tickets_tenant`,
+    DROP COLUMN `tenant_id`;
+
+ALTER TABLE `users`
+    DROP FOREIGN KEY `fk_users_tenant`,
+    DROP KEY `idx_users_tenant`,
+    DROP COLUMN `tenant_id`;
+
+DROP TABLE IF EXISTS `organizations`;
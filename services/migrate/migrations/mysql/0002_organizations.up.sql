@@ -0,0 +1,34 @@
+-- Organizations (tenants). Users, tickets and comments belong to one organization; rows that
+-- existed before go to the default organization with ID 1.
+
+--
+-- Table structure for table `organizations`
+--
+CREATE TABLE IF NOT EXISTS `organizations` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `name` VARCHAR(255) NOT NULL,
+    `host` VARCHAR(255) UNIQUE, -- Hostname the organization is served on, if any
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO `organizations` (`id`, `name`) VALUES (1, 'Default');
+
+ALTER TABLE `users`
+    ADD COLUMN `tenant_id` INT NOT NULL DEFAULT 1,
+    ADD KEY `idx_users_tenant` (`tenant_id`, `email`),
+    ADD CONSTRAINT `fk_users_tenant` FOREIGN KEY (`tenant_id`) REFERENCES `organizations`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE;
+
+ALTER TABLE `tickets`
+    ADD COLUMN `tenant_id` INT NOT NULL DEFAULT 1,
+    ADD KEY `idx_tickets_tenant` (`tenant_id`, `status`),
+    ADD CONSTRAINT `fk_tickets_tenant` FOREIGN KEY (`tenant_id`) REFERENCES `organizations`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE;
+
+ALTER TABLE `comments`
+    ADD COLUMN `tenant_id` INT NOT NULL DEFAULT 1,
+    ADD KEY `idx_comments_tenant` (`tenant_id`, `ticket_id`),
+    ADD CONSTRAINT `fk_comments_tenant` FOREIGN KEY (`tenant_id`) REFERENCES `organizations`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE;
+
+-- Null for entries not made on behalf of one organization, such as purges. No foreign key, like actor_id.
+ALTER TABLE `audit_log`
+    ADD COLUMN `tenant_id` INT NULL,
+    ADD KEY `idx_audit_log_tenant` (`tenant_id`, `created_at`);
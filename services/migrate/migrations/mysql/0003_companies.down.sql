@@ -0,0 +1,7 @@
+-- Drops companies. Their members stay, without a company.
+ALTER TABLE `users`
+    DROP FOREIGN KEY `fk_users_company`,
+    DROP KEY `idx_users_company`,
+    DROP COLUMN `company_id`;
+
+DROP TABLE IF EXISTS `companies`;
@@ -0,0 +1,22 @@
+-- Customer companies. Customers whose email domain matches a company's join it automatically, and
+-- a company can let its members see and comment on each other's tickets.
+
+--
+-- Table structure for table `companies`
+--
+CREATE TABLE IF NOT EXISTS `companies` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `tenant_id` INT NOT NULL,
+    `name` VARCHAR(255) NOT NULL,
+    `domain` VARCHAR(255), -- Email domain whose customers join the company, if any
+    `shared_tickets` BOOLEAN NOT NULL DEFAULT FALSE, -- Members may view and comment on each other's tickets
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY `uq_companies_name` (`tenant_id`, `name`),
+    UNIQUE KEY `uq_companies_domain` (`tenant_id`, `domain`),
+    CONSTRAINT `fk_companies_tenant` FOREIGN KEY (`tenant_id`) REFERENCES `organizations`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+ALTER TABLE `users`
+    ADD COLUMN `company_id` INT NULL,
+    ADD KEY `idx_users_company` (`company_id`),
+    ADD CONSTRAINT `fk_users_company` FOREIGN KEY (`company_id`) REFERENCES `companies`(`id`) ON DELETE SET NULL ON UPDATE CASCADE;
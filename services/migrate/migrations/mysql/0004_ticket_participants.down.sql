@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `ticket_ccs`;
+DROP TABLE IF EXISTS `ticket_watchers`;
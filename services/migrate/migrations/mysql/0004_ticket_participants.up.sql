@@ -0,0 +1,32 @@
+-- Ticket participants beyond the requester and assignee: agents and admins watching a ticket, and
+-- customers or email-only contacts copied on it.
+
+--
+-- Table structure for table `ticket_watchers`
+--
+CREATE TABLE IF NOT EXISTS `ticket_watchers` (
+    `ticket_id` INT NOT NULL,
+    `user_id` INT NOT NULL,
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`ticket_id`, `user_id`),
+    KEY `idx_ticket_watchers_user` (`user_id`),
+    FOREIGN KEY (`ticket_id`) REFERENCES `tickets`(`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table `ticket_ccs`
+--
+CREATE TABLE IF NOT EXISTS `ticket_ccs` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `ticket_id` INT NOT NULL,
+    `user_id` INT, -- Set for customers with an account
+    `email` VARCHAR(255), -- Set for contacts without one
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY `uq_ticket_ccs_user` (`ticket_id`, `user_id`),
+    UNIQUE KEY `uq_ticket_ccs_email` (`ticket_id`, `email`),
+    KEY `idx_ticket_ccs_user` (`user_id`),
+    KEY `idx_ticket_ccs_email` (`email`),
+    FOREIGN KEY (`ticket_id`) REFERENCES `tickets`(`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
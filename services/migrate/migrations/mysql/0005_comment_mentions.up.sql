@@ -0,0 +1,14 @@
+-- Users mentioned with @handle in a comment.
+
+--
+-- Table structure for table `comment_mentions`
+--
+CREATE TABLE IF NOT EXISTS `comment_mentions` (
+    `comment_id` INT NOT NULL,
+    `user_id` INT NOT NULL,
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`comment_id`, `user_id`),
+    KEY `idx_comment_mentions_user` (`user_id`),
+    FOREIGN KEY (`comment_id`) REFERENCES `comments`(`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE ON UPDATE CASCADE
+);
@@ -0,0 +1,4 @@
+-- Drops comment revisions. Comments keep their latest text.
+DROP TABLE IF EXISTS `comment_revisions`;
+
+ALTER TABLE `comments` DROP COLUMN `edited_at`;
@@ -0,0 +1,18 @@
+-- Comment edits. Each edit keeps the text it replaced as a revision.
+
+ALTER TABLE `comments`
+    ADD COLUMN `edited_at` DATETIME NULL; -- Set once the comment has been edited
+
+--
+-- Table structure for table `comment_revisions`
+--
+CREATE TABLE IF NOT EXISTS `comment_revisions` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `comment_id` INT NOT NULL,
+    `body` TEXT NOT NULL, -- Text the edit replaced
+    `editor_id` INT NOT NULL, -- User who made the edit
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP, -- When the edit was made
+    KEY `idx_comment_revisions_comment` (`comment_id`),
+    FOREIGN KEY (`comment_id`) REFERENCES `comments`(`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (`editor_id`) REFERENCES `users`(`id`) ON UPDATE CASCADE
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `trigger_runs`;
+DROP TABLE IF EXISTS `triggers`;
@@ -0,0 +1,40 @@
+-- Triggers: admin-defined rules that change a ticket when it is created, updated or commented on,
+-- and the log of each time one fired.
+
+--
+-- Table structure for table `triggers`
+--
+CREATE TABLE IF NOT EXISTS `triggers` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `tenant_id` INT NOT NULL,
+    `name` VARCHAR(255) NOT NULL,
+    `event` VARCHAR(50) NOT NULL, -- ticket.created, ticket.updated or comment.created
+    `conditions` JSON, -- Conditions that must all hold, and conditions of which one must hold
+    `actions` JSON, -- Ticket changes, in order
+    `position` INT NOT NULL DEFAULT 0, -- Triggers are evaluated by position, then ID
+    `active` BOOLEAN NOT NULL DEFAULT TRUE,
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    KEY `idx_triggers_tenant_event` (`tenant_id`, `event`),
+    CONSTRAINT `fk_triggers_tenant` FOREIGN KEY (`tenant_id`) REFERENCES `organizations`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table `trigger_runs`
+--
+CREATE TABLE IF NOT EXISTS `trigger_runs` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `tenant_id` INT NOT NULL,
+    `trigger_id` INT NOT NULL,
+    `ticket_id` INT NOT NULL,
+    `event` VARCHAR(50) NOT NULL,
+    `actor_id` INT, -- User whose request fired the trigger
+    `actions` JSON, -- Actions the trigger executed
+    `error_message` TEXT, -- Why the actions could not be saved, if they were not
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    KEY `idx_trigger_runs_trigger` (`trigger_id`),
+    KEY `idx_trigger_runs_ticket` (`ticket_id`),
+    CONSTRAINT `fk_trigger_runs_tenant` FOREIGN KEY (`tenant_id`) REFERENCES `organizations`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE,
+    FOREIGN KEY (`trigger_id`) REFERENCES `triggers`(`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (`ticket_id`) REFERENCES `tickets`(`id`) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (`actor_id`) REFERENCES `users`(`id`) ON DELETE SET NULL ON UPDATE CASCADE
+);
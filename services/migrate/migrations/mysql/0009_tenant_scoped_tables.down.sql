@@ -0,0 +1,62 @@
+-- Shares teams, views, tags, categories and custom fields between organizations again. Teams, tags
+-- and custom fields of the same name or key are merged into the oldest one, since names are unique
+-- again; which organization a row belonged to is lost.
+
+UPDATE `team_members` tm
+    JOIN `teams` t ON t.`id` = tm.`team_id`
+    JOIN (SELECT `name`, MIN(`id`) AS `id` FROM `teams` GROUP BY `name`) k ON k.`name` = t.`name`
+    SET tm.`team_id` = k.`id`;
+
+UPDATE `views` v
+    JOIN `teams` t ON t.`id` = v.`team_id`
+    JOIN (SELECT `name`, MIN(`id`) AS `id` FROM `teams` GROUP BY `name`) k ON k.`name` = t.`name`
+    SET v.`team_id` = k.`id`;
+
+UPDATE `macros` m
+    JOIN `teams` t ON t.`id` = m.`team_id`
+    JOIN (SELECT `name`, MIN(`id`) AS `id` FROM `teams` GROUP BY `name`) k ON k.`name` = t.`name`
+    SET m.`team_id` = k.`id`;
+
+DELETE t FROM `teams` t JOIN `teams` k ON k.`name` = t.`name` AND k.`id` < t.`id`;
+
+UPDATE `ticket_tags` tt
+    JOIN `tags` g ON g.`id` = tt.`tag_id`
+    JOIN (SELECT `name`, MIN(`id`) AS `id` FROM `tags` GROUP BY `name`) k ON k.`name` = g.`name`
+    SET tt.`tag_id` = k.`id`;
+
+DELETE g FROM `tags` g JOIN `tags` k ON k.`name` = g.`name` AND k.`id` < g.`id`;
+
+UPDATE `ticket_field_values` fv
+    JOIN `custom_fields` f ON f.`id` = fv.`field_id`
+    JOIN (SELECT `field_key`, MIN(`id`) AS `id` FROM `custom_fields` GROUP BY `field_key`) k ON k.`field_key` = f.`field_key`
+    SET fv.`field_id` = k.`id`;
+
+DELETE f FROM `custom_fields` f JOIN `custom_fields` k ON k.`field_key` = f.`field_key` AND k.`id` < f.`id`;
+
+ALTER TABLE `custom_fields`
+    DROP FOREIGN KEY `fk_custom_fields_tenant`,
+    DROP KEY `uq_custom_fields_tenant_key`,
+    DROP COLUMN `tenant_id`,
+    ADD UNIQUE KEY `field_key` (`field_key`);
+
+ALTER TABLE `categories`
+    DROP FOREIGN KEY `fk_categories_tenant`,
+    DROP KEY `idx_categories_tenant`,
+    DROP COLUMN `tenant_id`;
+
+ALTER TABLE `tags`
+    DROP FOREIGN KEY `fk_tags_tenant`,
+    DROP KEY `uq_tags_tenant_name`,
+    DROP COLUMN `tenant_id`,
+    ADD UNIQUE KEY `name` (`name`);
+
+ALTER TABLE `views`
+    DROP FOREIGN KEY `fk_views_tenant`,
+    DROP KEY `idx_views_tenant`,
+    DROP COLUMN `tenant_id`;
+
+ALTER TABLE `teams`
+    DROP FOREIGN KEY `fk_teams_tenant`,
+    DROP KEY `uq_teams_tenant_name`,
+    DROP COLUMN `tenant_id`,
+    ADD UNIQUE KEY `name` (`name`);
@@ -0,0 +1,111 @@
+-- Teams, views, tags, categories and custom fields belong to an organization too. Rows that were
+-- shared go to the default organization. Every other organization gets its own copy of the teams
+-- and tags it used, and of every category, custom field and suggested tag, since those applied to
+-- all organizations. copied_from links each copy to its original until references are moved.
+
+ALTER TABLE `teams`
+    ADD COLUMN `tenant_id` INT NOT NULL DEFAULT 1,
+    ADD COLUMN `copied_from` INT NULL,
+    DROP KEY `name`,
+    ADD UNIQUE KEY `uq_teams_tenant_name` (`tenant_id`, `name`),
+    ADD CONSTRAINT `fk_teams_tenant` FOREIGN KEY (`tenant_id`) REFERENCES `organizations`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE;
+
+ALTER TABLE `views`
+    ADD COLUMN `tenant_id` INT NOT NULL DEFAULT 1,
+    ADD KEY `idx_views_tenant` (`tenant_id`, `owner_id`),
+    ADD CONSTRAINT `fk_views_tenant` FOREIGN KEY (`tenant_id`) REFERENCES `organizations`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE;
+
+ALTER TABLE `tags`
+    ADD COLUMN `tenant_id` INT NOT NULL DEFAULT 1,
+    ADD COLUMN `copied_from` INT NULL,
+    DROP KEY `name`,
+    ADD UNIQUE KEY `uq_tags_tenant_name` (`tenant_id`, `name`),
+    ADD CONSTRAINT `fk_tags_tenant` FOREIGN KEY (`tenant_id`) REFERENCES `organizations`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE;
+
+ALTER TABLE `categories`
+    ADD COLUMN `tenant_id` INT NOT NULL DEFAULT 1,
+    ADD COLUMN `copied_from` INT NULL,
+    ADD KEY `idx_categories_tenant` (`tenant_id`, `parent_id`),
+    ADD CONSTRAINT `fk_categories_tenant` FOREIGN KEY (`tenant_id`) REFERENCES `organizations`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE;
+
+ALTER TABLE `custom_fields`
+    ADD COLUMN `tenant_id` INT NOT NULL DEFAULT 1,
+    ADD COLUMN `copied_from` INT NULL,
+    DROP KEY `field_key`,
+    ADD UNIQUE KEY `uq_custom_fields_tenant_key` (`tenant_id`, `field_key`),
+    ADD CONSTRAINT `fk_custom_fields_tenant` FOREIGN KEY (`tenant_id`) REFERENCES `organizations`(`id`) ON DELETE RESTRICT ON UPDATE CASCADE;
+
+-- Views belong to their owner's organization.
+UPDATE `views` v
+    JOIN `users` u ON u.`id` = v.`owner_id`
+    SET v.`tenant_id` = u.`tenant_id`;
+
+-- Teams are copied to the organizations of their members, views and macros.
+INSERT INTO `teams` (`tenant_id`, `name`, `created_at`, `copied_from`)
+SELECT DISTINCT used.`tenant_id`, t.`name`, t.`created_at`, t.`id`
+FROM `teams` t
+JOIN (
+    SELECT tm.`team_id`, u.`tenant_id` FROM `team_members` tm JOIN `users` u ON u.`id` = tm.`user_id`
+    UNION SELECT `team_id`, `tenant_id` FROM `views` WHERE `team_id` IS NOT NULL
+    UNION SELECT `team_id`, `tenant_id` FROM `macros` WHERE `team_id` IS NOT NULL
+) used ON used.`team_id` = t.`id`
+WHERE used.`tenant_id` <> 1;
+
+UPDATE `team_members` tm
+    JOIN `users` u ON u.`id` = tm.`user_id`
+    JOIN `teams` c ON c.`copied_from` = tm.`team_id` AND c.`tenant_id` = u.`tenant_id`
+    SET tm.`team_id` = c.`id`;
+
+UPDATE `views` v
+    JOIN `teams` c ON c.`copied_from` = v.`team_id` AND c.`tenant_id` = v.`tenant_id`
+    SET v.`team_id` = c.`id`;
+
+UPDATE `macros` m
+    JOIN `teams` c ON c.`copied_from` = m.`team_id` AND c.`tenant_id` = m.`tenant_id`
+    SET m.`team_id` = c.`id`;
+
+-- Tags are copied to the organizations whose tickets carry them, suggested tags to all.
+INSERT INTO `tags` (`tenant_id`, `name`, `suggested`, `created_at`, `copied_from`)
+SELECT o.`id`, g.`name`, g.`suggested`, g.`created_at`, g.`id`
+FROM `tags` g
+JOIN `organizations` o ON o.`id` <> 1
+WHERE g.`suggested` OR EXISTS (
+    SELECT 1 FROM `ticket_tags` tt JOIN `tickets` t ON t.`id` = tt.`ticket_id`
+    WHERE tt.`tag_id` = g.`id` AND t.`tenant_id` = o.`id`
+);
+
+UPDATE `ticket_tags` tt
+    JOIN `tickets` t ON t.`id` = tt.`ticket_id`
+    JOIN `tags` c ON c.`copied_from` = tt.`tag_id` AND c.`tenant_id` = t.`tenant_id`
+    SET tt.`tag_id` = c.`id`;
+
+-- Categories are copied to every organization, then the copies are linked to the copies of their
+-- parents.
+INSERT INTO `categories` (`tenant_id`, `name`, `parent_id`, `created_at`, `copied_from`)
+SELECT o.`id`, c.`name`, c.`parent_id`, c.`created_at`, c.`id`
+FROM `categories` c
+JOIN `organizations` o ON o.`id` <> 1;
+
+UPDATE `categories` c
+    JOIN `categories` p ON p.`copied_from` = c.`parent_id` AND p.`tenant_id` = c.`tenant_id`
+    SET c.`parent_id` = p.`id`;
+
+UPDATE `tickets` t
+    JOIN `categories` c ON c.`copied_from` = t.`category_id` AND c.`tenant_id` = t.`tenant_id`
+    SET t.`category_id` = c.`id`, t.`updated_at` = t.`updated_at`;
+
+-- Custom fields are copied to every organization.
+INSERT INTO `custom_fields` (`tenant_id`, `field_key`, `name`, `type`, `options`, `required_for`, `created_at`, `copied_from`)
+SELECT o.`id`, f.`field_key`, f.`name`, f.`type`, f.`options`, f.`required_for`, f.`created_at`, f.`id`
+FROM `custom_fields` f
+JOIN `organizations` o ON o.`id` <> 1;
+
+UPDATE `ticket_field_values` fv
+    JOIN `tickets` t ON t.`id` = fv.`ticket_id`
+    JOIN `custom_fields` c ON c.`copied_from` = fv.`field_id` AND c.`tenant_id` = t.`tenant_id`
+    SET fv.`field_id` = c.`id`;
+
+ALTER TABLE `teams` DROP COLUMN `copied_from`;
+ALTER TABLE `tags` DROP COLUMN `copied_from`;
+ALTER TABLE `categories` DROP COLUMN `copied_from`;
+ALTER TABLE `custom_fields` DROP COLUMN `copied_from`;
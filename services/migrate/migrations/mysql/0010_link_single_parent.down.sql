@@ -0,0 +1,3 @@
+ALTER TABLE `ticket_links`
+    DROP KEY `uq_ticket_links_parent`,
+    DROP COLUMN `parent_target_id`;
@@ -0,0 +1,7 @@
+-- A ticket has at most one parent. MySQL has no partial indexes, so a generated column holds the
+-- target of parent_of links only and its unique key ignores the NULLs of the other types. It is
+-- virtual because target_id cascades, which MySQL does not allow under a stored column.
+
+ALTER TABLE `ticket_links`
+    ADD COLUMN `parent_target_id` INT AS (IF(`type` = 'parent_of', `target_id`, NULL)) VIRTUAL,
+    ADD UNIQUE KEY `uq_ticket_links_parent` (`parent_target_id`);
@@ -0,0 +1,3 @@
+ALTER TABLE `ticket_ccs`
+    DROP KEY `uq_ticket_ccs_claim_token`,
+    DROP COLUMN `claim_token`;
@@ -0,0 +1,8 @@
+-- Contacts copied by email claim their CC for an account with a token sent to the address, instead
+-- of matching whichever account registered it. Existing contacts get a token too.
+
+ALTER TABLE `ticket_ccs`
+    ADD COLUMN `claim_token` VARCHAR(64) NULL, -- Set for contacts without an account
+    ADD UNIQUE KEY `uq_ticket_ccs_claim_token` (`claim_token`);
+
+UPDATE `ticket_ccs` SET `claim_token` = SHA2(CONCAT(`id`, '-', RAND(), '-', UUID()), 256) WHERE `user_id` IS NULL;
@@ -0,0 +1,207 @@
+-- PostgreSQL version of mysql/0001_initial.up.sql. Keep the two in step.
+-- The GIN indexes serve ticket search, as the FULLTEXT keys do on MySQL.
+
+--
+-- Table structure for table users
+--
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    role VARCHAR(50) NOT NULL DEFAULT 'Agent',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    password_reset_token VARCHAR(255),
+    password_reset_expires TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ -- Set while the user is in the trash
+);
+
+--
+-- Table structure for table categories
+--
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    parent_id INT, -- Null for top-level categories
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (parent_id) REFERENCES categories(id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table tickets
+--
+CREATE TABLE IF NOT EXISTS tickets (
+    id SERIAL PRIMARY KEY,
+    title VARCHAR(255) NOT NULL,
+    type VARCHAR(50) NOT NULL DEFAULT 'Question',
+    description TEXT,
+    status VARCHAR(50) NOT NULL DEFAULT 'Open',
+    priority VARCHAR(50) NOT NULL DEFAULT 'Medium',
+    requester_id INT NOT NULL,
+    assignee_id INT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMPTZ,
+    version INT NOT NULL DEFAULT 1,
+    category_id INT,
+    deleted_at TIMESTAMPTZ, -- Set while the ticket is in the trash
+    FOREIGN KEY (requester_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (assignee_id) REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE,
+    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table comments
+--
+CREATE TABLE IF NOT EXISTS comments (
+    id SERIAL PRIMARY KEY,
+    ticket_id INT NOT NULL,
+    author_id INT NOT NULL, -- Assuming comments are primarily from agents (users)
+    body TEXT NOT NULL,
+    is_internal BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMPTZ, -- Set while the comment is in the trash
+    FOREIGN KEY (ticket_id) REFERENCES tickets(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table teams
+--
+CREATE TABLE IF NOT EXISTS teams (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+--
+-- Table structure for table team_members
+--
+CREATE TABLE IF NOT EXISTS team_members (
+    team_id INT NOT NULL,
+    user_id INT NOT NULL,
+    PRIMARY KEY (team_id, user_id),
+    FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table views
+--
+CREATE TABLE IF NOT EXISTS views (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    query TEXT NOT NULL,
+    owner_id INT NOT NULL,
+    team_id INT, -- Shared with this team when set
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table tags
+--
+CREATE TABLE IF NOT EXISTS tags (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    suggested BOOLEAN DEFAULT FALSE, -- Curated by admins, offered first in autocomplete
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+--
+-- Table structure for table ticket_tags
+--
+CREATE TABLE IF NOT EXISTS ticket_tags (
+    ticket_id INT NOT NULL,
+    tag_id INT NOT NULL,
+    PRIMARY KEY (ticket_id, tag_id),
+    FOREIGN KEY (ticket_id) REFERENCES tickets(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table custom_fields
+--
+CREATE TABLE IF NOT EXISTS custom_fields (
+    id SERIAL PRIMARY KEY,
+    field_key VARCHAR(64) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL, -- text, number, date, select, multiselect or user
+    options JSONB, -- Allowed values of select and multiselect fields
+    required_for JSONB, -- Ticket types that must have a value
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+--
+-- Table structure for table ticket_field_values
+--
+CREATE TABLE IF NOT EXISTS ticket_field_values (
+    id SERIAL PRIMARY KEY,
+    ticket_id INT NOT NULL,
+    field_id INT NOT NULL,
+    text_value TEXT, -- Text and select values, one row per option of multiselect fields
+    number_value DOUBLE PRECISION,
+    date_value TIMESTAMPTZ,
+    user_id INT,
+    sort_key VARCHAR(191) NOT NULL, -- Orders values of any type as plain strings
+    FOREIGN KEY (ticket_id) REFERENCES tickets(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (field_id) REFERENCES custom_fields(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table ticket_links
+--
+CREATE TABLE IF NOT EXISTS ticket_links (
+    id SERIAL PRIMARY KEY,
+    source_id INT NOT NULL,
+    target_id INT NOT NULL,
+    type VARCHAR(20) NOT NULL, -- duplicates, related, blocks or parent_of, read from source to target
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (source_id) REFERENCES tickets(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (target_id) REFERENCES tickets(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+--
+-- Table structure for table audit_log
+--
+CREATE TABLE IF NOT EXISTS audit_log (
+    id SERIAL PRIMARY KEY,
+    actor_id INT, -- No foreign key, entries outlive the users who made them
+    action VARCHAR(50) NOT NULL,
+    entity_type VARCHAR(50) NOT NULL,
+    entity_id INT NOT NULL,
+    changes JSONB,
+    ip VARCHAR(45),
+    user_agent VARCHAR(512),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    prev_hash CHAR(64) NOT NULL DEFAULT '', -- Hash of the preceding entry
+    hash CHAR(64) NOT NULL DEFAULT '' -- SHA-256 over this entry and prev_hash
+);
+
+--
+-- Table structure for table audit_checkpoints
+--
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+    id SERIAL PRIMARY KEY,
+    last_entry_id INT NOT NULL, -- No foreign key, a checkpoint must survive to expose deleted entries
+    hash CHAR(64) NOT NULL,
+    signature VARCHAR(128) NOT NULL, -- Base64 ed25519 signature
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+--
+-- Indexes
+--
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_tickets_deleted_at ON tickets (deleted_at);
+CREATE INDEX IF NOT EXISTS ft_tickets_title_description ON tickets USING GIN (to_tsvector('english', COALESCE(title, '') || ' ' || COALESCE(description, '')));
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments (deleted_at);
+CREATE INDEX IF NOT EXISTS ft_comments_body ON comments USING GIN (to_tsvector('english', body));
+CREATE INDEX IF NOT EXISTS idx_ticket_field_values_ticket ON ticket_field_values (ticket_id, field_id);
+CREATE INDEX IF NOT EXISTS idx_ticket_field_values_sort ON ticket_field_values (field_id, sort_key);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_ticket_links ON ticket_links (source_id, target_id, type);
+CREATE INDEX IF NOT EXISTS idx_ticket_links_target ON ticket_links (target_id, type);
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log (entity_type, entity_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log (actor_id, created_at);
@@ -0,0 +1,7 @@
+-- Drops organizations. Every row is kept, but which organization it belonged to is lost.
+-- Dropping the columns drops their indexes and foreign keys too.
+ALTER TABLE audit_log DROP COLUMN tenant_id;
+ALTER TABLE comments DROP COLUMN tenant_id;
+ALTER TABLE tickets DROP COLUMN tenant_id;
+ALTER TABLE users DROP COLUMN tenant_id;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,34 @@
+-- PostgreSQL version of mysql/0002_organizations.up.sql. Keep the two in step.
+
+--
+-- Table structure for table organizations
+--
+CREATE TABLE IF NOT EXISTS organizations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    host VARCHAR(255) UNIQUE, -- Hostname the organization is served on, if any
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO organizations (id, name) VALUES (1, 'Default');
+SELECT setval(pg_get_serial_sequence('organizations', 'id'), 1);
+
+ALTER TABLE users
+    ADD COLUMN tenant_id INT NOT NULL DEFAULT 1
+    CONSTRAINT fk_users_tenant REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE;
+
+ALTER TABLE tickets
+    ADD COLUMN tenant_id INT NOT NULL DEFAULT 1
+    CONSTRAINT fk_tickets_tenant REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE;
+
+ALTER TABLE comments
+    ADD COLUMN tenant_id INT NOT NULL DEFAULT 1
+    CONSTRAINT fk_comments_tenant REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE;
+
+-- Null for entries not made on behalf of one organization, such as purges. No foreign key, like actor_id.
+ALTER TABLE audit_log ADD COLUMN tenant_id INT NULL;
+
+CREATE INDEX idx_users_tenant ON users (tenant_id, email);
+CREATE INDEX idx_tickets_tenant ON tickets (tenant_id, status);
+CREATE INDEX idx_comments_tenant ON comments (tenant_id, ticket_id);
+CREATE INDEX idx_audit_log_tenant ON audit_log (tenant_id, created_at);
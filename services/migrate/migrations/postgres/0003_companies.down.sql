@@ -0,0 +1,4 @@
+-- Drops companies. Their members stay, without a company.
+-- Dropping the column drops its index and foreign key too.
+ALTER TABLE users DROP COLUMN company_id;
+DROP TABLE IF EXISTS companies;
@@ -0,0 +1,22 @@
+-- PostgreSQL version of mysql/0003_companies.up.sql. Keep the two in step.
+
+--
+-- Table structure for table companies
+--
+CREATE TABLE IF NOT EXISTS companies (
+    id SERIAL PRIMARY KEY,
+    tenant_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    domain VARCHAR(255), -- Email domain whose customers join the company, if any
+    shared_tickets BOOLEAN NOT NULL DEFAULT FALSE, -- Members may view and comment on each other's tickets
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT uq_companies_name UNIQUE (tenant_id, name),
+    CONSTRAINT uq_companies_domain UNIQUE (tenant_id, domain),
+    CONSTRAINT fk_companies_tenant FOREIGN KEY (tenant_id) REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+ALTER TABLE users
+    ADD COLUMN company_id INT NULL
+    CONSTRAINT fk_users_company REFERENCES companies(id) ON DELETE SET NULL ON UPDATE CASCADE;
+
+CREATE INDEX idx_users_company ON users (company_id);
@@ -0,0 +1,33 @@
+-- PostgreSQL version of mysql/0004_ticket_participants.up.sql. Keep the two in step.
+
+--
+-- Table structure for table ticket_watchers
+--
+CREATE TABLE IF NOT EXISTS ticket_watchers (
+    ticket_id INT NOT NULL,
+    user_id INT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (ticket_id, user_id),
+    FOREIGN KEY (ticket_id) REFERENCES tickets(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_ticket_watchers_user ON ticket_watchers (user_id);
+
+--
+-- Table structure for table ticket_ccs
+--
+CREATE TABLE IF NOT EXISTS ticket_ccs (
+    id SERIAL PRIMARY KEY,
+    ticket_id INT NOT NULL,
+    user_id INT, -- Set for customers with an account
+    email VARCHAR(255), -- Set for contacts without one
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (ticket_id) REFERENCES tickets(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_ticket_ccs_user ON ticket_ccs (ticket_id, user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_ticket_ccs_email ON ticket_ccs (ticket_id, email);
+CREATE INDEX IF NOT EXISTS idx_ticket_ccs_user ON ticket_ccs (user_id);
+CREATE INDEX IF NOT EXISTS idx_ticket_ccs_email ON ticket_ccs (email);
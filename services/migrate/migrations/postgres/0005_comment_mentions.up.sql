@@ -0,0 +1,15 @@
+-- PostgreSQL version of mysql/0005_comment_mentions.up.sql. Keep the two in step.
+
+--
+-- Table structure for table comment_mentions
+--
+CREATE TABLE IF NOT EXISTS comment_mentions (
+    comment_id INT NOT NULL,
+    user_id INT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (comment_id, user_id),
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_comment_mentions_user ON comment_mentions (user_id);
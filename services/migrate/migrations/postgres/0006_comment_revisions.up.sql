@@ -0,0 +1,19 @@
+-- PostgreSQL version of mysql/0006_comment_revisions.up.sql. Keep the two in step.
+
+ALTER TABLE comments
+    ADD COLUMN edited_at TIMESTAMPTZ NULL; -- Set once the comment has been edited
+
+--
+-- Table structure for table comment_revisions
+--
+CREATE TABLE IF NOT EXISTS comment_revisions (
+    id SERIAL PRIMARY KEY,
+    comment_id INT NOT NULL,
+    body TEXT NOT NULL, -- Text the edit replaced
+    editor_id INT NOT NULL, -- User who made the edit
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP, -- When the edit was made
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (editor_id) REFERENCES users(id) ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_comment_revisions_comment ON comment_revisions (comment_id);
@@ -0,0 +1,44 @@
+-- PostgreSQL version of mysql/0009_tenant_scoped_tables.down.sql. Keep the two in step.
+
+UPDATE team_members tm SET team_id = k.id
+FROM teams t, (SELECT name, MIN(id) AS id FROM teams GROUP BY name) k
+WHERE t.id = tm.team_id AND k.name = t.name;
+
+UPDATE views v SET team_id = k.id
+FROM teams t, (SELECT name, MIN(id) AS id FROM teams GROUP BY name) k
+WHERE t.id = v.team_id AND k.name = t.name;
+
+UPDATE macros m SET team_id = k.id
+FROM teams t, (SELECT name, MIN(id) AS id FROM teams GROUP BY name) k
+WHERE t.id = m.team_id AND k.name = t.name;
+
+DELETE FROM teams t USING teams k WHERE k.name = t.name AND k.id < t.id;
+
+UPDATE ticket_tags tt SET tag_id = k.id
+FROM tags g, (SELECT name, MIN(id) AS id FROM tags GROUP BY name) k
+WHERE g.id = tt.tag_id AND k.name = g.name;
+
+DELETE FROM tags g USING tags k WHERE k.name = g.name AND k.id < g.id;
+
+UPDATE ticket_field_values fv SET field_id = k.id
+FROM custom_fields f, (SELECT field_key, MIN(id) AS id FROM custom_fields GROUP BY field_key) k
+WHERE f.id = fv.field_id AND k.field_key = f.field_key;
+
+DELETE FROM custom_fields f USING custom_fields k WHERE k.field_key = f.field_key AND k.id < f.id;
+
+-- Dropping the columns drops their indexes, unique constraints and foreign keys too.
+ALTER TABLE custom_fields
+    DROP COLUMN tenant_id,
+    ADD CONSTRAINT custom_fields_field_key_key UNIQUE (field_key);
+
+ALTER TABLE categories DROP COLUMN tenant_id;
+
+ALTER TABLE tags
+    DROP COLUMN tenant_id,
+    ADD CONSTRAINT tags_name_key UNIQUE (name);
+
+ALTER TABLE views DROP COLUMN tenant_id;
+
+ALTER TABLE teams
+    DROP COLUMN tenant_id,
+    ADD CONSTRAINT teams_name_key UNIQUE (name);
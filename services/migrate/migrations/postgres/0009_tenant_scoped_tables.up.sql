@@ -0,0 +1,104 @@
+-- PostgreSQL version of mysql/0009_tenant_scoped_tables.up.sql. Keep the two in step.
+
+ALTER TABLE teams
+    ADD COLUMN tenant_id INT NOT NULL DEFAULT 1
+    CONSTRAINT fk_teams_tenant REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    ADD COLUMN copied_from INT NULL,
+    DROP CONSTRAINT teams_name_key,
+    ADD CONSTRAINT uq_teams_tenant_name UNIQUE (tenant_id, name);
+
+ALTER TABLE views
+    ADD COLUMN tenant_id INT NOT NULL DEFAULT 1
+    CONSTRAINT fk_views_tenant REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE;
+
+ALTER TABLE tags
+    ADD COLUMN tenant_id INT NOT NULL DEFAULT 1
+    CONSTRAINT fk_tags_tenant REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    ADD COLUMN copied_from INT NULL,
+    DROP CONSTRAINT tags_name_key,
+    ADD CONSTRAINT uq_tags_tenant_name UNIQUE (tenant_id, name);
+
+ALTER TABLE categories
+    ADD COLUMN tenant_id INT NOT NULL DEFAULT 1
+    CONSTRAINT fk_categories_tenant REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    ADD COLUMN copied_from INT NULL;
+
+ALTER TABLE custom_fields
+    ADD COLUMN tenant_id INT NOT NULL DEFAULT 1
+    CONSTRAINT fk_custom_fields_tenant REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    ADD COLUMN copied_from INT NULL,
+    DROP CONSTRAINT custom_fields_field_key_key,
+    ADD CONSTRAINT uq_custom_fields_tenant_key UNIQUE (tenant_id, field_key);
+
+CREATE INDEX IF NOT EXISTS idx_views_tenant ON views (tenant_id, owner_id);
+CREATE INDEX IF NOT EXISTS idx_categories_tenant ON categories (tenant_id, parent_id);
+
+-- Views belong to their owner's organization.
+UPDATE views v SET tenant_id = u.tenant_id FROM users u WHERE u.id = v.owner_id;
+
+-- Teams are copied to the organizations of their members, views and macros.
+INSERT INTO teams (tenant_id, name, created_at, copied_from)
+SELECT DISTINCT used.tenant_id, t.name, t.created_at, t.id
+FROM teams t
+JOIN (
+    SELECT tm.team_id, u.tenant_id FROM team_members tm JOIN users u ON u.id = tm.user_id
+    UNION SELECT team_id, tenant_id FROM views WHERE team_id IS NOT NULL
+    UNION SELECT team_id, tenant_id FROM macros WHERE team_id IS NOT NULL
+) used ON used.team_id = t.id
+WHERE used.tenant_id <> 1;
+
+UPDATE team_members tm SET team_id = c.id
+FROM users u, teams c
+WHERE u.id = tm.user_id AND c.copied_from = tm.team_id AND c.tenant_id = u.tenant_id;
+
+UPDATE views v SET team_id = c.id
+FROM teams c
+WHERE c.copied_from = v.team_id AND c.tenant_id = v.tenant_id;
+
+UPDATE macros m SET team_id = c.id
+FROM teams c
+WHERE c.copied_from = m.team_id AND c.tenant_id = m.tenant_id;
+
+-- Tags are copied to the organizations whose tickets carry them, suggested tags to all.
+INSERT INTO tags (tenant_id, name, suggested, created_at, copied_from)
+SELECT o.id, g.name, g.suggested, g.created_at, g.id
+FROM tags g
+JOIN organizations o ON o.id <> 1
+WHERE g.suggested OR EXISTS (
+    SELECT 1 FROM ticket_tags tt JOIN tickets t ON t.id = tt.ticket_id
+    WHERE tt.tag_id = g.id AND t.tenant_id = o.id
+);
+
+UPDATE ticket_tags tt SET tag_id = c.id
+FROM tickets t, tags c
+WHERE t.id = tt.ticket_id AND c.copied_from = tt.tag_id AND c.tenant_id = t.tenant_id;
+
+-- Categories are copied to every organization, then the copies are linked to the copies of their
+-- parents.
+INSERT INTO categories (tenant_id, name, parent_id, created_at, copied_from)
+SELECT o.id, c.name, c.parent_id, c.created_at, c.id
+FROM categories c
+JOIN organizations o ON o.id <> 1;
+
+UPDATE categories c SET parent_id = p.id
+FROM categories p
+WHERE p.copied_from = c.parent_id AND p.tenant_id = c.tenant_id;
+
+UPDATE tickets t SET category_id = c.id
+FROM categories c
+WHERE c.copied_from = t.category_id AND c.tenant_id = t.tenant_id;
+
+-- Custom fields are copied to every organization.
+INSERT INTO custom_fields (tenant_id, field_key, name, type, options, required_for, created_at, copied_from)
+SELECT o.id, f.field_key, f.name, f.type, f.options, f.required_for, f.created_at, f.id
+FROM custom_fields f
+JOIN organizations o ON o.id <> 1;
+
+UPDATE ticket_field_values fv SET field_id = c.id
+FROM tickets t, custom_fields c
+WHERE t.id = fv.ticket_id AND c.copied_from = fv.field_id AND c.tenant_id = t.tenant_id;
+
+ALTER TABLE teams DROP COLUMN copied_from;
+ALTER TABLE tags DROP COLUMN copied_from;
+ALTER TABLE categories DROP COLUMN copied_from;
+ALTER TABLE custom_fields DROP COLUMN copied_from;
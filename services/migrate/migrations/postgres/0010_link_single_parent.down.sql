@@ -0,0 +1 @@
+DROP INDEX IF EXISTS uq_ticket_links_parent;
@@ -0,0 +1,3 @@
+-- PostgreSQL version of mysql/0010_link_single_parent.up.sql. Keep the two in step.
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_ticket_links_parent ON ticket_links (target_id) WHERE type = 'parent_of';
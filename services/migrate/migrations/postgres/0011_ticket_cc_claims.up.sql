@@ -0,0 +1,7 @@
+-- PostgreSQL version of mysql/0011_ticket_cc_claims.up.sql. Keep the two in step.
+
+ALTER TABLE ticket_ccs ADD COLUMN claim_token VARCHAR(64) NULL; -- Set for contacts without an account
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_ticket_ccs_claim_token ON ticket_ccs (claim_token);
+
+UPDATE ticket_ccs SET claim_token = md5(random()::text || id) || md5(random()::text || clock_timestamp()) WHERE user_id IS NULL;
@@ -0,0 +1,10 @@
+-- Drops organizations. Every row is kept, but which organization it belonged to is lost.
+DROP INDEX IF EXISTS idx_audit_log_tenant;
+DROP INDEX IF EXISTS idx_comments_tenant;
+DROP INDEX IF EXISTS idx_tickets_tenant;
+DROP INDEX IF EXISTS idx_users_tenant;
+ALTER TABLE audit_log DROP COLUMN tenant_id;
+ALTER TABLE comments DROP COLUMN tenant_id;
+ALTER TABLE tickets DROP COLUMN tenant_id;
+ALTER TABLE users DROP COLUMN tenant_id;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,26 @@
+-- SQLite version of mysql/0002_organizations.up.sql. Keep the two in step.
+-- SQLite cannot add a column with a foreign key and a non-null default, so tenant_id is not
+-- checked against organizations here.
+
+--
+-- Table structure for table organizations
+--
+CREATE TABLE IF NOT EXISTS organizations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    host VARCHAR(255) UNIQUE, -- Hostname the organization is served on, if any
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%S+00:00', 'now'))
+);
+
+INSERT INTO organizations (id, name) VALUES (1, 'Default');
+
+ALTER TABLE users ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE tickets ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE comments ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+-- Null for entries not made on behalf of one organization, such as purges.
+ALTER TABLE audit_log ADD COLUMN tenant_id INT NULL;
+
+CREATE INDEX idx_users_tenant ON users (tenant_id, email);
+CREATE INDEX idx_tickets_tenant ON tickets (tenant_id, status);
+CREATE INDEX idx_comments_tenant ON comments (tenant_id, ticket_id);
+CREATE INDEX idx_audit_log_tenant ON audit_log (tenant_id, created_at);
@@ -0,0 +1,4 @@
+-- Drops companies. Their members stay, without a company.
+DROP INDEX IF EXISTS idx_users_company;
+ALTER TABLE users DROP COLUMN company_id;
+DROP TABLE IF EXISTS companies;
@@ -0,0 +1,20 @@
+-- SQLite version of mysql/0003_companies.up.sql. Keep the two in step.
+
+--
+-- Table structure for table companies
+--
+CREATE TABLE IF NOT EXISTS companies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    domain VARCHAR(255), -- Email domain whose customers join the company, if any
+    shared_tickets BOOLEAN NOT NULL DEFAULT FALSE, -- Members may view and comment on each other's tickets
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%S+00:00', 'now')),
+    UNIQUE (tenant_id, name),
+    UNIQUE (tenant_id, domain),
+    FOREIGN KEY (tenant_id) REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+ALTER TABLE users ADD COLUMN company_id INT NULL REFERENCES companies(id) ON DELETE SET NULL ON UPDATE CASCADE;
+
+CREATE INDEX idx_users_company ON users (company_id);
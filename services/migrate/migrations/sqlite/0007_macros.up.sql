@@ -0,0 +1,24 @@
+-- SQLite version of mysql/0007_macros.up.sql. Keep the two in step.
+
+--
+-- Table structure for table macros
+--
+CREATE TABLE IF NOT EXISTS macros (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL, -- Reply template, empty for macros that only change the ticket
+    is_internal BOOLEAN NOT NULL DEFAULT FALSE, -- Reply as an internal note
+    actions JSON, -- Ticket changes to apply
+    owner_id INT NOT NULL,
+    team_id INT, -- Shared with this team when set
+    shared BOOLEAN NOT NULL DEFAULT FALSE, -- Shared with every agent of the organization
+    usage_count INT NOT NULL DEFAULT 0,
+    last_used_at DATETIME NULL,
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%S+00:00', 'now')),
+    FOREIGN KEY (tenant_id) REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_macros_tenant ON macros (tenant_id);
@@ -0,0 +1,41 @@
+-- SQLite version of mysql/0008_triggers.up.sql. Keep the two in step.
+
+--
+-- Table structure for table triggers
+--
+CREATE TABLE IF NOT EXISTS triggers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    event VARCHAR(50) NOT NULL, -- ticket.created, ticket.updated or comment.created
+    conditions JSON, -- Conditions that must all hold, and conditions of which one must hold
+    actions JSON, -- Ticket changes, in order
+    position INT NOT NULL DEFAULT 0, -- Triggers are evaluated by position, then ID
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%S+00:00', 'now')),
+    FOREIGN KEY (tenant_id) REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_triggers_tenant_event ON triggers (tenant_id, event);
+
+--
+-- Table structure for table trigger_runs
+--
+CREATE TABLE IF NOT EXISTS trigger_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id INT NOT NULL,
+    trigger_id INT NOT NULL,
+    ticket_id INT NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    actor_id INT, -- User whose request fired the trigger
+    actions JSON, -- Actions the trigger executed
+    error_message TEXT, -- Why the actions could not be saved, if they were not
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%S+00:00', 'now')),
+    FOREIGN KEY (tenant_id) REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    FOREIGN KEY (trigger_id) REFERENCES triggers(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (ticket_id) REFERENCES tickets(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_trigger_runs_trigger ON trigger_runs (trigger_id);
+CREATE INDEX IF NOT EXISTS idx_trigger_runs_ticket ON trigger_runs (ticket_id);
@@ -0,0 +1,165 @@
+-- SQLite version of mysql/0009_tenant_scoped_tables.down.sql. Keep the two in step.
+-- Teams, tags and custom fields are rebuilt without their organization, along with the tables
+-- referring to them, as in the up script.
+
+--
+-- Teams, and the team members, views and macros referring to them
+--
+CREATE TABLE teams_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%S+00:00', 'now'))
+);
+
+INSERT INTO teams_old (id, name, created_at)
+SELECT MIN(id), name, MIN(created_at) FROM teams GROUP BY name;
+
+CREATE TABLE team_members_old (
+    team_id INT NOT NULL,
+    user_id INT NOT NULL,
+    PRIMARY KEY (team_id, user_id),
+    FOREIGN KEY (team_id) REFERENCES teams_old(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+INSERT OR IGNORE INTO team_members_old (team_id, user_id)
+SELECT k.id, tm.user_id
+FROM team_members tm
+JOIN teams t ON t.id = tm.team_id
+JOIN teams_old k ON k.name = t.name;
+
+CREATE TABLE views_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(255) NOT NULL,
+    query TEXT NOT NULL,
+    owner_id INT NOT NULL,
+    team_id INT, -- Shared with this team when set
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%S+00:00', 'now')),
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (team_id) REFERENCES teams_old(id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+INSERT INTO views_old (id, name, query, owner_id, team_id, created_at)
+SELECT v.id, v.name, v.query, v.owner_id, k.id, v.created_at
+FROM views v
+LEFT JOIN teams t ON t.id = v.team_id
+LEFT JOIN teams_old k ON k.name = t.name;
+
+CREATE TABLE macros_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL, -- Reply template, empty for macros that only change the ticket
+    is_internal BOOLEAN NOT NULL DEFAULT FALSE, -- Reply as an internal note
+    actions JSON, -- Ticket changes to apply
+    owner_id INT NOT NULL,
+    team_id INT, -- Shared with this team when set
+    shared BOOLEAN NOT NULL DEFAULT FALSE, -- Shared with every agent of the organization
+    usage_count INT NOT NULL DEFAULT 0,
+    last_used_at DATETIME NULL,
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%S+00:00', 'now')),
+    FOREIGN KEY (tenant_id) REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (team_id) REFERENCES teams_old(id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+INSERT INTO macros_old (id, tenant_id, name, body, is_internal, actions, owner_id, team_id, shared, usage_count, last_used_at, created_at)
+SELECT m.id, m.tenant_id, m.name, m.body, m.is_internal, m.actions, m.owner_id, k.id, m.shared, m.usage_count, m.last_used_at, m.created_at
+FROM macros m
+LEFT JOIN teams t ON t.id = m.team_id
+LEFT JOIN teams_old k ON k.name = t.name;
+
+DROP TABLE macros;
+DROP TABLE views;
+DROP TABLE team_members;
+DROP TABLE teams;
+ALTER TABLE teams_old RENAME TO teams;
+ALTER TABLE team_members_old RENAME TO team_members;
+ALTER TABLE views_old RENAME TO views;
+ALTER TABLE macros_old RENAME TO macros;
+
+CREATE INDEX idx_macros_tenant ON macros (tenant_id);
+
+--
+-- Tags, and the ticket tags referring to them
+--
+CREATE TABLE tags_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    suggested BOOLEAN DEFAULT FALSE, -- Curated by admins, offered first in autocomplete
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%S+00:00', 'now'))
+);
+
+INSERT INTO tags_old (id, name, suggested, created_at)
+SELECT MIN(id), name, MAX(suggested), MIN(created_at) FROM tags GROUP BY name;
+
+CREATE TABLE ticket_tags_old (
+    ticket_id INT NOT NULL,
+    tag_id INT NOT NULL,
+    PRIMARY KEY (ticket_id, tag_id),
+    FOREIGN KEY (ticket_id) REFERENCES tickets(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags_old(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+INSERT OR IGNORE INTO ticket_tags_old (ticket_id, tag_id)
+SELECT tt.ticket_id, k.id
+FROM ticket_tags tt
+JOIN tags g ON g.id = tt.tag_id
+JOIN tags_old k ON k.name = g.name;
+
+DROP TABLE ticket_tags;
+DROP TABLE tags;
+ALTER TABLE tags_old RENAME TO tags;
+ALTER TABLE ticket_tags_old RENAME TO ticket_tags;
+
+--
+-- Categories
+--
+DROP INDEX IF EXISTS idx_categories_tenant;
+ALTER TABLE categories DROP COLUMN tenant_id;
+
+--
+-- Custom fields, and the values referring to them
+--
+CREATE TABLE custom_fields_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    field_key VARCHAR(64) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL, -- text, number, date, select, multiselect or user
+    options JSON, -- Allowed values of select and multiselect fields
+    required_for JSON, -- Ticket types that must have a value
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%S+00:00', 'now'))
+);
+
+INSERT INTO custom_fields_old (id, field_key, name, type, options, required_for, created_at)
+SELECT f.id, f.field_key, f.name, f.type, f.options, f.required_for, f.created_at
+FROM custom_fields f
+WHERE f.id = (SELECT MIN(k.id) FROM custom_fields k WHERE k.field_key = f.field_key);
+
+CREATE TABLE ticket_field_values_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ticket_id INT NOT NULL,
+    field_id INT NOT NULL,
+    text_value TEXT, -- Text and select values, one row per option of multiselect fields
+    number_value DOUBLE,
+    date_value DATETIME,
+    user_id INT,
+    sort_key VARCHAR(191) NOT NULL, -- Orders values of any type as plain strings
+    FOREIGN KEY (ticket_id) REFERENCES tickets(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (field_id) REFERENCES custom_fields_old(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+INSERT INTO ticket_field_values_old (id, ticket_id, field_id, text_value, number_value, date_value, user_id, sort_key)
+SELECT fv.id, fv.ticket_id, k.id, fv.text_value, fv.number_value, fv.date_value, fv.user_id, fv.sort_key
+FROM ticket_field_values fv
+JOIN custom_fields f ON f.id = fv.field_id
+JOIN custom_fields_old k ON k.field_key = f.field_key;
+
+DROP TABLE ticket_field_values;
+DROP TABLE custom_fields;
+ALTER TABLE custom_fields_old RENAME TO custom_fields;
+ALTER TABLE ticket_field_values_old RENAME TO ticket_field_values;
+
+CREATE INDEX idx_ticket_field_values_ticket ON ticket_field_values (ticket_id, field_id);
+CREATE INDEX idx_ticket_field_values_sort ON ticket_field_values (field_id, sort_key);
@@ -0,0 +1,226 @@
+-- SQLite version of mysql/0009_tenant_scoped_tables.up.sql. Keep the two in step.
+-- SQLite cannot drop a UNIQUE constraint, so teams, tags and custom fields are rebuilt, and with
+-- them the tables referring to them: a parent dropped while foreign keys are enforced would take
+-- its children's rows along. Renaming the rebuilt tables moves those references over.
+
+--
+-- Teams, and the team members, views and macros referring to them
+--
+CREATE TABLE teams_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id INT NOT NULL DEFAULT 1,
+    name VARCHAR(255) NOT NULL,
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%S+00:00', 'now')),
+    copied_from INT NULL,
+    UNIQUE (tenant_id, name),
+    FOREIGN KEY (tenant_id) REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+INSERT INTO teams_new (id, name, created_at)
+SELECT id, name, created_at FROM teams;
+
+CREATE TABLE views_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id INT NOT NULL DEFAULT 1,
+    name VARCHAR(255) NOT NULL,
+    query TEXT NOT NULL,
+    owner_id INT NOT NULL,
+    team_id INT, -- Shared with this team when set
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%S+00:00', 'now')),
+    FOREIGN KEY (tenant_id) REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (team_id) REFERENCES teams_new(id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+-- Views belong to their owner's organization.
+INSERT INTO views_new (id, tenant_id, name, query, owner_id, team_id, created_at)
+SELECT v.id, u.tenant_id, v.name, v.query, v.owner_id, v.team_id, v.created_at
+FROM views v JOIN users u ON u.id = v.owner_id;
+
+-- Teams are copied to the organizations of their members, views and macros.
+INSERT INTO teams_new (tenant_id, name, created_at, copied_from)
+SELECT DISTINCT used.tenant_id, t.name, t.created_at, t.id
+FROM teams t
+JOIN (
+    SELECT tm.team_id, u.tenant_id FROM team_members tm JOIN users u ON u.id = tm.user_id
+    UNION SELECT team_id, tenant_id FROM views_new WHERE team_id IS NOT NULL
+    UNION SELECT team_id, tenant_id FROM macros WHERE team_id IS NOT NULL
+) used ON used.team_id = t.id
+WHERE used.tenant_id <> 1;
+
+UPDATE views_new SET team_id = c.id
+FROM teams_new c
+WHERE c.copied_from = views_new.team_id AND c.tenant_id = views_new.tenant_id;
+
+CREATE TABLE team_members_new (
+    team_id INT NOT NULL,
+    user_id INT NOT NULL,
+    PRIMARY KEY (team_id, user_id),
+    FOREIGN KEY (team_id) REFERENCES teams_new(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+INSERT INTO team_members_new (team_id, user_id)
+SELECT COALESCE(c.id, tm.team_id), tm.user_id
+FROM team_members tm
+JOIN users u ON u.id = tm.user_id
+LEFT JOIN teams_new c ON c.copied_from = tm.team_id AND c.tenant_id = u.tenant_id;
+
+CREATE TABLE macros_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL, -- Reply template, empty for macros that only change the ticket
+    is_internal BOOLEAN NOT NULL DEFAULT FALSE, -- Reply as an internal note
+    actions JSON, -- Ticket changes to apply
+    owner_id INT NOT NULL,
+    team_id INT, -- Shared with this team when set
+    shared BOOLEAN NOT NULL DEFAULT FALSE, -- Shared with every agent of the organization
+    usage_count INT NOT NULL DEFAULT 0,
+    last_used_at DATETIME NULL,
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%S+00:00', 'now')),
+    FOREIGN KEY (tenant_id) REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (team_id) REFERENCES teams_new(id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+INSERT INTO macros_new (id, tenant_id, name, body, is_internal, actions, owner_id, team_id, shared, usage_count, last_used_at, created_at)
+SELECT m.id, m.tenant_id, m.name, m.body, m.is_internal, m.actions, m.owner_id, COALESCE(c.id, m.team_id), m.shared, m.usage_count, m.last_used_at, m.created_at
+FROM macros m
+LEFT JOIN teams_new c ON c.copied_from = m.team_id AND c.tenant_id = m.tenant_id;
+
+DROP TABLE macros;
+DROP TABLE views;
+DROP TABLE team_members;
+DROP TABLE teams;
+ALTER TABLE teams_new RENAME TO teams;
+ALTER TABLE team_members_new RENAME TO team_members;
+ALTER TABLE views_new RENAME TO views;
+ALTER TABLE macros_new RENAME TO macros;
+ALTER TABLE teams DROP COLUMN copied_from;
+
+CREATE INDEX idx_views_tenant ON views (tenant_id, owner_id);
+CREATE INDEX idx_macros_tenant ON macros (tenant_id);
+
+--
+-- Tags, and the ticket tags referring to them
+--
+CREATE TABLE tags_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id INT NOT NULL DEFAULT 1,
+    name VARCHAR(100) NOT NULL,
+    suggested BOOLEAN DEFAULT FALSE, -- Curated by admins, offered first in autocomplete
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%S+00:00', 'now')),
+    copied_from INT NULL,
+    UNIQUE (tenant_id, name),
+    FOREIGN KEY (tenant_id) REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+INSERT INTO tags_new (id, name, suggested, created_at)
+SELECT id, name, suggested, created_at FROM tags;
+
+-- Tags are copied to the organizations whose tickets carry them, suggested tags to all.
+INSERT INTO tags_new (tenant_id, name, suggested, created_at, copied_from)
+SELECT o.id, g.name, g.suggested, g.created_at, g.id
+FROM tags g
+JOIN organizations o ON o.id <> 1
+WHERE g.suggested OR EXISTS (
+    SELECT 1 FROM ticket_tags tt JOIN tickets t ON t.id = tt.ticket_id
+    WHERE tt.tag_id = g.id AND t.tenant_id = o.id
+);
+
+CREATE TABLE ticket_tags_new (
+    ticket_id INT NOT NULL,
+    tag_id INT NOT NULL,
+    PRIMARY KEY (ticket_id, tag_id),
+    FOREIGN KEY (ticket_id) REFERENCES tickets(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags_new(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+INSERT INTO ticket_tags_new (ticket_id, tag_id)
+SELECT tt.ticket_id, COALESCE(c.id, tt.tag_id)
+FROM ticket_tags tt
+JOIN tickets t ON t.id = tt.ticket_id
+LEFT JOIN tags_new c ON c.copied_from = tt.tag_id AND c.tenant_id = t.tenant_id;
+
+DROP TABLE ticket_tags;
+DROP TABLE tags;
+ALTER TABLE tags_new RENAME TO tags;
+ALTER TABLE ticket_tags_new RENAME TO ticket_tags;
+ALTER TABLE tags DROP COLUMN copied_from;
+
+--
+-- Categories, copied to every organization, then linked to the copies of their parents
+--
+ALTER TABLE categories ADD COLUMN tenant_id INT NOT NULL DEFAULT 1;
+ALTER TABLE categories ADD COLUMN copied_from INT NULL;
+
+INSERT INTO categories (tenant_id, name, parent_id, created_at, copied_from)
+SELECT o.id, c.name, c.parent_id, c.created_at, c.id
+FROM categories c
+JOIN organizations o ON o.id <> 1;
+
+UPDATE categories SET parent_id = p.id
+FROM categories p
+WHERE p.copied_from = categories.parent_id AND p.tenant_id = categories.tenant_id;
+
+UPDATE tickets SET category_id = c.id
+FROM categories c
+WHERE c.copied_from = tickets.category_id AND c.tenant_id = tickets.tenant_id;
+
+ALTER TABLE categories DROP COLUMN copied_from;
+CREATE INDEX idx_categories_tenant ON categories (tenant_id, parent_id);
+
+--
+-- Custom fields, copied to every organization, and the values referring to them
+--
+CREATE TABLE custom_fields_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    tenant_id INT NOT NULL DEFAULT 1,
+    field_key VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(20) NOT NULL, -- text, number, date, select, multiselect or user
+    options JSON, -- Allowed values of select and multiselect fields
+    required_for JSON, -- Ticket types that must have a value
+    created_at DATETIME DEFAULT (strftime('%Y-%m-%d %H:%M:%S+00:00', 'now')),
+    copied_from INT NULL,
+    UNIQUE (tenant_id, field_key),
+    FOREIGN KEY (tenant_id) REFERENCES organizations(id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+INSERT INTO custom_fields_new (id, field_key, name, type, options, required_for, created_at)
+SELECT id, field_key, name, type, options, required_for, created_at FROM custom_fields;
+
+INSERT INTO custom_fields_new (tenant_id, field_key, name, type, options, required_for, created_at, copied_from)
+SELECT o.id, f.field_key, f.name, f.type, f.options, f.required_for, f.created_at, f.id
+FROM custom_fields f
+JOIN organizations o ON o.id <> 1;
+
+CREATE TABLE ticket_field_values_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ticket_id INT NOT NULL,
+    field_id INT NOT NULL,
+    text_value TEXT, -- Text and select values, one row per option of multiselect fields
+    number_value DOUBLE,
+    date_value DATETIME,
+    user_id INT,
+    sort_key VARCHAR(191) NOT NULL, -- Orders values of any type as plain strings
+    FOREIGN KEY (ticket_id) REFERENCES tickets(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (field_id) REFERENCES custom_fields_new(id) ON DELETE CASCADE ON UPDATE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+INSERT INTO ticket_field_values_new (id, ticket_id, field_id, text_value, number_value, date_value, user_id, sort_key)
+SELECT fv.id, fv.ticket_id, COALESCE(c.id, fv.field_id), fv.text_value, fv.number_value, fv.date_value, fv.user_id, fv.sort_key
+FROM ticket_field_values fv
+JOIN tickets t ON t.id = fv.ticket_id
+LEFT JOIN custom_fields_new c ON c.copied_from = fv.field_id AND c.tenant_id = t.tenant_id;
+
+DROP TABLE ticket_field_values;
+DROP TABLE custom_fields;
+ALTER TABLE custom_fields_new RENAME TO custom_fields;
+ALTER TABLE ticket_field_values_new RENAME TO ticket_field_values;
+ALTER TABLE custom_fields DROP COLUMN copied_from;
+
+CREATE INDEX idx_ticket_field_values_ticket ON ticket_field_values (ticket_id, field_id);
+CREATE INDEX idx_ticket_field_values_sort ON ticket_field_values (field_id, sort_key);
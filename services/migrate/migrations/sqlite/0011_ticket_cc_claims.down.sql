@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS uq_ticket_ccs_claim_token;
+ALTER TABLE ticket_ccs DROP COLUMN claim_token;
@@ -0,0 +1,257 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Audit actions.
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditMerge   = "merge"
+	AuditSplit   = "split"
+	AuditLink    = "link"
+	AuditUnlink  = "unlink"
+	AuditRestore = "restore"
+	AuditPurge   = "purge" // Permanent removal of a deleted entity
+)
+
+// FieldChange is the value of a field before and after a change. Before is nil for created
+// entities and After is nil for deleted ones.
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditEntry records one change made through the API. Entries are only ever inserted.
+type AuditEntry struct {
+	bun.BaseModel `bun:"table:audit_log,alias:audit"`
+	ID            int64                  `bun:"id,pk,autoincrement,type:integer"`
+	TenantID      sql.NullInt64          `bun:"tenant_id"` // Null for entries made outside any organization, such as purges
+	ActorID       sql.NullInt64          `bun:"actor_id"`  // Null for anonymous requests such as registration
+	Action        string                 `bun:"action,notnull"`
+	EntityType    string                 `bun:"entity_type,notnull"`
+	EntityID      int64                  `bun:"entity_id,notnull"`
+	Changes       map[string]FieldChange `bun:"changes,type:json"`
+	IP            string                 `bun:"ip"`
+	UserAgent     string                 `bun:"user_agent"`
+	CreatedAt     time.Time              `bun:"created_at,notnull,default:current_timestamp"`
+	PrevHash      string                 `bun:"prev_hash,notnull"` // Hash of the preceding entry, empty for the first
+	Hash          string                 `bun:"hash,notnull"`
+}
+
+// AuditOrigin identifies who made a change, for writes that record their audit entries in their
+// own transaction.
+type AuditOrigin struct {
+	ActorID   sql.NullInt64 // Null for anonymous requests
+	IP        string
+	UserAgent string
+}
+
+// AuditFilter narrows a query on the audit log. Zero values match everything.
+type AuditFilter struct {
+	ActorID    int64
+	Action     string
+	EntityType string
+	EntityID   int64
+	Since      time.Time
+	Until      time.Time
+}
+
+// HistoryEvent is one item of a ticket timeline: either a recorded change or a comment.
+type HistoryEvent struct {
+	At      time.Time   `json:"At"`
+	Kind    string      `json:"Kind"` // "change" or "comment"
+	Change  *AuditEntry `json:"Change,omitempty"`
+	Comment *Comment    `json:"Comment,omitempty"`
+}
+
+// auditIgnored lists fields that change as a side effect of every write, or are rendered from
+// other fields, and would only add noise.
+var auditIgnored = []string{"Version", "CreatedAt", "UpdatedAt", "Comments", "Links", "Children", "BodyHTML", "DescriptionHTML"}
+
+// auditRedacted lists fields whose values must never be written to the audit log.
+var auditRedacted = []string{"PasswordHash", "PasswordResetToken", "PasswordResetExpires"}
+
+// Diff returns the fields that differ between two snapshots of an entity, compared through their
+// JSON form. Pass nil as before for a created entity or as after for a deleted one. When both are
+// set, fields missing from either side are not compared.
+func Diff(before, after any) (map[string]FieldChange, error) {
+	b, err := snapshot(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := snapshot(after)
+	if err != nil {
+		return nil, err
+	}
+
+	whole := len(b) == 0 || len(a) == 0 // Creation or deletion
+	keys := make(map[string]bool)
+	for key := range b {
+		keys[key] = whole || hasKey(a, key)
+	}
+	for key := range a {
+		keys[key] = whole || hasKey(b, key)
+	}
+
+	changes := make(map[string]FieldChange)
+	for key, compare := range keys {
+		if !compare || slices.Contains(auditIgnored, key) || reflect.DeepEqual(b[key], a[key]) {
+			continue
+		}
+		if slices.Contains(auditRedacted, key) {
+			changes[key] = FieldChange{Before: "[redacted]", After: "[redacted]"}
+			continue
+		}
+		changes[key] = FieldChange{Before: b[key], After: a[key]}
+	}
+	return changes, nil
+}
+
+func snapshot(v any) (map[string]any, error) {
+	m := make(map[string]any)
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return m, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return m, json.Unmarshal(data, &m)
+}
+
+func hasKey(m map[string]any, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+// CreateAuditEntry appends an entry to the audit log, chaining it to the previous entry's hash.
+// Appends are serialized by the database, which keeps working when db is a transaction of the
+// caller, so that no two entries share a predecessor. The entry belongs to the context's
+// organization; the chain itself spans all of them.
+func CreateAuditEntry(db bun.IDB, ctx context.Context, entry *AuditEntry) error {
+	tenantID, err := TenantID(ctx)
+	if err != nil {
+		return err
+	}
+	entry.TenantID = sql.NullInt64{Int64: tenantID, Valid: tenantID != 0}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	// The database stores whole seconds, so hash what will be read back.
+	entry.CreatedAt = entry.CreatedAt.UTC().Truncate(time.Second)
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		// Locking the last entry serializes appends from every connection and server instance.
+		if err := lockForWrite(ctx, tx, "audit_log"); err != nil {
+			return err
+		}
+		err := tx.NewSelect().
+			Model((*AuditEntry)(nil)).
+			Column("hash").
+			Order("id DESC").
+			Limit(1).
+			Apply(forUpdate).
+			Scan(WithAllTenants(ctx), &entry.PrevHash)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		entry.Hash = entry.ComputeHash()
+		_, err = tx.NewInsert().Model(entry).Exec(ctx)
+		return err
+	})
+}
+
+// RecordChange appends the change between two snapshots of an entity to the audit log, as
+// CreateAuditEntry. Pass nil as before for a created entity or as after for a deleted one. Updates
+// that change nothing are not recorded.
+func RecordChange(db bun.IDB, ctx context.Context, origin AuditOrigin, action, entityType string, entityID int64, before, after any) error {
+	changes, err := Diff(before, after)
+	if err != nil {
+		return err
+	}
+	if action == AuditUpdate && len(changes) == 0 {
+		return nil
+	}
+	return CreateAuditEntry(db, ctx, &AuditEntry{
+		ActorID:    origin.ActorID,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Changes:    changes,
+		IP:         origin.IP,
+		UserAgent:  origin.UserAgent,
+	})
+}
+
+// ListAuditEntries retrieves a page of audit entries matching the filter, newest first by default.
+func ListAuditEntries(db *bun.DB, ctx context.Context, filter AuditFilter, page PageRequest) (*Page[AuditEntry], error) {
+	q := db.NewSelect().Model((*AuditEntry)(nil))
+	if filter.ActorID != 0 {
+		q = q.Where("audit.actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		q = q.Where("audit.action = ?", filter.Action)
+	}
+	if filter.EntityType != "" {
+		q = q.Where("audit.entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID != 0 {
+		q = q.Where("audit.entity_id = ?", filter.EntityID)
+	}
+	if !filter.Since.IsZero() {
+		q = q.Where("audit.created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		q = q.Where("audit.created_at < ?", filter.Until)
+	}
+	if len(page.Sort) == 0 {
+		page.Sort = []SortKey{{Column: "created_at", Desc: true}}
+	}
+	return paginate[AuditEntry](ctx, q, page, "created_at", "actor_id", "entity_type", "action")
+}
+
+// GetTicketHistory retrieves the timeline of a ticket: its recorded changes interleaved with its
+// comments, oldest first. Internal comments are left out unless includeInternal is set.
+func GetTicketHistory(db *bun.DB, ctx context.Context, ticketID int64, includeInternal bool) ([]HistoryEvent, error) {
+	var entries []AuditEntry
+	err := db.NewSelect().
+		Model(&entries).
+		Where("entity_type = ?", "ticket").
+		Where("entity_id = ?", ticketID).
+		Order("id ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := ListCommentsByTicketID(db, ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(comments) // Oldest first, so comments with equal timestamps keep their order
+
+	events := make([]HistoryEvent, 0, len(entries)+len(comments))
+	for i := range entries {
+		events = append(events, HistoryEvent{At: entries[i].CreatedAt, Kind: "change", Change: &entries[i]})
+	}
+	for i := range comments {
+		if comments[i].IsInternal && !includeInternal {
+			continue
+		}
+		events = append(events, HistoryEvent{At: comments[i].CreatedAt, Kind: "comment", Comment: &comments[i]})
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+	return events, nil
+}
@@ -0,0 +1,237 @@
+package models
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// AuditCheckpoint is a signed statement of the audit log's hash as of one entry. Because each
+// entry's hash covers all earlier entries, a checkpoint vouches for the whole log up to that point,
+// and rewriting the log would require the signing key to forge new checkpoints.
+type AuditCheckpoint struct {
+	bun.BaseModel `bun:"table:audit_checkpoints,alias:cp"`
+	ID            int64     `bun:"id,pk,autoincrement,type:integer"`
+	LastEntryID   int64     `bun:"last_entry_id,notnull"`
+	Hash          string    `bun:"hash,notnull"`      // Hash of the entry LastEntryID
+	Signature     string    `bun:"signature,notnull"` // Base64 ed25519 signature of the checkpoint message
+	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+// AuditVerification is the outcome of walking the audit log's hash chain.
+type AuditVerification struct {
+	Valid              bool
+	EntriesChecked     int
+	LegacyEntries      int // Entries written before the chain existed, which cannot be verified
+	CheckpointsChecked int
+	SignaturesChecked  bool   // False when no public key is configured
+	BrokenEntryID      int64  `json:",omitempty"` // First entry whose hash or link does not match
+	BrokenCheckpointID int64  `json:",omitempty"`
+	Reason             string `json:",omitempty"`
+}
+
+// auditHashInput lists the hashed fields of an entry. Its ID is left out as it is assigned on
+// insert; the order of entries is fixed by PrevHash instead. TenantID is left out of entries
+// without one, so entries written before organizations existed keep their hashes.
+type auditHashInput struct {
+	PrevHash   string
+	TenantID   *int64 `json:",omitempty"`
+	ActorID    *int64
+	Action     string
+	EntityType string
+	EntityID   int64
+	Changes    map[string]FieldChange
+	IP         string
+	UserAgent  string
+	CreatedAt  string
+}
+
+// ComputeHash returns the hex SHA-256 of the entry's content and its predecessor's hash.
+func (e *AuditEntry) ComputeHash() string {
+	in := auditHashInput{
+		PrevHash:   e.PrevHash,
+		Action:     e.Action,
+		EntityType: e.EntityType,
+		EntityID:   e.EntityID,
+		Changes:    e.Changes,
+		IP:         e.IP,
+		UserAgent:  e.UserAgent,
+		CreatedAt:  e.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if e.TenantID.Valid {
+		in.TenantID = &e.TenantID.Int64
+	}
+	if e.ActorID.Valid {
+		in.ActorID = &e.ActorID.Int64
+	}
+	if in.Changes == nil {
+		in.Changes = map[string]FieldChange{}
+	}
+	// Maps marshal with sorted keys, so the encoding survives a round trip through the database.
+	data, _ := json.Marshal(in)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// message returns the bytes covered by the checkpoint's signature.
+func (c *AuditCheckpoint) message() []byte {
+	return fmt.Appendf(nil, "goat-audit-checkpoint:%d:%s:%s", c.LastEntryID, c.Hash, c.CreatedAt.UTC().Format(time.RFC3339))
+}
+
+// CreateAuditCheckpoint signs the current head of the audit log. It returns nil without writing
+// anything when no entries were added since the last checkpoint. The chain spans every
+// organization, so ctx is not limited to one.
+func CreateAuditCheckpoint(db *bun.DB, ctx context.Context, key ed25519.PrivateKey) (*AuditCheckpoint, error) {
+	ctx = WithAllTenants(ctx)
+	var head AuditEntry
+	err := db.NewSelect().Model(&head).Column("id", "hash").Order("id DESC").Limit(1).Scan(ctx)
+	if err == sql.ErrNoRows || (err == nil && head.Hash == "") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var last AuditCheckpoint
+	err = db.NewSelect().Model(&last).Order("id DESC").Limit(1).Scan(ctx)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil && last.LastEntryID == head.ID {
+		return nil, nil
+	}
+
+	checkpoint := &AuditCheckpoint{
+		LastEntryID: head.ID,
+		Hash:        head.Hash,
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+	}
+	checkpoint.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, checkpoint.message()))
+	if _, err := db.NewInsert().Model(checkpoint).Exec(ctx); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// RunAuditCheckpoints checkpoints the audit log every interval until ctx is done.
+// Failures are logged and retried at the next tick.
+func RunAuditCheckpoints(ctx context.Context, db *bun.DB, key ed25519.PrivateKey, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := CreateAuditCheckpoint(db, ctx, key); err != nil {
+				fmt.Printf("Error creating audit checkpoint: %v\n", err)
+			}
+		}
+	}
+}
+
+// VerifyAuditChain walks the audit log in order, recomputing every entry's hash and checking that it
+// links to its predecessor, then checks every checkpoint against the entry it covers. Signatures
+// are only checked when publicKey is set. The walk stops at the first broken link. The chain spans
+// every organization, so all of it is walked whatever ctx is limited to; only entry IDs are reported.
+func VerifyAuditChain(db *bun.DB, ctx context.Context, publicKey ed25519.PublicKey) (*AuditVerification, error) {
+	ctx = WithAllTenants(ctx)
+	result := &AuditVerification{SignaturesChecked: publicKey != nil}
+
+	var checkpoints []AuditCheckpoint
+	if err := db.NewSelect().Model(&checkpoints).Order("id ASC").Scan(ctx); err != nil {
+		return nil, err
+	}
+	byEntry := make(map[int64][]AuditCheckpoint)
+	for _, c := range checkpoints {
+		byEntry[c.LastEntryID] = append(byEntry[c.LastEntryID], c)
+	}
+
+	const batchSize = 1000
+	var lastID int64
+	prevHash := ""
+	chained := false
+	for {
+		var entries []AuditEntry
+		err := db.NewSelect().
+			Model(&entries).
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(batchSize).
+			Scan(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range entries {
+			e := &entries[i]
+			lastID = e.ID
+			if e.Hash == "" && !chained {
+				result.LegacyEntries++
+				continue
+			}
+			chained = true
+			result.EntriesChecked++
+
+			switch {
+			case e.PrevHash != prevHash:
+				return result.broken(e.ID, 0, "entry does not link to the preceding entry; an entry was removed, reordered or edited"), nil
+			case e.ComputeHash() != e.Hash:
+				return result.broken(e.ID, 0, "entry content does not match its hash"), nil
+			}
+			prevHash = e.Hash
+
+			for _, c := range byEntry[e.ID] {
+				if reason := checkCheckpoint(&c, e.Hash, publicKey); reason != "" {
+					return result.broken(e.ID, c.ID, reason), nil
+				}
+				result.CheckpointsChecked++
+			}
+			delete(byEntry, e.ID)
+		}
+
+		if len(entries) < batchSize {
+			break
+		}
+	}
+
+	// Checkpoints left over vouch for entries that no longer exist, e.g. after truncating the log.
+	for _, c := range checkpoints {
+		if _, missing := byEntry[c.LastEntryID]; missing {
+			return result.broken(c.LastEntryID, c.ID, "checkpointed entry is missing"), nil
+		}
+	}
+
+	result.Valid = true
+	return result, nil
+}
+
+// checkCheckpoint returns why a checkpoint does not vouch for an entry hash, or "" if it does.
+func checkCheckpoint(c *AuditCheckpoint, entryHash string, publicKey ed25519.PublicKey) string {
+	if c.Hash != entryHash {
+		return "checkpoint hash does not match the entry"
+	}
+	if publicKey == nil {
+		return ""
+	}
+	sig, err := base64.StdEncoding.DecodeString(c.Signature)
+	if err != nil || !ed25519.Verify(publicKey, c.message(), sig) {
+		return "checkpoint signature is invalid"
+	}
+	return ""
+}
+
+func (v *AuditVerification) broken(entryID, checkpointID int64, reason string) *AuditVerification {
+	v.BrokenEntryID = entryID
+	v.BrokenCheckpointID = checkpointID
+	v.Reason = reason
+	return v
+}
@@ -0,0 +1,74 @@
+package models_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/uptrace/bun"
+
+	"goat/services/models"
+)
+
+func TestCreateAuditEntryConcurrent(t *testing.T) {
+	const n = 50
+	db := newTestDB(t)
+	ctx := tenantContext()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entry := models.AuditEntry{Action: models.AuditCreate, EntityType: "ticket", EntityID: int64(i + 1)}
+			if i%2 == 0 {
+				errs <- models.CreateAuditEntry(db, ctx, &entry)
+				return
+			}
+			// Half of the entries are written inside a transaction of the caller.
+			errs <- db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+				return models.CreateAuditEntry(tx, ctx, &entry)
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := models.VerifyAuditChain(db, ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid || result.EntriesChecked != n {
+		t.Errorf("got %+v, want a valid chain of %d entries", result, n)
+	}
+}
+
+func TestCreateAuditEntryRollsBack(t *testing.T) {
+	db := newTestDB(t)
+	ctx := tenantContext()
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		entry := models.AuditEntry{Action: models.AuditCreate, EntityType: "ticket", EntityID: 1}
+		if err := models.CreateAuditEntry(tx, ctx, &entry); err != nil {
+			return err
+		}
+		return context.Canceled
+	})
+	if err != context.Canceled {
+		t.Fatalf("got %v, want the error of the transaction", err)
+	}
+
+	n, err := db.NewSelect().Model((*models.AuditEntry)(nil)).Count(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("got %d entries after rollback, want 0", n)
+	}
+}
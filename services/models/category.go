@@ -0,0 +1,78 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ErrCategoryHasChildren is returned when deleting a category that still has subcategories.
+var ErrCategoryHasChildren = errors.New("category has subcategories")
+
+// Category represents a node in the hierarchical ticket category tree.
+type Category struct {
+	bun.BaseModel `bun:"table:categories,alias:category"`
+	ID            int64         `bun:"id,pk,autoincrement,type:integer"`
+	TenantID      int64         `bun:"tenant_id,notnull"` // Organization the category belongs to
+	Name          string        `bun:"name,notnull"`
+	ParentID      sql.NullInt64 `bun:"parent_id"` // Null for top-level categories
+	CreatedAt     time.Time     `bun:"created_at,notnull,default:current_timestamp"`
+	Children      []*Category   `bun:"-" json:"Children,omitempty"` // This field is not stored in the database
+}
+
+// GetCategoryByID retrieves a category from the database by its ID.
+func GetCategoryByID(db *bun.DB, ctx context.Context, categoryID int64) (*Category, error) {
+	category := new(Category)
+	err := db.NewSelect().Model(category).Where("id = ?", categoryID).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// ListCategoryTree retrieves every category arranged as a tree of top-level categories.
+func ListCategoryTree(db *bun.DB, ctx context.Context) ([]*Category, error) {
+	var categories []*Category
+	err := db.NewSelect().Model(&categories).Order("name ASC").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*Category, len(categories))
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+
+	roots := []*Category{}
+	for _, category := range categories {
+		if parent, ok := byID[category.ParentID.Int64]; category.ParentID.Valid && ok {
+			parent.Children = append(parent.Children, category)
+		} else {
+			roots = append(roots, category)
+		}
+	}
+	return roots, nil
+}
+
+// CreateCategory inserts a new category into the database.
+func CreateCategory(db *bun.DB, ctx context.Context, category *Category) error {
+	_, err := db.NewInsert().Model(category).Exec(ctx)
+	return err
+}
+
+// DeleteCategory deletes a category with no subcategories. Its tickets become uncategorized.
+func DeleteCategory(db *bun.DB, ctx context.Context, categoryID int64) error {
+	hasChildren, err := db.NewSelect().Model((*Category)(nil)).Where("parent_id = ?", categoryID).Exists(ctx)
+	if err != nil {
+		return err
+	}
+	if hasChildren {
+		return ErrCategoryHasChildren
+	}
+
+	_, err = db.NewDelete().Model(&Category{}).Where("id = ?", categoryID).Exec(ctx)
+	return err
+}
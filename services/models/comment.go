@@ -2,22 +2,36 @@ package models
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
 	"github.com/uptrace/bun"
+
+	"goat/services/markdown"
 )
 
 // Comment represents the Comment model in the database.
 type Comment struct {
 	bun.BaseModel `bun:"table:comments,alias:comment"`
-	ID            int64     `bun:"id,pk,autoincrement,type:integer"`
-	TicketID      int64     `bun:"ticket_id,notnull"`
-	AuthorID      int64     `bun:"author_id,notnull"`
-	Body          string    `bun:"body,notnull"`
-	IsInternal    bool      `bun:"is_internal,default:false"`
-	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	ID            int64      `bun:"id,pk,autoincrement,type:integer"`
+	TenantID      int64      `bun:"tenant_id,notnull"` // Organization of the comment's ticket
+	TicketID      int64      `bun:"ticket_id,notnull"`
+	AuthorID      int64      `bun:"author_id,notnull"`
+	Body          string     `bun:"body,notnull"`
+	IsInternal    bool       `bun:"is_internal,default:false"`
+	CreatedAt     time.Time  `bun:"created_at,notnull,default:current_timestamp"`
+	EditedAt      *time.Time `bun:"edited_at,nullzero" json:"EditedAt,omitempty"`               // Set once the comment has been edited
+	DeletedAt     *time.Time `bun:"deleted_at,soft_delete,nullzero" json:"DeletedAt,omitempty"` // Set while the comment is in the trash
+	Mentions      []int64    `bun:"-" json:"Mentions,omitempty"`                                // Users mentioned with @handle, when the comment is created
+}
+
+// MarshalJSON adds BodyHTML, the body's Markdown rendered to sanitized HTML, next to the raw Body.
+func (c Comment) MarshalJSON() ([]byte, error) {
+	type comment Comment
+	return json.Marshal(struct {
+		comment
+		BodyHTML string `json:"BodyHTML"`
+	}{comment(c), markdown.Render(c.Body)})
 }
 
 // GetCommentByID retrieves a comment from the database by its ID.
@@ -30,14 +44,13 @@ func GetCommentByID(db *bun.DB, ctx context.Context, commentID int64) (*Comment,
 	return comment, nil
 }
 
-// ListComments retrieves all comments from the database.
-func ListComments(db *bun.DB, ctx context.Context) ([]Comment, error) {
-	var comments []Comment
-	err := db.NewSelect().Model(&comments).Scan(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return comments, nil
+// commentSortColumns lists the comment columns lists can be sorted by.
+var commentSortColumns = []string{"ticket_id", "author_id", "created_at"}
+
+// ListComments retrieves a page of comments from the database, narrowed by any filters.
+func ListComments(db *bun.DB, ctx context.Context, page PageRequest, filters ...func(*bun.SelectQuery) *bun.SelectQuery) (*Page[Comment], error) {
+	q := db.NewSelect().Model((*Comment)(nil)).Apply(filters...)
+	return paginate[Comment](ctx, q, page, commentSortColumns...)
 }
 
 // ListCommentsByTicketID retrieves comments for a specific ticket from the database.
@@ -53,22 +66,16 @@ func ListCommentsByTicketID(db *bun.DB, ctx context.Context, ticketID int64) ([]
 // CreateComment inserts a new comment into the database.
 func CreateComment(db *bun.DB, ctx context.Context, comment *Comment) error {
 	_, err := db.NewInsert().Model(comment).Exec(ctx)
-	if err != nil {
-		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
-			return fmt.Errorf("duplicate entry for comment: %w", err)
-		}
-		return err
-	}
-	return nil
+	return constraintError(err, "comment")
 }
 
-// UpdateComment updates an existing comment in the database.
+// UpdateComment saves an existing comment's body and edit time.
 func UpdateComment(db *bun.DB, ctx context.Context, comment *Comment) error {
-	_, err := db.NewUpdate().Model(comment).WherePK().Exec(ctx)
+	_, err := db.NewUpdate().Model(comment).Column("body", "edited_at").WherePK().Exec(ctx)
 	return err
 }
 
-// DeleteComment deletes a comment from the database by its ID.
+// DeleteComment moves a comment to the trash by its ID. PurgeDeleted removes it for good.
 func DeleteComment(db *bun.DB, ctx context.Context, commentID int64) error {
 	_, err := db.NewDelete().Model(&Comment{}).Where("id = ?", commentID).Exec(ctx)
 	return err
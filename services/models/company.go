@@ -0,0 +1,176 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Company is a customer's company. Customers whose email address is on its domain are listed as
+// pending members until an admin adds them, since nothing proves they own the address, and when
+// SharedTickets is set its members can view and comment on each other's
+// tickets. Companies belong to an organization.
+type Company struct {
+	bun.BaseModel `bun:"table:companies,alias:company"`
+	ID            int64     `bun:"id,pk,autoincrement,type:integer"`
+	TenantID      int64     `bun:"tenant_id,notnull"` // Organization the company belongs to
+	Name          string    `bun:"name,notnull"`
+	Domain        string    `bun:"domain,nullzero" json:"Domain,omitempty"` // Email domain whose customers are suggested as members, if any
+	SharedTickets bool      `bun:"shared_tickets,notnull"`                  // Members may view and comment on each other's tickets
+	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+// CompanyStats is a company's row in the company ticket report.
+type CompanyStats struct {
+	CompanyID int64          `json:"CompanyID"`
+	Name      string         `json:"Name"`
+	Members   int            `json:"Members"`
+	Tickets   int            `json:"Tickets"`
+	ByStatus  map[string]int `json:"ByStatus"` // Ticket count per status
+}
+
+// EmailDomain returns the lowercase domain of an email address, or "" if it has none.
+func EmailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(email[at+1:]))
+}
+
+// GetCompanyByID retrieves a company from the database by its ID.
+func GetCompanyByID(db bun.IDB, ctx context.Context, companyID int64) (*Company, error) {
+	company := new(Company)
+	err := db.NewSelect().Model(company).Where("id = ?", companyID).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return company, nil
+}
+
+// ListCompanies retrieves all companies from the database.
+func ListCompanies(db bun.IDB, ctx context.Context) ([]Company, error) {
+	companies := []Company{}
+	err := db.NewSelect().Model(&companies).Order("name ASC").Scan(ctx)
+	return companies, err
+}
+
+// CreateCompany inserts a new company into the database.
+func CreateCompany(db bun.IDB, ctx context.Context, company *Company) error {
+	_, err := db.NewInsert().Model(company).Exec(ctx)
+	return constraintError(err, "company")
+}
+
+// UpdateCompany saves a company's name, domain and ticket sharing.
+func UpdateCompany(db bun.IDB, ctx context.Context, company *Company) error {
+	_, err := db.NewUpdate().Model(company).Column("name", "domain", "shared_tickets").WherePK().Exec(ctx)
+	return constraintError(err, "company")
+}
+
+// DeleteCompany deletes a company from the database by its ID. Its members stay, without a company.
+func DeleteCompany(db bun.IDB, ctx context.Context, companyID int64) error {
+	_, err := db.NewDelete().Model(&Company{}).Where("id = ?", companyID).Exec(ctx)
+	return err
+}
+
+// ListCompanyMembers retrieves a page of a company's members.
+func ListCompanyMembers(db bun.IDB, ctx context.Context, companyID int64, page PageRequest) (*Page[*User], error) {
+	q := db.NewSelect().Model((*User)(nil)).Where("company_id = ?", companyID)
+	return paginate[*User](ctx, q, page, userSortColumns...)
+}
+
+// ListCompanyMemberIDs retrieves the IDs of all of a company's members.
+func ListCompanyMemberIDs(db bun.IDB, ctx context.Context, companyID int64) ([]int64, error) {
+	var ids []int64
+	err := db.NewSelect().Model((*User)(nil)).Column("id").Where("company_id = ?", companyID).Scan(ctx, &ids)
+	return ids, err
+}
+
+// ListDomainCustomers retrieves a page of the customers without a company whose email is on
+// domain, the pending members of the company with that domain.
+func ListDomainCustomers(db bun.IDB, ctx context.Context, domain string, page PageRequest) (*Page[*User], error) {
+	q := db.NewSelect().
+		Model((*User)(nil)).
+		Where("role = ?", "Customer").
+		Where("company_id IS NULL").
+		Where("LOWER(email) LIKE ?", "%@"+strings.ToLower(domain))
+	return paginate[*User](ctx, q, page, userSortColumns...)
+}
+
+// companyRequesters is the condition matching tickets requested by a current member of a company.
+const companyRequesters = "ticket.requester_id IN (SELECT cu.id FROM users AS cu WHERE cu.company_id = ? AND cu.deleted_at IS NULL)"
+
+// CompanyTickets narrows a ticket list to the tickets requested by the members of a company.
+func CompanyTickets(companyID int64) QueryFilter {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where(companyRequesters, companyID)
+	}
+}
+
+// GetCompanyStats counts each company's members and the tickets they requested per status,
+// optionally limited to tickets created in [createdAfter, createdBefore). Zero times mean no limit.
+func GetCompanyStats(db bun.IDB, ctx context.Context, createdAfter, createdBefore time.Time) ([]CompanyStats, error) {
+	companies, err := ListCompanies(db, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var members []struct {
+		CompanyID int64 `bun:"company_id"`
+		Count     int   `bun:"count"`
+	}
+	err = db.NewSelect().
+		Model((*User)(nil)).
+		ColumnExpr("?TableAlias.company_id").
+		ColumnExpr("COUNT(*) AS count").
+		Where("?TableAlias.company_id IS NOT NULL").
+		GroupExpr("?TableAlias.company_id").
+		Scan(ctx, &members)
+	if err != nil {
+		return nil, err
+	}
+
+	var tickets []struct {
+		CompanyID int64  `bun:"company_id"`
+		Status    string `bun:"status"`
+		Count     int    `bun:"count"`
+	}
+	q := db.NewSelect().
+		Model((*Ticket)(nil)).
+		ColumnExpr("cu.company_id").
+		ColumnExpr("ticket.status").
+		ColumnExpr("COUNT(*) AS count").
+		Join("JOIN users AS cu ON cu.id = ticket.requester_id AND cu.deleted_at IS NULL").
+		Where("cu.company_id IS NOT NULL").
+		GroupExpr("cu.company_id, ticket.status")
+	if !createdAfter.IsZero() {
+		q = q.Where("ticket.created_at >= ?", createdAfter)
+	}
+	if !createdBefore.IsZero() {
+		q = q.Where("ticket.created_at < ?", createdBefore)
+	}
+	if err := q.Scan(ctx, &tickets); err != nil {
+		return nil, err
+	}
+
+	stats := make([]CompanyStats, len(companies))
+	byID := make(map[int64]*CompanyStats, len(companies))
+	for i, company := range companies {
+		stats[i] = CompanyStats{CompanyID: company.ID, Name: company.Name, ByStatus: map[string]int{}}
+		byID[company.ID] = &stats[i]
+	}
+	for _, m := range members {
+		if s, ok := byID[m.CompanyID]; ok {
+			s.Members = m.Count
+		}
+	}
+	for _, t := range tickets {
+		if s, ok := byID[t.CompanyID]; ok {
+			s.Tickets += t.Count
+			s.ByStatus[t.Status] = t.Count
+		}
+	}
+	return stats, nil
+}
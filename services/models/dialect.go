@@ -0,0 +1,87 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+var (
+	// ErrDuplicate is returned when a write breaks a uniqueness constraint, whichever database is in use.
+	ErrDuplicate = errors.New("duplicate entry")
+	// ErrReference is returned when a write refers to a row that does not exist, or deletes a row
+	// that others still refer to, whichever database is in use.
+	ErrReference = errors.New("broken reference")
+)
+
+// constraintError maps a unique or foreign key violation reported by any backend to ErrDuplicate
+// or ErrReference, keeping the database's message. Other errors are returned unchanged.
+func constraintError(err error, entity string) error {
+	switch {
+	case err == nil:
+		return nil
+	case isDuplicateKey(err):
+		return fmt.Errorf("%w for %s: %v", ErrDuplicate, entity, err)
+	case isForeignKey(err):
+		return fmt.Errorf("%w for %s: %v", ErrReference, entity, err)
+	}
+	return err
+}
+
+// isDuplicateKey reports whether err is the database rejecting a duplicate unique or primary key.
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062
+	}
+	var pgErr pgdriver.Error
+	if errors.As(err, &pgErr) {
+		return pgErr.Field('C') == "23505"
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return true
+	}
+	// Both SQLite drivers report unique and primary key violations with this message.
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// isForeignKey reports whether err is the database rejecting a write that breaks a foreign key.
+func isForeignKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1451 || mysqlErr.Number == 1452
+	}
+	var pgErr pgdriver.Error
+	if errors.As(err, &pgErr) {
+		return pgErr.Field('C') == "23503"
+	}
+	return strings.Contains(err.Error(), "FOREIGN KEY constraint failed")
+}
+
+// forUpdate locks the selected rows until the transaction ends. SQLite has no row locks, so the
+// query is left as is there and the transaction takes the write lock with lockForWrite first.
+func forUpdate(q *bun.SelectQuery) *bun.SelectQuery {
+	if q.Dialect().Name() == dialect.SQLite {
+		return q
+	}
+	return q.For("UPDATE")
+}
+
+// lockForWrite makes a SQLite transaction take the database write lock before it reads rows it
+// will write after, which forUpdate cannot do there. A transaction that only read first would
+// still let another one write in between. Other databases rely on forUpdate alone.
+func lockForWrite(ctx context.Context, tx bun.Tx, table string) error {
+	if tx.Dialect().Name() != dialect.SQLite {
+		return nil
+	}
+	// Any write takes the lock, even one matching no rows.
+	_, err := tx.NewUpdate().Table(table).Set("id = id").Where("1 = 0").Exec(ctx)
+	return err
+}
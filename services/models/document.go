@@ -0,0 +1,1008 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// documentCursorStore marks cursors issued by the document store.
+const documentCursorStore = "doc"
+
+// DocumentStore keeps tickets, users and comments in a MongoDB-compatible document database, as
+// laid out in nosql_migration_plan.md. Tickets embed their comments. IDs are the same integers the
+// SQL database uses, so rows copied from there keep theirs. Tags, custom fields, links and the
+// other ticket relations stay in the SQL database and are not loaded from here.
+type DocumentStore struct {
+	tickets  *mongo.Collection
+	users    *mongo.Collection
+	counters *mongo.Collection
+	progress *mongo.Collection
+}
+
+// ticketDocument is a ticket as stored in the document database.
+type ticketDocument struct {
+	ID           int64             `bson:"_id"`
+	TenantID     int64             `bson:"tenant_id"`
+	Title        string            `bson:"title"`
+	Type         string            `bson:"type"`
+	Description  string            `bson:"description"`
+	Status       string            `bson:"status"`
+	Priority     string            `bson:"priority"`
+	PriorityRank int64             `bson:"priority_rank"` // Index in TicketPriorities, for sorting
+	RequesterID  int64             `bson:"requester_id"`
+	AssigneeID   *int64            `bson:"assignee_id"`
+	CategoryID   *int64            `bson:"category_id"`
+	CreatedAt    time.Time         `bson:"created_at"`
+	UpdatedAt    time.Time         `bson:"updated_at"`
+	ClosedAt     *time.Time        `bson:"closed_at"`
+	Version      int64             `bson:"version"`
+	DeletedAt    *time.Time        `bson:"deleted_at"`
+	Comments     []commentDocument `bson:"comments,omitempty"`
+}
+
+// commentDocument is a comment embedded in its ticket's document. TicketID and TenantID are only
+// set on comments unwound from their ticket.
+type commentDocument struct {
+	ID         int64      `bson:"id"`
+	TicketID   int64      `bson:"ticket_id,omitempty"`
+	TenantID   int64      `bson:"tenant_id,omitempty"`
+	AuthorID   int64      `bson:"author_id"`
+	Body       string     `bson:"body"`
+	IsInternal bool       `bson:"is_internal"`
+	CreatedAt  time.Time  `bson:"created_at"`
+	EditedAt   *time.Time `bson:"edited_at,omitempty"`
+	DeletedAt  *time.Time `bson:"deleted_at"`
+}
+
+// userDocument is a user as stored in the document database.
+type userDocument struct {
+	ID                   int64      `bson:"_id"`
+	TenantID             int64      `bson:"tenant_id"`
+	Name                 string     `bson:"name"`
+	Email                string     `bson:"email"`
+	PasswordHash         string     `bson:"password_hash"`
+	Role                 string     `bson:"role"`
+	CompanyID            *int64     `bson:"company_id"`
+	CreatedAt            time.Time  `bson:"created_at"`
+	PasswordResetToken   *string    `bson:"password_reset_token"`
+	PasswordResetExpires *time.Time `bson:"password_reset_expires"`
+	DeletedAt            *time.Time `bson:"deleted_at"`
+}
+
+// NewDocumentStore uses the tickets, users and counters collections of db, creating their indexes.
+// Tickets and users stored before organizations existed are moved to the default organization, as
+// the SQL migration does.
+func NewDocumentStore(ctx context.Context, db *mongo.Database) (*DocumentStore, error) {
+	s := &DocumentStore{
+		tickets:  db.Collection("tickets"),
+		users:    db.Collection("users"),
+		counters: db.Collection("counters"),
+		progress: db.Collection("data_migrations"),
+	}
+	for _, coll := range []*mongo.Collection{s.tickets, s.users} {
+		_, err := coll.UpdateMany(ctx, bson.D{{Key: "tenant_id", Value: bson.D{{Key: "$exists", Value: false}}}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "tenant_id", Value: DefaultTenantID}}}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to assign %s to the default organization: %w", coll.Name(), err)
+		}
+	}
+	_, err := s.tickets.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "requester_id", Value: 1}}},
+		{Keys: bson.D{{Key: "assignee_id", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "comments.id", Value: 1}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ticket indexes: %w", err)
+	}
+	_, err = s.users.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "email", Value: 1}}},
+		{Keys: bson.D{{Key: "email", Value: 1}}},
+		{Keys: bson.D{{Key: "role", Value: 1}}},
+		{Keys: bson.D{{Key: "password_reset_token", Value: 1}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user indexes: %w", err)
+	}
+	return s, nil
+}
+
+// Repositories returns repositories backed by the document store.
+func (s *DocumentStore) Repositories() Repositories {
+	return Repositories{
+		Tickets:  &DocumentTicketRepository{s},
+		Users:    &DocumentUserRepository{s},
+		Comments: &DocumentCommentRepository{s},
+	}
+}
+
+// nextID returns the next ID of a collection. reserve makes sure an ID assigned elsewhere, as by
+// the SQL database during dual writes, is never handed out again.
+func (s *DocumentStore) nextID(ctx context.Context, name string, reserve int64) (int64, error) {
+	update := bson.D{{Key: "$inc", Value: bson.D{{Key: "seq", Value: int64(1)}}}}
+	if reserve > 0 {
+		update = bson.D{{Key: "$max", Value: bson.D{{Key: "seq", Value: reserve}}}}
+	}
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := s.counters.FindOneAndUpdate(ctx, bson.D{{Key: "_id", Value: name}}, update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&counter)
+	return counter.Seq, err
+}
+
+// assignID sets *id to a new ID unless it already has one, which is then reserved.
+func (s *DocumentStore) assignID(ctx context.Context, name string, id *int64) error {
+	next, err := s.nextID(ctx, name, *id)
+	if err == nil && *id == 0 {
+		*id = next
+	}
+	return err
+}
+
+// PutTicket stores a copy of a ticket written elsewhere, replacing any earlier copy but keeping its
+// comments. It is how dual writes mirror the SQL database.
+func (s *DocumentStore) PutTicket(ctx context.Context, ticket *Ticket) error {
+	if err := s.assignID(ctx, "tickets", &ticket.ID); err != nil {
+		return err
+	}
+	doc := newTicketDocument(ticket)
+	_, err := s.tickets.UpdateOne(ctx, bson.D{{Key: "_id", Value: ticket.ID}},
+		bson.D{
+			{Key: "$set", Value: ticketFields(doc)},
+			{Key: "$setOnInsert", Value: bson.D{{Key: "comments", Value: bson.A{}}}},
+		},
+		options.UpdateOne().SetUpsert(true))
+	return err
+}
+
+// PutUser stores a copy of a user written elsewhere, replacing any earlier copy.
+func (s *DocumentStore) PutUser(ctx context.Context, user *User) error {
+	if err := s.assignID(ctx, "users", &user.ID); err != nil {
+		return err
+	}
+	_, err := s.users.ReplaceOne(ctx, bson.D{{Key: "_id", Value: user.ID}}, newUserDocument(user),
+		options.Replace().SetUpsert(true))
+	return err
+}
+
+// PutComment stores a copy of a comment written elsewhere in its ticket, replacing any earlier
+// copy. The ticket must already be in the document store.
+func (s *DocumentStore) PutComment(ctx context.Context, comment *Comment) error {
+	if err := s.assignID(ctx, "comments", &comment.ID); err != nil {
+		return err
+	}
+	doc := newCommentDocument(comment)
+	res, err := s.tickets.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: comment.TicketID}, {Key: "comments.id", Value: comment.ID}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "comments.$", Value: doc}}}})
+	if err != nil || res.MatchedCount > 0 {
+		return err
+	}
+	return s.pushComment(ctx, comment.TicketID, doc)
+}
+
+// PutTicketComments replaces every comment embedded in a ticket, including those in the trash, with
+// copies of comments written elsewhere. The ticket must already be in the document store.
+func (s *DocumentStore) PutTicketComments(ctx context.Context, ticketID int64, comments []Comment) error {
+	docs := make([]commentDocument, len(comments))
+	for i := range comments {
+		if err := s.assignID(ctx, "comments", &comments[i].ID); err != nil {
+			return err
+		}
+		docs[i] = newCommentDocument(&comments[i])
+	}
+	res, err := s.tickets.UpdateOne(ctx, bson.D{{Key: "_id", Value: ticketID}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "comments", Value: docs}}}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%w for comments: ticket %d is not in the document store", ErrReference, ticketID)
+	}
+	return nil
+}
+
+// PurgeTickets removes tickets and their comments for good, as purging the SQL trash does.
+func (s *DocumentStore) PurgeTickets(ctx context.Context, ids []int64) error {
+	_, err := s.tickets.DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+	return err
+}
+
+// PurgeUsers removes users for good, as purging the SQL trash does.
+func (s *DocumentStore) PurgeUsers(ctx context.Context, ids []int64) error {
+	_, err := s.users.DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+	return err
+}
+
+// PurgeComments removes comments from their tickets for good, as purging the SQL trash does.
+func (s *DocumentStore) PurgeComments(ctx context.Context, ids []int64) error {
+	_, err := s.tickets.UpdateMany(ctx,
+		bson.D{{Key: "comments.id", Value: bson.D{{Key: "$in", Value: ids}}}},
+		bson.D{{Key: "$pull", Value: bson.D{{Key: "comments", Value: bson.D{{Key: "id", Value: bson.D{{Key: "$in", Value: ids}}}}}}}})
+	return err
+}
+
+func (s *DocumentStore) pushComment(ctx context.Context, ticketID int64, doc commentDocument) error {
+	filter, err := tenantMatch(ctx, bson.D{{Key: "_id", Value: ticketID}})
+	if err != nil {
+		return err
+	}
+	res, err := s.tickets.UpdateOne(ctx, filter, bson.D{{Key: "$push", Value: bson.D{{Key: "comments", Value: doc}}}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%w for comment: ticket %d is not in the document store", ErrReference, ticketID)
+	}
+	return nil
+}
+
+// UsersAfter returns up to limit users with IDs above afterID in ID order, including deleted ones.
+func (s *DocumentStore) UsersAfter(ctx context.Context, afterID int64, limit int) ([]User, error) {
+	var docs []userDocument
+	if err := findAfter(ctx, s.users, afterID, limit, &docs); err != nil {
+		return nil, err
+	}
+	users := make([]User, len(docs))
+	for i := range docs {
+		users[i] = *docs[i].user()
+	}
+	return users, nil
+}
+
+// TicketsAfter returns up to limit tickets with IDs above afterID in ID order, including deleted
+// ones, without their comments.
+func (s *DocumentStore) TicketsAfter(ctx context.Context, afterID int64, limit int) ([]Ticket, error) {
+	var docs []ticketDocument
+	if err := findAfter(ctx, s.tickets, afterID, limit, &docs); err != nil {
+		return nil, err
+	}
+	tickets := make([]Ticket, len(docs))
+	for i := range docs {
+		docs[i].Comments = nil
+		tickets[i] = docs[i].ticket()
+	}
+	return tickets, nil
+}
+
+// CommentsAfter returns up to limit comments with IDs above afterID in ID order, including deleted ones.
+func (s *DocumentStore) CommentsAfter(ctx context.Context, afterID int64, limit int) ([]Comment, error) {
+	after := bson.D{{Key: "$gt", Value: afterID}}
+	match, err := tenantMatch(ctx, bson.D{{Key: "comments.id", Value: after}})
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := s.tickets.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$unwind", Value: "$comments"}},
+		{{Key: "$replaceRoot", Value: bson.D{{Key: "newRoot", Value: bson.D{{Key: "$mergeObjects", Value: bson.A{
+			"$comments", unwoundFields,
+		}}}}}}},
+		{{Key: "$match", Value: bson.D{{Key: "id", Value: after}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var docs []commentDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	comments := make([]Comment, len(docs))
+	for i := range docs {
+		comments[i] = docs[i].comment()
+	}
+	return comments, nil
+}
+
+func findAfter(ctx context.Context, coll *mongo.Collection, afterID int64, limit int, docs any) error {
+	filter, err := tenantMatch(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: afterID}}}})
+	if err != nil {
+		return err
+	}
+	cursor, err := coll.Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, docs)
+}
+
+// CopyProgress returns the last ID of an entity copied in by migrate-data, or 0.
+func (s *DocumentStore) CopyProgress(ctx context.Context, entity string) (int64, error) {
+	var progress struct {
+		LastID int64 `bson:"last_id"`
+	}
+	err := s.progress.FindOne(ctx, bson.D{{Key: "_id", Value: entity}}).Decode(&progress)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	return progress.LastID, err
+}
+
+// SaveCopyProgress records the last ID of an entity copied in by migrate-data.
+func (s *DocumentStore) SaveCopyProgress(ctx context.Context, entity string, lastID int64) error {
+	_, err := s.progress.UpdateOne(ctx, bson.D{{Key: "_id", Value: entity}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "last_id", Value: lastID}, {Key: "updated_at", Value: time.Now()}}}},
+		options.UpdateOne().SetUpsert(true))
+	return err
+}
+
+// ResetCopyProgress forgets what migrate-data copied in, so the next run starts over.
+func (s *DocumentStore) ResetCopyProgress(ctx context.Context) error {
+	_, err := s.progress.DeleteMany(ctx, bson.D{})
+	return err
+}
+
+// softDelete moves the document with the ID to the trash unless it is there already.
+func softDelete(ctx context.Context, coll *mongo.Collection, id int64) error {
+	filter, err := tenantMatch(ctx, bson.D{{Key: "_id", Value: id}, notDeleted})
+	if err != nil {
+		return err
+	}
+	_, err = coll.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: bson.D{{Key: "deleted_at", Value: time.Now()}}}})
+	return err
+}
+
+// notDeleted matches documents that are not in the trash.
+var notDeleted = bson.E{Key: "deleted_at", Value: nil}
+
+// tenantMatch adds the context's organization to a filter on tickets or users, like the query
+// hooks of the SQL models.
+func tenantMatch(ctx context.Context, filter bson.D) (bson.D, error) {
+	tenantID, err := TenantID(ctx)
+	if err != nil || tenantID == 0 {
+		return filter, err
+	}
+	return append(filter, bson.E{Key: "tenant_id", Value: tenantID}), nil
+}
+
+// documentError maps the document store's errors to the ones the SQL repositories return.
+func documentError(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// DocumentTicketRepository is the TicketRepository backed by the document store.
+type DocumentTicketRepository struct {
+	s *DocumentStore
+}
+
+func (r *DocumentTicketRepository) GetByID(ctx context.Context, id int64) (*Ticket, error) {
+	filter, err := tenantMatch(ctx, bson.D{{Key: "_id", Value: id}, notDeleted})
+	if err != nil {
+		return nil, err
+	}
+	var doc ticketDocument
+	if err := r.s.tickets.FindOne(ctx, filter).Decode(&doc); err != nil {
+		return nil, documentError(err)
+	}
+	ticket := doc.ticket()
+	return &ticket, nil
+}
+
+func (r *DocumentTicketRepository) list(ctx context.Context, match bson.D, page PageRequest, filters []QueryFilter) (*Page[Ticket], error) {
+	if err := noFilters(filters); err != nil {
+		return nil, err
+	}
+	match, err := tenantMatch(ctx, append(match, notDeleted))
+	if err != nil {
+		return nil, err
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$project", Value: bson.D{{Key: "comments", Value: 0}}}},
+	}
+	return documentPage(ctx, r.s.tickets, pipeline, page, ticketSortColumns, "_id", (*ticketDocument).ticket)
+}
+
+func (r *DocumentTicketRepository) List(ctx context.Context, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error) {
+	return r.list(ctx, bson.D{}, page, filters)
+}
+
+func (r *DocumentTicketRepository) ListOpen(ctx context.Context, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error) {
+	return r.list(ctx, bson.D{{Key: "status", Value: "Open"}}, page, filters)
+}
+
+func (r *DocumentTicketRepository) ListByAssigneeID(ctx context.Context, assigneeID int64, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error) {
+	return r.list(ctx, bson.D{{Key: "assignee_id", Value: assigneeID}}, page, filters)
+}
+
+func (r *DocumentTicketRepository) ListByRequesterID(ctx context.Context, requesterID int64, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error) {
+	return r.list(ctx, bson.D{{Key: "requester_id", Value: requesterID}}, page, filters)
+}
+
+func (r *DocumentTicketRepository) Create(ctx context.Context, ticket *Ticket) error {
+	now := time.Now().UTC()
+	ticket.Version = 1
+	if ticket.Type == "" {
+		ticket.Type = TicketTypes[0]
+	}
+	if ticket.Status == "" {
+		ticket.Status = "Open"
+	}
+	if ticket.Priority == "" {
+		ticket.Priority = "Medium"
+	}
+	if ticket.CreatedAt.IsZero() {
+		ticket.CreatedAt = now
+	}
+	if ticket.UpdatedAt.IsZero() {
+		ticket.UpdatedAt = now
+	}
+	if err := setTenant(ctx, &ticket.TenantID); err != nil {
+		return err
+	}
+	if err := r.s.assignID(ctx, "tickets", &ticket.ID); err != nil {
+		return err
+	}
+
+	_, err := r.s.tickets.InsertOne(ctx, newTicketDocument(ticket))
+	return constraintError(err, "ticket")
+}
+
+func (r *DocumentTicketRepository) Update(ctx context.Context, ticket *Ticket) error {
+	existing, err := r.GetByID(ctx, ticket.ID)
+	if err != nil {
+		return err
+	}
+	ticket.TenantID = existing.TenantID
+	ticket.CreatedAt = existing.CreatedAt
+
+	ticket.UpdatedAt = time.Now().UTC()
+	if ticket.Status == "Closed" {
+		ticket.ClosedAt = sql.NullTime{Time: ticket.UpdatedAt, Valid: true}
+	} else {
+		ticket.ClosedAt = sql.NullTime{Valid: false}
+	}
+
+	expectedVersion := ticket.Version
+	ticket.Version = expectedVersion + 1
+
+	doc := newTicketDocument(ticket)
+	res, err := r.s.tickets.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: ticket.ID}, {Key: "version", Value: expectedVersion}, notDeleted},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "type", Value: doc.Type},
+			{Key: "status", Value: doc.Status},
+			{Key: "priority", Value: doc.Priority},
+			{Key: "priority_rank", Value: doc.PriorityRank},
+			{Key: "assignee_id", Value: doc.AssigneeID},
+			{Key: "category_id", Value: doc.CategoryID},
+			{Key: "updated_at", Value: doc.UpdatedAt},
+			{Key: "closed_at", Value: doc.ClosedAt},
+			{Key: "version", Value: doc.Version},
+		}}})
+	if err != nil {
+		ticket.Version = expectedVersion
+		return err
+	}
+	if res.MatchedCount == 0 {
+		ticket.Version = expectedVersion
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+func (r *DocumentTicketRepository) Delete(ctx context.Context, id int64) error {
+	return softDelete(ctx, r.s.tickets, id)
+}
+
+// DocumentUserRepository is the UserRepository backed by the document store.
+type DocumentUserRepository struct {
+	s *DocumentStore
+}
+
+func (r *DocumentUserRepository) getBy(ctx context.Context, filter bson.D) (*User, error) {
+	filter, err := tenantMatch(ctx, append(filter, notDeleted))
+	if err != nil {
+		return nil, err
+	}
+	var doc userDocument
+	if err := r.s.users.FindOne(ctx, filter).Decode(&doc); err != nil {
+		return nil, documentError(err)
+	}
+	return doc.user(), nil
+}
+
+func (r *DocumentUserRepository) GetByID(ctx context.Context, id int64) (*User, error) {
+	return r.getBy(ctx, bson.D{{Key: "_id", Value: id}})
+}
+
+func (r *DocumentUserRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return r.getBy(ctx, bson.D{{Key: "email", Value: email}})
+}
+
+func (r *DocumentUserRepository) GetByResetToken(ctx context.Context, token string) (*User, error) {
+	return r.getBy(ctx, bson.D{{Key: "password_reset_token", Value: token}})
+}
+
+func (r *DocumentUserRepository) list(ctx context.Context, match bson.D, page PageRequest) (*Page[*User], error) {
+	match, err := tenantMatch(ctx, append(match, notDeleted))
+	if err != nil {
+		return nil, err
+	}
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: match}}}
+	return documentPage(ctx, r.s.users, pipeline, page, userSortColumns, "_id", (*userDocument).user)
+}
+
+func (r *DocumentUserRepository) List(ctx context.Context, page PageRequest) (*Page[*User], error) {
+	return r.list(ctx, bson.D{}, page)
+}
+
+func (r *DocumentUserRepository) ListByRole(ctx context.Context, role string, page PageRequest) (*Page[*User], error) {
+	return r.list(ctx, bson.D{{Key: "role", Value: role}}, page)
+}
+
+func (r *DocumentUserRepository) Create(ctx context.Context, user *User) error {
+	if user.Role == "" {
+		user.Role = "Agent"
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now().UTC()
+	}
+	if err := setTenant(ctx, &user.TenantID); err != nil {
+		return err
+	}
+	if err := r.s.assignID(ctx, "users", &user.ID); err != nil {
+		return err
+	}
+	_, err := r.s.users.InsertOne(ctx, newUserDocument(user))
+	return constraintError(err, "user")
+}
+
+func (r *DocumentUserRepository) Update(ctx context.Context, user *User) error {
+	filter, err := tenantMatch(ctx, bson.D{{Key: "_id", Value: user.ID}, notDeleted})
+	if err != nil {
+		return err
+	}
+	if err := setTenant(ctx, &user.TenantID); err != nil {
+		return err
+	}
+	_, err = r.s.users.ReplaceOne(ctx, filter, newUserDocument(user))
+	return err
+}
+
+func (r *DocumentUserRepository) Delete(ctx context.Context, id int64) error {
+	return softDelete(ctx, r.s.users, id)
+}
+
+// DocumentCommentRepository is the CommentRepository backed by the comments embedded in tickets.
+type DocumentCommentRepository struct {
+	s *DocumentStore
+}
+
+// unwoundFields are the fields an unwound comment takes from its ticket.
+var unwoundFields = bson.D{{Key: "ticket_id", Value: "$_id"}, {Key: "tenant_id", Value: "$tenant_id"}}
+
+// unwindComments turns the tickets matching match in the context's organization into their
+// comments that are not in the trash.
+func unwindComments(ctx context.Context, match bson.D, commentMatch bson.D) (mongo.Pipeline, error) {
+	match, err := tenantMatch(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+	return mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$unwind", Value: "$comments"}},
+		{{Key: "$replaceRoot", Value: bson.D{{Key: "newRoot", Value: bson.D{{Key: "$mergeObjects", Value: bson.A{
+			"$comments", unwoundFields,
+		}}}}}}},
+		{{Key: "$match", Value: append(commentMatch, notDeleted)}},
+	}, nil
+}
+
+func (r *DocumentCommentRepository) GetByID(ctx context.Context, id int64) (*Comment, error) {
+	pipeline, err := unwindComments(ctx, bson.D{{Key: "comments.id", Value: id}}, bson.D{{Key: "id", Value: id}})
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := r.s.tickets.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var docs []commentDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	comment := docs[0].comment()
+	return &comment, nil
+}
+
+func (r *DocumentCommentRepository) List(ctx context.Context, page PageRequest, filter CommentFilter) (*Page[Comment], error) {
+	match, commentMatch := bson.D{}, bson.D{}
+	if filter.TicketID != 0 {
+		match = append(match, bson.E{Key: "_id", Value: filter.TicketID})
+	}
+	if filter.PublicOnly {
+		commentMatch = append(commentMatch, bson.E{Key: "is_internal", Value: false})
+	}
+	pipeline, err := unwindComments(ctx, match, commentMatch)
+	if err != nil {
+		return nil, err
+	}
+	return documentPage(ctx, r.s.tickets, pipeline, page, commentSortColumns, "id", (*commentDocument).comment)
+}
+
+func (r *DocumentCommentRepository) Create(ctx context.Context, comment *Comment) error {
+	if comment.CreatedAt.IsZero() {
+		comment.CreatedAt = time.Now().UTC()
+	}
+	if err := setTenant(ctx, &comment.TenantID); err != nil {
+		return err
+	}
+	if err := r.s.assignID(ctx, "comments", &comment.ID); err != nil {
+		return err
+	}
+	return r.s.pushComment(ctx, comment.TicketID, newCommentDocument(comment))
+}
+
+func (r *DocumentCommentRepository) Update(ctx context.Context, comment *Comment) error {
+	filter, err := tenantMatch(ctx, bson.D{{Key: "comments", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "id", Value: comment.ID}, notDeleted}}}}})
+	if err != nil {
+		return err
+	}
+	_, err = r.s.tickets.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "comments.$.body", Value: comment.Body},
+		{Key: "comments.$.edited_at", Value: comment.EditedAt},
+	}}})
+	return err
+}
+
+func (r *DocumentCommentRepository) Delete(ctx context.Context, id int64) error {
+	filter, err := tenantMatch(ctx, bson.D{{Key: "comments", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "id", Value: id}, notDeleted}}}}})
+	if err != nil {
+		return err
+	}
+	_, err = r.s.tickets.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: bson.D{{Key: "comments.$.deleted_at", Value: time.Now()}}}})
+	return err
+}
+
+// documentPage pages through the documents produced by pipeline the way paginate pages through
+// rows, using keyset cursors. idField is the document field holding the ID.
+func documentPage[D any, T any](ctx context.Context, coll *mongo.Collection, pipeline mongo.Pipeline, page PageRequest, sortable []string, idField string, convert func(*D) T) (*Page[T], error) {
+	keys := slices.Clone(page.Sort)
+	hasID := false
+	for _, key := range keys {
+		if !canSort(sortable, key.Column) {
+			return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidSort, key.Column)
+		}
+		if _, computed := computedSortExpr(key.Column); computed {
+			return nil, fmt.Errorf("%w: cannot sort by %q in the document store", ErrInvalidSort, key.Column)
+		}
+		hasID = hasID || key.Column == "id"
+	}
+	if !hasID {
+		keys = append(keys, SortKey{Column: "id"})
+	}
+	fields := make([]string, len(keys))
+	for i, key := range keys {
+		fields[i] = documentSortField(key.Column, idField)
+	}
+
+	limit := page.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	result := &Page[T]{Items: []T{}}
+	if page.WithTotal {
+		total, err := countDocuments(ctx, coll, pipeline)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	pipeline = slices.Clone(pipeline)
+	if page.Cursor != "" {
+		values, err := decodeDocumentCursor(page.Cursor, keys)
+		if err != nil {
+			return nil, err
+		}
+		// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+		or := bson.A{}
+		for i := range keys {
+			cond := bson.D{}
+			for j := 0; j < i; j++ {
+				cond = append(cond, bson.E{Key: fields[j], Value: values[j]})
+			}
+			op := "$gt"
+			if keys[i].Desc {
+				op = "$lt"
+			}
+			or = append(or, append(cond, bson.E{Key: fields[i], Value: bson.D{{Key: op, Value: values[i]}}}))
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.D{{Key: "$or", Value: or}}}})
+	}
+
+	sort := bson.D{}
+	for i, key := range keys {
+		dir := 1
+		if key.Desc {
+			dir = -1
+		}
+		sort = append(sort, bson.E{Key: fields[i], Value: dir})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: sort}}, bson.D{{Key: "$limit", Value: limit + 1}})
+
+	cursor, err := coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var raws []bson.Raw
+	if err := cursor.All(ctx, &raws); err != nil {
+		return nil, err
+	}
+
+	for i, raw := range raws {
+		if i == limit {
+			next, err := encodeDocumentCursor(raws[limit-1], keys, fields)
+			if err != nil {
+				return nil, err
+			}
+			result.NextCursor = next
+			break
+		}
+		doc := new(D)
+		if err := bson.Unmarshal(raw, doc); err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, convert(doc))
+	}
+	return result, nil
+}
+
+// documentSortField returns the document field a column is ordered by.
+func documentSortField(column, idField string) string {
+	switch {
+	case column == "id":
+		return idField
+	case rankedSortColumns[column] != nil:
+		return column + "_rank"
+	}
+	return column
+}
+
+func countDocuments(ctx context.Context, coll *mongo.Collection, pipeline mongo.Pipeline) (int, error) {
+	cursor, err := coll.Aggregate(ctx, append(slices.Clone(pipeline), bson.D{{Key: "$count", Value: "n"}}))
+	if err != nil {
+		return 0, err
+	}
+	var counts []struct {
+		N int `bson:"n"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil || len(counts) == 0 {
+		return 0, err
+	}
+	return counts[0].N, nil
+}
+
+// encodeDocumentCursor stores the sort values of the last document as extended JSON, which keeps
+// their BSON types.
+func encodeDocumentCursor(last bson.Raw, keys []SortKey, fields []string) (string, error) {
+	c := pageCursor{Sort: formatSort(keys), Store: documentCursorStore}
+	for _, field := range fields {
+		value := last.Lookup(field)
+		raw, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: value}}, true, false)
+		if err != nil {
+			return "", err
+		}
+		c.Values = append(c.Values, raw)
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeDocumentCursor(cursor string, keys []SortKey) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Store != documentCursorStore || c.Sort != formatSort(keys) || len(c.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+	values := make([]any, len(keys))
+	for i, raw := range c.Values {
+		var v struct {
+			V bson.RawValue `bson:"v"`
+		}
+		if err := bson.UnmarshalExtJSON(raw, true, &v); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = v.V
+	}
+	return values, nil
+}
+
+func newTicketDocument(t *Ticket) ticketDocument {
+	doc := ticketDocument{
+		ID:           t.ID,
+		TenantID:     t.TenantID,
+		Title:        t.Title,
+		Type:         t.Type,
+		Description:  t.Description,
+		Status:       t.Status,
+		Priority:     t.Priority,
+		PriorityRank: int64(slices.Index(TicketPriorities, t.Priority)),
+		RequesterID:  t.RequesterID,
+		CreatedAt:    t.CreatedAt,
+		UpdatedAt:    t.UpdatedAt,
+		Version:      t.Version,
+		DeletedAt:    t.DeletedAt,
+	}
+	if t.AssigneeID.Valid {
+		doc.AssigneeID = &t.AssigneeID.Int64
+	}
+	if t.CategoryID.Valid {
+		doc.CategoryID = &t.CategoryID.Int64
+	}
+	if t.ClosedAt.Valid {
+		doc.ClosedAt = &t.ClosedAt.Time
+	}
+	for i := range t.Comments {
+		doc.Comments = append(doc.Comments, newCommentDocument(&t.Comments[i]))
+	}
+	return doc
+}
+
+// ticketFields lists the fields of a ticket document other than its ID and comments.
+func ticketFields(doc ticketDocument) bson.D {
+	return bson.D{
+		{Key: "tenant_id", Value: doc.TenantID},
+		{Key: "title", Value: doc.Title},
+		{Key: "type", Value: doc.Type},
+		{Key: "description", Value: doc.Description},
+		{Key: "status", Value: doc.Status},
+		{Key: "priority", Value: doc.Priority},
+		{Key: "priority_rank", Value: doc.PriorityRank},
+		{Key: "requester_id", Value: doc.RequesterID},
+		{Key: "assignee_id", Value: doc.AssigneeID},
+		{Key: "category_id", Value: doc.CategoryID},
+		{Key: "created_at", Value: doc.CreatedAt},
+		{Key: "updated_at", Value: doc.UpdatedAt},
+		{Key: "closed_at", Value: doc.ClosedAt},
+		{Key: "version", Value: doc.Version},
+		{Key: "deleted_at", Value: doc.DeletedAt},
+	}
+}
+
+func (d *ticketDocument) ticket() Ticket {
+	t := Ticket{
+		ID:          d.ID,
+		TenantID:    d.TenantID,
+		Title:       d.Title,
+		Type:        d.Type,
+		Description: d.Description,
+		Status:      d.Status,
+		Priority:    d.Priority,
+		RequesterID: d.RequesterID,
+		CreatedAt:   d.CreatedAt,
+		UpdatedAt:   d.UpdatedAt,
+		Version:     d.Version,
+		DeletedAt:   d.DeletedAt,
+	}
+	if d.AssigneeID != nil {
+		t.AssigneeID = sql.NullInt64{Int64: *d.AssigneeID, Valid: true}
+	}
+	if d.CategoryID != nil {
+		t.CategoryID = sql.NullInt64{Int64: *d.CategoryID, Valid: true}
+	}
+	if d.ClosedAt != nil {
+		t.ClosedAt = sql.NullTime{Time: *d.ClosedAt, Valid: true}
+	}
+	for _, c := range d.Comments {
+		if c.DeletedAt == nil {
+			c.TicketID, c.TenantID = d.ID, d.TenantID
+			t.Comments = append(t.Comments, c.comment())
+		}
+	}
+	return t
+}
+
+func newCommentDocument(c *Comment) commentDocument {
+	return commentDocument{
+		ID:         c.ID,
+		AuthorID:   c.AuthorID,
+		Body:       c.Body,
+		IsInternal: c.IsInternal,
+		CreatedAt:  c.CreatedAt,
+		EditedAt:   c.EditedAt,
+		DeletedAt:  c.DeletedAt,
+	}
+}
+
+func (d *commentDocument) comment() Comment {
+	return Comment{
+		ID:         d.ID,
+		TenantID:   d.TenantID,
+		TicketID:   d.TicketID,
+		AuthorID:   d.AuthorID,
+		Body:       d.Body,
+		IsInternal: d.IsInternal,
+		CreatedAt:  d.CreatedAt,
+		EditedAt:   d.EditedAt,
+		DeletedAt:  d.DeletedAt,
+	}
+}
+
+func newUserDocument(u *User) userDocument {
+	doc := userDocument{
+		ID:           u.ID,
+		TenantID:     u.TenantID,
+		Name:         u.Name,
+		Email:        u.Email,
+		PasswordHash: u.PasswordHash,
+		Role:         u.Role,
+		CreatedAt:    u.CreatedAt,
+		DeletedAt:    u.DeletedAt,
+	}
+	if u.CompanyID.Valid {
+		doc.CompanyID = &u.CompanyID.Int64
+	}
+	if u.PasswordResetToken.Valid {
+		doc.PasswordResetToken = &u.PasswordResetToken.String
+	}
+	if u.PasswordResetExpires.Valid {
+		doc.PasswordResetExpires = &u.PasswordResetExpires.Time
+	}
+	return doc
+}
+
+func (d *userDocument) user() *User {
+	u := &User{
+		ID:           d.ID,
+		TenantID:     d.TenantID,
+		Name:         d.Name,
+		Email:        d.Email,
+		PasswordHash: d.PasswordHash,
+		Role:         d.Role,
+		CreatedAt:    d.CreatedAt,
+		DeletedAt:    d.DeletedAt,
+	}
+	if d.CompanyID != nil {
+		u.CompanyID = sql.NullInt64{Int64: *d.CompanyID, Valid: true}
+	}
+	if d.PasswordResetToken != nil {
+		u.PasswordResetToken = sql.NullString{String: *d.PasswordResetToken, Valid: true}
+	}
+	if d.PasswordResetExpires != nil {
+		u.PasswordResetExpires = sql.NullTime{Time: *d.PasswordResetExpires, Valid: true}
+	}
+	return u
+}
+
+// isDocumentCursor reports whether cursor was issued by the document store.
+func isDocumentCursor(cursor string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return false
+	}
+	var c pageCursor
+	return json.Unmarshal(b, &c) == nil && c.Store == documentCursorStore
+}
@@ -0,0 +1,306 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/uptrace/bun"
+)
+
+// NewDualWriteRepositories returns repositories that write to primary and then copy each write to
+// the document store, following the dual write rollout in nosql_migration_plan.md. primary stays
+// the source of truth: it assigns IDs and its errors fail the request, while failed copies are only
+// logged. readPercent of reads, from 0 to 100, are served by the document store so read traffic can
+// be shifted over gradually. Lists narrowed by query filters are always read from primary. Rows
+// changed in primary's SQL database outside the repositories are copied by SyncChanges.
+func NewDualWriteRepositories(primary Repositories, store *DocumentStore, readPercent int) Repositories {
+	secondary := store.Repositories()
+	d := dualWrite{db: primary.SQL, store: store, readPercent: readPercent}
+	return Repositories{
+		Tickets:  &DualWriteTicketRepository{dualWrite: d, primary: primary.Tickets, secondary: secondary.Tickets},
+		Users:    &DualWriteUserRepository{dualWrite: d, primary: primary.Users, secondary: secondary.Users},
+		Comments: &DualWriteCommentRepository{dualWrite: d, primary: primary.Comments, secondary: secondary.Comments},
+		SQL:      primary.SQL,
+	}
+}
+
+type dualWrite struct {
+	db          *bun.DB
+	store       *DocumentStore
+	readPercent int
+}
+
+// readSecondary picks the store a read goes to. A page cursor must go back to the store that issued it.
+func (d dualWrite) readSecondary(cursor string) bool {
+	if cursor != "" {
+		return isDocumentCursor(cursor)
+	}
+	return rand.IntN(100) < d.readPercent
+}
+
+// copied logs a failed copy to the document store. Copies are best effort; migrate-data brings
+// the store back in line.
+func copied(entity string, id int64, err error) {
+	if err != nil {
+		fmt.Printf("Error copying %s %d to the document store: %v\n", entity, id, err)
+	}
+}
+
+// DualWriteTicketRepository writes tickets to both stores.
+type DualWriteTicketRepository struct {
+	dualWrite
+	primary, secondary TicketRepository
+}
+
+func (r *DualWriteTicketRepository) GetByID(ctx context.Context, id int64) (*Ticket, error) {
+	if r.readSecondary("") {
+		return r.secondary.GetByID(ctx, id)
+	}
+	return r.primary.GetByID(ctx, id)
+}
+
+// pick returns the repository a list reads from.
+func (r *DualWriteTicketRepository) pick(page PageRequest, filters []QueryFilter) TicketRepository {
+	if noFilters(filters) == nil && r.readSecondary(page.Cursor) {
+		return r.secondary
+	}
+	return r.primary
+}
+
+func (r *DualWriteTicketRepository) List(ctx context.Context, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error) {
+	return r.pick(page, filters).List(ctx, page, filters...)
+}
+
+func (r *DualWriteTicketRepository) ListOpen(ctx context.Context, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error) {
+	return r.pick(page, filters).ListOpen(ctx, page, filters...)
+}
+
+func (r *DualWriteTicketRepository) ListByAssigneeID(ctx context.Context, assigneeID int64, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error) {
+	return r.pick(page, filters).ListByAssigneeID(ctx, assigneeID, page, filters...)
+}
+
+func (r *DualWriteTicketRepository) ListByRequesterID(ctx context.Context, requesterID int64, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error) {
+	return r.pick(page, filters).ListByRequesterID(ctx, requesterID, page, filters...)
+}
+
+// mirror copies the ticket as primary now has it, defaults and all.
+func (r *DualWriteTicketRepository) mirror(ctx context.Context, id int64) {
+	ticket, err := r.primary.GetByID(ctx, id)
+	if err == nil {
+		err = r.store.PutTicket(ctx, ticket)
+	}
+	copied("ticket", id, err)
+}
+
+func (r *DualWriteTicketRepository) Create(ctx context.Context, ticket *Ticket) error {
+	if err := r.primary.Create(ctx, ticket); err != nil {
+		return err
+	}
+	r.mirror(ctx, ticket.ID)
+	return nil
+}
+
+func (r *DualWriteTicketRepository) Update(ctx context.Context, ticket *Ticket) error {
+	if err := r.primary.Update(ctx, ticket); err != nil {
+		return err
+	}
+	r.mirror(ctx, ticket.ID)
+	return nil
+}
+
+func (r *DualWriteTicketRepository) Delete(ctx context.Context, id int64) error {
+	if err := r.primary.Delete(ctx, id); err != nil {
+		return err
+	}
+	copied("ticket", id, r.secondary.Delete(ctx, id))
+	return nil
+}
+
+// syncChanges copies rows changed in the SQL database outside the repositories, trash and all.
+// Rows that are gone from it were purged and are removed from the document store too.
+func (r *DualWriteTicketRepository) syncChanges(ctx context.Context, changes RowChanges) {
+	for _, id := range changes.UserIDs {
+		copied("user", id, r.syncUser(ctx, id))
+	}
+	for _, id := range changes.TicketIDs {
+		copied("ticket", id, r.syncTicket(ctx, id))
+	}
+	for _, id := range changes.CommentIDs {
+		copied("comment", id, r.syncComment(ctx, id))
+	}
+}
+
+func (d dualWrite) syncUser(ctx context.Context, id int64) error {
+	user := new(User)
+	err := d.db.NewSelect().Model(user).WhereAllWithDeleted().Where("id = ?", id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return d.store.PurgeUsers(ctx, []int64{id})
+	}
+	if err != nil {
+		return err
+	}
+	return d.store.PutUser(ctx, user)
+}
+
+func (d dualWrite) syncTicket(ctx context.Context, id int64) error {
+	ticket := new(Ticket)
+	err := d.db.NewSelect().Model(ticket).WhereAllWithDeleted().Where("id = ?", id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return d.store.PurgeTickets(ctx, []int64{id})
+	}
+	if err != nil {
+		return err
+	}
+	if err := d.store.PutTicket(ctx, ticket); err != nil {
+		return err
+	}
+	var comments []Comment
+	err = d.db.NewSelect().
+		Model(&comments).
+		WhereAllWithDeleted().
+		Where("ticket_id = ?", id).
+		Order("id ASC").
+		Scan(ctx)
+	if err != nil {
+		return err
+	}
+	return d.store.PutTicketComments(ctx, id, comments)
+}
+
+func (d dualWrite) syncComment(ctx context.Context, id int64) error {
+	comment := new(Comment)
+	err := d.db.NewSelect().Model(comment).WhereAllWithDeleted().Where("id = ?", id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return d.store.PurgeComments(ctx, []int64{id})
+	}
+	if err != nil {
+		return err
+	}
+	return d.store.PutComment(ctx, comment)
+}
+
+// DualWriteUserRepository writes users to both stores.
+type DualWriteUserRepository struct {
+	dualWrite
+	primary, secondary UserRepository
+}
+
+func (r *DualWriteUserRepository) read() UserRepository {
+	if r.readSecondary("") {
+		return r.secondary
+	}
+	return r.primary
+}
+
+func (r *DualWriteUserRepository) GetByID(ctx context.Context, id int64) (*User, error) {
+	return r.read().GetByID(ctx, id)
+}
+
+func (r *DualWriteUserRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return r.read().GetByEmail(ctx, email)
+}
+
+func (r *DualWriteUserRepository) GetByResetToken(ctx context.Context, token string) (*User, error) {
+	return r.read().GetByResetToken(ctx, token)
+}
+
+func (r *DualWriteUserRepository) List(ctx context.Context, page PageRequest) (*Page[*User], error) {
+	if r.readSecondary(page.Cursor) {
+		return r.secondary.List(ctx, page)
+	}
+	return r.primary.List(ctx, page)
+}
+
+func (r *DualWriteUserRepository) ListByRole(ctx context.Context, role string, page PageRequest) (*Page[*User], error) {
+	if r.readSecondary(page.Cursor) {
+		return r.secondary.ListByRole(ctx, role, page)
+	}
+	return r.primary.ListByRole(ctx, role, page)
+}
+
+func (r *DualWriteUserRepository) mirror(ctx context.Context, id int64) {
+	user, err := r.primary.GetByID(ctx, id)
+	if err == nil {
+		err = r.store.PutUser(ctx, user)
+	}
+	copied("user", id, err)
+}
+
+func (r *DualWriteUserRepository) Create(ctx context.Context, user *User) error {
+	if err := r.primary.Create(ctx, user); err != nil {
+		return err
+	}
+	r.mirror(ctx, user.ID)
+	return nil
+}
+
+func (r *DualWriteUserRepository) Update(ctx context.Context, user *User) error {
+	if err := r.primary.Update(ctx, user); err != nil {
+		return err
+	}
+	r.mirror(ctx, user.ID)
+	return nil
+}
+
+func (r *DualWriteUserRepository) Delete(ctx context.Context, id int64) error {
+	if err := r.primary.Delete(ctx, id); err != nil {
+		return err
+	}
+	copied("user", id, r.secondary.Delete(ctx, id))
+	return nil
+}
+
+// DualWriteCommentRepository writes comments to both stores.
+type DualWriteCommentRepository struct {
+	dualWrite
+	primary, secondary CommentRepository
+}
+
+func (r *DualWriteCommentRepository) GetByID(ctx context.Context, id int64) (*Comment, error) {
+	if r.readSecondary("") {
+		return r.secondary.GetByID(ctx, id)
+	}
+	return r.primary.GetByID(ctx, id)
+}
+
+func (r *DualWriteCommentRepository) List(ctx context.Context, page PageRequest, filter CommentFilter) (*Page[Comment], error) {
+	if r.readSecondary(page.Cursor) {
+		return r.secondary.List(ctx, page, filter)
+	}
+	return r.primary.List(ctx, page, filter)
+}
+
+func (r *DualWriteCommentRepository) Create(ctx context.Context, comment *Comment) error {
+	if err := r.primary.Create(ctx, comment); err != nil {
+		return err
+	}
+	stored, err := r.primary.GetByID(ctx, comment.ID)
+	if err == nil {
+		err = r.store.PutComment(ctx, stored)
+	}
+	copied("comment", comment.ID, err)
+	return nil
+}
+
+func (r *DualWriteCommentRepository) Update(ctx context.Context, comment *Comment) error {
+	if err := r.primary.Update(ctx, comment); err != nil {
+		return err
+	}
+	stored, err := r.primary.GetByID(ctx, comment.ID)
+	if err == nil {
+		err = r.store.PutComment(ctx, stored)
+	}
+	copied("comment", comment.ID, err)
+	return nil
+}
+
+func (r *DualWriteCommentRepository) Delete(ctx context.Context, id int64) error {
+	if err := r.primary.Delete(ctx, id); err != nil {
+		return err
+	}
+	copied("comment", id, r.secondary.Delete(ctx, id))
+	return nil
+}
@@ -0,0 +1,417 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// Custom field types.
+const (
+	FieldTypeText        = "text"
+	FieldTypeNumber      = "number"
+	FieldTypeDate        = "date"
+	FieldTypeSelect      = "select"
+	FieldTypeMultiSelect = "multiselect"
+	FieldTypeUser        = "user"
+)
+
+// CustomFieldTypes lists the supported custom field types.
+var CustomFieldTypes = []string{FieldTypeText, FieldTypeNumber, FieldTypeDate, FieldTypeSelect, FieldTypeMultiSelect, FieldTypeUser}
+
+// CustomFieldPrefix prefixes custom field keys in ticket queries and sort parameters, as in cf.order_number.
+const CustomFieldPrefix = "cf."
+
+// ErrDuplicateFieldKey is returned when creating a custom field with a key that is already in use.
+var ErrDuplicateFieldKey = errors.New("a custom field with this key already exists")
+
+var fieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// CustomField represents an admin-defined ticket field. Its values are stored as rows in
+// ticket_field_values, so adding a field does not change the tickets table.
+type CustomField struct {
+	bun.BaseModel `bun:"table:custom_fields,alias:custom_field"`
+	ID            int64     `bun:"id,pk,autoincrement,type:integer"`
+	TenantID      int64     `bun:"tenant_id,notnull"`            // Organization the field belongs to
+	Key           string    `bun:"field_key,notnull" json:"Key"` // Name used in ticket JSON and queries, unique within the organization
+	Name          string    `bun:"name,notnull"`
+	Type          string    `bun:"type,notnull"`
+	Options       []string  `bun:"options,type:json" json:"Options,omitempty"`          // Allowed values of select and multiselect fields
+	RequiredFor   []string  `bun:"required_for,type:json" json:"RequiredFor,omitempty"` // Ticket types that must have a value
+	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+// TicketFieldValue stores one value of a custom field on a ticket. Only the column matching the
+// field type is set; multiselect fields store one row per selected option.
+type TicketFieldValue struct {
+	bun.BaseModel `bun:"table:ticket_field_values,alias:fv"`
+	ID            int64           `bun:"id,pk,autoincrement,type:integer"`
+	TicketID      int64           `bun:"ticket_id,notnull"`
+	FieldID       int64           `bun:"field_id,notnull"`
+	TextValue     sql.NullString  `bun:"text_value"`
+	NumberValue   sql.NullFloat64 `bun:"number_value"`
+	DateValue     sql.NullTime    `bun:"date_value"`
+	UserID        sql.NullInt64   `bun:"user_id"`
+	SortKey       string          `bun:"sort_key,notnull"` // Orders values of any type as plain strings
+}
+
+// FieldErrors maps custom field keys to the reason their value was rejected.
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key + ": " + e[key]
+	}
+	return "invalid custom fields: " + strings.Join(parts, "; ")
+}
+
+// FieldChanges holds validated custom field values keyed by field ID. An empty slice clears the field.
+type FieldChanges map[int64][]TicketFieldValue
+
+// IsRequiredFor reports whether tickets of the given type must have a value for the field.
+func (f *CustomField) IsRequiredFor(ticketType string) bool {
+	return slices.ContainsFunc(f.RequiredFor, func(t string) bool { return strings.EqualFold(t, ticketType) })
+}
+
+// Validate checks a field definition before it is saved.
+func (f *CustomField) Validate() error {
+	if !fieldKeyPattern.MatchString(f.Key) {
+		return fmt.Errorf("key must be lowercase letters, digits and underscores, starting with a letter")
+	}
+	if f.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !slices.Contains(CustomFieldTypes, f.Type) {
+		return fmt.Errorf("type must be one of %s", strings.Join(CustomFieldTypes, ", "))
+	}
+	if f.Type == FieldTypeSelect || f.Type == FieldTypeMultiSelect {
+		if len(f.Options) == 0 {
+			return fmt.Errorf("%s fields need at least one option", f.Type)
+		}
+	} else if len(f.Options) > 0 {
+		return fmt.Errorf("only select and multiselect fields take options")
+	}
+	for _, t := range f.RequiredFor {
+		if !slices.Contains(TicketTypes, t) {
+			return fmt.Errorf("unknown ticket type %q", t)
+		}
+	}
+	return nil
+}
+
+// GetCustomFieldByID retrieves a custom field definition from the database by its ID.
+func GetCustomFieldByID(db *bun.DB, ctx context.Context, fieldID int64) (*CustomField, error) {
+	field := new(CustomField)
+	err := db.NewSelect().Model(field).Where("id = ?", fieldID).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return field, nil
+}
+
+// ListCustomFields retrieves every custom field definition.
+func ListCustomFields(db *bun.DB, ctx context.Context) ([]CustomField, error) {
+	fields := []CustomField{}
+	err := db.NewSelect().Model(&fields).Order("name ASC").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// CreateCustomField inserts a new custom field definition into the database.
+func CreateCustomField(db *bun.DB, ctx context.Context, field *CustomField) error {
+	_, err := db.NewInsert().Model(field).Exec(ctx)
+	if err != nil {
+		if isDuplicateKey(err) {
+			return ErrDuplicateFieldKey
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateCustomField updates the name, options and required ticket types of a custom field.
+// The key and type are fixed once a field exists.
+func UpdateCustomField(db *bun.DB, ctx context.Context, field *CustomField) error {
+	_, err := db.NewUpdate().Model(field).Column("name", "options", "required_for").WherePK().Exec(ctx)
+	return err
+}
+
+// DeleteCustomField deletes a custom field along with its values on every ticket.
+func DeleteCustomField(db *bun.DB, ctx context.Context, fieldID int64) error {
+	_, err := db.NewDelete().Model(&CustomField{}).Where("id = ?", fieldID).Exec(ctx)
+	return err
+}
+
+// ValidateTicketFields checks a set of custom field values, keyed by field key, for a ticket and
+// converts them to rows. A null value clears the field. Fields required for the ticket's type must
+// end up with a value, either from values or already stored on the ticket.
+func ValidateTicketFields(db *bun.DB, ctx context.Context, ticket *Ticket, values map[string]json.RawMessage) (FieldChanges, error) {
+	defs, err := ListCustomFields(db, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[int64]bool)
+	if ticket.ID != 0 {
+		var ids []int64
+		err := db.NewSelect().Model((*TicketFieldValue)(nil)).Column("field_id").Distinct().
+			Where("ticket_id = ?", ticket.ID).Scan(ctx, &ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			present[id] = true
+		}
+	}
+
+	errs := FieldErrors{}
+	changes := FieldChanges{}
+	for key, raw := range values {
+		i := slices.IndexFunc(defs, func(f CustomField) bool { return f.Key == key })
+		if i < 0 {
+			errs[key] = "unknown field"
+			continue
+		}
+		field := &defs[i]
+
+		rows, err := field.parseValue(db, ctx, raw)
+		if err != nil {
+			errs[key] = err.Error()
+			continue
+		}
+		changes[field.ID] = rows
+		present[field.ID] = len(rows) > 0
+	}
+
+	for i := range defs {
+		if _, rejected := errs[defs[i].Key]; !rejected && defs[i].IsRequiredFor(ticket.Type) && !present[defs[i].ID] {
+			errs[defs[i].Key] = "required for " + ticket.Type + " tickets"
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return changes, nil
+}
+
+// SaveTicketFields replaces the stored values of every field in changes.
+func SaveTicketFields(db *bun.DB, ctx context.Context, ticketID int64, changes FieldChanges) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	fieldIDs := make([]int64, 0, len(changes))
+	var rows []TicketFieldValue
+	for fieldID, values := range changes {
+		fieldIDs = append(fieldIDs, fieldID)
+		for _, v := range values {
+			v.TicketID = ticketID
+			v.FieldID = fieldID
+			rows = append(rows, v)
+		}
+	}
+
+	_, err := db.NewDelete().
+		Model((*TicketFieldValue)(nil)).
+		Where("ticket_id = ?", ticketID).
+		Where("field_id IN (?)", bun.In(fieldIDs)).
+		Exec(ctx)
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+
+	_, err = db.NewInsert().Model(&rows).Exec(ctx)
+	return err
+}
+
+// LoadTicketFields fills in the Fields of each ticket from the stored custom field values.
+func LoadTicketFields(db *bun.DB, ctx context.Context, tickets ...*Ticket) error {
+	if len(tickets) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(tickets))
+	for i, t := range tickets {
+		ids[i] = t.ID
+	}
+
+	var rows []TicketFieldValue
+	err := db.NewSelect().Model(&rows).Where("ticket_id IN (?)", bun.In(ids)).Order("id ASC").Scan(ctx)
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+
+	defs, err := ListCustomFields(db, ctx)
+	if err != nil {
+		return err
+	}
+	byID := make(map[int64]*CustomField, len(defs))
+	for i := range defs {
+		byID[defs[i].ID] = &defs[i]
+	}
+
+	byTicket := make(map[int64]*Ticket, len(tickets))
+	for _, t := range tickets {
+		byTicket[t.ID] = t
+	}
+
+	for _, row := range rows {
+		field, ok := byID[row.FieldID]
+		if !ok {
+			continue
+		}
+		t := byTicket[row.TicketID]
+		if t.Fields == nil {
+			t.Fields = make(map[string]any)
+		}
+
+		switch field.Type {
+		case FieldTypeNumber:
+			t.Fields[field.Key] = row.NumberValue.Float64
+		case FieldTypeDate:
+			t.Fields[field.Key] = row.DateValue.Time
+		case FieldTypeUser:
+			t.Fields[field.Key] = row.UserID.Int64
+		case FieldTypeMultiSelect:
+			selected, _ := t.Fields[field.Key].([]string)
+			t.Fields[field.Key] = append(selected, row.TextValue.String)
+		default:
+			t.Fields[field.Key] = row.TextValue.String
+		}
+	}
+	return nil
+}
+
+// parseValue converts a JSON value into rows for the field, checking it against the field type.
+func (f *CustomField) parseValue(db *bun.DB, ctx context.Context, raw json.RawMessage) ([]TicketFieldValue, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return []TicketFieldValue{}, nil
+	}
+
+	switch f.Type {
+	case FieldTypeText:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Errorf("expected a string")
+		}
+		if s == "" {
+			return []TicketFieldValue{}, nil
+		}
+		return []TicketFieldValue{textValue(s)}, nil
+
+	case FieldTypeNumber:
+		var n float64
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return nil, fmt.Errorf("expected a number")
+		}
+		return []TicketFieldValue{{NumberValue: sql.NullFloat64{Float64: n, Valid: true}, SortKey: numberSortKey(n)}}, nil
+
+	case FieldTypeDate:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Errorf("expected a date string")
+		}
+		d, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			d, err = time.Parse("2006-01-02", s)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("expected a date like 2024-05-01 or 2024-05-01T09:00:00Z")
+		}
+		d = d.UTC()
+		return []TicketFieldValue{{DateValue: sql.NullTime{Time: d, Valid: true}, SortKey: d.Format("2006-01-02T15:04:05.000000000")}}, nil
+
+	case FieldTypeSelect:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Errorf("expected one of the field options")
+		}
+		if !slices.Contains(f.Options, s) {
+			return nil, fmt.Errorf("%q is not an option", s)
+		}
+		return []TicketFieldValue{textValue(s)}, nil
+
+	case FieldTypeMultiSelect:
+		var selected []string
+		if err := json.Unmarshal(raw, &selected); err != nil {
+			return nil, fmt.Errorf("expected a list of field options")
+		}
+		rows := []TicketFieldValue{}
+		seen := make(map[string]bool)
+		for _, s := range selected {
+			if !slices.Contains(f.Options, s) {
+				return nil, fmt.Errorf("%q is not an option", s)
+			}
+			if !seen[s] {
+				seen[s] = true
+				rows = append(rows, textValue(s))
+			}
+		}
+		return rows, nil
+
+	case FieldTypeUser:
+		var id int64
+		if err := json.Unmarshal(raw, &id); err != nil {
+			return nil, fmt.Errorf("expected a user ID")
+		}
+		if _, err := GetUserByID(db, ctx, id); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("user %d not found", id)
+			}
+			return nil, err
+		}
+		return []TicketFieldValue{{UserID: sql.NullInt64{Int64: id, Valid: true}, SortKey: fmt.Sprintf("%020d", id)}}, nil
+	}
+	return nil, fmt.Errorf("unsupported field type %q", f.Type)
+}
+
+// sortKeyLength is the number of characters the sort_key column holds.
+const sortKeyLength = 191
+
+func textValue(s string) TicketFieldValue {
+	key := strings.ToLower(s)
+	if runes := []rune(key); len(runes) > sortKeyLength {
+		key = string(runes[:sortKeyLength])
+	}
+	return TicketFieldValue{TextValue: sql.NullString{String: s, Valid: true}, SortKey: key}
+}
+
+// numberSortKey encodes a number as a fixed-width string that sorts in numeric order.
+func numberSortKey(n float64) string {
+	bits := math.Float64bits(n)
+	if n < 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return fmt.Sprintf("%016x", bits)
+}
+
+// customFieldSortExpr orders tickets by the value of the custom field with the given key.
+// Tickets without a value sort first, multiselect fields sort by their lowest option.
+func customFieldSortExpr(key string) schema.QueryWithArgs {
+	return bun.SafeQuery("(SELECT COALESCE(MIN(fv.sort_key), '') FROM ticket_field_values AS fv "+
+		"JOIN custom_fields AS cf ON cf.id = fv.field_id "+
+		"WHERE fv.ticket_id = ?TableAlias.id AND cf.field_key = ?)", key)
+}
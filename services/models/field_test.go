@@ -0,0 +1,44 @@
+package models_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"goat/services/models"
+)
+
+func TestTextFieldSortKey(t *testing.T) {
+	db := newTestDB(t)
+	ctx := tenantContext()
+	customer := newTestUser(t, db, ctx, "Customer")
+	ticket := newTestTicket(t, db, ctx, customer)
+	field := &models.CustomField{Key: "notes", Name: "Notes", Type: models.FieldTypeText}
+	if err := models.CreateCustomField(db, ctx, field); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two-byte characters put the 191-byte mark in the middle of one.
+	text := "É" + strings.Repeat("ü", 300)
+	raw, _ := json.Marshal(text)
+	changes, err := models.ValidateTicketFields(db, ctx, ticket, map[string]json.RawMessage{"notes": raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := models.SaveTicketFields(db, ctx, ticket.ID, changes); err != nil {
+		t.Fatal(err)
+	}
+
+	var value models.TicketFieldValue
+	if err := db.NewSelect().Model(&value).Where("ticket_id = ? AND field_id = ?", ticket.ID, field.ID).Scan(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if value.TextValue.String != text {
+		t.Errorf("text value was changed")
+	}
+	want := "é" + strings.Repeat("ü", 190)
+	if !utf8.ValidString(value.SortKey) || value.SortKey != want {
+		t.Errorf("sort key = %q (%d characters), want the first 191 characters lowercased", value.SortKey, utf8.RuneCountInString(value.SortKey))
+	}
+}
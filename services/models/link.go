@@ -0,0 +1,288 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Stored link types. Each reads from the source ticket to the target ticket,
+// e.g. "42 duplicates 17" or "10 parent_of 11".
+const (
+	LinkDuplicates = "duplicates"
+	LinkRelated    = "related"
+	LinkBlocks     = "blocks"
+	LinkParentOf   = "parent_of"
+)
+
+// linkInverses maps each link type to its name as seen from the target ticket.
+var linkInverses = map[string]string{
+	LinkDuplicates: "duplicated_by",
+	LinkRelated:    LinkRelated,
+	LinkBlocks:     "blocked_by",
+	LinkParentOf:   "child_of",
+}
+
+var (
+	// ErrInvalidLinkType is returned for a link type that is neither a stored type nor an inverse.
+	ErrInvalidLinkType = errors.New("invalid link type")
+	// ErrSelfLink is returned when linking a ticket to itself.
+	ErrSelfLink = errors.New("a ticket cannot be linked to itself")
+	// ErrDuplicateLink is returned when the same link already exists.
+	ErrDuplicateLink = errors.New("tickets are already linked")
+	// ErrLinkCycle is returned when a blocks or parent_of link would close a loop.
+	ErrLinkCycle = errors.New("link would create a cycle")
+	// ErrParentExists is returned when giving a second parent to a child ticket.
+	ErrParentExists = errors.New("ticket already has a parent")
+)
+
+// TicketLink represents a typed relationship between two tickets.
+type TicketLink struct {
+	bun.BaseModel `bun:"table:ticket_links,alias:ticket_link"`
+	ID            int64     `bun:"id,pk,autoincrement,type:integer"`
+	SourceID      int64     `bun:"source_id,notnull"`
+	TargetID      int64     `bun:"target_id,notnull"`
+	Type          string    `bun:"type,notnull"`
+	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+// LinkedTicket describes a link from the point of view of one of its tickets.
+type LinkedTicket struct {
+	LinkID   int64  `json:"LinkID"`
+	Type     string `json:"Type"` // e.g. "blocks" or "blocked_by"
+	TicketID int64  `json:"TicketID"`
+	Title    string `json:"Title"`
+	Status   string `json:"Status"`
+}
+
+// ChildRollup summarizes the status of a parent ticket's children.
+type ChildRollup struct {
+	Total    int            `json:"Total"`
+	ByStatus map[string]int `json:"ByStatus"`
+	Resolved bool           `json:"Resolved"` // Every child is closed
+}
+
+// NewTicketLink builds the stored link for "ticketID <linkType> otherID", where linkType may be a
+// stored type or its inverse such as "blocked_by". Related links are stored with the lower ID first.
+func NewTicketLink(ticketID int64, linkType string, otherID int64) (*TicketLink, error) {
+	if ticketID == otherID {
+		return nil, ErrSelfLink
+	}
+	for stored, inverse := range linkInverses {
+		if linkType == stored {
+			if stored == LinkRelated && otherID < ticketID {
+				ticketID, otherID = otherID, ticketID
+			}
+			return &TicketLink{SourceID: ticketID, TargetID: otherID, Type: stored}, nil
+		}
+		if linkType == inverse {
+			return &TicketLink{SourceID: otherID, TargetID: ticketID, Type: stored}, nil
+		}
+	}
+	return nil, ErrInvalidLinkType
+}
+
+// CreateTicketLink inserts a link after checking that blocks and parent_of links stay acyclic
+// and that a ticket has at most one parent. The checks and the insert run in one transaction that
+// holds both tickets, so concurrent links cannot slip past them.
+func CreateTicketLink(db bun.IDB, ctx context.Context, link *TicketLink) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := lockForWrite(ctx, tx, "ticket_links"); err != nil {
+			return err
+		}
+		var locked []int64
+		err := tx.NewSelect().
+			Model((*Ticket)(nil)).
+			Column("ticket.id").
+			Where("ticket.id IN (?)", bun.In([]int64{link.SourceID, link.TargetID})).
+			Order("ticket.id ASC").
+			Apply(forUpdate).
+			Scan(ctx, &locked)
+		if err != nil {
+			return err
+		}
+
+		if link.Type == LinkParentOf {
+			hasParent, err := tx.NewSelect().Model((*TicketLink)(nil)).
+				Where("type = ?", LinkParentOf).
+				Where("target_id = ?", link.TargetID).
+				Exists(ctx)
+			if err != nil {
+				return err
+			}
+			if hasParent {
+				return ErrParentExists
+			}
+		}
+
+		if link.Type == LinkBlocks || link.Type == LinkParentOf {
+			// The new edge source -> target closes a loop if target already reaches source.
+			reaches, err := linkReaches(tx, ctx, link.Type, link.TargetID, link.SourceID)
+			if err != nil {
+				return err
+			}
+			if reaches {
+				return ErrLinkCycle
+			}
+		}
+
+		_, err = tx.NewInsert().Model(link).Exec(ctx)
+		if err != nil {
+			if isDuplicateKey(err) {
+				// Only one parent_of link may point at a ticket, even an identical one.
+				if link.Type == LinkParentOf {
+					return ErrParentExists
+				}
+				return ErrDuplicateLink
+			}
+			return fmt.Errorf("failed to link tickets: %w", constraintError(err, "link"))
+		}
+		return nil
+	})
+}
+
+// linkReaches reports whether "to" can be reached from "from" by following links of one type.
+func linkReaches(db bun.IDB, ctx context.Context, linkType string, from, to int64) (bool, error) {
+	visited := map[int64]bool{from: true}
+	frontier := []int64{from}
+	for len(frontier) > 0 {
+		var next []int64
+		err := db.NewSelect().Model((*TicketLink)(nil)).
+			Column("target_id").
+			Where("type = ?", linkType).
+			Where("source_id IN (?)", bun.In(frontier)).
+			Scan(ctx, &next)
+		if err != nil {
+			return false, err
+		}
+
+		frontier = frontier[:0]
+		for _, id := range next {
+			if id == to {
+				return true, nil
+			}
+			if !visited[id] {
+				visited[id] = true
+				frontier = append(frontier, id)
+			}
+		}
+	}
+	return false, nil
+}
+
+// DeleteTicketLink deletes a link of the given ticket and returns it.
+// It returns sql.ErrNoRows when the ticket has no such link.
+func DeleteTicketLink(db *bun.DB, ctx context.Context, ticketID, linkID int64) (*TicketLink, error) {
+	tickets, err := tenantTickets(db, ctx)
+	if err != nil {
+		return nil, err
+	}
+	link := new(TicketLink)
+	err = db.NewSelect().
+		Model(link).
+		Where("id = ?", linkID).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("source_id = ?", ticketID).WhereOr("target_id = ?", ticketID)
+		}).
+		Where("? IN (?)", ticketID, tickets).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.NewDelete().Model(link).WherePK().Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// String describes the link for people, e.g. "#42 duplicates #17".
+func (l *TicketLink) String() string {
+	return fmt.Sprintf("#%d %s #%d", l.SourceID, l.Type, l.TargetID)
+}
+
+// ListTicketLinks retrieves the links of a ticket, named from its point of view.
+func ListTicketLinks(db *bun.DB, ctx context.Context, ticketID int64) ([]LinkedTicket, error) {
+	var links []TicketLink
+	err := db.NewSelect().
+		Model(&links).
+		Where("source_id = ?", ticketID).
+		WhereOr("target_id = ?", ticketID).
+		Order("id ASC").
+		Scan(ctx)
+	if err != nil || len(links) == 0 {
+		return nil, err
+	}
+
+	otherIDs := make([]int64, len(links))
+	for i, link := range links {
+		otherIDs[i] = link.TargetID
+		if link.TargetID == ticketID {
+			otherIDs[i] = link.SourceID
+		}
+	}
+
+	var others []Ticket
+	err = db.NewSelect().Model(&others).Column("id", "title", "status").Where("id IN (?)", bun.In(otherIDs)).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]*Ticket, len(others))
+	for i := range others {
+		byID[others[i].ID] = &others[i]
+	}
+
+	linked := make([]LinkedTicket, 0, len(links))
+	for i, link := range links {
+		other, ok := byID[otherIDs[i]]
+		if !ok {
+			continue
+		}
+		linkType := link.Type
+		if link.TargetID == ticketID {
+			linkType = linkInverses[link.Type]
+		}
+		linked = append(linked, LinkedTicket{
+			LinkID:   link.ID,
+			Type:     linkType,
+			TicketID: other.ID,
+			Title:    other.Title,
+			Status:   other.Status,
+		})
+	}
+	return linked, nil
+}
+
+// GetChildRollup counts the children of a ticket by status. It returns nil for tickets without children.
+func GetChildRollup(db *bun.DB, ctx context.Context, ticketID int64) (*ChildRollup, error) {
+	var counts []struct {
+		Status string `bun:"status"`
+		Count  int    `bun:"count"`
+	}
+	err := db.NewSelect().
+		Model((*Ticket)(nil)).
+		Column("ticket.status").
+		ColumnExpr("COUNT(*) AS count").
+		Join("JOIN ticket_links AS tl ON tl.target_id = ticket.id").
+		Where("tl.type = ?", LinkParentOf).
+		Where("tl.source_id = ?", ticketID).
+		Group("ticket.status").
+		Scan(ctx, &counts)
+	if err != nil || len(counts) == 0 {
+		return nil, err
+	}
+
+	rollup := &ChildRollup{ByStatus: make(map[string]int), Resolved: true}
+	for _, c := range counts {
+		rollup.Total += c.Count
+		rollup.ByStatus[c.Status] = c.Count
+		if c.Status != "Closed" {
+			rollup.Resolved = false
+		}
+	}
+	return rollup, nil
+}
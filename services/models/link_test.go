@@ -0,0 +1,40 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+
+	"goat/services/models"
+)
+
+func TestCreateTicketLinkParents(t *testing.T) {
+	db := newTestDB(t)
+	ctx := tenantContext()
+	customer := newTestUser(t, db, ctx, "Customer")
+	parent := newTestTicket(t, db, ctx, customer)
+	other := newTestTicket(t, db, ctx, customer)
+	child := newTestTicket(t, db, ctx, customer)
+
+	link := &models.TicketLink{SourceID: parent.ID, TargetID: child.ID, Type: models.LinkParentOf}
+	if err := models.CreateTicketLink(db, ctx, link); err != nil {
+		t.Fatal(err)
+	}
+	second := &models.TicketLink{SourceID: other.ID, TargetID: child.ID, Type: models.LinkParentOf}
+	if err := models.CreateTicketLink(db, ctx, second); !errors.Is(err, models.ErrParentExists) {
+		t.Errorf("second parent: got %v, want ErrParentExists", err)
+	}
+	cycle := &models.TicketLink{SourceID: child.ID, TargetID: parent.ID, Type: models.LinkParentOf}
+	if err := models.CreateTicketLink(db, ctx, cycle); !errors.Is(err, models.ErrLinkCycle) {
+		t.Errorf("child parenting its parent: got %v, want ErrLinkCycle", err)
+	}
+
+	// The database holds the line for writes that skip the checks.
+	_, err := db.NewInsert().Model(&models.TicketLink{SourceID: other.ID, TargetID: child.ID, Type: models.LinkParentOf}).Exec(ctx)
+	if err == nil {
+		t.Error("inserting a second parent link directly succeeded")
+	}
+	related := &models.TicketLink{SourceID: other.ID, TargetID: child.ID, Type: models.LinkRelated}
+	if err := models.CreateTicketLink(db, ctx, related); err != nil {
+		t.Errorf("related link next to a parent: %v", err)
+	}
+}
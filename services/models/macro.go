@@ -0,0 +1,214 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Macro is a canned reply an agent applies to a ticket in one step. Its body may contain
+// placeholders such as {{requester.name}}, and its actions change the ticket at the same time.
+// Macros are personal unless shared with a team or, by an admin, with the whole organization.
+type Macro struct {
+	bun.BaseModel `bun:"table:macros,alias:macro"`
+	ID            int64         `bun:"id,pk,autoincrement,type:integer"`
+	TenantID      int64         `bun:"tenant_id,notnull"` // Organization the macro belongs to
+	Name          string        `bun:"name,notnull"`
+	Body          string        `bun:"body,notnull"` // Reply template, empty for macros that only change the ticket
+	IsInternal    bool          `bun:"is_internal,notnull"`
+	Actions       MacroActions  `bun:"actions,type:json"`
+	OwnerID       int64         `bun:"owner_id,notnull"`
+	TeamID        sql.NullInt64 `bun:"team_id"`        // Set when the macro is shared with a team
+	Shared        bool          `bun:"shared,notnull"` // Shared with every agent of the organization
+	UsageCount    int           `bun:"usage_count,notnull"`
+	LastUsedAt    *time.Time    `bun:"last_used_at,nullzero" json:"LastUsedAt,omitempty"`
+	CreatedAt     time.Time     `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+// MacroActions are the ticket changes a macro makes. Empty fields leave the ticket as it is.
+type MacroActions struct {
+	Status     string   `json:"status,omitempty"`
+	Priority   string   `json:"priority,omitempty"`
+	Assignee   string   `json:"assignee,omitempty"` // "me" for the agent applying the macro, "none" to unassign, or a user ID
+	AddTags    []string `json:"add_tags,omitempty"`
+	RemoveTags []string `json:"remove_tags,omitempty"`
+}
+
+// MacroContext holds what a macro's placeholders are filled in from. Assignee is the ticket's
+// assignee once the macro's actions have been applied, nil if it is unassigned.
+type MacroContext struct {
+	Ticket    *Ticket
+	Requester *User
+	Agent     *User
+	Assignee  *User
+}
+
+// ErrUnknownPlaceholder is returned for a macro body using a placeholder that does not exist.
+var ErrUnknownPlaceholder = errors.New("unknown placeholder")
+
+// placeholderPattern matches {{name}} with optional spaces inside the braces.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([\w.]+)\s*\}\}`)
+
+// macroPlaceholders maps each placeholder a macro body may use to its value.
+var macroPlaceholders = map[string]func(MacroContext) string{
+	"ticket.id":       func(c MacroContext) string { return strconv.FormatInt(c.Ticket.ID, 10) },
+	"ticket.title":    func(c MacroContext) string { return c.Ticket.Title },
+	"ticket.status":   func(c MacroContext) string { return c.Ticket.Status },
+	"ticket.priority": func(c MacroContext) string { return c.Ticket.Priority },
+	"requester.name":  func(c MacroContext) string { return userName(c.Requester) },
+	"requester.email": func(c MacroContext) string { return userEmail(c.Requester) },
+	"agent.name":      func(c MacroContext) string { return userName(c.Agent) },
+	"assignee.name":   func(c MacroContext) string { return userName(c.Assignee) },
+}
+
+func userName(u *User) string {
+	if u == nil {
+		return ""
+	}
+	return u.Name
+}
+
+func userEmail(u *User) string {
+	if u == nil {
+		return ""
+	}
+	return u.Email
+}
+
+// CheckMacroBody returns ErrUnknownPlaceholder, naming the placeholder, if the body uses one that
+// does not exist.
+func CheckMacroBody(body string) error {
+	for _, m := range placeholderPattern.FindAllStringSubmatch(body, -1) {
+		if _, ok := macroPlaceholders[m[1]]; !ok {
+			return fmt.Errorf("%w %q", ErrUnknownPlaceholder, m[0])
+		}
+	}
+	return nil
+}
+
+// RenderMacro fills in the placeholders of a macro body. Unknown placeholders are left as they are.
+func RenderMacro(body string, c MacroContext) string {
+	return placeholderPattern.ReplaceAllStringFunc(body, func(s string) string {
+		value, ok := macroPlaceholders[placeholderPattern.FindStringSubmatch(s)[1]]
+		if !ok {
+			return s
+		}
+		return value(c)
+	})
+}
+
+// CheckMacroActions reports an error if an action sets an unknown priority or an invalid assignee.
+func CheckMacroActions(actions MacroActions) error {
+	if actions.Status != "" && !slices.Contains(TicketStatuses, actions.Status) {
+		return fmt.Errorf("unknown status %q", actions.Status)
+	}
+	if actions.Priority != "" && !slices.Contains(TicketPriorities, actions.Priority) {
+		return fmt.Errorf("unknown priority %q", actions.Priority)
+	}
+	switch actions.Assignee {
+	case "", "me", "none":
+	default:
+		if _, err := strconv.ParseInt(actions.Assignee, 10, 64); err != nil {
+			return fmt.Errorf("assignee must be \"me\", \"none\" or a user ID, not %q", actions.Assignee)
+		}
+	}
+	return nil
+}
+
+// GetMacroByID retrieves a macro from the database by its ID.
+func GetMacroByID(db bun.IDB, ctx context.Context, macroID int64) (*Macro, error) {
+	macro := new(Macro)
+	err := db.NewSelect().Model(macro).Where("id = ?", macroID).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return macro, nil
+}
+
+// ListMacrosForUser retrieves the macros a user owns, the ones shared with one of their teams and
+// the ones shared with everyone, by name.
+func ListMacrosForUser(db bun.IDB, ctx context.Context, userID int64, teamIDs []int64) ([]Macro, error) {
+	macros := []Macro{}
+	err := db.NewSelect().
+		Model(&macros).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			q = q.Where("macro.owner_id = ?", userID).WhereOr("macro.shared = ?", true)
+			if len(teamIDs) > 0 {
+				q = q.WhereOr("macro.team_id IN (?)", bun.In(teamIDs))
+			}
+			return q
+		}).
+		Order("name ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return macros, nil
+}
+
+// CreateMacro inserts a new macro into the database.
+func CreateMacro(db bun.IDB, ctx context.Context, macro *Macro) error {
+	_, err := db.NewInsert().Model(macro).Exec(ctx)
+	return constraintError(err, "macro")
+}
+
+// UpdateMacro saves a macro's name, body, actions and sharing.
+func UpdateMacro(db bun.IDB, ctx context.Context, macro *Macro) error {
+	_, err := db.NewUpdate().
+		Model(macro).
+		Column("name", "body", "is_internal", "actions", "team_id", "shared").
+		WherePK().
+		Exec(ctx)
+	return constraintError(err, "macro")
+}
+
+// DeleteMacro deletes a macro from the database by its ID.
+func DeleteMacro(db bun.IDB, ctx context.Context, macroID int64) error {
+	_, err := db.NewDelete().Model(&Macro{}).Where("id = ?", macroID).Exec(ctx)
+	return err
+}
+
+// ApplyMacro saves a ticket changed by a macro, with its tag changes and, unless comment is nil,
+// the reply it rendered, and counts the use. Everything happens in one transaction.
+// ticket.Version must hold the version the caller read; the ticket is only saved if the stored
+// version still matches, otherwise ErrVersionConflict is returned and nothing is saved.
+func ApplyMacro(db *bun.DB, ctx context.Context, macro *Macro, ticket *Ticket, comment *Comment) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := updateTicketColumns(tx, ctx, ticket, "status", "priority", "assignee_id"); err != nil {
+			return err
+		}
+
+		if err := AddTicketTags(tx, ctx, []int64{ticket.ID}, macro.Actions.AddTags); err != nil {
+			return err
+		}
+		if err := RemoveTicketTags(tx, ctx, []int64{ticket.ID}, macro.Actions.RemoveTags); err != nil {
+			return err
+		}
+		if comment != nil {
+			if _, err := tx.NewInsert().Model(comment).Exec(ctx); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		_, err := tx.NewUpdate().
+			Model(macro).
+			Set("usage_count = usage_count + 1").
+			Set("last_used_at = ?", now).
+			WherePK().
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		macro.UsageCount++
+		macro.LastUsedAt = &now
+		return nil
+	})
+}
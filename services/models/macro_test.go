@@ -0,0 +1,26 @@
+package models_test
+
+import (
+	"testing"
+
+	"goat/services/models"
+)
+
+func TestCheckMacroActions(t *testing.T) {
+	tests := []struct {
+		actions models.MacroActions
+		valid   bool
+	}{
+		{models.MacroActions{}, true},
+		{models.MacroActions{Status: "Pending", Priority: "High", Assignee: "me"}, true},
+		{models.MacroActions{Assignee: "42"}, true},
+		{models.MacroActions{Status: "Solved"}, false},
+		{models.MacroActions{Priority: "Huge"}, false},
+		{models.MacroActions{Assignee: "bob"}, false},
+	}
+	for _, tt := range tests {
+		if err := models.CheckMacroActions(tt.actions); (err == nil) != tt.valid {
+			t.Errorf("CheckMacroActions(%+v) = %v, want valid %v", tt.actions, err, tt.valid)
+		}
+	}
+}
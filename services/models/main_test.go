@@ -0,0 +1,61 @@
+package models_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/uptrace/bun"
+
+	"goat/services/config"
+	"goat/services/migrate"
+	"goat/services/models"
+)
+
+// newTestDB returns a migrated SQLite database private to the test, in a file so that it can be
+// shared by several connections.
+func newTestDB(t *testing.T) *bun.DB {
+	t.Helper()
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "goat.db"))
+	db := config.ConnectDB()
+	t.Cleanup(func() { db.Close() })
+	if _, err := migrate.Up(context.Background(), db, 0); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// tenantContext returns a context limited to the default organization.
+func tenantContext() context.Context {
+	return models.WithTenant(context.Background(), 1)
+}
+
+// newTestUser creates a user of the given role in the context's organization.
+func newTestUser(t *testing.T, db *bun.DB, ctx context.Context, role string) *models.User {
+	t.Helper()
+	user := &models.User{Name: role, Role: role, PasswordHash: "x"}
+	user.Email = fmt.Sprintf("%s-%d@example.com", role, testUsers.Add(1))
+	if err := models.CreateUser(db, ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+var testUsers atomic.Int64
+
+// newTestTicket creates an open ticket raised by requester, with one comment by it.
+func newTestTicket(t *testing.T, db *bun.DB, ctx context.Context, requester *models.User) *models.Ticket {
+	t.Helper()
+	ticket := &models.Ticket{Title: "Printer on fire", Status: "Open", Priority: "Medium", RequesterID: requester.ID}
+	if err := models.CreateTicket(db, ctx, ticket); err != nil {
+		t.Fatalf("create ticket: %v", err)
+	}
+	comment := &models.Comment{TicketID: ticket.ID, AuthorID: requester.ID, Body: "It is still burning."}
+	if err := models.CreateComment(db, ctx, comment); err != nil {
+		t.Fatalf("create comment: %v", err)
+	}
+	return ticket
+}
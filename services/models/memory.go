@@ -0,0 +1,450 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrFilterUnsupported is returned by the in-memory and document repositories when given query
+// filters, which only run against the SQL database.
+var ErrFilterUnsupported = errors.New("query filters are only supported by the SQL database")
+
+// NewMemoryRepositories returns empty repositories that keep everything in memory, for tests.
+// They follow the database's defaults and soft deletion, but do not load related data such as a
+// ticket's comments, tags or custom fields.
+func NewMemoryRepositories() Repositories {
+	return Repositories{
+		Tickets:  &MemoryTicketRepository{rows: newMemoryTable[Ticket]()},
+		Users:    &MemoryUserRepository{rows: newMemoryTable[User]()},
+		Comments: &MemoryCommentRepository{rows: newMemoryTable[Comment]()},
+	}
+}
+
+// memoryTable holds the rows of one model by ID. Rows are copied in and out so that callers
+// cannot change stored rows without going through the repository. Like the bun hooks, every
+// access is limited to the context's organization.
+type memoryTable[T any] struct {
+	mu     sync.Mutex
+	rows   map[int64]*T
+	nextID int64
+}
+
+func newMemoryTable[T any]() *memoryTable[T] {
+	return &memoryTable[T]{rows: make(map[int64]*T)}
+}
+
+func (t *memoryTable[T]) insert(ctx context.Context, row *T) error {
+	if err := setTenant(ctx, reflect.ValueOf(row).Elem().FieldByName("TenantID").Addr().Interface().(*int64)); err != nil {
+		return err
+	}
+	t.nextID++
+	reflect.ValueOf(row).Elem().FieldByName("ID").SetInt(t.nextID)
+	stored := *row
+	t.rows[t.nextID] = &stored
+	return nil
+}
+
+// stored returns the stored row with the ID if it has not been deleted and the context may see it.
+func (t *memoryTable[T]) stored(ctx context.Context, id int64) (*T, error) {
+	row, ok := t.rows[id]
+	if !ok || isDeleted(row) {
+		return nil, sql.ErrNoRows
+	}
+	if visible, err := sameTenant(ctx, row); !visible {
+		return nil, cmp.Or(err, sql.ErrNoRows)
+	}
+	return row, nil
+}
+
+// get returns a copy of a row that has not been deleted.
+func (t *memoryTable[T]) get(ctx context.Context, id int64) (*T, error) {
+	row, err := t.stored(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	found := *row
+	return &found, nil
+}
+
+// find returns copies of the rows that have not been deleted and match the predicate.
+func (t *memoryTable[T]) find(ctx context.Context, match func(*T) bool) ([]T, error) {
+	var found []T
+	for _, row := range t.rows {
+		visible, err := sameTenant(ctx, row)
+		if err != nil {
+			return nil, err
+		}
+		if visible && !isDeleted(row) && match(row) {
+			found = append(found, *row)
+		}
+	}
+	return found, nil
+}
+
+func (t *memoryTable[T]) delete(ctx context.Context, id int64) error {
+	row, err := t.stored(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil // Like a DELETE matching no rows
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	reflect.ValueOf(row).Elem().FieldByName("DeletedAt").Set(reflect.ValueOf(&now))
+	return nil
+}
+
+func isDeleted[T any](row *T) bool {
+	return !reflect.ValueOf(row).Elem().FieldByName("DeletedAt").IsNil()
+}
+
+// memoryPage sorts rows and cuts one page, following paginate. Its cursors hold an offset, so
+// rows inserted between pages may shift them; that is fine for tests.
+func memoryPage[T any](rows []T, page PageRequest, sortable ...string) (*Page[T], error) {
+	keys := slices.Clone(page.Sort)
+	hasID := false
+	for _, key := range keys {
+		if !canSort(sortable, key.Column) {
+			return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidSort, key.Column)
+		}
+		if _, computed := computedSortExpr(key.Column); computed {
+			return nil, fmt.Errorf("%w: cannot sort by %q in memory", ErrInvalidSort, key.Column)
+		}
+		hasID = hasID || key.Column == "id"
+	}
+	if !hasID {
+		keys = append(keys, SortKey{Column: "id"})
+	}
+
+	limit := page.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	result := &Page[T]{Items: []T{}}
+	if page.WithTotal {
+		total := len(rows)
+		result.Total = &total
+	}
+
+	slices.SortStableFunc(rows, func(a, b T) int {
+		for _, key := range keys {
+			c := compareColumn(reflect.Indirect(reflect.ValueOf(a)), reflect.Indirect(reflect.ValueOf(b)), key.Column)
+			if key.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+
+	sortSpec := formatSort(keys)
+	offset := 0
+	if page.Cursor != "" {
+		var c pageCursor
+		b, err := base64.RawURLEncoding.DecodeString(page.Cursor)
+		if err != nil || json.Unmarshal(b, &c) != nil || c.Sort != sortSpec || len(c.Values) != 1 || json.Unmarshal(c.Values[0], &offset) != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	if offset < len(rows) {
+		result.Items = append(result.Items, rows[offset:min(offset+limit, len(rows))]...)
+	}
+	if offset+limit < len(rows) {
+		next, _ := json.Marshal(offset + limit)
+		b, _ := json.Marshal(pageCursor{Sort: sortSpec, Values: []json.RawMessage{next}})
+		result.NextCursor = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return result, nil
+}
+
+// compareColumn compares two rows by the field stored in the named column.
+func compareColumn(a, b reflect.Value, column string) int {
+	for i := 0; i < a.NumField(); i++ {
+		name, _, _ := strings.Cut(a.Type().Field(i).Tag.Get("bun"), ",")
+		if name != column {
+			continue
+		}
+		x, y := a.Field(i).Interface(), b.Field(i).Interface()
+		if ranks, ok := rankedSortColumns[column]; ok {
+			return cmp.Compare(slices.Index(ranks, x.(string)), slices.Index(ranks, y.(string)))
+		}
+		switch x := x.(type) {
+		case int64:
+			return cmp.Compare(x, y.(int64))
+		case string:
+			return cmp.Compare(x, y.(string))
+		case time.Time:
+			return x.Compare(y.(time.Time))
+		case *time.Time:
+			if x == nil || y.(*time.Time) == nil {
+				return cmp.Compare(boolRank(x != nil), boolRank(y.(*time.Time) != nil))
+			}
+			return x.Compare(*y.(*time.Time))
+		}
+	}
+	return 0
+}
+
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// noFilters rejects query filters, which the in-memory and document repositories cannot apply.
+func noFilters(filters []QueryFilter) error {
+	for _, f := range filters {
+		if f != nil {
+			return ErrFilterUnsupported
+		}
+	}
+	return nil
+}
+
+// MemoryTicketRepository is an in-memory TicketRepository.
+type MemoryTicketRepository struct {
+	rows *memoryTable[Ticket]
+}
+
+func (r *MemoryTicketRepository) GetByID(ctx context.Context, id int64) (*Ticket, error) {
+	r.rows.mu.Lock()
+	defer r.rows.mu.Unlock()
+	return r.rows.get(ctx, id)
+}
+
+func (r *MemoryTicketRepository) list(ctx context.Context, page PageRequest, filters []QueryFilter, match func(*Ticket) bool) (*Page[Ticket], error) {
+	if err := noFilters(filters); err != nil {
+		return nil, err
+	}
+	r.rows.mu.Lock()
+	defer r.rows.mu.Unlock()
+	found, err := r.rows.find(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+	return memoryPage(found, page, ticketSortColumns...)
+}
+
+func (r *MemoryTicketRepository) List(ctx context.Context, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error) {
+	return r.list(ctx, page, filters, func(*Ticket) bool { return true })
+}
+
+func (r *MemoryTicketRepository) ListOpen(ctx context.Context, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error) {
+	return r.list(ctx, page, filters, func(t *Ticket) bool { return t.Status == "Open" })
+}
+
+func (r *MemoryTicketRepository) ListByAssigneeID(ctx context.Context, assigneeID int64, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error) {
+	return r.list(ctx, page, filters, func(t *Ticket) bool { return t.AssigneeID.Valid && t.AssigneeID.Int64 == assigneeID })
+}
+
+func (r *MemoryTicketRepository) ListByRequesterID(ctx context.Context, requesterID int64, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error) {
+	return r.list(ctx, page, filters, func(t *Ticket) bool { return t.RequesterID == requesterID })
+}
+
+func (r *MemoryTicketRepository) Create(ctx context.Context, ticket *Ticket) error {
+	r.rows.mu.Lock()
+	defer r.rows.mu.Unlock()
+
+	now := time.Now()
+	ticket.Version = 1
+	ticket.Type = cmp.Or(ticket.Type, TicketTypes[0])
+	ticket.Status = cmp.Or(ticket.Status, "Open")
+	ticket.Priority = cmp.Or(ticket.Priority, "Medium")
+	if ticket.CreatedAt.IsZero() {
+		ticket.CreatedAt = now
+	}
+	if ticket.UpdatedAt.IsZero() {
+		ticket.UpdatedAt = now
+	}
+	return r.rows.insert(ctx, ticket)
+}
+
+func (r *MemoryTicketRepository) Update(ctx context.Context, ticket *Ticket) error {
+	r.rows.mu.Lock()
+	defer r.rows.mu.Unlock()
+
+	stored, err := r.rows.stored(ctx, ticket.ID)
+	if err != nil {
+		return err
+	}
+	if stored.Version != ticket.Version {
+		return ErrVersionConflict
+	}
+
+	ticket.CreatedAt = stored.CreatedAt
+	ticket.UpdatedAt = time.Now()
+	ticket.ClosedAt = sql.NullTime{}
+	if ticket.Status == "Closed" {
+		ticket.ClosedAt = sql.NullTime{Time: ticket.UpdatedAt, Valid: true}
+	}
+	ticket.Version++
+
+	stored.Type, stored.Status, stored.Priority = ticket.Type, ticket.Status, ticket.Priority
+	stored.AssigneeID, stored.CategoryID = ticket.AssigneeID, ticket.CategoryID
+	stored.UpdatedAt, stored.ClosedAt, stored.Version = ticket.UpdatedAt, ticket.ClosedAt, ticket.Version
+	return nil
+}
+
+func (r *MemoryTicketRepository) Delete(ctx context.Context, id int64) error {
+	r.rows.mu.Lock()
+	defer r.rows.mu.Unlock()
+	return r.rows.delete(ctx, id)
+}
+
+// MemoryUserRepository is an in-memory UserRepository.
+type MemoryUserRepository struct {
+	rows *memoryTable[User]
+}
+
+func (r *MemoryUserRepository) GetByID(ctx context.Context, id int64) (*User, error) {
+	r.rows.mu.Lock()
+	defer r.rows.mu.Unlock()
+	return r.rows.get(ctx, id)
+}
+
+func (r *MemoryUserRepository) getBy(ctx context.Context, match func(*User) bool) (*User, error) {
+	r.rows.mu.Lock()
+	defer r.rows.mu.Unlock()
+	found, err := r.rows.find(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &found[0], nil
+}
+
+func (r *MemoryUserRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return r.getBy(ctx, func(u *User) bool { return u.Email == email })
+}
+
+func (r *MemoryUserRepository) GetByResetToken(ctx context.Context, token string) (*User, error) {
+	return r.getBy(ctx, func(u *User) bool { return u.PasswordResetToken.Valid && u.PasswordResetToken.String == token })
+}
+
+func (r *MemoryUserRepository) List(ctx context.Context, page PageRequest) (*Page[*User], error) {
+	return r.list(ctx, page, func(*User) bool { return true })
+}
+
+func (r *MemoryUserRepository) ListByRole(ctx context.Context, role string, page PageRequest) (*Page[*User], error) {
+	return r.list(ctx, page, func(u *User) bool { return u.Role == role })
+}
+
+func (r *MemoryUserRepository) list(ctx context.Context, page PageRequest, match func(*User) bool) (*Page[*User], error) {
+	r.rows.mu.Lock()
+	defer r.rows.mu.Unlock()
+	found, err := r.rows.find(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]*User, len(found))
+	for i := range found {
+		users[i] = &found[i]
+	}
+	return memoryPage(users, page, userSortColumns...)
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, user *User) error {
+	r.rows.mu.Lock()
+	defer r.rows.mu.Unlock()
+
+	user.Role = cmp.Or(user.Role, "Agent")
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+	return r.rows.insert(ctx, user)
+}
+
+func (r *MemoryUserRepository) Update(ctx context.Context, user *User) error {
+	r.rows.mu.Lock()
+	defer r.rows.mu.Unlock()
+
+	stored, err := r.rows.stored(ctx, user.ID)
+	if err == sql.ErrNoRows {
+		return nil // Like an UPDATE matching no rows
+	}
+	if err != nil {
+		return err
+	}
+	user.TenantID = stored.TenantID
+	*stored = *user
+	return nil
+}
+
+func (r *MemoryUserRepository) Delete(ctx context.Context, id int64) error {
+	r.rows.mu.Lock()
+	defer r.rows.mu.Unlock()
+	return r.rows.delete(ctx, id)
+}
+
+// MemoryCommentRepository is an in-memory CommentRepository.
+type MemoryCommentRepository struct {
+	rows *memoryTable[Comment]
+}
+
+func (r *MemoryCommentRepository) GetByID(ctx context.Context, id int64) (*Comment, error) {
+	r.rows.mu.Lock()
+	defer r.rows.mu.Unlock()
+	return r.rows.get(ctx, id)
+}
+
+func (r *MemoryCommentRepository) List(ctx context.Context, page PageRequest, filter CommentFilter) (*Page[Comment], error) {
+	r.rows.mu.Lock()
+	defer r.rows.mu.Unlock()
+	found, err := r.rows.find(ctx, func(c *Comment) bool {
+		return (filter.TicketID == 0 || c.TicketID == filter.TicketID) && !(filter.PublicOnly && c.IsInternal)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return memoryPage(found, page, commentSortColumns...)
+}
+
+func (r *MemoryCommentRepository) Create(ctx context.Context, comment *Comment) error {
+	r.rows.mu.Lock()
+	defer r.rows.mu.Unlock()
+	if comment.CreatedAt.IsZero() {
+		comment.CreatedAt = time.Now()
+	}
+	return r.rows.insert(ctx, comment)
+}
+
+func (r *MemoryCommentRepository) Update(ctx context.Context, comment *Comment) error {
+	r.rows.mu.Lock()
+	defer r.rows.mu.Unlock()
+
+	stored, err := r.rows.stored(ctx, comment.ID)
+	if err == sql.ErrNoRows {
+		return nil // Like an UPDATE matching no rows
+	}
+	if err != nil {
+		return err
+	}
+	stored.Body, stored.EditedAt = comment.Body, comment.EditedAt
+	return nil
+}
+
+func (r *MemoryCommentRepository) Delete(ctx context.Context, id int64) error {
+	r.rows.mu.Lock()
+	defer r.rows.mu.Unlock()
+	return r.rows.delete(ctx, id)
+}
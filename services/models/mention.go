@@ -0,0 +1,133 @@
+package models
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// CommentMention records a user mentioned with @handle in a comment.
+type CommentMention struct {
+	bun.BaseModel `bun:"table:comment_mentions,alias:comment_mention"`
+	CommentID     int64     `bun:"comment_id,pk"`
+	UserID        int64     `bun:"user_id,pk"`
+	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+// mentionPattern matches @handle not preceded by a word character, so email addresses in the text
+// are not taken for mentions. A handle may itself be a full email address.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@([\w][\w.+-]*(?:@[\w-]+(?:\.[\w-]+)+)?)`)
+
+// ParseMentions returns the distinct lowercase handles mentioned in a comment body, in order.
+func ParseMentions(body string) []string {
+	var handles []string
+	seen := map[string]bool{}
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		handle := strings.ToLower(strings.TrimRight(m[1], ".-"))
+		if handle != "" && !seen[handle] {
+			seen[handle] = true
+			handles = append(handles, handle)
+		}
+	}
+	return handles
+}
+
+// ResolveMentions finds the user each handle names: the user with that email address, the one whose
+// email starts with handle@, or the one whose name is handle without its spaces. Handles that match
+// no user, or more than one, are skipped, and each user is returned once.
+func ResolveMentions(db bun.IDB, ctx context.Context, handles []string) ([]User, error) {
+	if len(handles) == 0 {
+		return nil, nil
+	}
+
+	var candidates []User
+	err := db.NewSelect().
+		Model(&candidates).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			for _, handle := range handles {
+				q = q.WhereOr("LOWER(email) = ?", handle).
+					WhereOr("LOWER(email) LIKE ?", handle+"@%").
+					WhereOr("LOWER(REPLACE(name, ' ', '')) = ?", handle)
+			}
+			return q
+		}).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []User
+	found := map[int64]bool{}
+	for _, handle := range handles {
+		var match *User
+		ambiguous := false
+		for i := range candidates {
+			if !candidates[i].matchesHandle(handle) {
+				continue
+			}
+			if match != nil && match.ID != candidates[i].ID {
+				ambiguous = true
+			}
+			match = &candidates[i]
+		}
+		if match != nil && !ambiguous && !found[match.ID] {
+			found[match.ID] = true
+			users = append(users, *match)
+		}
+	}
+	return users, nil
+}
+
+// matchesHandle reports whether a mention handle names the user.
+func (u *User) matchesHandle(handle string) bool {
+	email := strings.ToLower(u.Email)
+	if strings.Contains(handle, "@") {
+		return email == handle
+	}
+	return strings.HasPrefix(email, handle+"@") || strings.ToLower(strings.ReplaceAll(u.Name, " ", "")) == handle
+}
+
+// CreateMentions records that the users were mentioned in a comment.
+func CreateMentions(db bun.IDB, ctx context.Context, commentID int64, userIDs []int64) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	mentions := make([]CommentMention, len(userIDs))
+	for i, userID := range userIDs {
+		mentions[i] = CommentMention{CommentID: commentID, UserID: userID}
+	}
+	_, err := db.NewInsert().Model(&mentions).Ignore().Exec(ctx)
+	return err
+}
+
+// ListMentionedUserIDs retrieves the IDs of the users mentioned in a comment.
+func ListMentionedUserIDs(db bun.IDB, ctx context.Context, commentID int64) ([]int64, error) {
+	var ids []int64
+	err := db.NewSelect().Model((*CommentMention)(nil)).Column("user_id").Where("comment_id = ?", commentID).Scan(ctx, &ids)
+	return ids, err
+}
+
+// IsMentionedOnTicket reports whether a user was mentioned in any comment of a ticket.
+func IsMentionedOnTicket(db bun.IDB, ctx context.Context, ticketID, userID int64) (bool, error) {
+	return db.NewSelect().
+		Model((*CommentMention)(nil)).
+		Join("JOIN comments AS c ON c.id = comment_mention.comment_id AND c.deleted_at IS NULL").
+		Where("c.ticket_id = ?", ticketID).
+		Where("comment_mention.user_id = ?", userID).
+		Exists(ctx)
+}
+
+// ListMentions retrieves a page of the comments mentioning a user, leaving out internal notes when
+// publicOnly is set.
+func ListMentions(db bun.IDB, ctx context.Context, userID int64, publicOnly bool, page PageRequest) (*Page[Comment], error) {
+	q := db.NewSelect().
+		Model((*Comment)(nil)).
+		Where("comment.id IN (SELECT cm.comment_id FROM comment_mentions AS cm WHERE cm.user_id = ?)", userID)
+	if publicOnly {
+		q = q.Where("comment.is_internal = ?", false)
+	}
+	return paginate[Comment](ctx, q, page, commentSortColumns...)
+}
@@ -0,0 +1,240 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+var (
+	// ErrMergeIntoSelf is returned when a ticket is listed as both merge target and source.
+	ErrMergeIntoSelf = errors.New("a ticket cannot be merged into itself")
+	// ErrMergeClosed is returned when a merge source is already closed.
+	ErrMergeClosed = errors.New("closed tickets cannot be merged")
+	// ErrAlreadyMerged is returned when a merge source was merged into another ticket before.
+	ErrAlreadyMerged = errors.New("ticket has already been merged into another ticket")
+	// ErrCommentNotOnTicket is returned when splitting off comments that belong to another ticket.
+	ErrCommentNotOnTicket = errors.New("comment does not belong to the ticket")
+)
+
+// MergeTickets moves every comment of the source tickets into the target ticket and copies their
+// watchers and CCs to it, copying their requesters as well so that they can follow their comments,
+// then closes each source with a "duplicates" link to the target. An
+// internal note recording the merge is added to every ticket and the merge is recorded in the audit
+// log, on behalf of origin. Sources that are closed or were merged before are rejected with
+// ErrMergeClosed or ErrAlreadyMerged. targetVersion must hold the version of the target the caller
+// read, otherwise ErrVersionConflict is returned. Everything happens in one transaction.
+// Tickets have no attachments yet; once they do, they must move along with the comments.
+func MergeTickets(db *bun.DB, ctx context.Context, targetID, targetVersion int64, sourceIDs []int64, origin AuditOrigin) error {
+	for _, id := range sourceIDs {
+		if id == targetID {
+			return ErrMergeIntoSelf
+		}
+	}
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		now := time.Now()
+
+		if err := lockForWrite(ctx, tx, "tickets"); err != nil {
+			return err
+		}
+		var sources []Ticket
+		err := tx.NewSelect().
+			Model(&sources).
+			Where("ticket.id IN (?)", bun.In(sourceIDs)).
+			Order("ticket.id ASC").
+			Apply(forUpdate).
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+		if len(sources) != len(sourceIDs) {
+			return sql.ErrNoRows
+		}
+		for _, source := range sources {
+			if source.Status == "Closed" {
+				return fmt.Errorf("ticket %d: %w", source.ID, ErrMergeClosed)
+			}
+		}
+		var merged []int64
+		err = tx.NewSelect().
+			Model((*TicketLink)(nil)).
+			Column("source_id").
+			Where("source_id IN (?)", bun.In(sourceIDs)).
+			Where("type = ?", LinkDuplicates).
+			Limit(1).
+			Scan(ctx, &merged)
+		if err != nil {
+			return err
+		}
+		if len(merged) > 0 {
+			return fmt.Errorf("ticket %d: %w", merged[0], ErrAlreadyMerged)
+		}
+
+		res, err := tx.NewUpdate().
+			Model((*Ticket)(nil)).
+			Set("updated_at = ?", now).
+			Set("version = version + 1").
+			Where("id = ?", targetID).
+			Where("version = ?", targetVersion).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+			return ErrVersionConflict
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*Comment)(nil)).
+			Set("ticket_id = ?", targetID).
+			Where("ticket_id IN (?)", bun.In(sourceIDs)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*Ticket)(nil)).
+			Set("status = ?", "Closed").
+			Set("closed_at = ?", now).
+			Set("updated_at = ?", now).
+			Set("version = version + 1").
+			Where("id IN (?)", bun.In(sourceIDs)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := moveParticipants(tx, ctx, targetID, sourceIDs); err != nil {
+			return err
+		}
+		var targetRequesterID int64
+		err = tx.NewSelect().Model((*Ticket)(nil)).Column("ticket.requester_id").Where("ticket.id = ?", targetID).Scan(ctx, &targetRequesterID)
+		if err != nil {
+			return err
+		}
+		for _, source := range sources {
+			if source.RequesterID == targetRequesterID {
+				continue
+			}
+			cc := &TicketCC{TicketID: targetID, UserID: sql.NullInt64{Int64: source.RequesterID, Valid: true}}
+			if err := AddTicketCC(tx, ctx, cc); err != nil {
+				return err
+			}
+		}
+
+		actorID := origin.ActorID.Int64
+		links := make([]TicketLink, len(sourceIDs))
+		notes := make([]Comment, 0, len(sourceIDs)+1)
+		refs := make([]string, len(sourceIDs))
+		for i, id := range sourceIDs {
+			links[i] = TicketLink{SourceID: id, TargetID: targetID, Type: LinkDuplicates}
+			notes = append(notes, Comment{TicketID: id, AuthorID: actorID, Body: fmt.Sprintf("Merged into ticket #%d.", targetID), IsInternal: true})
+			refs[i] = fmt.Sprintf("#%d", id)
+		}
+		notes = append(notes, Comment{TicketID: targetID, AuthorID: actorID, Body: "Merged tickets " + strings.Join(refs, ", ") + " into this ticket.", IsInternal: true})
+
+		if _, err := tx.NewInsert().Model(&links).Ignore().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := tx.NewInsert().Model(&notes).Exec(ctx); err != nil {
+			return err
+		}
+
+		err = RecordChange(tx, ctx, origin, AuditMerge, "ticket", targetID, map[string]any{"MergedTickets": nil}, map[string]any{"MergedTickets": sourceIDs})
+		if err != nil {
+			return err
+		}
+		for _, source := range sources {
+			err := RecordChange(tx, ctx, origin, AuditMerge, "ticket", source.ID,
+				map[string]any{"Status": source.Status, "MergedInto": nil},
+				map[string]any{"Status": "Closed", "MergedInto": targetID})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SplitTicket creates newTicket from selected comments of a ticket. The comments move to the new
+// ticket, both tickets are linked as related and each gets an internal note. The split and the new
+// ticket are recorded in the audit log, on behalf of origin. ticketVersion must hold the version of
+// the ticket the caller read, otherwise ErrVersionConflict is returned. Everything happens in one
+// transaction.
+func SplitTicket(db *bun.DB, ctx context.Context, ticketID, ticketVersion int64, commentIDs []int64, newTicket *Ticket, origin AuditOrigin) error {
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		now := time.Now()
+
+		if err := touchTicket(tx, ctx, ticketID, ticketVersion, now); err != nil {
+			return err
+		}
+		if err := CreateTicket(tx, ctx, newTicket); err != nil {
+			return err
+		}
+
+		res, err := tx.NewUpdate().
+			Model((*Comment)(nil)).
+			Set("ticket_id = ?", newTicket.ID).
+			Where("id IN (?)", bun.In(commentIDs)).
+			Where("ticket_id = ?", ticketID).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		if moved, err := res.RowsAffected(); err != nil || moved != int64(len(commentIDs)) {
+			if err != nil {
+				return err
+			}
+			return ErrCommentNotOnTicket
+		}
+
+		link, err := NewTicketLink(ticketID, LinkRelated, newTicket.ID)
+		if err != nil {
+			return err
+		}
+		if err := CreateTicketLink(tx, ctx, link); err != nil {
+			return err
+		}
+
+		actorID := origin.ActorID.Int64
+		notes := []Comment{
+			{TicketID: ticketID, AuthorID: actorID, Body: fmt.Sprintf("Split %d comments into ticket #%d.", len(commentIDs), newTicket.ID), IsInternal: true},
+			{TicketID: newTicket.ID, AuthorID: actorID, Body: fmt.Sprintf("Split from ticket #%d.", ticketID), IsInternal: true},
+		}
+		if _, err := tx.NewInsert().Model(&notes).Exec(ctx); err != nil {
+			return err
+		}
+
+		err = RecordChange(tx, ctx, origin, AuditSplit, "ticket", ticketID, map[string]any{"SplitInto": nil}, map[string]any{"SplitInto": newTicket.ID})
+		if err != nil {
+			return err
+		}
+		return RecordChange(tx, ctx, origin, AuditCreate, "ticket", newTicket.ID, nil, newTicket)
+	})
+}
+
+// touchTicket bumps the version and update time of a ticket whose comments changed, failing with
+// ErrVersionConflict unless version is still the stored version.
+func touchTicket(db bun.IDB, ctx context.Context, ticketID, version int64, now time.Time) error {
+	res, err := db.NewUpdate().
+		Model((*Ticket)(nil)).
+		Set("updated_at = ?", now).
+		Set("version = version + 1").
+		Where("id = ?", ticketID).
+		Where("version = ?", version).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
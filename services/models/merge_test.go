@@ -0,0 +1,136 @@
+package models_test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"goat/services/models"
+)
+
+func TestMergeTickets(t *testing.T) {
+	db := newTestDB(t)
+	ctx := tenantContext()
+	customer := newTestUser(t, db, ctx, "Customer")
+	other := newTestUser(t, db, ctx, "Customer")
+	agent := newTestUser(t, db, ctx, "Agent")
+	target := newTestTicket(t, db, ctx, customer)
+	source := newTestTicket(t, db, ctx, other)
+	origin := models.AuditOrigin{ActorID: sql.NullInt64{Int64: agent.ID, Valid: true}}
+
+	err := models.MergeTickets(db, ctx, target.ID, target.Version+1, []int64{source.ID}, origin)
+	if !errors.Is(err, models.ErrVersionConflict) {
+		t.Fatalf("merge with a stale version: got %v, want ErrVersionConflict", err)
+	}
+
+	if err := models.MergeTickets(db, ctx, target.ID, target.Version, []int64{source.ID}, origin); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := models.GetTicketByID(db, ctx, source.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Status != "Closed" {
+		t.Errorf("source status %q, want Closed", merged.Status)
+	}
+	comments, err := models.ListCommentsByTicketID(db, ctx, target.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 3 { // Both tickets' comments and the merge note
+		t.Errorf("target has %d comments, want 3", len(comments))
+	}
+	visible, err := db.NewSelect().
+		Model((*models.Ticket)(nil)).
+		Where("ticket.id = ?", target.ID).
+		Apply(models.CustomerTickets(other.ID, 0)).
+		Exists(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !visible {
+		t.Error("the source's requester cannot open the target after the merge")
+	}
+	if _, err := models.GetTicketCCForUser(db, ctx, target.ID, customer.ID); err != sql.ErrNoRows {
+		t.Errorf("the target's own requester was copied on it: got %v, want sql.ErrNoRows", err)
+	}
+	entries, err := models.ListAuditEntries(db, ctx, models.AuditFilter{Action: models.AuditMerge}, models.PageRequest{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries.Items) != 2 {
+		t.Errorf("got %d merge audit entries, want 2", len(entries.Items))
+	}
+
+	// A merged source is closed; reopened, it is still rejected as merged.
+	target, err = models.GetTicketByID(db, ctx, target.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = models.MergeTickets(db, ctx, target.ID, target.Version, []int64{source.ID}, origin)
+	if !errors.Is(err, models.ErrMergeClosed) {
+		t.Errorf("merge of a closed source: got %v, want ErrMergeClosed", err)
+	}
+	merged.Status = "Open"
+	if err := models.UpdateTicket(db, ctx, merged); err != nil {
+		t.Fatal(err)
+	}
+	err = models.MergeTickets(db, ctx, target.ID, target.Version, []int64{source.ID}, origin)
+	if !errors.Is(err, models.ErrAlreadyMerged) {
+		t.Errorf("merge of a merged source: got %v, want ErrAlreadyMerged", err)
+	}
+}
+
+func TestSplitTicket(t *testing.T) {
+	db := newTestDB(t)
+	ctx := tenantContext()
+	customer := newTestUser(t, db, ctx, "Customer")
+	agent := newTestUser(t, db, ctx, "Agent")
+	ticket := newTestTicket(t, db, ctx, customer)
+	other := newTestTicket(t, db, ctx, customer)
+	origin := models.AuditOrigin{ActorID: sql.NullInt64{Int64: agent.ID, Valid: true}}
+
+	comments, err := models.ListCommentsByTicketID(db, ctx, other.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	split := &models.Ticket{Title: "Split", Status: "Open", RequesterID: customer.ID}
+	err = models.SplitTicket(db, ctx, ticket.ID, ticket.Version, []int64{comments[0].ID}, split, origin)
+	if !errors.Is(err, models.ErrCommentNotOnTicket) {
+		t.Fatalf("split of another ticket's comment: got %v, want ErrCommentNotOnTicket", err)
+	}
+	n, err := db.NewSelect().Model((*models.AuditEntry)(nil)).Count(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("failed split left %d audit entries, want 0", n)
+	}
+
+	comments, err = models.ListCommentsByTicketID(db, ctx, ticket.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	split = &models.Ticket{Title: "Split", Status: "Open", RequesterID: customer.ID}
+	err = models.SplitTicket(db, ctx, ticket.ID, ticket.Version+1, []int64{comments[0].ID}, split, origin)
+	if !errors.Is(err, models.ErrVersionConflict) {
+		t.Fatalf("split with a stale version: got %v, want ErrVersionConflict", err)
+	}
+	split = &models.Ticket{Title: "Split", Status: "Open", RequesterID: customer.ID}
+	if err := models.SplitTicket(db, ctx, ticket.ID, ticket.Version, []int64{comments[0].ID}, split, origin); err != nil {
+		t.Fatal(err)
+	}
+	if touched, err := models.GetTicketByID(db, ctx, ticket.ID); err != nil || touched.Version != ticket.Version+1 {
+		t.Errorf("split ticket: got %v, %v, want version %d", touched, err, ticket.Version+1)
+	}
+	for _, action := range []string{models.AuditSplit, models.AuditCreate} {
+		entries, err := models.ListAuditEntries(db, ctx, models.AuditFilter{Action: action}, models.PageRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries.Items) != 1 {
+			t.Errorf("got %d %s audit entries, want 1", len(entries.Items), action)
+		}
+	}
+}
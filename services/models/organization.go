@@ -0,0 +1,57 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Organization is a tenant: a separate set of users, tickets and comments, with its own teams,
+// views, tags, categories, custom fields, macros and triggers.
+type Organization struct {
+	bun.BaseModel `bun:"table:organizations,alias:org"`
+	ID            int64     `bun:"id,pk,autoincrement,type:integer"`
+	Name          string    `bun:"name,notnull"`
+	Host          string    `bun:"host,nullzero,unique" json:"Host,omitempty"` // Hostname the organization is served on, if any
+	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+// GetOrganizationByID retrieves an organization from the database by its ID.
+func GetOrganizationByID(db bun.IDB, ctx context.Context, orgID int64) (*Organization, error) {
+	org := new(Organization)
+	err := db.NewSelect().Model(org).Where("id = ?", orgID).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// GetOrganizationByHost retrieves the organization served on a hostname.
+func GetOrganizationByHost(db bun.IDB, ctx context.Context, host string) (*Organization, error) {
+	org := new(Organization)
+	err := db.NewSelect().Model(org).Where("host = ?", host).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// ListOrganizations retrieves every organization in ID order.
+func ListOrganizations(db bun.IDB, ctx context.Context) ([]Organization, error) {
+	orgs := []Organization{}
+	err := db.NewSelect().Model(&orgs).Order("id ASC").Scan(ctx)
+	return orgs, err
+}
+
+// CreateOrganization inserts a new organization into the database.
+func CreateOrganization(db bun.IDB, ctx context.Context, org *Organization) error {
+	_, err := db.NewInsert().Model(org).Exec(ctx)
+	return constraintError(err, "organization")
+}
+
+// UpdateOrganization saves an organization's name and host.
+func UpdateOrganization(db bun.IDB, ctx context.Context, org *Organization) error {
+	_, err := db.NewUpdate().Model(org).Column("name", "host").WherePK().Exec(ctx)
+	return constraintError(err, "organization")
+}
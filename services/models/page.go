@@ -0,0 +1,280 @@
+package models
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+// ErrInvalidCursor is returned when a cursor is malformed or was issued for a different sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrInvalidSort is returned when a list is sorted by a column that does not support sorting.
+var ErrInvalidSort = errors.New("invalid sort")
+
+// SortKey orders a list by a single column.
+type SortKey struct {
+	Column string
+	Desc   bool
+}
+
+// PageRequest describes one page of a keyset-paginated list.
+type PageRequest struct {
+	Limit     int       // Defaults to DefaultPageLimit, capped at MaxPageLimit
+	Cursor    string    // Opaque cursor returned with the previous page
+	Sort      []SortKey // The row ID is always appended as a final tie-breaker
+	WithTotal bool      // Also count every row matching the filters
+}
+
+// Page is one page of a list along with the cursor for the next page.
+type Page[T any] struct {
+	Items      []T
+	NextCursor string // Empty on the last page
+	Total      *int   // Only set when requested
+}
+
+// ParseSort parses a comma-separated sort specification such as "-priority,created_at",
+// where a leading "-" sorts descending.
+func ParseSort(spec string) []SortKey {
+	var keys []SortKey
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key := SortKey{Column: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func formatSort(keys []SortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key.Column
+		if key.Desc {
+			parts[i] = "-" + key.Column
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// rankedSortColumns lists columns that sort by the position of their value in a list rather than alphabetically.
+var rankedSortColumns = map[string][]string{
+	"priority": TicketPriorities,
+}
+
+// computedSortColumns maps a column prefix to the expression ordering rows by a computed string,
+// such as the value of a custom field. A sortable column ending in "." allows any such column.
+var computedSortColumns = map[string]func(name string) schema.QueryWithArgs{
+	CustomFieldPrefix: customFieldSortExpr,
+}
+
+func computedSortExpr(column string) (schema.QueryWithArgs, bool) {
+	for prefix, expr := range computedSortColumns {
+		if name, ok := strings.CutPrefix(column, prefix); ok && name != "" {
+			return expr(name), true
+		}
+	}
+	return schema.QueryWithArgs{}, false
+}
+
+func canSort(sortable []string, column string) bool {
+	return column == "id" || slices.ContainsFunc(sortable, func(s string) bool {
+		return s == column || (strings.HasSuffix(s, ".") && strings.HasPrefix(column, s))
+	})
+}
+
+// sortExpr returns the expression a column is ordered by.
+func sortExpr(column string) schema.QueryWithArgs {
+	if expr, ok := computedSortExpr(column); ok {
+		return expr
+	}
+	ranks, ok := rankedSortColumns[column]
+	if !ok {
+		return bun.SafeQuery("?TableAlias.?", bun.Ident(column))
+	}
+
+	query := "CASE ?TableAlias.?"
+	args := []any{bun.Ident(column)}
+	for i, value := range ranks {
+		query += " WHEN ? THEN ?"
+		args = append(args, value, i)
+	}
+	return bun.SafeQuery(query+" ELSE -1 END", args...)
+}
+
+// sortValue converts a cursor value into a value comparable with sortExpr.
+func sortValue(column string, value any) any {
+	ranks, ok := rankedSortColumns[column]
+	if !ok {
+		return value
+	}
+	s, _ := value.(string)
+	return slices.Index(ranks, s)
+}
+
+// pageCursor is the decoded form of a cursor: the sort it belongs to and the sort values of the last row.
+// Store is set on cursors issued by the document store, whose values SQL queries cannot use.
+type pageCursor struct {
+	Sort   string            `json:"s"`
+	Values []json.RawMessage `json:"v"`
+	Store  string            `json:"st,omitempty"`
+}
+
+// paginate runs q, which must select from the model of T, one page at a time in a stable order.
+// Only the listed columns may be sorted on; they must be NOT NULL so that keyset comparisons hold.
+func paginate[T any](ctx context.Context, q *bun.SelectQuery, page PageRequest, sortable ...string) (*Page[T], error) {
+	keys := slices.Clone(page.Sort)
+	hasID := false
+	for _, key := range keys {
+		if !canSort(sortable, key.Column) {
+			return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidSort, key.Column)
+		}
+		hasID = hasID || key.Column == "id"
+	}
+	if !hasID {
+		keys = append(keys, SortKey{Column: "id"})
+	}
+
+	limit := page.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	table := q.DB().Table(typ)
+
+	result := &Page[T]{Items: []T{}}
+	if page.WithTotal {
+		total, err := countRows(ctx, q, table.ZeroIface)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	if page.Cursor != "" {
+		values, err := decodeCursor(page.Cursor, keys, table.FieldMap)
+		if err != nil {
+			return nil, err
+		}
+		q = q.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+			for i := range keys {
+				q = q.WhereGroup(" OR ", func(q *bun.SelectQuery) *bun.SelectQuery {
+					for j := 0; j < i; j++ {
+						q = q.Where("? = ?", sortExpr(keys[j].Column), sortValue(keys[j].Column, values[j]))
+					}
+					op := " > "
+					if keys[i].Desc {
+						op = " < "
+					}
+					return q.Where("?"+op+"?", sortExpr(keys[i].Column), sortValue(keys[i].Column, values[i]))
+				})
+			}
+			return q
+		})
+	}
+
+	for _, key := range keys {
+		dir := "ASC"
+		if key.Desc {
+			dir = "DESC"
+		}
+		q = q.OrderExpr("? "+dir, sortExpr(key.Column))
+	}
+
+	if err := q.Limit(limit+1).Scan(ctx, &result.Items); err != nil {
+		return nil, err
+	}
+
+	if len(result.Items) > limit {
+		result.Items = result.Items[:limit]
+		last := reflect.Indirect(reflect.ValueOf(result.Items[limit-1]))
+		cursor, err := encodeCursor(ctx, q.DB(), table, last, keys)
+		if err != nil {
+			return nil, err
+		}
+		result.NextCursor = cursor
+	}
+	return result, nil
+}
+
+func encodeCursor(ctx context.Context, db *bun.DB, table *schema.Table, row reflect.Value, keys []SortKey) (string, error) {
+	c := pageCursor{Sort: formatSort(keys)}
+	for _, key := range keys {
+		var value any
+		if field, ok := table.FieldMap[key.Column]; ok {
+			value = field.Value(row).Interface()
+		} else {
+			// Computed columns are not part of the row, so look up the value for the last row.
+			var s string
+			q := db.NewSelect().
+				Model(reflect.New(table.Type).Interface()).
+				ColumnExpr("?", sortExpr(key.Column)).
+				Where("?TableAlias.id = ?", table.FieldMap["id"].Value(row).Interface())
+			if table.SoftDeleteField != nil {
+				q = q.WhereAllWithDeleted() // The page may list deleted rows
+			}
+			if err := q.Scan(ctx, &s); err != nil {
+				return "", err
+			}
+			value = s
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		c.Values = append(c.Values, raw)
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(cursor string, keys []SortKey, fields map[string]*schema.Field) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.Store != "" || c.Sort != formatSort(keys) || len(c.Values) != len(keys) {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		typ := reflect.TypeOf("")
+		if field, ok := fields[key.Column]; ok {
+			typ = field.IndirectType
+		}
+		v := reflect.New(typ)
+		if err := json.Unmarshal(c.Values[i], v.Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = v.Elem().Interface()
+	}
+	return values, nil
+}
@@ -0,0 +1,239 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// TicketWatcher subscribes an agent or admin to a ticket's notifications.
+type TicketWatcher struct {
+	bun.BaseModel `bun:"table:ticket_watchers,alias:ticket_watcher"`
+	TicketID      int64     `bun:"ticket_id,pk"`
+	UserID        int64     `bun:"user_id,pk"`
+	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+// TicketCC copies a customer, or a contact known only by email, on a ticket. CC'd customers can
+// view and comment on the ticket, and both get its public notifications. A contact's CC only names
+// an account once they claim it with the token sent to their address, since registering with an
+// address does not prove owning it.
+type TicketCC struct {
+	bun.BaseModel `bun:"table:ticket_ccs,alias:ticket_cc"`
+	ID            int64         `bun:"id,pk,autoincrement,type:integer"`
+	TicketID      int64         `bun:"ticket_id,notnull"`
+	UserID        sql.NullInt64 `bun:"user_id"`                               // Set for customers with an account
+	Email         string        `bun:"email,nullzero" json:"Email,omitempty"` // Set for contacts without one
+	ClaimToken    string        `bun:"claim_token,nullzero" json:"-"`         // Sent to the contact to claim the CC
+	CreatedAt     time.Time     `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+// AddTicketWatcher subscribes a user to a ticket. Watching a ticket twice is a no-op.
+func AddTicketWatcher(db bun.IDB, ctx context.Context, ticketID, userID int64) error {
+	watcher := &TicketWatcher{TicketID: ticketID, UserID: userID}
+	_, err := db.NewInsert().Model(watcher).Ignore().Exec(ctx)
+	return err
+}
+
+// RemoveTicketWatcher unsubscribes a user from a ticket.
+func RemoveTicketWatcher(db bun.IDB, ctx context.Context, ticketID, userID int64) error {
+	_, err := db.NewDelete().Model((*TicketWatcher)(nil)).Where("ticket_id = ? AND user_id = ?", ticketID, userID).Exec(ctx)
+	return err
+}
+
+// ListTicketWatcherIDs retrieves the IDs of the users watching a ticket.
+func ListTicketWatcherIDs(db bun.IDB, ctx context.Context, ticketID int64) ([]int64, error) {
+	ids := []int64{}
+	err := db.NewSelect().Model((*TicketWatcher)(nil)).Column("user_id").Where("ticket_id = ?", ticketID).Order("user_id ASC").Scan(ctx, &ids)
+	return ids, err
+}
+
+// IsWatchingTicket reports whether a user watches a ticket.
+func IsWatchingTicket(db bun.IDB, ctx context.Context, ticketID, userID int64) (bool, error) {
+	return db.NewSelect().Model((*TicketWatcher)(nil)).Where("ticket_id = ? AND user_id = ?", ticketID, userID).Exists(ctx)
+}
+
+// AddTicketCC copies a customer or an email-only contact on a ticket, filling in cc's ID and, for
+// a contact, its claim token. Copying the same participant twice returns the existing CC.
+func AddTicketCC(db bun.IDB, ctx context.Context, cc *TicketCC) error {
+	cc.Email = strings.ToLower(strings.TrimSpace(cc.Email))
+	if !cc.UserID.Valid && cc.ClaimToken == "" {
+		cc.ClaimToken = newClaimToken()
+	}
+	if _, err := db.NewInsert().Model(cc).Ignore().Exec(ctx); err != nil {
+		return err
+	}
+	q := db.NewSelect().Model(cc).Where("ticket_id = ?", cc.TicketID)
+	if cc.UserID.Valid {
+		q = q.Where("user_id = ?", cc.UserID.Int64)
+	} else {
+		q = q.Where("email = ?", cc.Email)
+	}
+	return q.Scan(ctx)
+}
+
+// RemoveTicketCC removes a CC from a ticket by its ID.
+func RemoveTicketCC(db bun.IDB, ctx context.Context, ticketID, ccID int64) error {
+	_, err := db.NewDelete().Model((*TicketCC)(nil)).Where("ticket_id = ? AND id = ?", ticketID, ccID).Exec(ctx)
+	return err
+}
+
+// ListTicketCCs retrieves the participants copied on a ticket.
+func ListTicketCCs(db bun.IDB, ctx context.Context, ticketID int64) ([]TicketCC, error) {
+	ccs := []TicketCC{}
+	err := db.NewSelect().Model(&ccs).Where("ticket_id = ?", ticketID).Order("id ASC").Scan(ctx)
+	return ccs, err
+}
+
+// newClaimToken returns a random token for a contact to claim a CC with.
+func newClaimToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand does not fail on supported platforms
+	}
+	return hex.EncodeToString(b)
+}
+
+// ClaimTicketCC binds the contact CC with the claim token to a customer's account, who proved
+// owning the address by receiving the token, and returns the contact's CC as it was. A customer
+// already copied on the ticket keeps their CC and the contact's is removed. It returns
+// sql.ErrNoRows for an unknown token.
+func ClaimTicketCC(db *bun.DB, ctx context.Context, token string, userID int64) (*TicketCC, error) {
+	cc := new(TicketCC)
+	var claimed TicketCC
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		err := tx.NewSelect().
+			Model(cc).
+			Where("claim_token = ?", token).
+			Where("user_id IS NULL").
+			Apply(forUpdate).
+			Scan(ctx)
+		if err != nil {
+			return err
+		}
+		claimed = *cc
+		copied, err := tx.NewSelect().Model((*TicketCC)(nil)).Where("ticket_id = ? AND user_id = ?", cc.TicketID, userID).Exists(ctx)
+		if err != nil {
+			return err
+		}
+		if copied {
+			_, err = tx.NewDelete().Model(cc).WherePK().Exec(ctx)
+			return err
+		}
+		cc.UserID = sql.NullInt64{Int64: userID, Valid: true}
+		cc.Email = ""
+		cc.ClaimToken = ""
+		_, err = tx.NewUpdate().Model(cc).Column("user_id", "email", "claim_token").WherePK().Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &claimed, nil
+}
+
+// GetTicketCCForUser retrieves the CC of a user's account on a ticket.
+func GetTicketCCForUser(db bun.IDB, ctx context.Context, ticketID, userID int64) (*TicketCC, error) {
+	cc := new(TicketCC)
+	err := db.NewSelect().
+		Model(cc).
+		Where("ticket_id = ?", ticketID).
+		Where("user_id = ?", userID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return cc, nil
+}
+
+// CCTickets narrows a ticket list to the tickets a user's account is copied on.
+func CCTickets(userID int64) QueryFilter {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where(ccTickets, userID)
+	}
+}
+
+// ccTickets is the condition matching tickets a user's account is copied on. Contacts copied by
+// email only match once they claimed the CC.
+const ccTickets = "ticket.id IN (SELECT cc.ticket_id FROM ticket_ccs AS cc WHERE cc.user_id = ?)"
+
+// CustomerTickets narrows a ticket list to the tickets a customer may see: their own, the ones
+// they are copied on and, when sharingCompanyID is set, the ones requested by the members of
+// their company, which must share tickets.
+func CustomerTickets(userID, sharingCompanyID int64) QueryFilter {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			q = q.Where("ticket.requester_id = ?", userID).WhereOr(ccTickets, userID)
+			if sharingCompanyID != 0 {
+				q = q.WhereOr(companyRequesters, sharingCompanyID)
+			}
+			return q
+		})
+	}
+}
+
+// ListTicketSubscribers retrieves who besides the requester and assignee is notified about the
+// tickets: their watchers and, unless the notification is internal, the users and email-only
+// contacts copied on them. Contacts are notified by email with their claim token, even when an
+// account has their address, since only receiving the token proves owning it.
+func ListTicketSubscribers(db bun.IDB, ctx context.Context, ticketIDs []int64, internal bool) (userIDs []int64, contacts []TicketCC, err error) {
+	if len(ticketIDs) == 0 {
+		return nil, nil, nil
+	}
+	err = db.NewSelect().Model((*TicketWatcher)(nil)).Column("user_id").Where("ticket_id IN (?)", bun.In(ticketIDs)).Scan(ctx, &userIDs)
+	if err != nil || internal {
+		return userIDs, nil, err
+	}
+
+	var ccs []TicketCC
+	if err := db.NewSelect().Model(&ccs).Where("ticket_id IN (?)", bun.In(ticketIDs)).Order("id ASC").Scan(ctx); err != nil {
+		return nil, nil, err
+	}
+	for _, cc := range ccs {
+		if cc.UserID.Valid {
+			userIDs = append(userIDs, cc.UserID.Int64)
+		} else {
+			contacts = append(contacts, cc)
+		}
+	}
+	return userIDs, contacts, nil
+}
+
+// moveParticipants copies the watchers and CCs of the source tickets to the target, for merges.
+func moveParticipants(tx bun.Tx, ctx context.Context, targetID int64, sourceIDs []int64) error {
+	var watchers []TicketWatcher
+	if err := tx.NewSelect().Model(&watchers).Where("ticket_id IN (?)", bun.In(sourceIDs)).Scan(ctx); err != nil {
+		return err
+	}
+	for i := range watchers {
+		watchers[i].TicketID = targetID
+	}
+	if len(watchers) > 0 {
+		if _, err := tx.NewInsert().Model(&watchers).Ignore().Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	var ccs []TicketCC
+	if err := tx.NewSelect().Model(&ccs).Where("ticket_id IN (?)", bun.In(sourceIDs)).Scan(ctx); err != nil {
+		return err
+	}
+	for i := range ccs {
+		ccs[i].ID = 0
+		ccs[i].TicketID = targetID
+		if !ccs[i].UserID.Valid {
+			ccs[i].ClaimToken = newClaimToken() // Tokens are unique to their CC
+		}
+	}
+	if len(ccs) > 0 {
+		if _, err := tx.NewInsert().Model(&ccs).Ignore().Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package models_test
+
+import (
+	"database/sql"
+	"slices"
+	"testing"
+
+	"goat/services/models"
+)
+
+func TestCustomerTickets(t *testing.T) {
+	db := newTestDB(t)
+	ctx := tenantContext()
+	company := &models.Company{Name: "Acme", SharedTickets: true}
+	if err := models.CreateCompany(db, ctx, company); err != nil {
+		t.Fatal(err)
+	}
+	customer := newTestUser(t, db, ctx, "Customer")
+	colleague := newTestUser(t, db, ctx, "Customer")
+	stranger := newTestUser(t, db, ctx, "Customer")
+	for _, u := range []*models.User{customer, colleague} {
+		u.CompanyID = sql.NullInt64{Int64: company.ID, Valid: true}
+		if err := models.UpdateUser(db, ctx, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	own := newTestTicket(t, db, ctx, customer)
+	shared := newTestTicket(t, db, ctx, colleague)
+	copied := newTestTicket(t, db, ctx, stranger)
+	other := newTestTicket(t, db, ctx, stranger)
+	cc := &models.TicketCC{TicketID: copied.ID, UserID: sql.NullInt64{Int64: customer.ID, Valid: true}}
+	if err := models.AddTicketCC(db, ctx, cc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		companyID int64
+		want      []int64
+	}{
+		{"without sharing", 0, []int64{own.ID, copied.ID}},
+		{"with sharing", company.ID, []int64{own.ID, shared.ID, copied.ID}},
+	}
+	for _, tt := range tests {
+		var ids []int64
+		err := db.NewSelect().
+			Model((*models.Ticket)(nil)).
+			Column("ticket.id").
+			Apply(models.CustomerTickets(customer.ID, tt.companyID)).
+			Order("ticket.id").
+			Scan(ctx, &ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("%s: got tickets %v, want %v (not %d)", tt.name, ids, tt.want, other.ID)
+		}
+	}
+}
+
+func TestClaimTicketCC(t *testing.T) {
+	db := newTestDB(t)
+	ctx := tenantContext()
+	requester := newTestUser(t, db, ctx, "Customer")
+	contact := newTestUser(t, db, ctx, "Customer")
+	ticket := newTestTicket(t, db, ctx, requester)
+
+	cc := &models.TicketCC{TicketID: ticket.ID, Email: contact.Email}
+	if err := models.AddTicketCC(db, ctx, cc); err != nil {
+		t.Fatal(err)
+	}
+	if cc.ClaimToken == "" {
+		t.Fatal("contact CC has no claim token")
+	}
+
+	visible := func() []int64 {
+		t.Helper()
+		var ids []int64
+		err := db.NewSelect().Model((*models.Ticket)(nil)).Column("ticket.id").Apply(models.CustomerTickets(contact.ID, 0)).Scan(ctx, &ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+	if ids := visible(); len(ids) != 0 {
+		t.Errorf("account registered with the copied address sees tickets %v before claiming", ids)
+	}
+	if _, err := models.GetTicketCCForUser(db, ctx, ticket.ID, contact.ID); err != sql.ErrNoRows {
+		t.Errorf("CC for the unclaimed account: got %v, want sql.ErrNoRows", err)
+	}
+
+	if _, err := models.ClaimTicketCC(db, ctx, "not-a-token", contact.ID); err != sql.ErrNoRows {
+		t.Errorf("claim with a wrong token: got %v, want sql.ErrNoRows", err)
+	}
+	claimed, err := models.ClaimTicketCC(db, ctx, cc.ClaimToken, contact.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed.ID != cc.ID {
+		t.Errorf("claimed CC %d, want %d", claimed.ID, cc.ID)
+	}
+	if ids := visible(); !slices.Equal(ids, []int64{ticket.ID}) {
+		t.Errorf("after claiming got tickets %v, want %v", ids, []int64{ticket.ID})
+	}
+	if _, err := models.ClaimTicketCC(db, ctx, cc.ClaimToken, requester.ID); err != sql.ErrNoRows {
+		t.Errorf("claiming twice: got %v, want sql.ErrNoRows", err)
+	}
+}
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+// QueryFilter narrows a select, e.g. a compiled ticket query. Only the bun repositories apply them.
+type QueryFilter = func(*bun.SelectQuery) *bun.SelectQuery
+
+// TicketRepository stores tickets. Lookups return sql.ErrNoRows for missing or deleted tickets.
+type TicketRepository interface {
+	GetByID(ctx context.Context, id int64) (*Ticket, error)
+	List(ctx context.Context, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error)
+	ListOpen(ctx context.Context, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error)
+	ListByAssigneeID(ctx context.Context, assigneeID int64, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error)
+	ListByRequesterID(ctx context.Context, requesterID int64, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error)
+	Create(ctx context.Context, ticket *Ticket) error
+	// Update saves the ticket's type, status, priority, assignee and category, failing with
+	// ErrVersionConflict unless ticket.Version is still the stored version.
+	Update(ctx context.Context, ticket *Ticket) error
+	Delete(ctx context.Context, id int64) error
+}
+
+// UserRepository stores users. Lookups return sql.ErrNoRows for missing or deleted users.
+type UserRepository interface {
+	GetByID(ctx context.Context, id int64) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetByResetToken(ctx context.Context, token string) (*User, error)
+	List(ctx context.Context, page PageRequest) (*Page[*User], error)
+	ListByRole(ctx context.Context, role string, page PageRequest) (*Page[*User], error)
+	Create(ctx context.Context, user *User) error
+	Update(ctx context.Context, user *User) error
+	Delete(ctx context.Context, id int64) error
+}
+
+// CommentFilter narrows a comment list. Zero values match everything.
+type CommentFilter struct {
+	TicketID   int64
+	PublicOnly bool // Leave out internal comments
+}
+
+// CommentRepository stores comments. Lookups return sql.ErrNoRows for missing or deleted comments.
+type CommentRepository interface {
+	GetByID(ctx context.Context, id int64) (*Comment, error)
+	List(ctx context.Context, page PageRequest, filter CommentFilter) (*Page[Comment], error)
+	Create(ctx context.Context, comment *Comment) error
+	// Update saves the comment's body and edit time.
+	Update(ctx context.Context, comment *Comment) error
+	Delete(ctx context.Context, id int64) error
+}
+
+// Repositories groups the stores the ticket, user and comment handlers depend on.
+type Repositories struct {
+	Tickets  TicketRepository
+	Users    UserRepository
+	Comments CommentRepository
+	// SQL is the database the tickets, users and comments are rows of, which the tables referring
+	// to them such as custom field values, participants, mentions and trigger runs live in. It is
+	// nil when they are stored elsewhere, and those features are then unavailable.
+	SQL *bun.DB
+}
+
+// NewBunRepositories returns repositories backed by the database.
+func NewBunRepositories(db *bun.DB) Repositories {
+	return Repositories{
+		Tickets:  &BunTicketRepository{db: db},
+		Users:    &BunUserRepository{db: db},
+		Comments: &BunCommentRepository{db: db},
+		SQL:      db,
+	}
+}
+
+// RowChanges lists the users, tickets and comments changed in the SQL database without going
+// through the repositories, as merges, macros, triggers and the trash do.
+type RowChanges struct {
+	UserIDs    []int64
+	TicketIDs  []int64
+	CommentIDs []int64
+}
+
+// changeSyncer is implemented by ticket repositories that keep a copy of the SQL database.
+type changeSyncer interface {
+	syncChanges(ctx context.Context, changes RowChanges)
+}
+
+// SyncChanges brings a copy of the SQL database kept by tickets, such as the dual write document
+// store, in line with rows changed outside the repositories. Tickets are copied along with all of
+// their comments, so comments moved between tickets follow. It does nothing for other repositories.
+func SyncChanges(tickets TicketRepository, ctx context.Context, changes RowChanges) {
+	if s, ok := tickets.(changeSyncer); ok {
+		s.syncChanges(ctx, changes)
+	}
+}
+
+// BunTicketRepository is the TicketRepository backed by the database.
+type BunTicketRepository struct {
+	db *bun.DB
+}
+
+func (r *BunTicketRepository) GetByID(ctx context.Context, id int64) (*Ticket, error) {
+	return GetTicketByID(r.db, ctx, id)
+}
+
+func (r *BunTicketRepository) List(ctx context.Context, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error) {
+	return ListTickets(r.db, ctx, page, filters...)
+}
+
+func (r *BunTicketRepository) ListOpen(ctx context.Context, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error) {
+	return ListOpenTickets(r.db, ctx, page, filters...)
+}
+
+func (r *BunTicketRepository) ListByAssigneeID(ctx context.Context, assigneeID int64, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error) {
+	return ListTicketsByAssigneeID(r.db, ctx, assigneeID, page, filters...)
+}
+
+func (r *BunTicketRepository) ListByRequesterID(ctx context.Context, requesterID int64, page PageRequest, filters ...QueryFilter) (*Page[Ticket], error) {
+	return ListTicketsByRequesterID(r.db, ctx, requesterID, page, filters...)
+}
+
+func (r *BunTicketRepository) Create(ctx context.Context, ticket *Ticket) error {
+	return CreateTicket(r.db, ctx, ticket)
+}
+
+func (r *BunTicketRepository) Update(ctx context.Context, ticket *Ticket) error {
+	return UpdateTicket(r.db, ctx, ticket)
+}
+
+func (r *BunTicketRepository) Delete(ctx context.Context, id int64) error {
+	return DeleteTicket(r.db, ctx, id)
+}
+
+// BunUserRepository is the UserRepository backed by the database.
+type BunUserRepository struct {
+	db *bun.DB
+}
+
+func (r *BunUserRepository) GetByID(ctx context.Context, id int64) (*User, error) {
+	return GetUserByID(r.db, ctx, id)
+}
+
+func (r *BunUserRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	return GetUserByEmail(r.db, ctx, email)
+}
+
+func (r *BunUserRepository) GetByResetToken(ctx context.Context, token string) (*User, error) {
+	return GetUserByResetToken(r.db, ctx, token)
+}
+
+func (r *BunUserRepository) List(ctx context.Context, page PageRequest) (*Page[*User], error) {
+	return GetUsers(r.db, ctx, page)
+}
+
+func (r *BunUserRepository) ListByRole(ctx context.Context, role string, page PageRequest) (*Page[*User], error) {
+	return GetUsersByRole(r.db, ctx, role, page)
+}
+
+func (r *BunUserRepository) Create(ctx context.Context, user *User) error {
+	return CreateUser(r.db, ctx, user)
+}
+
+func (r *BunUserRepository) Update(ctx context.Context, user *User) error {
+	return UpdateUser(r.db, ctx, user)
+}
+
+func (r *BunUserRepository) Delete(ctx context.Context, id int64) error {
+	return DeleteUser(r.db, ctx, id)
+}
+
+// BunCommentRepository is the CommentRepository backed by the database.
+type BunCommentRepository struct {
+	db *bun.DB
+}
+
+func (r *BunCommentRepository) GetByID(ctx context.Context, id int64) (*Comment, error) {
+	return GetCommentByID(r.db, ctx, id)
+}
+
+func (r *BunCommentRepository) List(ctx context.Context, page PageRequest, filter CommentFilter) (*Page[Comment], error) {
+	return ListComments(r.db, ctx, page, func(q *bun.SelectQuery) *bun.SelectQuery {
+		if filter.TicketID != 0 {
+			q = q.Where("comment.ticket_id = ?", filter.TicketID)
+		}
+		if filter.PublicOnly {
+			q = q.Where("comment.is_internal = ?", false)
+		}
+		return q
+	})
+}
+
+func (r *BunCommentRepository) Create(ctx context.Context, comment *Comment) error {
+	return CreateComment(r.db, ctx, comment)
+}
+
+func (r *BunCommentRepository) Update(ctx context.Context, comment *Comment) error {
+	return UpdateComment(r.db, ctx, comment)
+}
+
+func (r *BunCommentRepository) Delete(ctx context.Context, id int64) error {
+	return DeleteComment(r.db, ctx, id)
+}
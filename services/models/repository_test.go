@@ -0,0 +1,254 @@
+package models_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/uptrace/bun"
+
+	"goat/services/config"
+	"goat/services/migrate"
+	"goat/services/models"
+)
+
+// testBackends lists the databases the repository tests run against. SQLite runs in memory
+// everywhere; MySQL and PostgreSQL run against the servers named by the variables with the prefix,
+// e.g. TEST_MYSQL_HOST, and are skipped unless the host is set. Their databases are emptied first.
+var testBackends = []struct {
+	driver string
+	prefix string
+}{
+	{"sqlite", ""},
+	{"mysql", "TEST_MYSQL_"},
+	{"postgres", "TEST_POSTGRES_"},
+}
+
+// connectBackend returns a database of the driver with the current schema and no rows.
+func connectBackend(t *testing.T, driver, prefix string) *bun.DB {
+	t.Helper()
+	t.Setenv("DB_DRIVER", driver)
+	if prefix == "" {
+		t.Setenv("DB_PATH", ":memory:")
+	} else {
+		if os.Getenv(prefix+"HOST") == "" {
+			t.Skipf("set %sHOST to run against %s", prefix, driver)
+		}
+		for _, name := range []string{"HOST", "PORT", "USER", "PASSWORD", "NAME"} {
+			t.Setenv("DB_"+name, os.Getenv(prefix+name))
+		}
+	}
+	db := config.ConnectDB()
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	migrations, err := migrate.Migrations(db.Dialect().Name())
+	if err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	if _, err := migrate.Down(ctx, db, len(migrations)); err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+	if _, err := migrate.Up(ctx, db, 0); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	return db
+}
+
+// TestRepositories runs the ticket, user and comment repositories against every SQL backend.
+func TestRepositories(t *testing.T) {
+	for _, b := range testBackends {
+		t.Run(b.driver, func(t *testing.T) {
+			repos := models.NewBunRepositories(connectBackend(t, b.driver, b.prefix))
+			t.Run("users", func(t *testing.T) { testUserRepository(t, repos) })
+			t.Run("tickets", func(t *testing.T) { testTicketRepository(t, repos) })
+			t.Run("comments", func(t *testing.T) { testCommentRepository(t, repos) })
+		})
+	}
+}
+
+// createRepoUser stores a user of the given role through the repositories.
+func createRepoUser(t *testing.T, repos models.Repositories, ctx context.Context, role string) *models.User {
+	t.Helper()
+	user := &models.User{Name: role, Role: role, PasswordHash: "x"}
+	user.Email = fmt.Sprintf("%s-%d@example.com", role, testUsers.Add(1))
+	if err := repos.Users.Create(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func testUserRepository(t *testing.T, repos models.Repositories) {
+	ctx := tenantContext()
+	user := createRepoUser(t, repos, ctx, "Agent")
+	createRepoUser(t, repos, ctx, "Customer")
+
+	got, err := repos.Users.GetByEmail(ctx, user.Email)
+	if err != nil || got.ID != user.ID {
+		t.Fatalf("get by email: got %v, %v, want user %d", got, err, user.ID)
+	}
+	agents, err := repos.Users.ListByRole(ctx, "Agent", models.PageRequest{WithTotal: true})
+	if err != nil {
+		t.Fatalf("list by role: %v", err)
+	}
+	if len(agents.Items) != 1 || *agents.Total != 1 || agents.Items[0].ID != user.ID {
+		t.Errorf("got %d agents, want only user %d", len(agents.Items), user.ID)
+	}
+
+	duplicate := &models.User{ID: user.ID, Name: "Copy", Email: "copy@example.com", Role: "Agent", PasswordHash: "x"}
+	if err := repos.Users.Create(ctx, duplicate); !errors.Is(err, models.ErrDuplicate) {
+		t.Errorf("creating a user with a taken ID got %v, want ErrDuplicate", err)
+	}
+
+	user.Name = "Renamed"
+	if err := repos.Users.Update(ctx, user); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got, err := repos.Users.GetByID(ctx, user.ID); err != nil || got.Name != "Renamed" {
+		t.Errorf("after update got %v, %v, want the new name", got, err)
+	}
+
+	if err := repos.Users.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := repos.Users.GetByID(ctx, user.ID); err != sql.ErrNoRows {
+		t.Errorf("deleted user: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testTicketRepository(t *testing.T, repos models.Repositories) {
+	ctx := tenantContext()
+	requester := createRepoUser(t, repos, ctx, "Customer")
+	agent := createRepoUser(t, repos, ctx, "Agent")
+
+	ticket := &models.Ticket{Title: "Printer on fire", Status: "Open", Priority: "High", RequesterID: requester.ID}
+	if err := repos.Tickets.Create(ctx, ticket); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if ticket.ID == 0 || ticket.Version != 1 || ticket.Type != models.TicketTypes[0] {
+		t.Errorf("created ticket %d with version %d and type %q, want an ID, version 1 and the default type", ticket.ID, ticket.Version, ticket.Type)
+	}
+	other := &models.Ticket{Title: "Toner", Status: "Open", Priority: "Low", RequesterID: requester.ID}
+	other.AssigneeID = sql.NullInt64{Int64: agent.ID, Valid: true}
+	if err := repos.Tickets.Create(ctx, other); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	orphan := &models.Ticket{Title: "Nobody's", Status: "Open", Priority: "Low", RequesterID: other.ID + 1000}
+	if err := repos.Tickets.Create(ctx, orphan); !errors.Is(err, models.ErrReference) {
+		t.Errorf("creating a ticket for a missing requester got %v, want ErrReference", err)
+	}
+
+	stale := *ticket
+	ticket.Status = "Closed"
+	if err := repos.Tickets.Update(ctx, ticket); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if ticket.Version != 2 {
+		t.Errorf("got version %d after update, want 2", ticket.Version)
+	}
+	stale.Priority = "Low"
+	if err := repos.Tickets.Update(ctx, &stale); err != models.ErrVersionConflict {
+		t.Errorf("updating an old version got %v, want ErrVersionConflict", err)
+	}
+	got, err := repos.Tickets.GetByID(ctx, ticket.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Status != "Closed" || got.Priority != "High" || !got.ClosedAt.Valid {
+		t.Errorf("got %s/%s closed %v, want the first update only", got.Status, got.Priority, got.ClosedAt.Valid)
+	}
+
+	for _, tc := range []struct {
+		name string
+		list func() (*models.Page[models.Ticket], error)
+		want []int64
+	}{
+		{"all", func() (*models.Page[models.Ticket], error) {
+			return repos.Tickets.List(ctx, models.PageRequest{})
+		}, []int64{ticket.ID, other.ID}},
+		{"open", func() (*models.Page[models.Ticket], error) {
+			return repos.Tickets.ListOpen(ctx, models.PageRequest{})
+		}, []int64{other.ID}},
+		{"assignee", func() (*models.Page[models.Ticket], error) {
+			return repos.Tickets.ListByAssigneeID(ctx, agent.ID, models.PageRequest{})
+		}, []int64{other.ID}},
+		{"requester", func() (*models.Page[models.Ticket], error) {
+			return repos.Tickets.ListByRequesterID(ctx, requester.ID, models.PageRequest{Limit: 1})
+		}, []int64{ticket.ID}},
+	} {
+		page, err := tc.list()
+		if err != nil {
+			t.Errorf("list %s: %v", tc.name, err)
+			continue
+		}
+		if ids := ticketIDs(page.Items); fmt.Sprint(ids) != fmt.Sprint(tc.want) {
+			t.Errorf("list %s: got tickets %v, want %v", tc.name, ids, tc.want)
+		}
+	}
+
+	if err := repos.Tickets.Delete(ctx, ticket.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := repos.Tickets.GetByID(ctx, ticket.ID); err != sql.ErrNoRows {
+		t.Errorf("deleted ticket: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testCommentRepository(t *testing.T, repos models.Repositories) {
+	ctx := tenantContext()
+	requester := createRepoUser(t, repos, ctx, "Customer")
+	ticket := &models.Ticket{Title: "Refund", Status: "Open", Priority: "Low", RequesterID: requester.ID}
+	if err := repos.Tickets.Create(ctx, ticket); err != nil {
+		t.Fatalf("create ticket: %v", err)
+	}
+
+	public := &models.Comment{TicketID: ticket.ID, AuthorID: requester.ID, Body: "Any news?"}
+	internal := &models.Comment{TicketID: ticket.ID, AuthorID: requester.ID, Body: "Ask finance", IsInternal: true}
+	for _, c := range []*models.Comment{public, internal} {
+		if err := repos.Comments.Create(ctx, c); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	all, err := repos.Comments.List(ctx, models.PageRequest{}, models.CommentFilter{TicketID: ticket.ID})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(all.Items) != 2 {
+		t.Errorf("got %d comments, want 2", len(all.Items))
+	}
+	visible, err := repos.Comments.List(ctx, models.PageRequest{}, models.CommentFilter{TicketID: ticket.ID, PublicOnly: true})
+	if err != nil {
+		t.Fatalf("list public: %v", err)
+	}
+	if len(visible.Items) != 1 || visible.Items[0].ID != public.ID {
+		t.Errorf("got %d public comments, want only comment %d", len(visible.Items), public.ID)
+	}
+
+	public.Body = "Any news on the refund?"
+	if err := repos.Comments.Update(ctx, public); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got, err := repos.Comments.GetByID(ctx, public.ID); err != nil || got.Body != public.Body {
+		t.Errorf("after update got %v, %v, want the new body", got, err)
+	}
+
+	if err := repos.Comments.Delete(ctx, internal.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := repos.Comments.GetByID(ctx, internal.ID); err != sql.ErrNoRows {
+		t.Errorf("deleted comment: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func ticketIDs(tickets []models.Ticket) []int64 {
+	ids := make([]int64, len(tickets))
+	for i, t := range tickets {
+		ids[i] = t.ID
+	}
+	return ids
+}
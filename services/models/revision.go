@@ -0,0 +1,31 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// CommentRevision is the text a comment had before an edit, with who made the edit and when.
+type CommentRevision struct {
+	bun.BaseModel `bun:"table:comment_revisions,alias:comment_revision"`
+	ID            int64     `bun:"id,pk,autoincrement,type:integer"`
+	CommentID     int64     `bun:"comment_id,notnull"`
+	Body          string    `bun:"body,notnull"`      // Text the edit replaced
+	EditorID      int64     `bun:"editor_id,notnull"` // User who made the edit
+	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+// CreateCommentRevision inserts a revision into the database.
+func CreateCommentRevision(db bun.IDB, ctx context.Context, revision *CommentRevision) error {
+	_, err := db.NewInsert().Model(revision).Exec(ctx)
+	return err
+}
+
+// ListCommentRevisions retrieves the revisions of a comment, oldest first.
+func ListCommentRevisions(db bun.IDB, ctx context.Context, commentID int64) ([]CommentRevision, error) {
+	revisions := []CommentRevision{}
+	err := db.NewSelect().Model(&revisions).Where("comment_id = ?", commentID).Order("id ASC").Scan(ctx)
+	return revisions, err
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Tag represents a free-form ticket label. Suggested tags are curated by admins.
+type Tag struct {
+	bun.BaseModel `bun:"table:tags,alias:tag"`
+	ID            int64     `bun:"id,pk,autoincrement,type:integer"`
+	TenantID      int64     `bun:"tenant_id,notnull"` // Organization the tag belongs to
+	Name          string    `bun:"name,notnull"`      // Unique within the organization
+	Suggested     bool      `bun:"suggested,default:false"`
+	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UsageCount    int       `bun:"usage_count,scanonly"` // Number of tickets carrying the tag, only set by ListTagStats
+}
+
+// TicketTag links a tag to a ticket.
+type TicketTag struct {
+	bun.BaseModel `bun:"table:ticket_tags,alias:ticket_tag"`
+	TicketID      int64 `bun:"ticket_id,pk"`
+	TagID         int64 `bun:"tag_id,pk"`
+}
+
+// NormalizeTagName lowercases a tag name and replaces inner whitespace with dashes.
+func NormalizeTagName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), "-")
+}
+
+func normalizeTagNames(names []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = NormalizeTagName(name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, name)
+		}
+	}
+	return normalized
+}
+
+// GetOrCreateTags retrieves the tags with the given names, creating any that do not exist yet.
+func GetOrCreateTags(db bun.IDB, ctx context.Context, names []string) ([]Tag, error) {
+	names = normalizeTagNames(names)
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	missing := make([]Tag, len(names))
+	for i, name := range names {
+		missing[i] = Tag{Name: name}
+	}
+	if _, err := db.NewInsert().Model(&missing).Ignore().Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	var tags []Tag
+	err := db.NewSelect().Model(&tags).Where("name IN (?)", bun.In(names)).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// AddTicketTags tags every given ticket with every given tag name, creating tags as needed.
+func AddTicketTags(db bun.IDB, ctx context.Context, ticketIDs []int64, names []string) error {
+	tags, err := GetOrCreateTags(db, ctx, names)
+	if err != nil || len(tags) == 0 || len(ticketIDs) == 0 {
+		return err
+	}
+
+	links := make([]TicketTag, 0, len(ticketIDs)*len(tags))
+	for _, ticketID := range ticketIDs {
+		for _, tag := range tags {
+			links = append(links, TicketTag{TicketID: ticketID, TagID: tag.ID})
+		}
+	}
+	_, err = db.NewInsert().Model(&links).Ignore().Exec(ctx)
+	return err
+}
+
+// RemoveTicketTags removes the given tag names from every given ticket.
+func RemoveTicketTags(db bun.IDB, ctx context.Context, ticketIDs []int64, names []string) error {
+	names = normalizeTagNames(names)
+	if len(names) == 0 || len(ticketIDs) == 0 {
+		return nil
+	}
+
+	tagIDs := db.NewSelect().Model((*Tag)(nil)).Column("id").Where("name IN (?)", bun.In(names))
+	_, err := db.NewDelete().
+		Model((*TicketTag)(nil)).
+		Where("ticket_id IN (?)", bun.In(ticketIDs)).
+		Where("tag_id IN (?)", tagIDs).
+		Exec(ctx)
+	return err
+}
+
+// ListTagNamesByTicketID retrieves the names of the tags on a ticket.
+func ListTagNamesByTicketID(db *bun.DB, ctx context.Context, ticketID int64) ([]string, error) {
+	tickets, err := tenantTickets(db, ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	err = db.NewSelect().
+		Model((*Tag)(nil)).
+		Column("tag.name").
+		Join("JOIN ticket_tags AS tt ON tt.tag_id = tag.id").
+		Where("tt.ticket_id = ?", ticketID).
+		Where("tt.ticket_id IN (?)", tickets).
+		Order("tag.name ASC").
+		Scan(ctx, &names)
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// ListTagStats retrieves every tag along with the number of tickets using it, most used first.
+func ListTagStats(db *bun.DB, ctx context.Context) ([]Tag, error) {
+	tickets, err := tenantTickets(db, ctx)
+	if err != nil {
+		return nil, err
+	}
+	var tags []Tag
+	err = db.NewSelect().
+		Model(&tags).
+		ColumnExpr("tag.*").
+		ColumnExpr("COUNT(tt.ticket_id) AS usage_count").
+		Join("LEFT JOIN ticket_tags AS tt ON tt.tag_id = tag.id AND tt.ticket_id IN (?)", tickets).
+		Group("tag.id").
+		OrderExpr("usage_count DESC, tag.name ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// SuggestTags retrieves tags starting with prefix, curated suggestions first, then by usage.
+func SuggestTags(db *bun.DB, ctx context.Context, prefix string, limit int) ([]Tag, error) {
+	tickets, err := tenantTickets(db, ctx)
+	if err != nil {
+		return nil, err
+	}
+	var tags []Tag
+	err = db.NewSelect().
+		Model(&tags).
+		ColumnExpr("tag.*").
+		ColumnExpr("COUNT(tt.ticket_id) AS usage_count").
+		Join("LEFT JOIN ticket_tags AS tt ON tt.tag_id = tag.id AND tt.ticket_id IN (?)", tickets).
+		Where("tag.name LIKE ? ESCAPE '!'", likePrefix(NormalizeTagName(prefix))).
+		Group("tag.id").
+		OrderExpr("tag.suggested DESC, usage_count DESC, tag.name ASC").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// CreateSuggestedTag adds a curated tag, or marks an existing tag as suggested.
+func CreateSuggestedTag(db *bun.DB, ctx context.Context, name string) (*Tag, error) {
+	tags, err := GetOrCreateTags(db, ctx, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	tag := &tags[0]
+	tag.Suggested = true
+	_, err = db.NewUpdate().Model(tag).Column("suggested").WherePK().Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// DeleteTag deletes a tag and removes it from every ticket. It returns sql.ErrNoRows if the
+// organization has no such tag.
+func DeleteTag(db *bun.DB, ctx context.Context, tagID int64) error {
+	res, err := db.NewDelete().Model(&Tag{}).Where("id = ?", tagID).Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return cmp.Or(err, sql.ErrNoRows)
+	}
+	return nil
+}
+
+// likePrefix builds a LIKE pattern matching strings that start with prefix, for use with ESCAPE '!'.
+func likePrefix(prefix string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(prefix) + "%"
+}
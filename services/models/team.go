@@ -0,0 +1,101 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Team represents a group of agents that can share saved views.
+type Team struct {
+	bun.BaseModel `bun:"table:teams,alias:team"`
+	ID            int64     `bun:"id,pk,autoincrement,type:integer"`
+	TenantID      int64     `bun:"tenant_id,notnull"` // Organization the team belongs to
+	Name          string    `bun:"name,notnull"`      // Unique within the organization
+	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	Members       []*User   `bun:"-" json:"Members,omitempty"` // This field is not stored in the database
+}
+
+// TeamMember links a user to a team.
+type TeamMember struct {
+	bun.BaseModel `bun:"table:team_members,alias:team_member"`
+	TeamID        int64 `bun:"team_id,pk"`
+	UserID        int64 `bun:"user_id,pk"`
+}
+
+// GetTeamByID retrieves a team and its members from the database by its ID.
+func GetTeamByID(db *bun.DB, ctx context.Context, teamID int64) (*Team, error) {
+	team := new(Team)
+	err := db.NewSelect().Model(team).Where("id = ?", teamID).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.NewSelect().
+		Model(&team.Members).
+		Join("JOIN team_members AS tm ON tm.user_id = ?TableAlias.id").
+		Where("tm.team_id = ?", teamID).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+// ListTeams retrieves all teams from the database.
+func ListTeams(db *bun.DB, ctx context.Context) ([]Team, error) {
+	var teams []Team
+	err := db.NewSelect().Model(&teams).Order("name ASC").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+// CreateTeam inserts a new team into the database.
+func CreateTeam(db *bun.DB, ctx context.Context, team *Team) error {
+	_, err := db.NewInsert().Model(team).Exec(ctx)
+	return constraintError(err, "team")
+}
+
+// DeleteTeam deletes a team and its memberships from the database by its ID.
+func DeleteTeam(db *bun.DB, ctx context.Context, teamID int64) error {
+	_, err := db.NewDelete().Model(&Team{}).Where("id = ?", teamID).Exec(ctx)
+	return err
+}
+
+// AddTeamMember adds a user to a team. Adding an existing member is a no-op.
+func AddTeamMember(db *bun.DB, ctx context.Context, teamID, userID int64) error {
+	member := &TeamMember{TeamID: teamID, UserID: userID}
+	exists, err := db.NewSelect().Model(member).WherePK().Exists(ctx)
+	if err != nil || exists {
+		return err
+	}
+	_, err = db.NewInsert().Model(member).Exec(ctx)
+	return constraintError(err, "team member")
+}
+
+// RemoveTeamMember removes a user from a team of the context's organization.
+func RemoveTeamMember(db *bun.DB, ctx context.Context, teamID, userID int64) error {
+	teams := db.NewSelect().Model((*Team)(nil)).Column("id")
+	if err := scopeSelect(ctx, teams); err != nil {
+		return err
+	}
+	_, err := db.NewDelete().
+		Model(&TeamMember{}).
+		Where("team_id = ? AND user_id = ?", teamID, userID).
+		Where("team_id IN (?)", teams).
+		Exec(ctx)
+	return err
+}
+
+// ListTeamIDsByUserID retrieves the IDs of the teams a user belongs to.
+func ListTeamIDsByUserID(db *bun.DB, ctx context.Context, userID int64) ([]int64, error) {
+	var teamIDs []int64
+	err := db.NewSelect().Model((*TeamMember)(nil)).Column("team_id").Where("user_id = ?", userID).Scan(ctx, &teamIDs)
+	if err != nil {
+		return nil, err
+	}
+	return teamIDs, nil
+}
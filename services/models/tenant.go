@@ -0,0 +1,341 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/uptrace/bun"
+)
+
+// DefaultTenantID is the organization that rows created before organizations existed belong to,
+// and that requests on hostnames of no organization are served from.
+const DefaultTenantID int64 = 1
+
+// ErrNoTenant is returned by queries on tenant-owned tables whose context names no organization.
+// Queries fail rather than run unscoped, so a handler that forgets the request context cannot read
+// or write across organizations.
+var ErrNoTenant = errors.New("no organization in context")
+
+type tenantKey struct{}
+
+// tenantScope is the organization a context is limited to. All is set for the super-admin scope,
+// background jobs and commands, which see every organization.
+type tenantScope struct {
+	ID  int64
+	All bool
+}
+
+// WithTenant returns a context whose queries only see and write rows of the organization.
+func WithTenant(ctx context.Context, tenantID int64) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantScope{ID: tenantID})
+}
+
+// WithAllTenants returns a context whose queries see the rows of every organization. Rows written
+// through it must name their organization themselves.
+func WithAllTenants(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantScope{All: true})
+}
+
+// TenantID returns the organization a context is limited to. It returns 0 for contexts that see
+// every organization and ErrNoTenant for contexts that name none.
+func TenantID(ctx context.Context) (int64, error) {
+	scope, ok := ctx.Value(tenantKey{}).(tenantScope)
+	if !ok || (!scope.All && scope.ID == 0) {
+		return 0, ErrNoTenant
+	}
+	return scope.ID, nil
+}
+
+// tenantCondition returns the WHERE condition limiting a query on a tenant-owned table to the
+// context's organization, or an empty query for contexts that see every organization.
+func tenantCondition(ctx context.Context) (string, []any, error) {
+	tenantID, err := TenantID(ctx)
+	if err != nil || tenantID == 0 {
+		return "", nil, err
+	}
+	return "?TableAlias.tenant_id = ?", []any{tenantID}, nil
+}
+
+// scopeSelect, scopeUpdate and scopeDelete back the query hooks of the tenant-owned models.
+func scopeSelect(ctx context.Context, q *bun.SelectQuery) error {
+	cond, args, err := tenantCondition(ctx)
+	if err == nil && cond != "" {
+		q.Where(cond, args...)
+	}
+	return err
+}
+
+func scopeUpdate(ctx context.Context, q *bun.UpdateQuery) error {
+	cond, args, err := tenantCondition(ctx)
+	if err == nil && cond != "" {
+		q.Where(cond, args...)
+	}
+	return err
+}
+
+func scopeDelete(ctx context.Context, q *bun.DeleteQuery) error {
+	cond, args, err := tenantCondition(ctx)
+	if err == nil && cond != "" {
+		q.Where(cond, args...)
+	}
+	return err
+}
+
+// stampTenant sets the organization of a row about to be inserted or updated by query.
+func stampTenant(ctx context.Context, query bun.Query, tenantID *int64) error {
+	switch query.(type) {
+	case *bun.InsertQuery, *bun.UpdateQuery:
+		return setTenant(ctx, tenantID)
+	}
+	return nil
+}
+
+// setTenant sets the organization of a row about to be written to the context's. Contexts that
+// see every organization keep the row's own, which must be set.
+func setTenant(ctx context.Context, tenantID *int64) error {
+	id, err := TenantID(ctx)
+	if err != nil {
+		return err
+	}
+	if id != 0 {
+		*tenantID = id
+	} else if *tenantID == 0 {
+		return ErrNoTenant
+	}
+	return nil
+}
+
+// countRows counts the rows q selects. bun runs no model hooks for counts, so the tenant scope of
+// the model is applied here, to a copy so that q can still be scanned.
+func countRows(ctx context.Context, q *bun.SelectQuery, model any) (int, error) {
+	q = q.Clone()
+	if hook, ok := model.(bun.BeforeSelectHook); ok {
+		if err := hook.BeforeSelect(ctx, q); err != nil {
+			return 0, err
+		}
+	}
+	return q.Count(ctx)
+}
+
+// tenantTickets selects the IDs of the context's live tickets, to limit queries on the tables that
+// hang off tickets and have no organization of their own, such as tags and custom field values.
+func tenantTickets(db bun.IDB, ctx context.Context) (*bun.SelectQuery, error) {
+	q := db.NewSelect().Model((*Ticket)(nil)).Column("id")
+	return q, scopeSelect(ctx, q)
+}
+
+// sameTenant reports whether a row with a TenantID field may be used from the context, for the
+// stores that do not go through bun.
+func sameTenant(ctx context.Context, row any) (bool, error) {
+	id, err := TenantID(ctx)
+	if err != nil || id == 0 {
+		return err == nil, err
+	}
+	return reflect.Indirect(reflect.ValueOf(row)).FieldByName("TenantID").Int() == id, nil
+}
+
+var (
+	_ bun.BeforeSelectHook      = (*User)(nil)
+	_ bun.BeforeUpdateHook      = (*User)(nil)
+	_ bun.BeforeDeleteHook      = (*User)(nil)
+	_ bun.BeforeAppendModelHook = (*User)(nil)
+)
+
+func (*User) BeforeSelect(ctx context.Context, q *bun.SelectQuery) error {
+	return scopeSelect(ctx, q)
+}
+
+func (*User) BeforeUpdate(ctx context.Context, q *bun.UpdateQuery) error {
+	return scopeUpdate(ctx, q)
+}
+
+func (*User) BeforeDelete(ctx context.Context, q *bun.DeleteQuery) error {
+	return scopeDelete(ctx, q)
+}
+
+func (u *User) BeforeAppendModel(ctx context.Context, q bun.Query) error {
+	return stampTenant(ctx, q, &u.TenantID)
+}
+
+func (*Ticket) BeforeSelect(ctx context.Context, q *bun.SelectQuery) error {
+	return scopeSelect(ctx, q)
+}
+
+func (*Ticket) BeforeUpdate(ctx context.Context, q *bun.UpdateQuery) error {
+	return scopeUpdate(ctx, q)
+}
+
+func (*Ticket) BeforeDelete(ctx context.Context, q *bun.DeleteQuery) error {
+	return scopeDelete(ctx, q)
+}
+
+func (t *Ticket) BeforeAppendModel(ctx context.Context, q bun.Query) error {
+	return stampTenant(ctx, q, &t.TenantID)
+}
+
+func (*Comment) BeforeSelect(ctx context.Context, q *bun.SelectQuery) error {
+	return scopeSelect(ctx, q)
+}
+
+func (*Comment) BeforeUpdate(ctx context.Context, q *bun.UpdateQuery) error {
+	return scopeUpdate(ctx, q)
+}
+
+func (*Comment) BeforeDelete(ctx context.Context, q *bun.DeleteQuery) error {
+	return scopeDelete(ctx, q)
+}
+
+func (c *Comment) BeforeAppendModel(ctx context.Context, q bun.Query) error {
+	return stampTenant(ctx, q, &c.TenantID)
+}
+
+func (*Company) BeforeSelect(ctx context.Context, q *bun.SelectQuery) error {
+	return scopeSelect(ctx, q)
+}
+
+func (*Company) BeforeUpdate(ctx context.Context, q *bun.UpdateQuery) error {
+	return scopeUpdate(ctx, q)
+}
+
+func (*Company) BeforeDelete(ctx context.Context, q *bun.DeleteQuery) error {
+	return scopeDelete(ctx, q)
+}
+
+func (c *Company) BeforeAppendModel(ctx context.Context, q bun.Query) error {
+	return stampTenant(ctx, q, &c.TenantID)
+}
+
+func (*Macro) BeforeSelect(ctx context.Context, q *bun.SelectQuery) error {
+	return scopeSelect(ctx, q)
+}
+
+func (*Macro) BeforeUpdate(ctx context.Context, q *bun.UpdateQuery) error {
+	return scopeUpdate(ctx, q)
+}
+
+func (*Macro) BeforeDelete(ctx context.Context, q *bun.DeleteQuery) error {
+	return scopeDelete(ctx, q)
+}
+
+func (m *Macro) BeforeAppendModel(ctx context.Context, q bun.Query) error {
+	return stampTenant(ctx, q, &m.TenantID)
+}
+
+func (*Trigger) BeforeSelect(ctx context.Context, q *bun.SelectQuery) error {
+	return scopeSelect(ctx, q)
+}
+
+func (*Trigger) BeforeUpdate(ctx context.Context, q *bun.UpdateQuery) error {
+	return scopeUpdate(ctx, q)
+}
+
+func (*Trigger) BeforeDelete(ctx context.Context, q *bun.DeleteQuery) error {
+	return scopeDelete(ctx, q)
+}
+
+func (t *Trigger) BeforeAppendModel(ctx context.Context, q bun.Query) error {
+	return stampTenant(ctx, q, &t.TenantID)
+}
+
+func (*TriggerRun) BeforeSelect(ctx context.Context, q *bun.SelectQuery) error {
+	return scopeSelect(ctx, q)
+}
+
+func (*TriggerRun) BeforeUpdate(ctx context.Context, q *bun.UpdateQuery) error {
+	return scopeUpdate(ctx, q)
+}
+
+func (*TriggerRun) BeforeDelete(ctx context.Context, q *bun.DeleteQuery) error {
+	return scopeDelete(ctx, q)
+}
+
+func (r *TriggerRun) BeforeAppendModel(ctx context.Context, q bun.Query) error {
+	return stampTenant(ctx, q, &r.TenantID)
+}
+
+func (*Team) BeforeSelect(ctx context.Context, q *bun.SelectQuery) error {
+	return scopeSelect(ctx, q)
+}
+
+func (*Team) BeforeUpdate(ctx context.Context, q *bun.UpdateQuery) error {
+	return scopeUpdate(ctx, q)
+}
+
+func (*Team) BeforeDelete(ctx context.Context, q *bun.DeleteQuery) error {
+	return scopeDelete(ctx, q)
+}
+
+func (t *Team) BeforeAppendModel(ctx context.Context, q bun.Query) error {
+	return stampTenant(ctx, q, &t.TenantID)
+}
+
+func (*View) BeforeSelect(ctx context.Context, q *bun.SelectQuery) error {
+	return scopeSelect(ctx, q)
+}
+
+func (*View) BeforeUpdate(ctx context.Context, q *bun.UpdateQuery) error {
+	return scopeUpdate(ctx, q)
+}
+
+func (*View) BeforeDelete(ctx context.Context, q *bun.DeleteQuery) error {
+	return scopeDelete(ctx, q)
+}
+
+func (v *View) BeforeAppendModel(ctx context.Context, q bun.Query) error {
+	return stampTenant(ctx, q, &v.TenantID)
+}
+
+func (*Tag) BeforeSelect(ctx context.Context, q *bun.SelectQuery) error {
+	return scopeSelect(ctx, q)
+}
+
+func (*Tag) BeforeUpdate(ctx context.Context, q *bun.UpdateQuery) error {
+	return scopeUpdate(ctx, q)
+}
+
+func (*Tag) BeforeDelete(ctx context.Context, q *bun.DeleteQuery) error {
+	return scopeDelete(ctx, q)
+}
+
+func (t *Tag) BeforeAppendModel(ctx context.Context, q bun.Query) error {
+	return stampTenant(ctx, q, &t.TenantID)
+}
+
+func (*Category) BeforeSelect(ctx context.Context, q *bun.SelectQuery) error {
+	return scopeSelect(ctx, q)
+}
+
+func (*Category) BeforeUpdate(ctx context.Context, q *bun.UpdateQuery) error {
+	return scopeUpdate(ctx, q)
+}
+
+func (*Category) BeforeDelete(ctx context.Context, q *bun.DeleteQuery) error {
+	return scopeDelete(ctx, q)
+}
+
+func (c *Category) BeforeAppendModel(ctx context.Context, q bun.Query) error {
+	return stampTenant(ctx, q, &c.TenantID)
+}
+
+func (*CustomField) BeforeSelect(ctx context.Context, q *bun.SelectQuery) error {
+	return scopeSelect(ctx, q)
+}
+
+func (*CustomField) BeforeUpdate(ctx context.Context, q *bun.UpdateQuery) error {
+	return scopeUpdate(ctx, q)
+}
+
+func (*CustomField) BeforeDelete(ctx context.Context, q *bun.DeleteQuery) error {
+	return scopeDelete(ctx, q)
+}
+
+func (f *CustomField) BeforeAppendModel(ctx context.Context, q bun.Query) error {
+	return stampTenant(ctx, q, &f.TenantID)
+}
+
+// BeforeSelect limits audit entries to the context's organization. Entries are only ever inserted,
+// by CreateAuditEntry, which sets their organization.
+func (*AuditEntry) BeforeSelect(ctx context.Context, q *bun.SelectQuery) error {
+	return scopeSelect(ctx, q)
+}
@@ -3,30 +3,67 @@ package models
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
 	"github.com/uptrace/bun"
+
+	"goat/services/markdown"
 )
 
 // Ticket represents the Ticket model in the database.
 type Ticket struct {
 	bun.BaseModel `bun:"table:tickets,alias:ticket"`
-	ID            int64         `bun:"id,pk,autoincrement,type:integer"`
-	Title         string        `bun:"title,notnull"`
-	Description   string        `bun:"description"`
-	Status        string        `bun:"status,notnull,default:'Open'"`
-	Priority      string        `bun:"priority,notnull,default:'Medium'"`
-	RequesterID   int64         `bun:"requester_id,notnull"`
-	AssigneeID    sql.NullInt64 `bun:"assignee_id"` // Use sql.NullInt64 for nullable foreign key
-	CreatedAt     time.Time     `bun:"created_at,notnull,default:current_timestamp" json:"CreatedAt"`
-	UpdatedAt     time.Time     `bun:"updated_at,notnull,default:current_timestamp" json:"UpdatedAt"`
-	ClosedAt      sql.NullTime  `bun:"closed_at" json:"ClosedAt"`   // Use sql.NullTime for nullable timestamp
-	Comments      []Comment     `bun:"-" json:"Comments,omitempty"` // This field is not stored in the database
+	ID            int64          `bun:"id,pk,autoincrement,type:integer"`
+	TenantID      int64          `bun:"tenant_id,notnull"` // Organization the ticket belongs to
+	Title         string         `bun:"title,notnull"`
+	Type          string         `bun:"type,notnull,default:'Question'"`
+	Description   string         `bun:"description"`
+	Status        string         `bun:"status,notnull,default:'Open'"`
+	Priority      string         `bun:"priority,notnull,default:'Medium'"`
+	RequesterID   int64          `bun:"requester_id,notnull"`
+	AssigneeID    sql.NullInt64  `bun:"assignee_id"` // Use sql.NullInt64 for nullable foreign key
+	CategoryID    sql.NullInt64  `bun:"category_id"`
+	CreatedAt     time.Time      `bun:"created_at,notnull,default:current_timestamp" json:"CreatedAt"`
+	UpdatedAt     time.Time      `bun:"updated_at,notnull,default:current_timestamp" json:"UpdatedAt"`
+	ClosedAt      sql.NullTime   `bun:"closed_at" json:"ClosedAt"` // Use sql.NullTime for nullable timestamp
+	Version       int64          `bun:"version,notnull,default:1" json:"Version"`
+	DeletedAt     *time.Time     `bun:"deleted_at,soft_delete,nullzero" json:"DeletedAt,omitempty"` // Set while the ticket is in the trash
+	Comments      []Comment      `bun:"-" json:"Comments,omitempty"`                                // This field is not stored in the database
+	Tags          []string       `bun:"-" json:"Tags,omitempty"`                                    // This field is not stored in the database
+	Fields        map[string]any `bun:"-" json:"Fields,omitempty"`                                  // Custom field values keyed by field key
+	Links         []LinkedTicket `bun:"-" json:"Links,omitempty"`                                   // This field is not stored in the database
+	Children      *ChildRollup   `bun:"-" json:"Children,omitempty"`                                // Status of child tickets, only set on parents
+	Watchers      []int64        `bun:"-" json:"Watchers,omitempty"`                                // IDs of the agents and admins watching the ticket
+	CCs           []TicketCC     `bun:"-" json:"CCs,omitempty"`                                     // Customers and contacts copied on the ticket
+}
+
+// MarshalJSON adds DescriptionHTML, the description's Markdown rendered to sanitized HTML, next to
+// the raw Description.
+func (t Ticket) MarshalJSON() ([]byte, error) {
+	type ticket Ticket
+	return json.Marshal(struct {
+		ticket
+		DescriptionHTML string `json:"DescriptionHTML"`
+	}{ticket(t), markdown.Render(t.Description)})
 }
 
-// GetTicketByID retrieves a ticket from the database by its ID and also fetches related comments.
+// TicketTypes lists the kinds of ticket. Custom fields can be required for specific types.
+var TicketTypes = []string{"Question", "Incident", "Problem", "Task"}
+
+// TicketStatuses lists the ticket statuses.
+var TicketStatuses = []string{"Open", "Pending", "Closed"}
+
+// TicketPriorities lists the ticket priorities from lowest to highest.
+var TicketPriorities = []string{"Low", "Medium", "High", "Urgent"}
+
+// ErrVersionConflict is returned by UpdateTicket when the ticket was modified after it was read.
+var ErrVersionConflict = errors.New("ticket has been modified since it was last read")
+
+// GetTicketByID retrieves a ticket from the database by its ID and also fetches related comments,
+// tags, custom fields and links.
 func GetTicketByID(db *bun.DB, ctx context.Context, ticketID int64) (*Ticket, error) {
 	ticket := new(Ticket)
 	err := db.NewSelect().Model(ticket).Where("id = ?", ticketID).Scan(ctx)
@@ -41,32 +78,82 @@ func GetTicketByID(db *bun.DB, ctx context.Context, ticketID int64) (*Ticket, er
 	}
 	ticket.Comments = comments
 
+	tags, err := ListTagNamesByTicketID(db, ctx, ticketID)
+	if err != nil {
+		fmt.Printf("Error fetching tags for ticket %d: %v\n", ticketID, err)
+	}
+	ticket.Tags = tags
+
+	if err := LoadTicketFields(db, ctx, ticket); err != nil {
+		fmt.Printf("Error fetching custom fields for ticket %d: %v\n", ticketID, err)
+	}
+
+	links, err := ListTicketLinks(db, ctx, ticketID)
+	if err != nil {
+		fmt.Printf("Error fetching links for ticket %d: %v\n", ticketID, err)
+	}
+	ticket.Links = links
+
+	children, err := GetChildRollup(db, ctx, ticketID)
+	if err != nil {
+		fmt.Printf("Error fetching child tickets for ticket %d: %v\n", ticketID, err)
+	}
+	ticket.Children = children
+
+	watchers, err := ListTicketWatcherIDs(db, ctx, ticketID)
+	if err != nil {
+		fmt.Printf("Error fetching watchers for ticket %d: %v\n", ticketID, err)
+	}
+	ticket.Watchers = watchers
+
+	ccs, err := ListTicketCCs(db, ctx, ticketID)
+	if err != nil {
+		fmt.Printf("Error fetching CCs for ticket %d: %v\n", ticketID, err)
+	}
+	ticket.CCs = ccs
+
 	return ticket, nil
 }
 
-// ListTickets retrieves all tickets from the database.
-func ListTickets(db *bun.DB, ctx context.Context) ([]Ticket, error) {
-	var tickets []Ticket
-	err := db.NewSelect().Model(&tickets).Scan(ctx)
+// ticketSortColumns lists the ticket columns lists can be sorted by, including any custom field.
+var ticketSortColumns = []string{"title", "type", "status", "priority", "requester_id", "created_at", "updated_at", CustomFieldPrefix}
+
+// paginateTickets runs a ticket select one page at a time and loads the custom fields of each page.
+func paginateTickets(ctx context.Context, q *bun.SelectQuery, page PageRequest) (*Page[Ticket], error) {
+	result, err := paginate[Ticket](ctx, q, page, ticketSortColumns...)
 	if err != nil {
 		return nil, err
 	}
-	return tickets, nil
+
+	tickets := make([]*Ticket, len(result.Items))
+	for i := range result.Items {
+		tickets[i] = &result.Items[i]
+	}
+	if err := LoadTicketFields(q.DB(), ctx, tickets...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListTickets retrieves a page of tickets from the database, narrowed by any filters.
+func ListTickets(db *bun.DB, ctx context.Context, page PageRequest, filters ...func(*bun.SelectQuery) *bun.SelectQuery) (*Page[Ticket], error) {
+	q := db.NewSelect().Model((*Ticket)(nil)).Apply(filters...)
+	return paginateTickets(ctx, q, page)
 }
 
 // CreateTicket inserts a new ticket into the database.
-func CreateTicket(db *bun.DB, ctx context.Context, ticket *Ticket) error {
-	_, err := db.NewInsert().Model(ticket).Exec(ctx)
-	if err != nil {
-		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
-			return fmt.Errorf("duplicate entry for ticket: %w", err)
-		}
-		return err
+func CreateTicket(db bun.IDB, ctx context.Context, ticket *Ticket) error {
+	ticket.Version = 1
+	if ticket.Type == "" {
+		ticket.Type = TicketTypes[0]
 	}
-	return nil
+	_, err := db.NewInsert().Model(ticket).Exec(ctx)
+	return constraintError(err, "ticket")
 }
 
 // UpdateTicket updates an existing ticket in the database.
+// ticket.Version must hold the version the caller read; the update only applies if the
+// stored version still matches, otherwise ErrVersionConflict is returned.
 func UpdateTicket(db *bun.DB, ctx context.Context, ticket *Ticket) error {
 
 	// Preserve the original CreatedAt time.
@@ -83,46 +170,82 @@ func UpdateTicket(db *bun.DB, ctx context.Context, ticket *Ticket) error {
 		ticket.ClosedAt = sql.NullTime{Valid: false}
 	}
 
-	_, err = db.NewUpdate().
+	expectedVersion := ticket.Version
+	ticket.Version = expectedVersion + 1
+
+	res, err := db.NewUpdate().
 		Model(ticket).
-		Column("status", "priority", "assignee_id", "updated_at", "closed_at").
+		Column("type", "status", "priority", "assignee_id", "category_id", "updated_at", "closed_at", "version").
 		Where("id = ?", ticket.ID).
+		Where("version = ?", expectedVersion).
 		Exec(ctx)
-	return err
+	if err != nil {
+		ticket.Version = expectedVersion
+		return constraintError(err, "ticket")
+	}
+
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		ticket.Version = expectedVersion
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// updateTicketColumns saves the given columns of a ticket along with its update and close times,
+// for writes that run inside a transaction. ticket.Version must hold the version the caller read;
+// the ticket is only saved if the stored version still matches, otherwise ErrVersionConflict is
+// returned. On success ticket.Version is the new version.
+func updateTicketColumns(db bun.IDB, ctx context.Context, ticket *Ticket, columns ...string) error {
+	now := time.Now()
+	if ticket.Status != "Closed" {
+		ticket.ClosedAt = sql.NullTime{}
+	} else if !ticket.ClosedAt.Valid {
+		ticket.ClosedAt = sql.NullTime{Time: now, Valid: true}
+	}
+	ticket.UpdatedAt = now
+
+	res, err := db.NewUpdate().
+		Model(ticket).
+		Column(append(columns, "updated_at", "closed_at")...).
+		Set("version = version + 1").
+		Where("id = ?", ticket.ID).
+		Where("version = ?", ticket.Version).
+		Exec(ctx)
+	if err != nil {
+		return constraintError(err, "ticket")
+	}
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return ErrVersionConflict
+	}
+	ticket.Version++
+	return nil
 }
 
-// DeleteTicket deletes a ticket from the database by its ID.
+// DeleteTicket moves a ticket to the trash by its ID. PurgeDeleted removes it for good.
 func DeleteTicket(db *bun.DB, ctx context.Context, ticketID int64) error {
 	_, err := db.NewDelete().Model(&Ticket{}).Where("id = ?", ticketID).Exec(ctx)
 	return err
 }
 
-// ListTicketsByAssigneeID retrieves all tickets from the database assigned to a specific user.
-func ListTicketsByAssigneeID(db *bun.DB, ctx context.Context, assigneeID int64) ([]Ticket, error) {
-	var tickets []Ticket
-	err := db.NewSelect().Model(&tickets).Where("assignee_id = ?", assigneeID).Scan(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return tickets, nil
+// ListTicketsByAssigneeID retrieves a page of tickets from the database assigned to a specific user.
+func ListTicketsByAssigneeID(db *bun.DB, ctx context.Context, assigneeID int64, page PageRequest, filters ...func(*bun.SelectQuery) *bun.SelectQuery) (*Page[Ticket], error) {
+	q := db.NewSelect().Model((*Ticket)(nil)).Where("ticket.assignee_id = ?", assigneeID).Apply(filters...)
+	return paginateTickets(ctx, q, page)
 }
 
-// ListTicketsByRequesterID retrieves all tickets from the database requested by a specific user.
-func ListTicketsByRequesterID(db *bun.DB, ctx context.Context, requesterID int64) ([]Ticket, error) {
-	var tickets []Ticket
-	err := db.NewSelect().Model(&tickets).Where("requester_id = ?", requesterID).Scan(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return tickets, nil
+// ListTicketsByRequesterID retrieves a page of tickets from the database requested by a specific user.
+func ListTicketsByRequesterID(db *bun.DB, ctx context.Context, requesterID int64, page PageRequest, filters ...func(*bun.SelectQuery) *bun.SelectQuery) (*Page[Ticket], error) {
+	q := db.NewSelect().Model((*Ticket)(nil)).Where("ticket.requester_id = ?", requesterID).Apply(filters...)
+	return paginateTickets(ctx, q, page)
 }
 
-// ListOpenTickets retrieves all tickets with status 'Open' from the database.
-func ListOpenTickets(db *bun.DB, ctx context.Context) ([]Ticket, error) {
-	var tickets []Ticket
-	err := db.NewSelect().Model(&tickets).Where("status = ?", "Open").Scan(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return tickets, nil
+// ListOpenTickets retrieves a page of tickets with status 'Open' from the database.
+func ListOpenTickets(db *bun.DB, ctx context.Context, page PageRequest, filters ...func(*bun.SelectQuery) *bun.SelectQuery) (*Page[Ticket], error) {
+	q := db.NewSelect().Model((*Ticket)(nil)).Where("ticket.status = ?", "Open").Apply(filters...)
+	return paginateTickets(ctx, q, page)
+}
+
+// CountTickets counts the tickets matching all filters.
+func CountTickets(db *bun.DB, ctx context.Context, filters ...func(*bun.SelectQuery) *bun.SelectQuery) (int, error) {
+	return countRows(ctx, db.NewSelect().Model((*Ticket)(nil)).Apply(filters...), (*Ticket)(nil))
 }
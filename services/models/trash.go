@@ -0,0 +1,162 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// PurgeResult lists the rows removed for good by PurgeDeleted.
+type PurgeResult struct {
+	UserIDs    []int64
+	TicketIDs  []int64
+	CommentIDs []int64
+}
+
+// ListDeletedUsers retrieves a page of users in the trash, most recently deleted first by default.
+func ListDeletedUsers(db *bun.DB, ctx context.Context, page PageRequest) (*Page[*User], error) {
+	q := db.NewSelect().Model((*User)(nil)).WhereDeleted()
+	return paginate[*User](ctx, q, defaultDeletedSort(page), slices.Concat(userSortColumns, []string{"deleted_at"})...)
+}
+
+// ListDeletedTickets retrieves a page of tickets in the trash, most recently deleted first by default.
+func ListDeletedTickets(db *bun.DB, ctx context.Context, page PageRequest) (*Page[Ticket], error) {
+	q := db.NewSelect().Model((*Ticket)(nil)).WhereDeleted()
+	return paginate[Ticket](ctx, q, defaultDeletedSort(page), "status", "priority", "created_at", "updated_at", "deleted_at")
+}
+
+// ListDeletedComments retrieves a page of comments in the trash, most recently deleted first by default.
+func ListDeletedComments(db *bun.DB, ctx context.Context, page PageRequest) (*Page[Comment], error) {
+	q := db.NewSelect().Model((*Comment)(nil)).WhereDeleted()
+	return paginate[Comment](ctx, q, defaultDeletedSort(page), slices.Concat(commentSortColumns, []string{"deleted_at"})...)
+}
+
+func defaultDeletedSort(page PageRequest) PageRequest {
+	if len(page.Sort) == 0 {
+		page.Sort = []SortKey{{Column: "deleted_at", Desc: true}}
+	}
+	return page
+}
+
+// RestoreUser takes a user out of the trash and reports whether it was there.
+func RestoreUser(db *bun.DB, ctx context.Context, userID int64) (bool, error) {
+	return restore(db, ctx, (*User)(nil), userID)
+}
+
+// RestoreTicket takes a ticket out of the trash and reports whether it was there.
+func RestoreTicket(db *bun.DB, ctx context.Context, ticketID int64) (bool, error) {
+	return restore(db, ctx, (*Ticket)(nil), ticketID)
+}
+
+// RestoreComment takes a comment out of the trash and reports whether it was there.
+func RestoreComment(db *bun.DB, ctx context.Context, commentID int64) (bool, error) {
+	return restore(db, ctx, (*Comment)(nil), commentID)
+}
+
+func restore(db *bun.DB, ctx context.Context, model any, id int64) (bool, error) {
+	res, err := db.NewUpdate().
+		Model(model).
+		WhereDeleted().
+		Set("deleted_at = NULL").
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+// PurgeDeleted permanently deletes users, tickets and comments that were moved to the trash before
+// the cutoff. Deleting a ticket also removes its comments, links, tags and field values. A user is
+// kept while any ticket, comment or field value still refers to them, because the foreign keys would
+// otherwise delete or rewrite that history along with the user. Each removal is audited.
+func PurgeDeleted(db *bun.DB, ctx context.Context, cutoff time.Time) (*PurgeResult, error) {
+	result := new(PurgeResult)
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		result.CommentIDs, err = purge(tx, ctx, (*Comment)(nil), cutoff, nil)
+		if err != nil {
+			return err
+		}
+		result.TicketIDs, err = purge(tx, ctx, (*Ticket)(nil), cutoff, nil)
+		if err != nil {
+			return err
+		}
+		result.UserIDs, err = purge(tx, ctx, (*User)(nil), cutoff, func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.
+				Where("NOT EXISTS (SELECT 1 FROM tickets AS t WHERE t.requester_id = ?TableAlias.id OR t.assignee_id = ?TableAlias.id)").
+				Where("NOT EXISTS (SELECT 1 FROM comments AS c WHERE c.author_id = ?TableAlias.id)").
+				Where("NOT EXISTS (SELECT 1 FROM ticket_field_values AS fv WHERE fv.user_id = ?TableAlias.id)").
+				Where("NOT EXISTS (SELECT 1 FROM comment_revisions AS cr WHERE cr.editor_id = ?TableAlias.id)")
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, purged := range []struct {
+		entityType string
+		ids        []int64
+	}{{"comment", result.CommentIDs}, {"ticket", result.TicketIDs}, {"user", result.UserIDs}} {
+		for _, id := range purged.ids {
+			entry := AuditEntry{Action: AuditPurge, EntityType: purged.entityType, EntityID: id}
+			if err := CreateAuditEntry(db, ctx, &entry); err != nil {
+				fmt.Printf("Error recording audit entry for %s %d: %v\n", purged.entityType, id, err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// purge permanently deletes the rows of model deleted before the cutoff and returns their IDs.
+func purge(tx bun.Tx, ctx context.Context, model any, cutoff time.Time, filter func(*bun.SelectQuery) *bun.SelectQuery) ([]int64, error) {
+	ids := []int64{}
+	q := tx.NewSelect().
+		Model(model).
+		Column("id").
+		WhereDeleted().
+		Where("?TableAlias.deleted_at < ?", cutoff)
+	if filter != nil {
+		q = q.Apply(filter)
+	}
+	if err := q.Scan(ctx, &ids); err != nil || len(ids) == 0 {
+		return ids, err
+	}
+
+	_, err := tx.NewDelete().
+		Model(model).
+		WhereDeleted().
+		Where("id IN (?)", bun.In(ids)).
+		ForceDelete().
+		Exec(ctx)
+	return ids, err
+}
+
+// RunPurgeDeleted purges rows that have been in the trash longer than retention, once at start and
+// then every hour until ctx is done. Failures are logged and retried at the next tick. Every
+// organization's trash is purged, and copies kept by tickets are told about the purged rows.
+func RunPurgeDeleted(ctx context.Context, db *bun.DB, tickets TicketRepository, retention time.Duration) {
+	ctx = WithAllTenants(ctx)
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		result, err := PurgeDeleted(db, ctx, time.Now().Add(-retention))
+		if err != nil {
+			fmt.Printf("Error purging deleted rows: %v\n", err)
+		} else if len(result.UserIDs)+len(result.TicketIDs)+len(result.CommentIDs) > 0 {
+			SyncChanges(tickets, ctx, RowChanges(*result))
+			fmt.Printf("Purged %d users, %d tickets and %d comments from the trash\n", len(result.UserIDs), len(result.TicketIDs), len(result.CommentIDs))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
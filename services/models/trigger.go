@@ -0,0 +1,268 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Trigger events.
+const (
+	EventTicketCreated  = "ticket.created"
+	EventTicketUpdated  = "ticket.updated"
+	EventCommentCreated = "comment.created"
+)
+
+// TriggerEvents lists the events triggers can react to.
+var TriggerEvents = []string{EventTicketCreated, EventTicketUpdated, EventCommentCreated}
+
+// Trigger is an admin-defined rule: when an event happens to a ticket and the conditions hold, the
+// actions change the ticket. Triggers belong to an organization and are evaluated in order of
+// Position, then ID.
+type Trigger struct {
+	bun.BaseModel `bun:"table:triggers,alias:ticket_trigger"`
+	ID            int64             `bun:"id,pk,autoincrement,type:integer"`
+	TenantID      int64             `bun:"tenant_id,notnull"` // Organization the trigger belongs to
+	Name          string            `bun:"name,notnull"`
+	Event         string            `bun:"event,notnull"`
+	Conditions    TriggerConditions `bun:"conditions,type:json"`
+	Actions       []TriggerAction   `bun:"actions,type:json"`
+	Position      int               `bun:"position,notnull"`
+	Active        bool              `bun:"active,notnull"`
+	CreatedAt     time.Time         `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+// TriggerConditions decide whether a trigger fires: every condition in All must hold and, unless
+// Any is empty, at least one in Any.
+type TriggerConditions struct {
+	All []TriggerCondition `json:"all,omitempty"`
+	Any []TriggerCondition `json:"any,omitempty"`
+}
+
+// TriggerCondition compares a field of the event with a value, e.g. title contains "refund".
+type TriggerCondition struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator"`
+	Value    string `json:"value,omitempty"`
+}
+
+// TriggerAction is one change a trigger makes, e.g. set_priority High.
+type TriggerAction struct {
+	Type  string `json:"type"`
+	Value string `json:"value,omitempty"`
+}
+
+// TriggerRun records a trigger firing on a ticket, with the actions it executed.
+type TriggerRun struct {
+	bun.BaseModel `bun:"table:trigger_runs,alias:trigger_run"`
+	ID            int64           `bun:"id,pk,autoincrement,type:integer"`
+	TenantID      int64           `bun:"tenant_id,notnull"`
+	TriggerID     int64           `bun:"trigger_id,notnull"`
+	TicketID      int64           `bun:"ticket_id,notnull"`
+	Event         string          `bun:"event,notnull"`
+	ActorID       sql.NullInt64   `bun:"actor_id"` // User whose request fired the trigger
+	Actions       []TriggerAction `bun:"actions,type:json"`
+	Error         string          `bun:"error_message,nullzero" json:"Error,omitempty"` // Why the actions could not be saved
+	CreatedAt     time.Time       `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+// TriggerRunFilter narrows a query on the trigger log. Zero values match everything.
+type TriggerRunFilter struct {
+	TriggerID int64
+	TicketID  int64
+	Failed    bool // Only runs whose actions could not be saved
+}
+
+// Trigger condition operators.
+const (
+	OpIs          = "is"
+	OpIsNot       = "is_not"
+	OpContains    = "contains"
+	OpNotContains = "not_contains"
+	OpGreaterThan = "greater_than"
+	OpLessThan    = "less_than"
+	OpChanged     = "changed" // The field differs from before the update
+)
+
+// Trigger action types. Their values are noted alongside.
+const (
+	ActionSetStatus   = "set_status"   // Status
+	ActionSetPriority = "set_priority" // One of TicketPriorities
+	ActionSetType     = "set_type"     // One of TicketTypes
+	ActionSetCategory = "set_category" // Category ID, or "none"
+	ActionAssignUser  = "assign_user"  // User ID
+	ActionAssignTeam  = "assign_team"  // Team ID; the member with the fewest open tickets gets the ticket
+	ActionUnassign    = "unassign"     // No value
+	ActionAddTag      = "add_tag"      // Tag name
+	ActionRemoveTag   = "remove_tag"   // Tag name
+	ActionAddNote     = "add_note"     // Text of an internal note
+)
+
+// triggerFields lists the fields conditions can test and the events each one is set for; nil
+// means every event.
+var triggerFields = map[string][]string{
+	"title":               nil,
+	"description":         nil,
+	"status":              nil,
+	"priority":            nil,
+	"type":                nil,
+	"category":            nil, // Category ID or "none"
+	"assignee":            nil, // User ID or "none"
+	"requester":           nil, // User ID
+	"tag":                 nil, // Matches when any of the ticket's tags matches
+	"actor":               nil, // ID of the user whose request caused the event
+	"actor.role":          nil, // Admin, Agent or Customer
+	"comment.body":        {EventCommentCreated},
+	"comment.is_internal": {EventCommentCreated}, // "true" or "false"
+}
+
+// ticketTriggerFields lists the fields that belong to the ticket and can therefore change.
+var ticketTriggerFields = []string{"title", "description", "status", "priority", "type", "category", "assignee", "requester", "tag"}
+
+// CheckTrigger reports the first problem with a trigger's event, conditions or actions.
+func CheckTrigger(t *Trigger) error {
+	if !slices.Contains(TriggerEvents, t.Event) {
+		return fmt.Errorf("unknown event %q", t.Event)
+	}
+	for _, c := range slices.Concat(t.Conditions.All, t.Conditions.Any) {
+		if err := checkTriggerCondition(t.Event, c); err != nil {
+			return err
+		}
+	}
+	if len(t.Actions) == 0 {
+		return fmt.Errorf("a trigger needs at least one action")
+	}
+	for _, a := range t.Actions {
+		if err := checkTriggerAction(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkTriggerCondition(event string, c TriggerCondition) error {
+	events, ok := triggerFields[c.Field]
+	if !ok {
+		return fmt.Errorf("unknown condition field %q", c.Field)
+	}
+	if events != nil && !slices.Contains(events, event) {
+		return fmt.Errorf("field %q is not set for %s events", c.Field, event)
+	}
+	switch c.Operator {
+	case OpIs, OpIsNot, OpContains, OpNotContains:
+	case OpGreaterThan, OpLessThan:
+		if c.Field != "priority" {
+			return fmt.Errorf("operator %q only applies to priority", c.Operator)
+		}
+		if !slices.Contains(TicketPriorities, c.Value) {
+			return fmt.Errorf("unknown priority %q", c.Value)
+		}
+	case OpChanged:
+		if event != EventTicketUpdated || !slices.Contains(ticketTriggerFields, c.Field) {
+			return fmt.Errorf("operator %q only applies to ticket fields of %s events", c.Operator, EventTicketUpdated)
+		}
+	default:
+		return fmt.Errorf("unknown condition operator %q", c.Operator)
+	}
+	return nil
+}
+
+func checkTriggerAction(a TriggerAction) error {
+	switch a.Type {
+	case ActionSetStatus, ActionAddTag, ActionRemoveTag, ActionAddNote:
+		if a.Value == "" {
+			return fmt.Errorf("action %q needs a value", a.Type)
+		}
+	case ActionSetPriority:
+		if !slices.Contains(TicketPriorities, a.Value) {
+			return fmt.Errorf("unknown priority %q", a.Value)
+		}
+	case ActionSetType:
+		if !slices.Contains(TicketTypes, a.Value) {
+			return fmt.Errorf("unknown ticket type %q", a.Value)
+		}
+	case ActionSetCategory:
+		if a.Value == "none" {
+			return nil
+		}
+		fallthrough
+	case ActionAssignUser, ActionAssignTeam:
+		if _, err := strconv.ParseInt(a.Value, 10, 64); err != nil {
+			return fmt.Errorf("action %q needs an ID, not %q", a.Type, a.Value)
+		}
+	case ActionUnassign:
+	default:
+		return fmt.Errorf("unknown action %q", a.Type)
+	}
+	return nil
+}
+
+// GetTriggerByID retrieves a trigger from the database by its ID.
+func GetTriggerByID(db bun.IDB, ctx context.Context, triggerID int64) (*Trigger, error) {
+	trigger := new(Trigger)
+	err := db.NewSelect().Model(trigger).Where("id = ?", triggerID).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return trigger, nil
+}
+
+// ListTriggers retrieves the triggers in the order they are evaluated, only the active ones for
+// event unless event is empty.
+func ListTriggers(db bun.IDB, ctx context.Context, event string) ([]Trigger, error) {
+	triggers := []Trigger{}
+	q := db.NewSelect().Model(&triggers)
+	if event != "" {
+		q = q.Where("event = ?", event).Where("active = ?", true)
+	}
+	err := q.Order("position ASC", "id ASC").Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return triggers, nil
+}
+
+// CreateTrigger inserts a new trigger into the database.
+func CreateTrigger(db bun.IDB, ctx context.Context, trigger *Trigger) error {
+	_, err := db.NewInsert().Model(trigger).Exec(ctx)
+	return constraintError(err, "trigger")
+}
+
+// UpdateTrigger saves every field of a trigger but its creation time.
+func UpdateTrigger(db bun.IDB, ctx context.Context, trigger *Trigger) error {
+	_, err := db.NewUpdate().
+		Model(trigger).
+		Column("name", "event", "conditions", "actions", "position", "active").
+		WherePK().
+		Exec(ctx)
+	return constraintError(err, "trigger")
+}
+
+// DeleteTrigger deletes a trigger and its log from the database by its ID.
+func DeleteTrigger(db bun.IDB, ctx context.Context, triggerID int64) error {
+	_, err := db.NewDelete().Model(&Trigger{}).Where("id = ?", triggerID).Exec(ctx)
+	return err
+}
+
+// ListTriggerRuns retrieves a page of the trigger log matching the filter, newest first by default.
+func ListTriggerRuns(db bun.IDB, ctx context.Context, filter TriggerRunFilter, page PageRequest) (*Page[TriggerRun], error) {
+	q := db.NewSelect().Model((*TriggerRun)(nil))
+	if filter.TriggerID != 0 {
+		q = q.Where("trigger_run.trigger_id = ?", filter.TriggerID)
+	}
+	if filter.TicketID != 0 {
+		q = q.Where("trigger_run.ticket_id = ?", filter.TicketID)
+	}
+	if filter.Failed {
+		q = q.Where("trigger_run.error_message IS NOT NULL")
+	}
+	if len(page.Sort) == 0 {
+		page.Sort = []SortKey{{Column: "created_at", Desc: true}}
+	}
+	return paginate[TriggerRun](ctx, q, page, "created_at", "trigger_id", "ticket_id")
+}
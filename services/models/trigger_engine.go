@@ -0,0 +1,363 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// TriggerEvent is something that happened to a ticket, for triggers to react to.
+type TriggerEvent struct {
+	Type      string
+	Before    *Ticket  // The ticket before an update, nil for other events
+	Comment   *Comment // The created comment, for comment events
+	ActorID   int64
+	ActorRole string
+}
+
+// TriggerResult is what the triggers of an event did to its ticket.
+type TriggerResult struct {
+	Ticket *Ticket         `json:"Ticket"` // The ticket with the changes of every trigger that fired
+	Fired  []FiredTrigger  `json:"Fired"`
+	Notes  []string        `json:"Notes,omitempty"` // Internal notes the triggers add
+	Before *Ticket         `json:"-"`               // The ticket before the triggers ran
+	Errors map[int64]error `json:"-"`               // Why a trigger's actions could not be executed
+}
+
+// FiredTrigger is a trigger whose conditions held, with the actions it executed.
+type FiredTrigger struct {
+	TriggerID int64           `json:"TriggerID"`
+	Name      string          `json:"Name"`
+	Actions   []TriggerAction `json:"Actions"`
+}
+
+// staffRoles lists the roles tickets can be assigned to.
+var staffRoles = []string{"Admin", "Agent"}
+
+// EvaluateTriggers works out what the triggers do to a ticket for an event, without saving
+// anything. The ticket must be the stored ticket with its tags; it is left as it is.
+//
+// Triggers are evaluated in order. Whenever one fires, evaluation starts over from the first
+// trigger with the changed ticket, so earlier triggers see the changes of later ones. Each trigger
+// fires at most once per event, which keeps triggers that undo each other from looping, and the
+// changes triggers make do not raise events of their own.
+func EvaluateTriggers(db bun.IDB, ctx context.Context, triggers []Trigger, event TriggerEvent, ticket *Ticket) (*TriggerResult, error) {
+	current := *ticket
+	current.Tags = slices.Clone(ticket.Tags)
+	result := &TriggerResult{Ticket: &current, Fired: []FiredTrigger{}, Before: ticket}
+
+	fired := make(map[int64]bool)
+	for pass := true; pass; {
+		pass = false
+		for _, t := range triggers {
+			if fired[t.ID] || !t.matches(event, &current) {
+				continue
+			}
+			fired[t.ID] = true
+			for _, a := range t.Actions {
+				if err := applyTriggerAction(db, ctx, &current, a, result); err != nil {
+					result.Errors = map[int64]error{t.ID: err}
+					return result, fmt.Errorf("trigger %q: %w", t.Name, err)
+				}
+			}
+			result.Fired = append(result.Fired, FiredTrigger{TriggerID: t.ID, Name: t.Name, Actions: t.Actions})
+			pass = true
+			break
+		}
+	}
+	return result, nil
+}
+
+// triggerAttempts is how many times RunTriggers evaluates the triggers of an event when the
+// ticket keeps changing before their changes are saved.
+const triggerAttempts = 3
+
+// RunTriggers executes the active triggers of an event on a ticket: their changes, tags and notes
+// are saved in one transaction and each trigger that fired is logged. It returns nil when no
+// trigger fired. Notes are written by the actor.
+//
+// The triggers run once the change that raised the event is saved. When the ticket changes again
+// before their own changes are saved, they are evaluated anew against the latest ticket. Triggers
+// that fail are logged with the error, which is also returned.
+func RunTriggers(db *bun.DB, ctx context.Context, event TriggerEvent, ticketID int64) (*TriggerResult, error) {
+	triggers, err := ListTriggers(db, ctx, event.Type)
+	if err != nil || len(triggers) == 0 {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		ticket, err := GetTicketByID(db, ctx, ticketID)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := EvaluateTriggers(db, ctx, triggers, event, ticket)
+		if err != nil {
+			for id, cause := range result.Errors {
+				logTriggerRuns(db, ctx, event, ticketID, []FiredTrigger{{TriggerID: id}}, cause)
+			}
+			return nil, err
+		}
+		if len(result.Fired) == 0 {
+			return nil, nil
+		}
+
+		err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			return saveTriggerResult(tx, ctx, event, result)
+		})
+		if errors.Is(err, ErrVersionConflict) && attempt < triggerAttempts {
+			continue
+		}
+		if err != nil {
+			logTriggerRuns(db, ctx, event, ticketID, result.Fired, err)
+			return nil, err
+		}
+		return result, nil
+	}
+}
+
+func saveTriggerResult(tx bun.Tx, ctx context.Context, event TriggerEvent, result *TriggerResult) error {
+	before, after := result.Before, result.Ticket
+	if before.Status != after.Status || before.Priority != after.Priority || before.Type != after.Type ||
+		before.CategoryID != after.CategoryID || before.AssigneeID != after.AssigneeID {
+		err := updateTicketColumns(tx, ctx, after, "status", "priority", "type", "category_id", "assignee_id")
+		if err != nil {
+			return err
+		}
+	}
+
+	var added, removed []string
+	for _, tag := range after.Tags {
+		if !slices.Contains(before.Tags, tag) {
+			added = append(added, tag)
+		}
+	}
+	for _, tag := range before.Tags {
+		if !slices.Contains(after.Tags, tag) {
+			removed = append(removed, tag)
+		}
+	}
+	if err := AddTicketTags(tx, ctx, []int64{after.ID}, added); err != nil {
+		return err
+	}
+	if err := RemoveTicketTags(tx, ctx, []int64{after.ID}, removed); err != nil {
+		return err
+	}
+
+	if len(result.Notes) > 0 {
+		notes := make([]Comment, len(result.Notes))
+		for i, body := range result.Notes {
+			notes[i] = Comment{TicketID: after.ID, AuthorID: event.ActorID, Body: body, IsInternal: true}
+		}
+		if _, err := tx.NewInsert().Model(&notes).Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	runs := triggerRuns(event, after.ID, result.Fired, nil)
+	_, err := tx.NewInsert().Model(&runs).Exec(ctx)
+	return err
+}
+
+// logTriggerRuns logs triggers whose actions could not be saved. Failing to log is only printed.
+func logTriggerRuns(db bun.IDB, ctx context.Context, event TriggerEvent, ticketID int64, fired []FiredTrigger, cause error) {
+	runs := triggerRuns(event, ticketID, fired, cause)
+	if _, err := db.NewInsert().Model(&runs).Exec(ctx); err != nil {
+		log.Printf("Error logging failed trigger runs for ticket %d (%v): %v", ticketID, cause, err)
+	}
+}
+
+func triggerRuns(event TriggerEvent, ticketID int64, fired []FiredTrigger, cause error) []TriggerRun {
+	runs := make([]TriggerRun, len(fired))
+	for i, f := range fired {
+		runs[i] = TriggerRun{
+			TriggerID: f.TriggerID,
+			TicketID:  ticketID,
+			Event:     event.Type,
+			ActorID:   sql.NullInt64{Int64: event.ActorID, Valid: event.ActorID != 0},
+			Actions:   f.Actions,
+		}
+		if cause != nil {
+			runs[i].Error = cause.Error()
+		}
+	}
+	return runs
+}
+
+// matches reports whether the trigger's conditions hold for the event and the ticket as the
+// triggers have left it so far.
+func (t *Trigger) matches(event TriggerEvent, ticket *Ticket) bool {
+	for _, c := range t.Conditions.All {
+		if !c.holds(event, ticket) {
+			return false
+		}
+	}
+	if len(t.Conditions.Any) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(t.Conditions.Any, func(c TriggerCondition) bool { return c.holds(event, ticket) })
+}
+
+func (c TriggerCondition) holds(event TriggerEvent, ticket *Ticket) bool {
+	values := triggerFieldValues(c.Field, event, ticket)
+	want := c.Value
+	if c.Field == "tag" {
+		want = NormalizeTagName(want)
+	}
+
+	switch c.Operator {
+	case OpIs:
+		return slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(v, want) })
+	case OpIsNot:
+		return !slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(v, want) })
+	case OpContains:
+		return slices.ContainsFunc(values, func(v string) bool { return containsFold(v, want) })
+	case OpNotContains:
+		return !slices.ContainsFunc(values, func(v string) bool { return containsFold(v, want) })
+	case OpGreaterThan, OpLessThan:
+		have, limit := slices.Index(TicketPriorities, ticket.Priority), slices.Index(TicketPriorities, want)
+		if have < 0 || limit < 0 {
+			return false
+		}
+		return (c.Operator == OpGreaterThan && have > limit) || (c.Operator == OpLessThan && have < limit)
+	case OpChanged:
+		if event.Before == nil {
+			return false
+		}
+		before := triggerFieldValues(c.Field, event, event.Before)
+		return !slices.Equal(slices.Sorted(slices.Values(before)), slices.Sorted(slices.Values(values)))
+	}
+	return false
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// triggerFieldValues returns the values of a condition field. Only tags can have several, and
+// fields the event does not set have none.
+func triggerFieldValues(field string, event TriggerEvent, ticket *Ticket) []string {
+	switch field {
+	case "title":
+		return []string{ticket.Title}
+	case "description":
+		return []string{ticket.Description}
+	case "status":
+		return []string{ticket.Status}
+	case "priority":
+		return []string{ticket.Priority}
+	case "type":
+		return []string{ticket.Type}
+	case "category":
+		return []string{nullIDString(ticket.CategoryID)}
+	case "assignee":
+		return []string{nullIDString(ticket.AssigneeID)}
+	case "requester":
+		return []string{strconv.FormatInt(ticket.RequesterID, 10)}
+	case "tag":
+		return ticket.Tags
+	case "actor":
+		return []string{strconv.FormatInt(event.ActorID, 10)}
+	case "actor.role":
+		return []string{event.ActorRole}
+	case "comment.body":
+		if event.Comment != nil {
+			return []string{event.Comment.Body}
+		}
+	case "comment.is_internal":
+		if event.Comment != nil {
+			return []string{strconv.FormatBool(event.Comment.IsInternal)}
+		}
+	}
+	return nil
+}
+
+func nullIDString(id sql.NullInt64) string {
+	if !id.Valid {
+		return "none"
+	}
+	return strconv.FormatInt(id.Int64, 10)
+}
+
+// applyTriggerAction makes one action's change to the ticket, or adds its note to the result.
+func applyTriggerAction(db bun.IDB, ctx context.Context, ticket *Ticket, a TriggerAction, result *TriggerResult) error {
+	switch a.Type {
+	case ActionSetStatus:
+		ticket.Status = a.Value
+	case ActionSetPriority:
+		ticket.Priority = a.Value
+	case ActionSetType:
+		ticket.Type = a.Value
+	case ActionSetCategory:
+		ticket.CategoryID = sql.NullInt64{}
+		if a.Value != "none" {
+			id, _ := strconv.ParseInt(a.Value, 10, 64)
+			ticket.CategoryID = sql.NullInt64{Int64: id, Valid: true}
+		}
+	case ActionAssignUser:
+		id, _ := strconv.ParseInt(a.Value, 10, 64)
+		staff, err := db.NewSelect().
+			Model((*User)(nil)).
+			Where("?TableAlias.id = ?", id).
+			Where("?TableAlias.role IN (?)", bun.In(staffRoles)).
+			Exists(ctx)
+		if err != nil {
+			return err
+		}
+		if !staff {
+			return fmt.Errorf("user %d is not an agent", id)
+		}
+		ticket.AssigneeID = sql.NullInt64{Int64: id, Valid: true}
+	case ActionAssignTeam:
+		teamID, _ := strconv.ParseInt(a.Value, 10, 64)
+		id, err := leastBusyTeamMember(db, ctx, teamID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("team %d has no agents", teamID)
+		}
+		if err != nil {
+			return err
+		}
+		ticket.AssigneeID = sql.NullInt64{Int64: id, Valid: true}
+	case ActionUnassign:
+		ticket.AssigneeID = sql.NullInt64{}
+	case ActionAddTag:
+		if name := NormalizeTagName(a.Value); !slices.Contains(ticket.Tags, name) {
+			ticket.Tags = append(ticket.Tags, name)
+		}
+	case ActionRemoveTag:
+		name := NormalizeTagName(a.Value)
+		ticket.Tags = slices.DeleteFunc(ticket.Tags, func(tag string) bool { return tag == name })
+	case ActionAddNote:
+		result.Notes = append(result.Notes, a.Value)
+	}
+	return nil
+}
+
+// leastBusyTeamMember returns the ID of the team's agent or admin with the fewest open tickets
+// assigned, the lowest ID on a tie, or sql.ErrNoRows if the team has none.
+func leastBusyTeamMember(db bun.IDB, ctx context.Context, teamID int64) (int64, error) {
+	var ids []int64
+	err := db.NewSelect().
+		Model((*User)(nil)).
+		Column("id").
+		Where("?TableAlias.role IN (?)", bun.In(staffRoles)).
+		Where("?TableAlias.id IN (SELECT tm.user_id FROM team_members AS tm WHERE tm.team_id = ?)", teamID).
+		OrderExpr("(SELECT COUNT(*) FROM tickets AS t WHERE t.assignee_id = ?TableAlias.id AND t.status <> 'Closed' AND t.deleted_at IS NULL) ASC").
+		OrderExpr("?TableAlias.id ASC").
+		Limit(1).
+		Scan(ctx, &ids)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, sql.ErrNoRows
+	}
+	return ids[0], nil
+}
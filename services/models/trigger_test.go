@@ -0,0 +1,56 @@
+package models_test
+
+import (
+	"strconv"
+	"testing"
+
+	"goat/services/models"
+)
+
+func TestRunTriggers(t *testing.T) {
+	db := newTestDB(t)
+	ctx := tenantContext()
+	customer := newTestUser(t, db, ctx, "Customer")
+	ticket := newTestTicket(t, db, ctx, customer)
+	team := &models.Team{Name: "Nobody"}
+	if err := models.CreateTeam(db, ctx, team); err != nil {
+		t.Fatal(err)
+	}
+
+	urgent := &models.Trigger{Name: "Urgent", Event: models.EventTicketCreated, Active: true,
+		Actions: []models.TriggerAction{{Type: models.ActionSetPriority, Value: "Urgent"}}}
+	if err := models.CreateTrigger(db, ctx, urgent); err != nil {
+		t.Fatal(err)
+	}
+	result, err := models.RunTriggers(db, ctx, models.TriggerEvent{Type: models.EventTicketCreated}, ticket.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil || result.Ticket.Priority != "Urgent" {
+		t.Fatalf("got %+v, want the ticket made urgent", result)
+	}
+	saved, err := models.GetTicketByID(db, ctx, ticket.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Priority != "Urgent" || saved.Version != ticket.Version+1 {
+		t.Errorf("saved priority %q version %d, want Urgent and version %d", saved.Priority, saved.Version, ticket.Version+1)
+	}
+
+	// A team without agents cannot take the ticket: the failure is returned and logged.
+	assign := &models.Trigger{Name: "Assign", Event: models.EventTicketUpdated, Active: true,
+		Actions: []models.TriggerAction{{Type: models.ActionAssignTeam, Value: strconv.FormatInt(team.ID, 10)}}}
+	if err := models.CreateTrigger(db, ctx, assign); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.RunTriggers(db, ctx, models.TriggerEvent{Type: models.EventTicketUpdated, Before: saved}, ticket.ID); err == nil {
+		t.Fatal("got no error from a trigger that cannot run")
+	}
+	runs, err := models.ListTriggerRuns(db, ctx, models.TriggerRunFilter{Failed: true}, models.PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs.Items) != 1 || runs.Items[0].TriggerID != assign.ID || runs.Items[0].Error == "" {
+		t.Errorf("got failed runs %+v, want one of trigger %d with its error", runs.Items, assign.ID)
+	}
+}
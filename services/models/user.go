@@ -3,10 +3,8 @@ package models
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"time"
 
-	"github.com/go-sql-driver/mysql"
 	"github.com/uptrace/bun"
 )
 
@@ -14,13 +12,16 @@ import (
 type User struct {
 	bun.BaseModel        `bun:"table:users,alias:user"`
 	ID                   int64          `bun:"id,pk,autoincrement,type:integer"`
+	TenantID             int64          `bun:"tenant_id,notnull"` // Organization the user belongs to
 	Name                 string         `bun:"name,notnull"`
 	Email                string         `bun:"email,notnull,unique"`
 	PasswordHash         string         `bun:"password_hash,notnull"`
 	Role                 string         `bun:"role,notnull,default:'Agent'"`
+	CompanyID            sql.NullInt64  `bun:"company_id"` // Customer's company, if any
 	CreatedAt            time.Time      `bun:"created_at,notnull,default:current_timestamp"`
 	PasswordResetToken   sql.NullString `bun:"password_reset_token"`
 	PasswordResetExpires sql.NullTime   `bun:"password_reset_expires"`
+	DeletedAt            *time.Time     `bun:"deleted_at,soft_delete,nullzero" json:"DeletedAt,omitempty"` // Set while the user is in the trash
 }
 
 // GetUserByID retrieves a user from the database by their ID.
@@ -36,13 +37,7 @@ func GetUserByID(db *bun.DB, ctx context.Context, userID int64) (*User, error) {
 // CreateUser inserts a new user into the database.
 func CreateUser(db *bun.DB, ctx context.Context, user *User) error {
 	_, err := db.NewInsert().Model(user).Exec(ctx)
-	if err != nil {
-		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == 1062 {
-			return fmt.Errorf("duplicate entry for user: %w", err)
-		}
-		return err
-	}
-	return nil
+	return constraintError(err, "user")
 }
 
 // UpdateUser updates an existing user in the database.
@@ -51,30 +46,36 @@ func UpdateUser(db *bun.DB, ctx context.Context, user *User) error {
 	return err
 }
 
-// DeleteUser deletes a user from the database by their ID.
+// DeleteUser moves a user to the trash by their ID, keeping their tickets and comments.
+// PurgeDeleted removes it for good.
 func DeleteUser(db *bun.DB, ctx context.Context, userID int64) error {
 	_, err := db.NewDelete().Model(&User{}).Where("id = ?", userID).Exec(ctx)
 	return err
 }
 
-// GetUsers retrieves a list of users from the database.
-func GetUsers(db *bun.DB, ctx context.Context) ([]*User, error) {
-	var users []*User
-	err := db.NewSelect().Model(&users).Scan(ctx)
-	if err != nil {
-		return nil, err
-	}
-	return users, nil
+// userSortColumns lists the user columns lists can be sorted by.
+var userSortColumns = []string{"name", "email", "role", "created_at"}
+
+// GetUsers retrieves a page of users from the database.
+func GetUsers(db *bun.DB, ctx context.Context, page PageRequest) (*Page[*User], error) {
+	q := db.NewSelect().Model((*User)(nil))
+	return paginate[*User](ctx, q, page, userSortColumns...)
+}
+
+// GetUsersByRole retrieves a page of users with the given role from the database.
+func GetUsersByRole(db *bun.DB, ctx context.Context, role string, page PageRequest) (*Page[*User], error) {
+	q := db.NewSelect().Model((*User)(nil)).Where("role = ?", role)
+	return paginate[*User](ctx, q, page, userSortColumns...)
 }
 
-// GetCustomers retrieves a list of users with the role 'Customer' from the database.
-func GetUsersByRole(db *bun.DB, ctx context.Context, role string) ([]*User, error) {
-	var users []*User
-	err := db.NewSelect().Model(&users).Where("role = ?", role).Scan(ctx)
+// GetUserByResetToken retrieves a user from the database by their password reset token.
+func GetUserByResetToken(db *bun.DB, ctx context.Context, token string) (*User, error) {
+	user := new(User)
+	err := db.NewSelect().Model(user).Where("password_reset_token = ?", token).Scan(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return users, nil
+	return user, nil
 }
 
 // GetUserByEmail retrieves a user from the database by their email.
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// View represents a saved ticket query. Views shared with a team appear as queues for its members.
+type View struct {
+	bun.BaseModel `bun:"table:views,alias:view"`
+	ID            int64         `bun:"id,pk,autoincrement,type:integer"`
+	TenantID      int64         `bun:"tenant_id,notnull"` // Organization the view belongs to
+	Name          string        `bun:"name,notnull"`
+	Query         string        `bun:"query,notnull"`
+	OwnerID       int64         `bun:"owner_id,notnull"`
+	TeamID        sql.NullInt64 `bun:"team_id"` // Set when the view is shared with a team
+	CreatedAt     time.Time     `bun:"created_at,notnull,default:current_timestamp"`
+	TicketCount   *int          `bun:"-" json:"TicketCount,omitempty"` // This field is not stored in the database
+}
+
+// GetViewByID retrieves a saved view from the database by its ID.
+func GetViewByID(db *bun.DB, ctx context.Context, viewID int64) (*View, error) {
+	view := new(View)
+	err := db.NewSelect().Model(view).Where("id = ?", viewID).Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// ListViewsForUser retrieves the views a user owns or that are shared with one of their teams.
+func ListViewsForUser(db *bun.DB, ctx context.Context, userID int64, teamIDs []int64) ([]View, error) {
+	var views []View
+	err := db.NewSelect().
+		Model(&views).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			q = q.Where("view.owner_id = ?", userID)
+			if len(teamIDs) > 0 {
+				q = q.WhereOr("view.team_id IN (?)", bun.In(teamIDs))
+			}
+			return q
+		}).
+		Order("name ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return views, nil
+}
+
+// CreateView inserts a new saved view into the database.
+func CreateView(db *bun.DB, ctx context.Context, view *View) error {
+	_, err := db.NewInsert().Model(view).Exec(ctx)
+	return constraintError(err, "view")
+}
+
+// DeleteView deletes a saved view from the database by its ID.
+func DeleteView(db *bun.DB, ctx context.Context, viewID int64) error {
+	_, err := db.NewDelete().Model(&View{}).Where("id = ?", viewID).Exec(ctx)
+	return err
+}
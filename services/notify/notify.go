@@ -0,0 +1,36 @@
+// Package notify delivers notifications about ticket activity to users.
+package notify
+
+import (
+	"context"
+	"fmt"
+)
+
+// Notification is a message about a ticket for a set of users.
+type Notification struct {
+	UserIDs []int64
+	Emails  []string // Contacts without an account
+	// ClaimTokens holds each contact's token, which links the ticket to their account when they
+	// send it to POST /customer/ccs/claim while signed in.
+	ClaimTokens map[string]string
+	TicketID    int64
+	Subject     string
+	Body        string
+}
+
+// Notifier delivers notifications. Implementations must be safe for concurrent use.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// LogNotifier writes notifications to the console until a delivery channel such as email is configured.
+type LogNotifier struct{}
+
+// Notify prints the notification.
+func (LogNotifier) Notify(ctx context.Context, n Notification) error {
+	fmt.Printf("Notify users %v about ticket %d: %s\n%s\n", n.UserIDs, n.TicketID, n.Subject, n.Body)
+	for _, email := range n.Emails {
+		fmt.Printf("Notify %s about ticket %d (claim token %s): %s\n", email, n.TicketID, n.ClaimTokens[email], n.Subject)
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"goat/services/models"
+)
+
+// Env holds the request-specific values a query is evaluated against.
+type Env struct {
+	UserID int64     // Resolves "me"
+	Now    time.Time // Reference point for relative dates such as 7d
+}
+
+// FieldFunc compiles a term on a field into a WHERE condition on the "ticket" table alias.
+// The condition must evaluate to false, not NULL, when it does not match so that negation works.
+type FieldFunc func(t Term, env Env) (cond string, args []any, err error)
+
+var fields = map[string]FieldFunc{
+	"id":        compileID,
+	"type":      compileType,
+	"status":    compileStatus,
+	"priority":  compilePriority,
+	"assignee":  userField("ticket.assignee_id"),
+	"requester": userField("ticket.requester_id"),
+	"created":   dateField("ticket.created_at"),
+	"updated":   dateField("ticket.updated_at"),
+	"closed":    dateField("ticket.closed_at"),
+	"tag":       compileTag,
+	"category":  compileCategory,
+	"company":   compileCompany,
+}
+
+// Register adds a field to the query language, replacing any field with the same name.
+func Register(name string, fn FieldFunc) {
+	fields[name] = fn
+}
+
+// Compile turns the query into a function that adds its conditions to a ticket select.
+func (q *Query) Compile(env Env) (func(*bun.SelectQuery) *bun.SelectQuery, error) {
+	type clause struct {
+		cond string
+		args []any
+	}
+
+	clauses := make([]clause, 0, len(q.Terms))
+	for _, t := range q.Terms {
+		fn := compileText
+		if t.Field != "" {
+			var ok bool
+			fn, ok = fields[t.Field]
+			if key, custom := strings.CutPrefix(t.Field, models.CustomFieldPrefix); !ok && custom && key != "" {
+				fn, ok = customField(key), true
+			}
+			if !ok {
+				return nil, fmt.Errorf("unknown field %q", t.Field)
+			}
+		}
+
+		cond, args, err := fn(t, env)
+		if err != nil {
+			return nil, err
+		}
+		if t.Negate {
+			cond = "NOT (" + cond + ")"
+		}
+		clauses = append(clauses, clause{cond: cond, args: args})
+	}
+
+	return func(sel *bun.SelectQuery) *bun.SelectQuery {
+		for _, c := range clauses {
+			sel = sel.Where(c.cond, c.args...)
+		}
+		return sel
+	}, nil
+}
+
+// Filter parses and compiles a query string in one step. An empty string matches every ticket.
+func Filter(input string, env Env) (func(*bun.SelectQuery) *bun.SelectQuery, error) {
+	q, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	return q.Compile(env)
+}
+
+// EscapeLike escapes the LIKE wildcards in s for use with ESCAPE '!'.
+func EscapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+func compileText(t Term, env Env) (string, []any, error) {
+	pattern := "%" + EscapeLike(strings.ToLower(t.Value)) + "%"
+	return "LOWER(ticket.title) LIKE ? ESCAPE '!' OR LOWER(COALESCE(ticket.description, '')) LIKE ? ESCAPE '!'",
+		[]any{pattern, pattern}, nil
+}
+
+func compileID(t Term, env Env) (string, []any, error) {
+	id, err := strconv.ParseInt(t.Value, 10, 64)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid id %q", t.Value)
+	}
+	return "ticket.id " + sqlOp(t.Op) + " ?", []any{id}, nil
+}
+
+func compileStatus(t Term, env Env) (string, []any, error) {
+	if t.Op != ":" {
+		return "", nil, fmt.Errorf("status does not support %q", t.Op)
+	}
+	values := strings.Split(strings.ToLower(t.Value), ",")
+	return "LOWER(ticket.status) IN (?)", []any{bun.In(values)}, nil
+}
+
+func compileType(t Term, env Env) (string, []any, error) {
+	if t.Op != ":" {
+		return "", nil, fmt.Errorf("type does not support %q", t.Op)
+	}
+	values := strings.Split(strings.ToLower(t.Value), ",")
+	return "LOWER(ticket.type) IN (?)", []any{bun.In(values)}, nil
+}
+
+func compilePriority(t Term, env Env) (string, []any, error) {
+	var matched []string
+	for _, value := range strings.Split(t.Value, ",") {
+		rank := -1
+		for i, p := range models.TicketPriorities {
+			if strings.EqualFold(p, value) {
+				rank = i
+			}
+		}
+		if rank < 0 {
+			return "", nil, fmt.Errorf("unknown priority %q", value)
+		}
+
+		for i, p := range models.TicketPriorities {
+			if (t.Op == ":" && i == rank) ||
+				(t.Op == ">" && i > rank) || (t.Op == ">=" && i >= rank) ||
+				(t.Op == "<" && i < rank) || (t.Op == "<=" && i <= rank) {
+				matched = append(matched, p)
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return "1 = 0", nil, nil
+	}
+	return "ticket.priority IN (?)", []any{bun.In(matched)}, nil
+}
+
+func compileTag(t Term, env Env) (string, []any, error) {
+	if t.Op != ":" {
+		return "", nil, fmt.Errorf("tag does not support %q", t.Op)
+	}
+	var names []string
+	for _, name := range strings.Split(t.Value, ",") {
+		names = append(names, models.NormalizeTagName(name))
+	}
+	return "EXISTS (SELECT 1 FROM ticket_tags AS tt JOIN tags AS tg ON tg.id = tt.tag_id WHERE tt.ticket_id = ticket.id AND tg.name IN (?))",
+		[]any{bun.In(names)}, nil
+}
+
+func compileCategory(t Term, env Env) (string, []any, error) {
+	if t.Op != ":" {
+		return "", nil, fmt.Errorf("category does not support %q", t.Op)
+	}
+	if strings.EqualFold(t.Value, "none") {
+		return "ticket.category_id IS NULL", nil, nil
+	}
+	id, err := strconv.ParseInt(t.Value, 10, 64)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid category %q, expected none or a category ID", t.Value)
+	}
+	return "ticket.category_id IS NOT NULL AND ticket.category_id = ?", []any{id}, nil
+}
+
+// compileCompany compiles a term matching the tickets requested by the members of a customer
+// company, or by customers in no company for "none".
+func compileCompany(t Term, env Env) (string, []any, error) {
+	if t.Op != ":" {
+		return "", nil, fmt.Errorf("company does not support %q", t.Op)
+	}
+	if strings.EqualFold(t.Value, "none") {
+		return "NOT EXISTS (SELECT 1 FROM users AS cu WHERE cu.id = ticket.requester_id AND cu.company_id IS NOT NULL)", nil, nil
+	}
+	id, err := strconv.ParseInt(t.Value, 10, 64)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid company %q, expected none or a company ID", t.Value)
+	}
+	return "EXISTS (SELECT 1 FROM users AS cu WHERE cu.id = ticket.requester_id AND cu.company_id = ?)", []any{id}, nil
+}
+
+// customField compiles a term on the custom field with the given key. The field type is not known
+// here, so the value is compared with every value column it can be read as: text always, numbers,
+// dates and user references when it parses as one. "none" matches tickets without a value.
+func customField(key string) FieldFunc {
+	const exists = "EXISTS (SELECT 1 FROM ticket_field_values AS fv JOIN custom_fields AS cf ON cf.id = fv.field_id " +
+		"WHERE fv.ticket_id = ticket.id AND cf.field_key = ?"
+
+	return func(t Term, env Env) (string, []any, error) {
+		if t.Op == ":" && strings.EqualFold(t.Value, "none") {
+			return "NOT " + exists + ")", []any{key}, nil
+		}
+
+		var conds []string
+		args := []any{key}
+		for _, value := range strings.Split(t.Value, ",") {
+			conds = append(conds, "LOWER(fv.text_value) "+sqlOp(t.Op)+" ?")
+			args = append(args, strings.ToLower(value))
+
+			if n, err := strconv.ParseFloat(value, 64); err == nil {
+				conds = append(conds, "fv.number_value "+sqlOp(t.Op)+" ?")
+				args = append(args, n)
+			}
+			dateTerm := t
+			dateTerm.Value = value
+			if cond, dateArgs, err := dateField("fv.date_value")(dateTerm, env); err == nil {
+				conds = append(conds, "("+cond+")")
+				args = append(args, dateArgs...)
+			}
+			if t.Op == ":" {
+				if strings.EqualFold(value, "me") {
+					conds = append(conds, "fv.user_id = ?")
+					args = append(args, env.UserID)
+				} else if id, err := strconv.ParseInt(value, 10, 64); err == nil {
+					conds = append(conds, "fv.user_id = ?")
+					args = append(args, id)
+				}
+			}
+		}
+		return exists + " AND (" + strings.Join(conds, " OR ") + "))", args, nil
+	}
+}
+
+// userField compiles "me", "none" or a user ID against a nullable user reference column.
+func userField(column string) FieldFunc {
+	return func(t Term, env Env) (string, []any, error) {
+		if t.Op != ":" {
+			return "", nil, fmt.Errorf("%s does not support %q", t.Field, t.Op)
+		}
+		switch strings.ToLower(t.Value) {
+		case "none":
+			return column + " IS NULL", nil, nil
+		case "me":
+			return column + " IS NOT NULL AND " + column + " = ?", []any{env.UserID}, nil
+		}
+		id, err := strconv.ParseInt(t.Value, 10, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid %s %q, expected me, none or a user ID", t.Field, t.Value)
+		}
+		return column + " IS NOT NULL AND " + column + " = ?", []any{id}, nil
+	}
+}
+
+var relativeDate = regexp.MustCompile(`^(\d+)([mhdw])$`)
+
+// dateField compiles a date comparison. Values are either absolute (2024-05-01 or RFC 3339)
+// or relative to now (30m, 12h, 7d, 2w), so created:>7d means "created within the last 7 days".
+// A plain field:date matches the whole day, a plain field:7d is the same as field:>=7d.
+func dateField(column string) FieldFunc {
+	return func(t Term, env Env) (string, []any, error) {
+		if m := relativeDate.FindStringSubmatch(t.Value); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			unit := map[string]time.Duration{"m": time.Minute, "h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour}[m[2]]
+			at := env.Now.Add(-time.Duration(n) * unit)
+			op := t.Op
+			if op == ":" {
+				op = ">="
+			}
+			return column + " IS NOT NULL AND " + column + " " + op + " ?", []any{at}, nil
+		}
+
+		if day, err := time.Parse("2006-01-02", t.Value); err == nil {
+			if t.Op == ":" {
+				return column + " IS NOT NULL AND " + column + " >= ? AND " + column + " < ?", []any{day, day.AddDate(0, 0, 1)}, nil
+			}
+			return column + " IS NOT NULL AND " + column + " " + t.Op + " ?", []any{day}, nil
+		}
+
+		at, err := time.Parse(time.RFC3339, t.Value)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid date %q for %s", t.Value, t.Field)
+		}
+		return column + " IS NOT NULL AND " + column + " " + sqlOp(t.Op) + " ?", []any{at}, nil
+	}
+}
+
+func sqlOp(op string) string {
+	if op == ":" {
+		return "="
+	}
+	return op
+}
@@ -0,0 +1,118 @@
+// Package query implements the ticket query language used by listing endpoints and saved views.
+//
+// A query is a list of space-separated terms that are all required to match:
+//
+//	status:open priority:>=high assignee:me created:>7d -tag:spam "printer jam"
+//
+// A term is either free text matched against the ticket title and description, or
+// field:value where the value may start with a comparison operator (>, >=, <, <=).
+// Prefixing a term with "-" negates it, and double quotes group words with spaces.
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Term is a single condition of a query.
+type Term struct {
+	Field  string // Empty for free text
+	Op     string // ":", ">", ">=", "<" or "<="
+	Value  string
+	Negate bool
+}
+
+// Query is a parsed ticket query. All terms must match.
+type Query struct {
+	Terms []Term
+}
+
+var fieldPattern = regexp.MustCompile(`^[a-z][a-z0-9_.]*$`)
+
+// Parse parses a query string into its terms.
+func Parse(input string) (*Query, error) {
+	q := &Query{}
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		// Read one token, keeping quoted sections intact.
+		start := i
+		inQuotes := false
+		for i < len(runes) && (inQuotes || !unicode.IsSpace(runes[i])) {
+			if runes[i] == '"' {
+				inQuotes = !inQuotes
+			}
+			i++
+		}
+		if inQuotes {
+			return nil, fmt.Errorf("unterminated quote in %q", string(runes[start:i]))
+		}
+
+		term, err := parseTerm(string(runes[start:i]))
+		if err != nil {
+			return nil, err
+		}
+		q.Terms = append(q.Terms, term)
+	}
+	return q, nil
+}
+
+func parseTerm(token string) (Term, error) {
+	term := Term{Op: ":"}
+	if len(token) > 1 && token[0] == '-' {
+		term.Negate = true
+		token = token[1:]
+	}
+
+	if field, value, ok := strings.Cut(token, ":"); ok && fieldPattern.MatchString(field) {
+		term.Field = field
+		for _, op := range []string{">=", "<=", ">", "<"} {
+			if strings.HasPrefix(value, op) {
+				term.Op = op
+				value = value[len(op):]
+				break
+			}
+		}
+		token = value
+	}
+
+	term.Value = strings.ReplaceAll(token, `"`, "")
+	if term.Value == "" {
+		if term.Field != "" {
+			return term, fmt.Errorf("missing value for %q", term.Field)
+		}
+		return term, fmt.Errorf("empty search term")
+	}
+	return term, nil
+}
+
+// String formats the query back into its textual form.
+func (q *Query) String() string {
+	parts := make([]string, len(q.Terms))
+	for i, t := range q.Terms {
+		var b strings.Builder
+		if t.Negate {
+			b.WriteByte('-')
+		}
+		if t.Field != "" {
+			b.WriteString(t.Field)
+			b.WriteByte(':')
+			if t.Op != ":" {
+				b.WriteString(t.Op)
+			}
+		}
+		if strings.ContainsFunc(t.Value, unicode.IsSpace) {
+			b.WriteString(`"` + t.Value + `"`)
+		} else {
+			b.WriteString(t.Value)
+		}
+		parts[i] = b.String()
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,250 @@
+package query_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"goat/services/config"
+	"goat/services/migrate"
+	"goat/services/models"
+	"goat/services/query"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []query.Term
+	}{
+		{"", nil},
+		{"printer", []query.Term{{Op: ":", Value: "printer"}}},
+		{`"printer jam" toner`, []query.Term{{Op: ":", Value: "printer jam"}, {Op: ":", Value: "toner"}}},
+		{`cf.team:"Level 2"`, []query.Term{{Field: "cf.team", Op: ":", Value: "Level 2"}}},
+		{"status:open,pending", []query.Term{{Field: "status", Op: ":", Value: "open,pending"}}},
+		{"-status:closed", []query.Term{{Field: "status", Op: ":", Value: "closed", Negate: true}}},
+		{`-"printer jam"`, []query.Term{{Op: ":", Value: "printer jam", Negate: true}}},
+		{"priority:>=high", []query.Term{{Field: "priority", Op: ">=", Value: "high"}}},
+		{"priority:>low", []query.Term{{Field: "priority", Op: ">", Value: "low"}}},
+		{"created:<=2024-05-01", []query.Term{{Field: "created", Op: "<=", Value: "2024-05-01"}}},
+		{"updated:<7d", []query.Term{{Field: "updated", Op: "<", Value: "7d"}}},
+		{"  assignee:me \t tag:vip  ", []query.Term{{Field: "assignee", Op: ":", Value: "me"}, {Field: "tag", Op: ":", Value: "vip"}}},
+		// Parse accepts any well-formed field; Compile rejects unknown ones.
+		{"color:red", []query.Term{{Field: "color", Op: ":", Value: "red"}}},
+		// Only lowercase field names are fields, anything else is text.
+		{"Status:open", []query.Term{{Op: ":", Value: "Status:open"}}},
+		{"-", []query.Term{{Op: ":", Value: "-"}}},
+	}
+	for _, tt := range tests {
+		q, err := query.Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.input, err)
+			continue
+		}
+		if !slices.Equal(q.Terms, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.input, q.Terms, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{
+		`"printer jam`,
+		`title "printer`,
+		`cf.team:"Level 2`,
+		"status:",
+		"-status:",
+		`""`,
+	} {
+		if q, err := query.Parse(input); err == nil {
+			t.Errorf("Parse(%q) = %+v, want an error", input, q.Terms)
+		}
+	}
+}
+
+func TestQueryString(t *testing.T) {
+	for _, input := range []string{
+		`status:open priority:>=high -tag:spam "printer jam"`,
+		`cf.team:"Level 2" created:<7d`,
+	} {
+		q, err := query.Parse(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := q.String(); got != input {
+			t.Errorf("String() = %q, want %q", got, input)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, input := range []string{
+		"color:red",
+		"cf.:x",
+		"priority:critical",
+		"status:>open",
+		"assignee:someone",
+		"assignee:>me",
+		"created:yesterday",
+		"id:abc",
+		"category:misc",
+		"company:acme",
+	} {
+		if _, err := query.Filter(input, query.Env{}); err == nil {
+			t.Errorf("Filter(%q) compiled, want an error", input)
+		}
+	}
+}
+
+// TestCompile runs compiled queries against a SQLite database holding three tickets:
+//
+//	low:    Low priority, assigned to me, created a day ago, with team, size and owner fields
+//	high:   High priority, unassigned, created ten days ago, without fields
+//	urgent: Urgent priority, assigned to another agent, created three hours ago, without fields
+func TestCompile(t *testing.T) {
+	db := newTestDB(t)
+	ctx := models.WithTenant(context.Background(), 1)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	me := newUser(t, db, ctx, "Agent")
+	other := newUser(t, db, ctx, "Agent")
+	customer := newUser(t, db, ctx, "Customer")
+
+	low := newTicket(t, db, ctx, "Printer jam", "Low", customer, me, now.Add(-24*time.Hour))
+	high := newTicket(t, db, ctx, "Toner empty", "High", customer, nil, now.Add(-10*24*time.Hour))
+	urgent := newTicket(t, db, ctx, "Server down", "Urgent", customer, other, now.Add(-3*time.Hour))
+
+	for _, field := range []*models.CustomField{
+		{Key: "team", Name: "Team", Type: models.FieldTypeText},
+		{Key: "size", Name: "Size", Type: models.FieldTypeNumber},
+		{Key: "owner", Name: "Owner", Type: models.FieldTypeUser},
+	} {
+		if err := models.CreateCustomField(db, ctx, field); err != nil {
+			t.Fatalf("create field %s: %v", field.Key, err)
+		}
+	}
+	values := map[string]json.RawMessage{
+		"team":  json.RawMessage(`"Billing"`),
+		"size":  json.RawMessage(`5`),
+		"owner": json.RawMessage(fmt.Sprint(me.ID)),
+	}
+	changes, err := models.ValidateTicketFields(db, ctx, low, values)
+	if err != nil {
+		t.Fatalf("validate fields: %v", err)
+	}
+	if err := models.SaveTicketFields(db, ctx, low.ID, changes); err != nil {
+		t.Fatalf("save fields: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  []int64
+	}{
+		{"", []int64{low.ID, high.ID, urgent.ID}},
+		{"printer", []int64{low.ID}},
+		{"-printer", []int64{high.ID, urgent.ID}},
+		{"status:open", []int64{low.ID, high.ID, urgent.ID}},
+		{"status:closed", nil},
+		{fmt.Sprintf("id:>%d", low.ID), []int64{high.ID, urgent.ID}},
+
+		{"priority:high", []int64{high.ID}},
+		{"priority:>=high", []int64{high.ID, urgent.ID}},
+		{"priority:>high", []int64{urgent.ID}},
+		{"priority:<high", []int64{low.ID}},
+		{"priority:<=medium", []int64{low.ID}},
+		{"priority:<low", nil},
+		{"priority:low,urgent", []int64{low.ID, urgent.ID}},
+		{"-priority:>=high", []int64{low.ID}},
+
+		{"assignee:me", []int64{low.ID}},
+		{"-assignee:me", []int64{high.ID, urgent.ID}},
+		{"assignee:none", []int64{high.ID}},
+		{"-assignee:none", []int64{low.ID, urgent.ID}},
+		{fmt.Sprintf("assignee:%d", other.ID), []int64{urgent.ID}},
+		{fmt.Sprintf("requester:%d", customer.ID), []int64{low.ID, high.ID, urgent.ID}},
+
+		{"created:>2d", []int64{low.ID, urgent.ID}},
+		{"created:7d", []int64{low.ID, urgent.ID}},
+		{"created:<2d", []int64{high.ID}},
+		{"created:>6h", []int64{urgent.ID}},
+		{"-created:>2d", []int64{high.ID}},
+		{"closed:>7d", nil},
+		{"-closed:>7d", []int64{low.ID, high.ID, urgent.ID}},
+
+		{"cf.team:billing", []int64{low.ID}},
+		{`cf.team:"Billing"`, []int64{low.ID}},
+		{"-cf.team:billing", []int64{high.ID, urgent.ID}},
+		{"cf.team:none", []int64{high.ID, urgent.ID}},
+		{"-cf.team:none", []int64{low.ID}},
+		{"cf.size:>3", []int64{low.ID}},
+		{"cf.size:<3", nil},
+		{"cf.owner:me", []int64{low.ID}},
+		{fmt.Sprintf("cf.owner:%d", other.ID), nil},
+		{"cf.missing:x", nil},
+
+		{"priority:>=high assignee:none", []int64{high.ID}},
+	}
+	for _, tt := range tests {
+		filter, err := query.Filter(tt.query, query.Env{UserID: me.ID, Now: now})
+		if err != nil {
+			t.Errorf("Filter(%q): %v", tt.query, err)
+			continue
+		}
+		var ids []int64
+		err = db.NewSelect().
+			Model((*models.Ticket)(nil)).
+			Column("ticket.id").
+			Apply(filter).
+			Order("ticket.id ASC").
+			Scan(ctx, &ids)
+		if err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("%q matched tickets %v, want %v", tt.query, ids, tt.want)
+		}
+	}
+}
+
+// newTestDB returns a migrated SQLite database private to the test.
+func newTestDB(t *testing.T) *bun.DB {
+	t.Helper()
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "goat.db"))
+	db := config.ConnectDB()
+	t.Cleanup(func() { db.Close() })
+	if _, err := migrate.Up(context.Background(), db, 0); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func newUser(t *testing.T, db *bun.DB, ctx context.Context, role string) *models.User {
+	t.Helper()
+	user := &models.User{Name: role, Role: role, PasswordHash: "x"}
+	user.Email = fmt.Sprintf("%s-%d@example.com", role, time.Now().UnixNano())
+	if err := models.CreateUser(db, ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// newTicket creates an open ticket created at the given time, assigned to assignee unless nil.
+func newTicket(t *testing.T, db *bun.DB, ctx context.Context, title, priority string, requester, assignee *models.User, created time.Time) *models.Ticket {
+	t.Helper()
+	ticket := &models.Ticket{Title: title, Status: "Open", Priority: priority, RequesterID: requester.ID, CreatedAt: created}
+	if assignee != nil {
+		ticket.AssigneeID = sql.NullInt64{Int64: assignee.ID, Valid: true}
+	}
+	if err := models.CreateTicket(db, ctx, ticket); err != nil {
+		t.Fatalf("create ticket: %v", err)
+	}
+	return ticket
+}
@@ -0,0 +1,120 @@
+package search
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"goat/services/models"
+)
+
+const defaultLimit = 25
+
+// MySQLSearcher runs ticket searches using MySQL/MariaDB FULLTEXT indexes.
+type MySQLSearcher struct {
+	db *bun.DB
+}
+
+func NewMySQLSearcher(db *bun.DB) *MySQLSearcher {
+	return &MySQLSearcher{db: db}
+}
+
+type ticketHit struct {
+	models.Ticket `bun:",extend"`
+	Relevance     float64 `bun:"relevance"`
+}
+
+// SearchTickets ranks tickets by the combined relevance of their title, description and comments.
+func (s *MySQLSearcher) SearchTickets(ctx context.Context, q Query) ([]Result, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+
+	commentScore := s.db.NewSelect().
+		TableExpr("comments AS c").
+		ColumnExpr("MAX(MATCH(c.body) AGAINST (? IN NATURAL LANGUAGE MODE))", q.Text).
+		Where("c.ticket_id = ticket.id").
+		Where("c.deleted_at IS NULL")
+	if !q.IncludeInternal {
+		commentScore = commentScore.Where("c.is_internal = FALSE")
+	}
+
+	var hits []ticketHit
+	err := s.db.NewSelect().
+		Model(&hits).
+		ColumnExpr("ticket.*").
+		ColumnExpr("MATCH(ticket.title, ticket.description) AGAINST (? IN NATURAL LANGUAGE MODE) + COALESCE((?), 0) AS relevance", q.Text, commentScore).
+		Apply(q.filters).
+		Having("relevance > 0").
+		OrderExpr("relevance DESC").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(hits))
+	if len(hits) == 0 {
+		return results, nil
+	}
+
+	ticketIDs := make([]int64, len(hits))
+	for i, hit := range hits {
+		ticketIDs[i] = hit.ID
+	}
+
+	var comments []models.Comment
+	commentQuery := s.db.NewSelect().
+		Model(&comments).
+		Where("ticket_id IN (?)", bun.In(ticketIDs)).
+		Where("MATCH(body) AGAINST (? IN NATURAL LANGUAGE MODE) > 0", q.Text).
+		Order("created_at DESC")
+	if !q.IncludeInternal {
+		commentQuery = commentQuery.Where("is_internal = FALSE")
+	}
+	if err := commentQuery.Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	commentsByTicket := make(map[int64][]models.Comment)
+	for _, comment := range comments {
+		commentsByTicket[comment.TicketID] = append(commentsByTicket[comment.TicketID], comment)
+	}
+
+	terms := Terms(q.Text)
+	for _, hit := range hits {
+		results = append(results, Result{
+			Ticket:     hit.Ticket,
+			Relevance:  hit.Relevance,
+			Highlights: TicketHighlights(&hit.Ticket, commentsByTicket[hit.ID], terms),
+		})
+	}
+	return results, nil
+}
+
+// filters applies the structured part of a query to a select on the tickets table.
+func (q Query) filters(sel *bun.SelectQuery) *bun.SelectQuery {
+	if q.Status != "" {
+		sel = sel.Where("ticket.status = ?", q.Status)
+	}
+	if q.Priority != "" {
+		sel = sel.Where("ticket.priority = ?", q.Priority)
+	}
+	if q.AssigneeID != 0 {
+		sel = sel.Where("ticket.assignee_id = ?", q.AssigneeID)
+	}
+	if q.RequesterID != 0 {
+		sel = sel.Where("ticket.requester_id = ?", q.RequesterID)
+	}
+	if !q.CreatedAfter.IsZero() {
+		sel = sel.Where("ticket.created_at >= ?", q.CreatedAfter)
+	}
+	if !q.CreatedBefore.IsZero() {
+		sel = sel.Where("ticket.created_at < ?", q.CreatedBefore)
+	}
+	if q.Visible != nil {
+		sel = sel.Apply(q.Visible)
+	}
+	return sel
+}
@@ -0,0 +1,110 @@
+package search
+
+import (
+	"context"
+	"strings"
+
+	"github.com/uptrace/bun"
+
+	"goat/services/models"
+)
+
+// PostgresSearcher runs ticket searches using PostgreSQL text search and the GIN indexes created by
+// the initial migration.
+type PostgresSearcher struct {
+	db *bun.DB
+}
+
+func NewPostgresSearcher(db *bun.DB) *PostgresSearcher {
+	return &PostgresSearcher{db: db}
+}
+
+// The expressions must match the indexes for them to be used.
+const (
+	ticketDocument  = "to_tsvector('english', COALESCE(ticket.title, '') || ' ' || COALESCE(ticket.description, ''))"
+	commentDocument = "to_tsvector('english', c.body)"
+)
+
+// SearchTickets ranks tickets by the combined relevance of their title, description and comments.
+// Like MySQL's natural language mode, a ticket matches when it contains any of the search terms.
+func (s *PostgresSearcher) SearchTickets(ctx context.Context, q Query) ([]Result, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+
+	results := []Result{}
+	terms := Terms(q.Text)
+	if len(terms) == 0 {
+		return results, nil
+	}
+	// Terms only hold letters and digits, so they need no escaping inside a tsquery.
+	tsquery := bun.SafeQuery("to_tsquery('english', ?)", strings.Join(terms, " | "))
+
+	commentScore := s.commentQuery(q).
+		ColumnExpr("MAX(ts_rank("+commentDocument+", ?))", tsquery).
+		Where("c.ticket_id = ticket.id").
+		Where(commentDocument+" @@ ?", tsquery)
+
+	var hits []ticketHit
+	err := s.db.NewSelect().
+		Model(&hits).
+		ColumnExpr("ticket.*").
+		ColumnExpr("ts_rank("+ticketDocument+", ?) + COALESCE((?), 0) AS relevance", tsquery, commentScore).
+		Apply(q.filters).
+		WhereGroup(" AND ", func(sel *bun.SelectQuery) *bun.SelectQuery {
+			return sel.
+				Where(ticketDocument+" @@ ?", tsquery).
+				WhereOr("EXISTS (?)", s.commentQuery(q).
+					ColumnExpr("1").
+					Where("c.ticket_id = ticket.id").
+					Where(commentDocument+" @@ ?", tsquery))
+		}).
+		OrderExpr("relevance DESC").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil || len(hits) == 0 {
+		return results, err
+	}
+
+	ticketIDs := make([]int64, len(hits))
+	for i, hit := range hits {
+		ticketIDs[i] = hit.ID
+	}
+
+	var comments []models.Comment
+	err = s.commentQuery(q).
+		ColumnExpr("c.*").
+		Where("c.ticket_id IN (?)", bun.In(ticketIDs)).
+		Where(commentDocument+" @@ ?", tsquery).
+		Order("c.created_at DESC").
+		Scan(ctx, &comments)
+	if err != nil {
+		return nil, err
+	}
+
+	commentsByTicket := make(map[int64][]models.Comment)
+	for _, comment := range comments {
+		commentsByTicket[comment.TicketID] = append(commentsByTicket[comment.TicketID], comment)
+	}
+
+	for _, hit := range hits {
+		results = append(results, Result{
+			Ticket:     hit.Ticket,
+			Relevance:  hit.Relevance,
+			Highlights: TicketHighlights(&hit.Ticket, commentsByTicket[hit.ID], terms),
+		})
+	}
+	return results, nil
+}
+
+// commentQuery selects the comments a search may match.
+func (s *PostgresSearcher) commentQuery(q Query) *bun.SelectQuery {
+	sel := s.db.NewSelect().
+		TableExpr("comments AS c").
+		Where("c.deleted_at IS NULL")
+	if !q.IncludeInternal {
+		sel = sel.Where("c.is_internal = FALSE")
+	}
+	return sel
+}
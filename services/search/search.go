@@ -0,0 +1,174 @@
+package search
+
+import (
+	"context"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+
+	"goat/services/models"
+)
+
+// snippetLength is the number of characters kept around the first match in a snippet.
+const snippetLength = 160
+
+// Query describes a full-text ticket search combined with structured filters.
+// Zero values mean "no filter".
+type Query struct {
+	Text            string
+	Status          string
+	Priority        string
+	AssigneeID      int64
+	RequesterID     int64
+	CreatedAfter    time.Time
+	CreatedBefore   time.Time
+	IncludeInternal bool               // Whether internal comments are searched and shown in snippets
+	Visible         models.QueryFilter // Limits the search to the tickets the caller may see, nil for all
+	Limit           int
+}
+
+// Highlight is a snippet of a matching field with the search terms marked up.
+type Highlight struct {
+	Field     string `json:"Field"` // Title, Description or Comment
+	CommentID int64  `json:"CommentID,omitempty"`
+	Snippet   string `json:"Snippet"` // HTML-escaped text with matches wrapped in <mark>
+}
+
+// Result is a single ticket matched by a search, ordered by relevance.
+type Result struct {
+	Ticket     models.Ticket `json:"Ticket"`
+	Relevance  float64       `json:"Relevance"`
+	Highlights []Highlight   `json:"Highlights"`
+}
+
+// Searcher is implemented by every storage backend that can run ticket searches.
+type Searcher interface {
+	SearchTickets(ctx context.Context, q Query) ([]Result, error)
+}
+
+// NewSearcher returns the searcher for the database's dialect.
+func NewSearcher(db *bun.DB) Searcher {
+	switch db.Dialect().Name() {
+	case dialect.PG:
+		return NewPostgresSearcher(db)
+	case dialect.SQLite:
+		return NewSQLiteSearcher(db)
+	default:
+		return NewMySQLSearcher(db)
+	}
+}
+
+// Terms splits search text into the lowercase words used for highlighting.
+func Terms(text string) []string {
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if len([]rune(word)) > 1 {
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// HighlightText returns an HTML-escaped fragment of text around the first matching term,
+// with every term occurrence inside the fragment wrapped in <mark> tags.
+// It returns an empty string when none of the terms occur in text.
+func HighlightText(text string, terms []string) string {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// Find non-overlapping matches as [start, end) rune offsets.
+	var matches [][2]int
+	for i := 0; i < len(lower); {
+		matched := 0
+		for _, term := range terms {
+			t := []rune(term)
+			if len(t) > matched && hasPrefix(lower[i:], t) {
+				matched = len(t)
+			}
+		}
+		if matched > 0 {
+			matches = append(matches, [2]int{i, i + matched})
+			i += matched
+			continue
+		}
+		i++
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+
+	start := matches[0][0] - snippetLength/4
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, m := range matches {
+		if m[0] >= end {
+			break
+		}
+		if m[0] < start {
+			continue
+		}
+		matchEnd := m[1]
+		if matchEnd > end {
+			matchEnd = end
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:m[0]])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(runes[m[0]:matchEnd])))
+		b.WriteString("</mark>")
+		pos = matchEnd
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:end])))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func hasPrefix(s, prefix []rune) bool {
+	if len(prefix) > len(s) {
+		return false
+	}
+	for i := range prefix {
+		if s[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TicketHighlights builds the highlights for a ticket and the comments the searcher may show.
+func TicketHighlights(ticket *models.Ticket, comments []models.Comment, terms []string) []Highlight {
+	highlights := []Highlight{}
+	if snippet := HighlightText(ticket.Title, terms); snippet != "" {
+		highlights = append(highlights, Highlight{Field: "Title", Snippet: snippet})
+	}
+	if snippet := HighlightText(ticket.Description, terms); snippet != "" {
+		highlights = append(highlights, Highlight{Field: "Description", Snippet: snippet})
+	}
+	for _, comment := range comments {
+		if snippet := HighlightText(comment.Body, terms); snippet != "" {
+			highlights = append(highlights, Highlight{Field: "Comment", CommentID: comment.ID, Snippet: snippet})
+		}
+	}
+	return highlights
+}
@@ -0,0 +1,65 @@
+package search_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"goat/services/search"
+)
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"printer", []string{"printer"}},
+		{"Printer-jam, ERROR 42!", []string{"printer", "jam", "error", "42"}},
+		{"a b cd", []string{"cd"}},
+		{`"quoted" <b>tags</b>`, []string{"quoted", "tags"}},
+		{"Größe ändern", []string{"größe", "ändern"}},
+		{"日本 x", []string{"日本"}},
+	}
+	for _, tt := range tests {
+		if got := search.Terms(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("Terms(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestHighlightText(t *testing.T) {
+	tests := []struct {
+		text  string
+		terms []string
+		want  string
+	}{
+		{"Printer jam", []string{"toner"}, ""},
+		{"", []string{"printer"}, ""},
+		{"Printer jam", []string{"printer"}, "<mark>Printer</mark> jam"},
+		{"Printer jam", []string{"printer", "jam"}, "<mark>Printer</mark> <mark>jam</mark>"},
+		{"printers print", []string{"print", "printer"}, "<mark>printer</mark>s <mark>print</mark>"},
+		{"Tom & Jerry", []string{"jerry"}, "Tom &amp; <mark>Jerry</mark>"},
+		{`<script>alert("jam")</script>`, []string{"jam"}, "&lt;script&gt;alert(&#34;<mark>jam</mark>&#34;)&lt;/script&gt;"},
+		{"Größe ändern", []string{"größe"}, "<mark>Größe</mark> ändern"},
+	}
+	for _, tt := range tests {
+		if got := search.HighlightText(tt.text, tt.terms); got != tt.want {
+			t.Errorf("HighlightText(%q, %q) = %q, want %q", tt.text, tt.terms, got, tt.want)
+		}
+	}
+}
+
+func TestHighlightTextSnippet(t *testing.T) {
+	text := strings.Repeat("ä <", 100) + "needle" + strings.Repeat("> ö", 100)
+	got := search.HighlightText(text, []string{"needle"})
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("snippet of a long text is not elided at both ends: %q", got)
+	}
+	if !strings.Contains(got, "<mark>needle</mark>") {
+		t.Errorf("snippet lacks the match: %q", got)
+	}
+	if strings.Contains(got, "<ä") || strings.Contains(got, ">ö") || strings.ContainsRune(got, '�') {
+		t.Errorf("snippet is not escaped or splits a character: %q", got)
+	}
+}
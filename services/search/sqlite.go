@@ -0,0 +1,133 @@
+package search
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun"
+
+	"goat/services/models"
+	"goat/services/query"
+)
+
+// sqliteCandidates caps how many matching tickets are ranked per search.
+const sqliteCandidates = 500
+
+// SQLiteSearcher runs ticket searches on SQLite, which has no FULLTEXT indexes. Tickets match when
+// any search term occurs in their title, description or comments, and rank by how often the terms
+// occur. Only the most recently updated matches are ranked, which is fine at single-node scale.
+type SQLiteSearcher struct {
+	db *bun.DB
+}
+
+func NewSQLiteSearcher(db *bun.DB) *SQLiteSearcher {
+	return &SQLiteSearcher{db: db}
+}
+
+// SearchTickets ranks tickets by the number of search term occurrences in their title, description
+// and comments.
+func (s *SQLiteSearcher) SearchTickets(ctx context.Context, q Query) ([]Result, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+
+	results := []Result{}
+	terms := Terms(q.Text)
+	if len(terms) == 0 {
+		return results, nil
+	}
+
+	var tickets []models.Ticket
+	err := s.db.NewSelect().
+		Model(&tickets).
+		Apply(q.filters).
+		WhereGroup(" AND ", func(sel *bun.SelectQuery) *bun.SelectQuery {
+			for _, term := range terms {
+				pattern := likePattern(term)
+				comments := s.commentQuery(q).
+					Where("c.ticket_id = ticket.id").
+					Where("LOWER(c.body) LIKE ? ESCAPE '!'", pattern)
+				sel = sel.
+					WhereOr("LOWER(ticket.title) LIKE ? ESCAPE '!'", pattern).
+					WhereOr("LOWER(ticket.description) LIKE ? ESCAPE '!'", pattern).
+					WhereOr("EXISTS (?)", comments.ColumnExpr("1"))
+			}
+			return sel
+		}).
+		Order("ticket.updated_at DESC").
+		Limit(sqliteCandidates).
+		Scan(ctx)
+	if err != nil || len(tickets) == 0 {
+		return results, err
+	}
+
+	ticketIDs := make([]int64, len(tickets))
+	for i, ticket := range tickets {
+		ticketIDs[i] = ticket.ID
+	}
+
+	var comments []models.Comment
+	err = s.commentQuery(q).
+		ColumnExpr("c.*").
+		Where("c.ticket_id IN (?)", bun.In(ticketIDs)).
+		WhereGroup(" AND ", func(sel *bun.SelectQuery) *bun.SelectQuery {
+			for _, term := range terms {
+				sel = sel.WhereOr("LOWER(c.body) LIKE ? ESCAPE '!'", likePattern(term))
+			}
+			return sel
+		}).
+		Order("c.created_at DESC").
+		Scan(ctx, &comments)
+	if err != nil {
+		return nil, err
+	}
+
+	commentsByTicket := make(map[int64][]models.Comment)
+	for _, comment := range comments {
+		commentsByTicket[comment.TicketID] = append(commentsByTicket[comment.TicketID], comment)
+	}
+
+	for _, ticket := range tickets {
+		relevance := countTerms(ticket.Title, terms) + countTerms(ticket.Description, terms)
+		for _, comment := range commentsByTicket[ticket.ID] {
+			relevance += countTerms(comment.Body, terms)
+		}
+		results = append(results, Result{
+			Ticket:     ticket,
+			Relevance:  float64(relevance),
+			Highlights: TicketHighlights(&ticket, commentsByTicket[ticket.ID], terms),
+		})
+	}
+	slices.SortStableFunc(results, func(a, b Result) int {
+		return cmp.Compare(b.Relevance, a.Relevance)
+	})
+	return results[:min(limit, len(results))], nil
+}
+
+// commentQuery selects the comments a search may match.
+func (s *SQLiteSearcher) commentQuery(q Query) *bun.SelectQuery {
+	sel := s.db.NewSelect().
+		TableExpr("comments AS c").
+		Where("c.deleted_at IS NULL")
+	if !q.IncludeInternal {
+		sel = sel.Where("c.is_internal = FALSE")
+	}
+	return sel
+}
+
+func likePattern(term string) string {
+	return "%" + query.EscapeLike(term) + "%"
+}
+
+// countTerms counts the occurrences of the terms in text, ignoring case.
+func countTerms(text string, terms []string) int {
+	text = strings.ToLower(text)
+	n := 0
+	for _, term := range terms {
+		n += strings.Count(text, term)
+	}
+	return n
+}
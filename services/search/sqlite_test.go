@@ -0,0 +1,154 @@
+package search_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/uptrace/bun"
+
+	"goat/services/config"
+	"goat/services/migrate"
+	"goat/services/models"
+	"goat/services/search"
+)
+
+func TestSQLiteSearcher(t *testing.T) {
+	db := newTestDB(t)
+	ctx := models.WithTenant(context.Background(), 1)
+	customer := newUser(t, db, ctx, "Customer", 1)
+	stranger := newUser(t, db, ctx, "Customer", 2)
+	agent := newUser(t, db, ctx, "Agent", 3)
+
+	// Relevance counts term occurrences: three in the title and description, four in a comment,
+	// and one in the title alone.
+	described := newTicket(t, db, ctx, customer, "Printer jam", "The printer jams on every printer job.")
+	commented := newTicket(t, db, ctx, customer, "Scanner", "")
+	newComment(t, db, ctx, commented, agent, "printer printer printer printer", false)
+	titled := newTicket(t, db, ctx, customer, "Printer toner", "")
+
+	others := newTicket(t, db, ctx, stranger, "Printer on the second floor", "")
+	deleted := newTicket(t, db, ctx, customer, "Network", "")
+	comment := newComment(t, db, ctx, deleted, customer, "The printer is down too.", false)
+	if err := models.DeleteComment(db, ctx, comment.ID); err != nil {
+		t.Fatal(err)
+	}
+	internal := newTicket(t, db, ctx, customer, "Phones", "")
+	newComment(t, db, ctx, internal, agent, "Probably the printer again.", true)
+
+	searcher := search.NewSQLiteSearcher(db)
+	tests := []struct {
+		name    string
+		query   search.Query
+		want    []int64
+		ordered bool // Whether want is in rank order rather than a set
+	}{
+		{
+			name:    "ranking",
+			query:   search.Query{Text: "printer", Visible: models.CustomerTickets(customer.ID, 0)},
+			want:    []int64{commented.ID, described.ID, titled.ID},
+			ordered: true,
+		},
+		{
+			name:  "other customer",
+			query: search.Query{Text: "printer", Visible: models.CustomerTickets(stranger.ID, 0)},
+			want:  []int64{others.ID},
+		},
+		{
+			name:  "agent",
+			query: search.Query{Text: "printer", IncludeInternal: true},
+			want:  []int64{commented.ID, described.ID, titled.ID, others.ID, internal.ID},
+		},
+		{
+			name:  "filters",
+			query: search.Query{Text: "printer", Priority: "Medium", RequesterID: customer.ID, IncludeInternal: true},
+			want:  []int64{commented.ID, described.ID, titled.ID, internal.ID},
+		},
+		{
+			name:    "limit",
+			query:   search.Query{Text: "printer", Limit: 2},
+			want:    []int64{commented.ID, described.ID},
+			ordered: true,
+		},
+		{
+			name:  "internal comment",
+			query: search.Query{Text: "again"},
+		},
+		{
+			name:  "deleted comment",
+			query: search.Query{Text: "down", IncludeInternal: true},
+		},
+		{
+			name:  "no terms",
+			query: search.Query{Text: "a"},
+		},
+	}
+	for _, tt := range tests {
+		results, err := searcher.SearchTickets(ctx, tt.query)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var ids []int64
+		for _, result := range results {
+			ids = append(ids, result.Ticket.ID)
+		}
+		if !tt.ordered {
+			slices.Sort(ids)
+			slices.Sort(tt.want)
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("%s: found tickets %v, want %v", tt.name, ids, tt.want)
+		}
+	}
+
+	results, err := searcher.SearchTickets(ctx, search.Query{Text: "printer", Visible: models.CustomerTickets(customer.ID, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := results[0].Highlights; len(got) != 1 || got[0].Field != "Comment" || got[0].Snippet != "<mark>printer</mark> <mark>printer</mark> <mark>printer</mark> <mark>printer</mark>" {
+		t.Errorf("highlights = %+v, want the comment", got)
+	}
+}
+
+// newTestDB returns a migrated SQLite database private to the test.
+func newTestDB(t *testing.T) *bun.DB {
+	t.Helper()
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "goat.db"))
+	db := config.ConnectDB()
+	t.Cleanup(func() { db.Close() })
+	if _, err := migrate.Up(context.Background(), db, 0); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func newUser(t *testing.T, db *bun.DB, ctx context.Context, role string, n int) *models.User {
+	t.Helper()
+	user := &models.User{Name: role, Role: role, PasswordHash: "x", Email: fmt.Sprintf("%s-%d@example.com", role, n)}
+	if err := models.CreateUser(db, ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func newTicket(t *testing.T, db *bun.DB, ctx context.Context, requester *models.User, title, description string) *models.Ticket {
+	t.Helper()
+	ticket := &models.Ticket{Title: title, Description: description, Status: "Open", Priority: "Medium", RequesterID: requester.ID}
+	if err := models.CreateTicket(db, ctx, ticket); err != nil {
+		t.Fatalf("create ticket: %v", err)
+	}
+	return ticket
+}
+
+func newComment(t *testing.T, db *bun.DB, ctx context.Context, ticket *models.Ticket, author *models.User, body string, internal bool) *models.Comment {
+	t.Helper()
+	comment := &models.Comment{TicketID: ticket.ID, AuthorID: author.ID, Body: body, IsInternal: internal}
+	if err := models.CreateComment(db, ctx, comment); err != nil {
+		t.Fatalf("create comment: %v", err)
+	}
+	return comment
+}